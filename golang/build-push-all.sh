@@ -1,6 +1,7 @@
 # Build
 docker buildx build -f Dockerfile -t ghcr.io/open-digital-twin/ktwin-device-service:0.1 --build-arg SERVICE_NAME=device-service .
 docker buildx build -f Dockerfile -t ghcr.io/open-digital-twin/ktwin-neighborhood-service:0.1 --build-arg SERVICE_NAME=neighborhood-service .
+docker buildx build -f Dockerfile -t ghcr.io/open-digital-twin/ktwin-city-service:0.1 --build-arg SERVICE_NAME=city-service .
 docker buildx build -f Dockerfile -t ghcr.io/open-digital-twin/ktwin-parking-service:0.1 --build-arg SERVICE_NAME=parking-service .
 docker buildx build -f Dockerfile -t ghcr.io/open-digital-twin/ktwin-parking-spot-service:0.1 --build-arg SERVICE_NAME=parking-spot-service .
 docker buildx build -f Dockerfile -t ghcr.io/open-digital-twin/ktwin-pole-service:0.1 --build-arg SERVICE_NAME=pole-service .
@@ -9,10 +10,13 @@ docker buildx build -f Dockerfile -t ghcr.io/open-digital-twin/ktwin-crowd-flow-
 docker buildx build -f Dockerfile -t ghcr.io/open-digital-twin/ktwin-traffic-flow-observed-service:0.1 --build-arg SERVICE_NAME=traffic-flow-observed-service .
 docker buildx build -f Dockerfile -t ghcr.io/open-digital-twin/ktwin-weather-observed-service:0.1 --build-arg SERVICE_NAME=weather-observed-service .
 docker buildx build -f Dockerfile -t ghcr.io/open-digital-twin/ktwin-streetlight-service:0.1 --build-arg SERVICE_NAME=streetlight-service .
+docker buildx build -f Dockerfile -t ghcr.io/open-digital-twin/ktwin-city:0.1 --build-arg SERVICE_NAME=ktwin-city .
+docker buildx build -f Dockerfile -t ghcr.io/open-digital-twin/ktwin-watchdog:0.1 --build-arg SERVICE_NAME=ktwin-watchdog .
 
 # # Push
 docker push ghcr.io/open-digital-twin/ktwin-device-service:0.1
 docker push ghcr.io/open-digital-twin/ktwin-neighborhood-service:0.1
+docker push ghcr.io/open-digital-twin/ktwin-city-service:0.1
 docker push ghcr.io/open-digital-twin/ktwin-parking-service:0.1
 docker push ghcr.io/open-digital-twin/ktwin-parking-spot-service:0.1
 docker push ghcr.io/open-digital-twin/ktwin-pole-service:0.1
@@ -21,3 +25,5 @@ docker push ghcr.io/open-digital-twin/ktwin-crowd-flow-observed-service:0.1
 docker push ghcr.io/open-digital-twin/ktwin-traffic-flow-observed-service:0.1
 docker push ghcr.io/open-digital-twin/ktwin-weather-observed-service:0.1
 docker push ghcr.io/open-digital-twin/ktwin-streetlight-service:0.1
+docker push ghcr.io/open-digital-twin/ktwin-city:0.1
+docker push ghcr.io/open-digital-twin/ktwin-watchdog:0.1
\ No newline at end of file
@@ -0,0 +1,107 @@
+package model
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/aqi"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestAirQualitySuite(t *testing.T) {
+	suite.Run(t, new(AirQualitySuite))
+}
+
+type AirQualitySuite struct {
+	suite.Suite
+}
+
+func appendDouble(b []byte, number protowire.Number, value float64) []byte {
+	b = protowire.AppendTag(b, number, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(value))
+}
+
+// The devices encode the attributes of the first version of the model, new attributes must not
+// change their field numbers
+func (s *AirQualitySuite) Test_DecodeProtobufOfDevices() {
+	var payload []byte
+	payload = appendDouble(payload, 1, 42)
+	payload = appendDouble(payload, 2, 0.9)
+	payload = protowire.AppendTag(payload, 4, protowire.BytesType)
+	payload = protowire.AppendString(payload, "outdoor")
+	payload = appendDouble(payload, 9, 12.5)
+	payload = appendDouble(payload, 22, 21)
+	payload = protowire.AppendTag(payload, 29, protowire.BytesType)
+	payload = protowire.AppendString(payload, "GOOD")
+
+	codec, err := ktwin.GetCodec(ktwin.ApplicationProtobuf)
+	s.Require().NoError(err)
+
+	var actual AirQualityEvent
+	s.Require().NoError(codec.Unmarshal(payload, &actual))
+	s.Assert().Equal(AirQualityEvent{
+		AirQualityIndex: 42,
+		Reliability:     0.9,
+		TypeOfLocation:  "outdoor",
+		PM25Density:     12.5,
+		Temperature:     21,
+		O3AqiLevel:      "GOOD",
+	}, actual)
+}
+
+// The field numbers of the model must not change, new attributes get new numbers
+func (s *AirQualitySuite) Test_ProtobufFieldNumbers() {
+	codec, err := ktwin.GetCodec(ktwin.ApplicationProtobuf)
+	s.Require().NoError(err)
+
+	data, err := codec.Marshal(AirQualityEvent{
+		AirQualityIndex:   42,
+		Reliability:       0.9,
+		PM25Density:       12.5,
+		O3AqiLevel:        aqi.Good,
+		NO2Aqi:            20,
+		PM25NowCast:       11,
+		AqiStandard:       "EPA",
+		AqiBand:           "Good",
+		AqiLevel:          aqi.Good,
+		DominantPollutant: aqi.PM25,
+	})
+	s.Require().NoError(err)
+
+	var numbers []protowire.Number
+	for len(data) > 0 {
+		number, wireType, n := protowire.ConsumeTag(data)
+		s.Require().Greater(n, 0)
+		data = data[n:]
+		n = protowire.ConsumeFieldValue(number, wireType, data)
+		s.Require().Greater(n, 0)
+		data = data[n:]
+		numbers = append(numbers, number)
+	}
+	s.Assert().Equal([]protowire.Number{1, 2, 9, 29, 36, 42, 44, 45, 46, 47}, numbers)
+}
+
+// The stored state keeps the hourly averages of the rolling windows, maps by pollutant
+func (s *AirQualitySuite) Test_ProtobufRoundTrip() {
+	codec, err := ktwin.GetCodec(ktwin.ApplicationProtobuf)
+	s.Require().NoError(err)
+
+	hour := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	expected := AirQualityEvent{
+		PM25Density: 12.5,
+		AqiLevel:    aqi.Moderate,
+		HourlyAverages: []aqi.HourlyAverage{
+			{Hour: hour, Concentrations: map[aqi.Pollutant]float64{aqi.PM25: 12.5, aqi.CO: 0.4}, Counts: map[aqi.Pollutant]int{aqi.PM25: 3, aqi.CO: 1}},
+			{Hour: hour.Add(time.Hour), Concentrations: map[aqi.Pollutant]float64{aqi.PM25: 0}, Counts: map[aqi.Pollutant]int{aqi.PM25: 1}},
+		},
+	}
+
+	data, err := codec.Marshal(expected)
+	s.Require().NoError(err)
+	var actual AirQualityEvent
+	s.Require().NoError(codec.Unmarshal(data, &actual))
+	s.Assert().Equal(expected, actual)
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/aqi"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kpolicy"
+)
+
+// Config of the air quality observed service, the services.air-quality section of the config.
+// The standard can be chosen per city or neighborhood with the policy overrides.
+type Config struct {
+	// AQI standard: epa, caqi, daqi or naqi
+	Standard string `yaml:"standard" env:"KTWIN_AIR_QUALITY_STANDARD"`
+	// Concentration of the PM AQI: nowcast, falling back to the 24h average without enough recent readings, or 24h
+	PMAveraging string `yaml:"pmAveraging" env:"KTWIN_AIR_QUALITY_PM_AVERAGING"`
+	// Store of the rolling windows of the instances: eventstore, kept with the stored twin events, or memory
+	WindowStore string `yaml:"windowStore" env:"KTWIN_AIR_QUALITY_WINDOW_STORE"`
+}
+
+const (
+	PM_AVERAGING_NOWCAST = "nowcast"
+	PM_AVERAGING_24H     = "24h"
+
+	WINDOW_STORE_EVENT_STORE = "eventstore"
+	WINDOW_STORE_MEMORY      = "memory"
+)
+
+var serviceConfig = Config{
+	Standard:    aqi.EPA.Name(),
+	PMAveraging: PM_AVERAGING_NOWCAST,
+	WindowStore: WINDOW_STORE_EVENT_STORE,
+}
+
+func (c *Config) Validate() error {
+	if _, err := aqi.GetStandard(c.Standard); err != nil {
+		return err
+	}
+	if c.PMAveraging != PM_AVERAGING_NOWCAST && c.PMAveraging != PM_AVERAGING_24H {
+		return fmt.Errorf("invalid pmAveraging %q, must be %s or %s", c.PMAveraging, PM_AVERAGING_NOWCAST, PM_AVERAGING_24H)
+	}
+	if c.WindowStore != WINDOW_STORE_EVENT_STORE && c.WindowStore != WINDOW_STORE_MEMORY {
+		return fmt.Errorf("invalid windowStore %q, must be %s or %s", c.WindowStore, WINDOW_STORE_EVENT_STORE, WINDOW_STORE_MEMORY)
+	}
+	return nil
+}
+
+func LoadConfig() error {
+	if err := config.LoadService("air-quality", &serviceConfig); err != nil {
+		return err
+	}
+	return kpolicy.Register(TWIN_INTERFACE_AIR_QUALITY_OBSERVED, serviceConfig)
+}
+
+// Gets the AQI standard of the twin instance
+func instanceStandard(twinInstance string) aqi.Standard {
+	standard, err := aqi.GetStandard(kpolicy.ResolveOrDefault(TWIN_INTERFACE_AIR_QUALITY_OBSERVED, twinInstance, serviceConfig).Standard)
+	if err != nil {
+		// The config is validated, so the standard is always known
+		return aqi.EPA
+	}
+	return standard
+}
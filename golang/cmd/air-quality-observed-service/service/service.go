@@ -4,10 +4,12 @@ import (
 	"fmt"
 
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/air-quality-observed-service/model"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/clock"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kcommand"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kevent"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/keventstore"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kpolicy"
 	ktwingraph "github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/ktwingraph"
 
 	log "github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
@@ -56,29 +58,48 @@ func handleAirQualityObservedEvent(event *ktwin.TwinEvent) error {
 		return err
 	}
 
-	airQualityObserved.CalcCOAqiLevel()
-	airQualityObserved.CalcPM10AqiLevel()
-	airQualityObserved.CalcPM25AqiLevel()
-	airQualityObserved.CalcSO2AqiLevel()
-	airQualityObserved.CalcO3AqiLevel()
+	// The AQI is calculated with the averaged concentrations of the rolling window of the instance
+	window, err := getWindowStore().Get(event.TwinInterface, event.TwinInstance)
+	if err != nil {
+		return err
+	}
+
+	// The readings are bucketed by the time of the event, the simulator and the replay send hours of
+	// readings within minutes
+	observedAt := event.CloudEvent.Time()
+	if observedAt.IsZero() {
+		observedAt = *clock.Now()
+	}
+	window.Add(observedAt, airQualityObserved.Concentrations())
+	airQualityObserved.SetAverages(window, observedAt)
 
-	event.SetData(airQualityObserved)
+	policy := kpolicy.ResolveOrDefault(TWIN_INTERFACE_AIR_QUALITY_OBSERVED, event.TwinInstance, serviceConfig)
+	concentrations := window.Concentrations(observedAt, policy.PMAveraging == PM_AVERAGING_NOWCAST)
+	err = airQualityObserved.CalcAqi(instanceStandard(event.TwinInstance), concentrations)
+	if err != nil {
+		return err
+	}
+
+	err = event.SetData(airQualityObserved)
+	if err != nil {
+		return err
+	}
 	err = keventstore.UpdateTwinEvent(event)
 
 	if err != nil {
 		return err
 	}
 
-	allLevels := []model.AQICategory{
-		airQualityObserved.COAqiLevel,
-		airQualityObserved.PM10AqiLevel,
-		airQualityObserved.PM25AqiLevel,
-		airQualityObserved.SO2AqiLevel,
-		airQualityObserved.O3AqiLevel,
+	err = getWindowStore().Put(event.TwinInstance, window)
+	if err != nil {
+		return err
 	}
 
-	var updateAirQualityIndexCommand model.UpdateAirQualityIndexCommand
-	updateAirQualityIndexCommand.SetAqiLevel(allLevels)
+	updateAirQualityIndexCommand := model.UpdateAirQualityIndexCommand{
+		AqiLevel:          airQualityObserved.AqiLevel,
+		AirQualityIndex:   int(airQualityObserved.AirQualityIndex),
+		DominantPollutant: airQualityObserved.DominantPollutant,
+	}
 
 	if twinGraph == nil {
 		logger.Error("Twin Graph not loaded", nil)
@@ -3,12 +3,17 @@ package service
 import (
 	"net/http"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/air-quality-observed-service/model"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/aqi"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/clock"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kpolicy"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/ktwingraph"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/uuid"
 	cloudevents "github.com/cloudevents/sdk-go/v2"
 	"github.com/h2non/gock"
@@ -91,6 +96,10 @@ func (s *AirQualityObservedServiceSuite) Test_PoleAirQualityObservedEvent() {
 				return twinEvent
 			},
 			mockExternalService: func() {
+				gock.New(s.eventStoreUrl).
+					Get("/api/v1/twin-events/ngsi-ld-city-airqualityobserved/ngsi-ld-city-airqualityobserved-nb001-p00007/latest").
+					Reply(http.StatusNotFound)
+
 				gock.New(s.brokerUrl).
 					Post("/").
 					MatchHeader("Content-Type", "application/json").
@@ -100,7 +109,7 @@ func (s *AirQualityObservedServiceSuite) Test_PoleAirQualityObservedEvent() {
 					MatchHeader("ce-source", "ngsi-ld-city-airqualityobserved-nb001-p00007").
 					MatchHeader("ce-type", "ktwin.store.ngsi-ld-city-airqualityobserved").
 					MatchHeader("ce-subject", "").
-					BodyString(`{"CODensity":8,"PM10Density":8,"PM25Density":8,"SO2Density":8,"NO2Density":8,"O3Density":8,"COAqiLevel":"MODERATE","PM10AqiLevel":"GOOD","PM25AqiLevel":"GOOD","SO2AqiLevel":"GOOD","O3AqiLevel":"GOOD"}`).
+					BodyString(`{"airQualityIndex":86,"aqiLevel":"MODERATE","aqiStandard":"epa","aqiBand":"Moderate","dominantPollutant":"CO","CODensity":8,"PM10Density":8,"PM25Density":8,"SO2Density":8,"NO2Density":8,"O3Density":8,"COAqiLevel":"MODERATE","PM10AqiLevel":"GOOD","PM25AqiLevel":"GOOD","SO2AqiLevel":"GOOD","O3AqiLevel":"GOOD","NO2AqiLevel":"GOOD","COAqi":86,"PM10Aqi":7,"PM25Aqi":44,"SO2Aqi":11,"O3Aqi":7,"NO2Aqi":8,"O3Density8h":8,"CODensity8h":8,"PM10Density24h":8,"PM25Density24h":8,"hourlyAverages":[{"hour":"2024-01-01T00:00:00Z","concentrations":{"CO":8,"NO2":8,"O3":8,"PM10":8,"PM25":8,"SO2":8},"counts":{"CO":1,"NO2":1,"O3":1,"PM10":1,"PM25":1,"SO2":1}}]}`).
 					Reply(http.StatusAccepted)
 
 				gock.New(s.brokerUrl).
@@ -110,9 +119,10 @@ func (s *AirQualityObservedServiceSuite) Test_PoleAirQualityObservedEvent() {
 					MatchHeader("ce-specversion", "1.0").
 					MatchHeader("ce-time", dateTimeFormatted).
 					MatchHeader("ce-source", "city-pole-nb001-p00007").
+					MatchHeader("ce-ktwincausation", "ngsi-ld-city-airqualityobserved-nb001-p00007").
 					MatchHeader("ce-type", "ktwin.command.city-pole.updateairqualityindex").
 					MatchHeader("ce-subject", "").
-					BodyString(`{"aqiLevel":"MODERATE"}`).
+					BodyString(`{"aqiLevel":"MODERATE","airQualityIndex":86,"dominantPollutant":"CO"}`).
 					Reply(http.StatusAccepted)
 			},
 			expectedError: nil,
@@ -129,3 +139,202 @@ func (s *AirQualityObservedServiceSuite) Test_PoleAirQualityObservedEvent() {
 		})
 	}
 }
+
+func (s *AirQualityObservedServiceSuite) Test_AirQualityObservedEventWithNeighborhoodStandard() {
+	defer clock.ResetClockImplementation()
+	defer uuid.ResetUuidImplementation()
+	defer kpolicy.Reset()
+	defer gock.Off()
+
+	uuid.NewUuid = func() string {
+		return DEFAULT_UUID
+	}
+
+	clock.NowFunc = func() *time.Time {
+		now, _ := time.Parse(time.RFC3339, "2024-01-01T00:00:00Z")
+		return &now
+	}
+	dateTime := clock.NowFunc()
+
+	// The neighborhood of the pole of the air quality observed uses the UK DAQI
+	policyFile := filepath.Join(s.T().TempDir(), "policies.yaml")
+	s.Require().NoError(os.WriteFile(policyFile, []byte(`
+overrides:
+  s4city-city-neighborhood-nb001:
+    ngsi-ld-city-airqualityobserved:
+      standard: daqi
+`), 0644))
+	registry := kpolicy.NewRegistry(policyFile, ktwingraph.LoadTwinGraphByInterfaces)
+	s.Require().NoError(registry.Load())
+	kpolicy.Set(registry)
+
+	gock.New(s.eventStoreUrl).
+		Get("/api/v1/twin-events/ngsi-ld-city-airqualityobserved/ngsi-ld-city-airqualityobserved-nb001-p00007/latest").
+		Reply(http.StatusNotFound)
+
+	gock.New(s.brokerUrl).
+		Post("/").
+		MatchHeader("ce-type", "ktwin.store.ngsi-ld-city-airqualityobserved").
+		BodyString(`{"airQualityIndex":7,"aqiLevel":"UNHEALTHY","aqiStandard":"daqi","aqiBand":"High","dominantPollutant":"PM10","CODensity":8,"PM10Density":80,"PM25Density":8,"SO2Density":8,"NO2Density":8,"O3Density":8,"PM10AqiLevel":"UNHEALTHY","PM25AqiLevel":"GOOD","SO2AqiLevel":"GOOD","O3AqiLevel":"GOOD","NO2AqiLevel":"GOOD","PM10Aqi":7,"PM25Aqi":1,"SO2Aqi":1,"O3Aqi":1,"NO2Aqi":1,"O3Density8h":8,"CODensity8h":8,"PM10Density24h":80,"PM25Density24h":8,"hourlyAverages":[{"hour":"2024-01-01T00:00:00Z","concentrations":{"CO":8,"NO2":8,"O3":8,"PM10":80,"PM25":8,"SO2":8},"counts":{"CO":1,"NO2":1,"O3":1,"PM10":1,"PM25":1,"SO2":1}}]}`).
+		Reply(http.StatusAccepted)
+
+	gock.New(s.brokerUrl).
+		Post("/").
+		MatchHeader("ce-source", "city-pole-nb001-p00007").
+		MatchHeader("ce-type", "ktwin.command.city-pole.updateairqualityindex").
+		BodyString(`{"aqiLevel":"UNHEALTHY","airQualityIndex":7,"dominantPollutant":"PM10"}`).
+		Reply(http.StatusAccepted)
+
+	twinEvent := ktwin.NewTwinEvent()
+	twinEvent.EventType = ktwin.RealEvent
+	twinEvent.TwinInstance = "ngsi-ld-city-airqualityobserved-nb001-p00007"
+	twinEvent.TwinInterface = "ngsi-ld-city-airqualityobserved"
+
+	cloudEvent := cloudevents.NewEvent()
+	cloudEvent.SetData("application/json", []byte(`{"CODensity": 8, "NO2Density": 8, "O3Density": 8, "SO2Density": 8, "PM10Density": 80, "PM25Density": 8}`))
+	cloudEvent.SetSource("ngsi-ld-city-airqualityobserved-nb001-p00007")
+	cloudEvent.SetType("ktwin.real.ngsi-ld-city-airqualityobserved")
+	cloudEvent.SetTime(*dateTime)
+	twinEvent.CloudEvent = &cloudEvent
+
+	s.Assert().NoError(HandleEvent(twinEvent))
+	s.Assert().True(gock.IsDone())
+}
+
+func (s *AirQualityObservedServiceSuite) Test_AirQualityObservedEventAveragesSpike() {
+	defer clock.ResetClockImplementation()
+	defer uuid.ResetUuidImplementation()
+	defer gock.Off()
+
+	uuid.NewUuid = func() string {
+		return DEFAULT_UUID
+	}
+
+	clock.NowFunc = func() *time.Time {
+		now, _ := time.Parse(time.RFC3339, "2024-01-01T00:00:00Z")
+		return &now
+	}
+	dateTime := clock.NowFunc()
+
+	// 7 hours of CO at 2 ppm and 2 hours of PM2.5 at 10 µg/m³ before the reading
+	var hourlyAverages []aqi.HourlyAverage
+	for hour := 7; hour > 0; hour-- {
+		hourlyAverage := aqi.HourlyAverage{
+			Hour:           dateTime.Add(-time.Duration(hour) * time.Hour),
+			Concentrations: map[aqi.Pollutant]float64{aqi.CO: 2},
+			Counts:         map[aqi.Pollutant]int{aqi.CO: 1},
+		}
+		if hour <= 2 {
+			hourlyAverage.Concentrations[aqi.PM25] = 10
+			hourlyAverage.Counts[aqi.PM25] = 1
+		}
+		hourlyAverages = append(hourlyAverages, hourlyAverage)
+	}
+
+	gock.New(s.eventStoreUrl).
+		Get("/api/v1/twin-events/ngsi-ld-city-airqualityobserved/ngsi-ld-city-airqualityobserved-nb001-p00007/latest").
+		Reply(http.StatusOK).
+		SetHeader("Content-Type", "application/json").
+		SetHeader("ce-specversion", "1.0").
+		SetHeader("ce-time", dateTime.Format(time.RFC3339)).
+		SetHeader("ce-source", "ngsi-ld-city-airqualityobserved-nb001-p00007").
+		SetHeader("ce-type", "ktwin.real.ngsi-ld-city-airqualityobserved").
+		SetHeader("ce-subject", "").
+		JSON(model.AirQualityEvent{CODensity: 2, PM25Density: 10, HourlyAverages: hourlyAverages})
+
+	gock.New(s.brokerUrl).
+		Post("/").
+		MatchHeader("ce-type", "ktwin.store.ngsi-ld-city-airqualityobserved").
+		Reply(http.StatusAccepted)
+
+	// A CO spike of 40 ppm is hazardous, its 8-hour average of 6.75 ppm is moderate
+	gock.New(s.brokerUrl).
+		Post("/").
+		MatchHeader("ce-source", "city-pole-nb001-p00007").
+		MatchHeader("ce-type", "ktwin.command.city-pole.updateairqualityindex").
+		BodyString(`{"aqiLevel":"MODERATE","airQualityIndex":73,"dominantPollutant":"CO"}`).
+		Reply(http.StatusAccepted)
+
+	twinEvent := ktwin.NewTwinEvent()
+	twinEvent.EventType = ktwin.RealEvent
+	twinEvent.TwinInstance = "ngsi-ld-city-airqualityobserved-nb001-p00007"
+	twinEvent.TwinInterface = "ngsi-ld-city-airqualityobserved"
+
+	cloudEvent := cloudevents.NewEvent()
+	cloudEvent.SetData("application/json", []byte(`{"CODensity": 40, "PM25Density": 10}`))
+	cloudEvent.SetSource("ngsi-ld-city-airqualityobserved-nb001-p00007")
+	cloudEvent.SetType("ktwin.real.ngsi-ld-city-airqualityobserved")
+	cloudEvent.SetTime(*dateTime)
+	twinEvent.CloudEvent = &cloudEvent
+
+	s.Assert().NoError(HandleEvent(twinEvent))
+	s.Assert().True(gock.IsDone())
+
+	var airQualityObserved model.AirQualityEvent
+	s.Require().NoError(twinEvent.ToModel(&airQualityObserved))
+	s.Assert().Equal(6.75, airQualityObserved.CODensity8h)
+	s.Assert().Equal(10.0, airQualityObserved.PM25NowCast)
+	s.Assert().Equal(53, airQualityObserved.PM25Aqi)
+	s.Assert().Len(airQualityObserved.HourlyAverages, 8)
+}
+
+func (s *AirQualityObservedServiceSuite) Test_AirQualityObservedEventsAcrossHours() {
+	defer clock.ResetClockImplementation()
+	defer uuid.ResetUuidImplementation()
+	defer gock.Off()
+
+	windows = newMemoryWindows()
+	defer func() { windows = nil }()
+
+	uuid.NewUuid = func() string {
+		return DEFAULT_UUID
+	}
+
+	// The events of 11 hours arrive within the same minute
+	clock.NowFunc = func() *time.Time {
+		now, _ := time.Parse(time.RFC3339, "2024-01-02T00:00:00Z")
+		return &now
+	}
+	observedFrom, _ := time.Parse(time.RFC3339, "2024-01-01T00:00:00Z")
+
+	gock.New(s.brokerUrl).
+		Post("/").
+		MatchHeader("ce-type", "ktwin.store.ngsi-ld-city-airqualityobserved").
+		Times(11).
+		Reply(http.StatusAccepted)
+	gock.New(s.brokerUrl).
+		Post("/").
+		MatchHeader("ce-type", "ktwin.command.city-pole.updateairqualityindex").
+		Times(11).
+		Reply(http.StatusAccepted)
+
+	var twinEvent *ktwin.TwinEvent
+	for hour := 0; hour <= 10; hour++ {
+		coDensity := "2"
+		if hour == 10 {
+			coDensity = "10"
+		}
+
+		twinEvent = ktwin.NewTwinEvent()
+		twinEvent.EventType = ktwin.RealEvent
+		twinEvent.TwinInstance = "ngsi-ld-city-airqualityobserved-nb001-p00007"
+		twinEvent.TwinInterface = "ngsi-ld-city-airqualityobserved"
+
+		cloudEvent := cloudevents.NewEvent()
+		cloudEvent.SetData("application/json", []byte(`{"CODensity": `+coDensity+`}`))
+		cloudEvent.SetSource("ngsi-ld-city-airqualityobserved-nb001-p00007")
+		cloudEvent.SetType("ktwin.real.ngsi-ld-city-airqualityobserved")
+		cloudEvent.SetTime(observedFrom.Add(time.Duration(hour)*time.Hour + 30*time.Minute))
+		twinEvent.CloudEvent = &cloudEvent
+
+		s.Require().NoError(HandleEvent(twinEvent))
+	}
+	s.Assert().True(gock.IsDone())
+
+	// The 8-hour average is of the hourly averages of the 8 latest hours of the events
+	var airQualityObserved model.AirQualityEvent
+	s.Require().NoError(twinEvent.ToModel(&airQualityObserved))
+	s.Assert().Len(airQualityObserved.HourlyAverages, 11)
+	s.Assert().Equal(3.0, airQualityObserved.CODensity8h)
+	s.Assert().Equal(observedFrom.Add(10*time.Hour), airQualityObserved.HourlyAverages[10].Hour)
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"sync"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/air-quality-observed-service/model"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/aqi"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/keventstore"
+)
+
+// windowStore keeps the rolling windows of the readings of the air quality observed instances
+type windowStore interface {
+	Get(twinInterface, twinInstance string) (*aqi.Window, error)
+	Put(twinInstance string, window *aqi.Window) error
+}
+
+// eventStoreWindows reads the windows from the latest twin events, which store their hourly averages
+type eventStoreWindows struct{}
+
+func (eventStoreWindows) Get(twinInterface, twinInstance string) (*aqi.Window, error) {
+	latestEvent, err := keventstore.GetLatestTwinEvent(twinInterface, twinInstance)
+	if err != nil {
+		return nil, err
+	}
+
+	if latestEvent == nil {
+		return aqi.NewWindow(nil), nil
+	}
+
+	var latestAirQualityObserved model.AirQualityEvent
+	if err := latestEvent.ToStoredModel(&latestAirQualityObserved); err != nil {
+		return nil, err
+	}
+	return aqi.NewWindow(latestAirQualityObserved.HourlyAverages), nil
+}
+
+// The window is stored with the twin event
+func (eventStoreWindows) Put(twinInstance string, window *aqi.Window) error {
+	return nil
+}
+
+// memoryWindows keeps the windows in the service, they are lost when it restarts
+type memoryWindows struct {
+	mutex   sync.Mutex
+	windows map[string][]aqi.HourlyAverage
+}
+
+func newMemoryWindows() *memoryWindows {
+	return &memoryWindows{windows: map[string][]aqi.HourlyAverage{}}
+}
+
+func (m *memoryWindows) Get(twinInterface, twinInstance string) (*aqi.Window, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return aqi.NewWindow(m.windows[twinInstance]), nil
+}
+
+func (m *memoryWindows) Put(twinInstance string, window *aqi.Window) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.windows[twinInstance] = window.Hours
+	return nil
+}
+
+var windows windowStore
+
+func getWindowStore() windowStore {
+	if windows == nil {
+		if serviceConfig.WindowStore == WINDOW_STORE_MEMORY {
+			windows = newMemoryWindows()
+		} else {
+			windows = eventStoreWindows{}
+		}
+	}
+	return windows
+}
@@ -0,0 +1,129 @@
+package model
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/aqi"
+)
+
+var (
+	TWIN_INTERFACE_CITY              = "s4city-city-city"
+	TWIN_COMMAND_UPDATE_NEIGHBORHOOD = "updateNeighborhood"
+)
+
+// UpdateNeighborhoodCommand is sent by a neighborhood to its city when its KPIs change.
+// The attributes that are not set keep their latest value.
+type UpdateNeighborhoodCommand struct {
+	AqiLevel            aqi.Category `json:"aqiLevel,omitempty" validate:"oneof=GOOD MODERATE UNHEALTHY_FOR_SENSITIVE_GROUPS UNHEALTHY VERY_UNHEALTHY HAZARDOUS" protobuf:"1"`
+	AvailableSpotNumber *int         `json:"availableSpotNumber,omitempty" validate:"min=0" protobuf:"2"`
+	TotalSpotNumber     *int         `json:"totalSpotNumber,omitempty" validate:"min=0" protobuf:"3"`
+	// Other KPIs of the neighborhood by name
+	Kpis map[string]float64 `json:"kpis,omitempty" protobuf:"4"`
+}
+
+// NeighborhoodState is the latest state reported by a neighborhood of the city
+type NeighborhoodState struct {
+	AqiLevel            aqi.Category       `json:"aqiLevel,omitempty" protobuf:"1"`
+	AvailableSpotNumber *int               `json:"availableSpotNumber,omitempty" protobuf:"2"`
+	TotalSpotNumber     *int               `json:"totalSpotNumber,omitempty" protobuf:"3"`
+	Kpis                map[string]float64 `json:"kpis,omitempty" protobuf:"4"`
+	DateModified        *time.Time         `json:"dateModified,omitempty" protobuf:"5"`
+}
+
+// City is the dashboard of the city, aggregated from the latest state of its neighborhoods
+type City struct {
+	NeighborhoodCount int `json:"neighborhoodCount,omitempty" protobuf:"1"`
+	// Worst level of the neighborhoods
+	AqiLevel aqi.Category `json:"aqiLevel,omitempty" validate:"oneof=GOOD MODERATE UNHEALTHY_FOR_SENSITIVE_GROUPS UNHEALTHY VERY_UNHEALTHY HAZARDOUS" protobuf:"2"`
+	// Number of neighborhoods with each level
+	AqiLevelCounts map[aqi.Category]int `json:"aqiLevelCounts,omitempty" protobuf:"3"`
+	// Neighborhoods with a level worse than GOOD, the worst first
+	WorstNeighborhoods  []string `json:"worstNeighborhoods,omitempty" protobuf:"4"`
+	AvailableSpotNumber int      `json:"availableSpotNumber,omitempty" protobuf:"5"`
+	TotalSpotNumber     int      `json:"totalSpotNumber,omitempty" protobuf:"6"`
+	// Average of each KPI over the neighborhoods reporting it
+	Kpis          map[string]float64           `json:"kpis,omitempty" protobuf:"7"`
+	DateModified  *time.Time                   `json:"dateModified,omitempty" protobuf:"8"`
+	Neighborhoods map[string]NeighborhoodState `json:"neighborhoods,omitempty" protobuf:"9"`
+}
+
+// Updates the state of the neighborhood with the attributes set in the command
+func (c *City) UpdateNeighborhood(neighborhood string, command UpdateNeighborhoodCommand, now *time.Time) {
+	if c.Neighborhoods == nil {
+		c.Neighborhoods = map[string]NeighborhoodState{}
+	}
+
+	state := c.Neighborhoods[neighborhood]
+	if command.AqiLevel != "" {
+		state.AqiLevel = command.AqiLevel
+	}
+	if command.AvailableSpotNumber != nil {
+		state.AvailableSpotNumber = command.AvailableSpotNumber
+	}
+	if command.TotalSpotNumber != nil {
+		state.TotalSpotNumber = command.TotalSpotNumber
+	}
+	for name, value := range command.Kpis {
+		if state.Kpis == nil {
+			state.Kpis = map[string]float64{}
+		}
+		state.Kpis[name] = value
+	}
+	state.DateModified = now
+
+	c.Neighborhoods[neighborhood] = state
+}
+
+// Calculates the dashboard from the state of the neighborhoods, listing at most worstCount worst neighborhoods
+func (c *City) Aggregate(worstCount int) {
+	c.NeighborhoodCount = len(c.Neighborhoods)
+	c.AqiLevel = ""
+	c.AqiLevelCounts = map[aqi.Category]int{}
+	c.WorstNeighborhoods = nil
+	c.AvailableSpotNumber = 0
+	c.TotalSpotNumber = 0
+	c.Kpis = nil
+
+	kpiSums := map[string]float64{}
+	kpiCounts := map[string]int{}
+
+	for name, state := range c.Neighborhoods {
+		if state.AqiLevel != "" {
+			c.AqiLevelCounts[state.AqiLevel]++
+			c.AqiLevel = aqi.Worst(c.AqiLevel, state.AqiLevel)
+			if state.AqiLevel.Level() > aqi.Good.Level() {
+				c.WorstNeighborhoods = append(c.WorstNeighborhoods, name)
+			}
+		}
+		if state.AvailableSpotNumber != nil {
+			c.AvailableSpotNumber += *state.AvailableSpotNumber
+		}
+		if state.TotalSpotNumber != nil {
+			c.TotalSpotNumber += *state.TotalSpotNumber
+		}
+		for kpi, value := range state.Kpis {
+			kpiSums[kpi] += value
+			kpiCounts[kpi]++
+		}
+	}
+
+	sort.Slice(c.WorstNeighborhoods, func(i, j int) bool {
+		left := c.Neighborhoods[c.WorstNeighborhoods[i]].AqiLevel.Level()
+		right := c.Neighborhoods[c.WorstNeighborhoods[j]].AqiLevel.Level()
+		if left != right {
+			return left > right
+		}
+		return c.WorstNeighborhoods[i] < c.WorstNeighborhoods[j]
+	})
+	if len(c.WorstNeighborhoods) > worstCount {
+		c.WorstNeighborhoods = c.WorstNeighborhoods[:worstCount]
+	}
+
+	for kpi, sum := range kpiSums {
+		if c.Kpis == nil {
+			c.Kpis = map[string]float64{}
+		}
+		c.Kpis[kpi] = sum / float64(kpiCounts[kpi])
+	}
+}
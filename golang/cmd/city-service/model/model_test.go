@@ -0,0 +1,44 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/aqi"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestCitySuite(t *testing.T) {
+	suite.Run(t, new(CitySuite))
+}
+
+type CitySuite struct {
+	suite.Suite
+}
+
+func (s *CitySuite) Test_ProtobufRoundTrip() {
+	codec, err := ktwin.GetCodec(ktwin.ApplicationProtobuf)
+	s.Require().NoError(err)
+
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	availableSpotNumber, totalSpotNumber := 10, 50
+	expected := City{
+		NeighborhoodCount:  2,
+		AqiLevel:           aqi.Moderate,
+		AqiLevelCounts:     map[aqi.Category]int{aqi.Good: 1, aqi.Moderate: 1},
+		WorstNeighborhoods: []string{"s4city-city-neighborhood-nb002"},
+		Kpis:               map[string]float64{"livability": 75},
+		DateModified:       &now,
+		Neighborhoods: map[string]NeighborhoodState{
+			"s4city-city-neighborhood-nb001": {AqiLevel: aqi.Good, AvailableSpotNumber: &availableSpotNumber, TotalSpotNumber: &totalSpotNumber, DateModified: &now},
+			"s4city-city-neighborhood-nb002": {AqiLevel: aqi.Moderate, Kpis: map[string]float64{"livability": 75}, DateModified: &now},
+		},
+	}
+
+	data, err := codec.Marshal(expected)
+	s.Require().NoError(err)
+	var actual City
+	s.Require().NoError(codec.Unmarshal(data, &actual))
+	s.Assert().Equal(expected, actual)
+}
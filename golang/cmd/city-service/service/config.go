@@ -0,0 +1,28 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+)
+
+// Config of the city service, the services.city section of the config
+type Config struct {
+	// Number of worst neighborhoods listed in the dashboard
+	WorstNeighborhoods int `yaml:"worstNeighborhoods" env:"KTWIN_CITY_WORST_NEIGHBORHOODS"`
+}
+
+var serviceConfig = Config{
+	WorstNeighborhoods: 5,
+}
+
+func (c *Config) Validate() error {
+	if c.WorstNeighborhoods <= 0 {
+		return errors.New("worstNeighborhoods must be greater than 0")
+	}
+	return nil
+}
+
+func LoadConfig() error {
+	return config.LoadService("city", &serviceConfig)
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/city-service/model"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/clock"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kcommand"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/keventstore"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/ktwingraph"
+
+	log "github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
+)
+
+var logger = log.NewLogger()
+var twinGraph *ktwin.TwinGraph
+
+func loadTwinGraph() error {
+	if twinGraph == nil {
+		var err error
+		graph, err := ktwingraph.LoadTwinGraphByInterfaces([]string{model.TWIN_INTERFACE_CITY})
+		if err != nil {
+			logger.Error("Error loading twin graph", err)
+			return err
+		}
+		twinGraph = &graph
+	}
+	return nil
+}
+
+func HandleEvent(event *ktwin.TwinEvent) error {
+	err := loadTwinGraph()
+	if err != nil {
+		return err
+	}
+
+	return kcommand.HandleCommand(event, model.TWIN_INTERFACE_CITY, model.TWIN_COMMAND_UPDATE_NEIGHBORHOOD, *twinGraph, handleUpdateNeighborhood)
+}
+
+func handleUpdateNeighborhood(command *ktwin.TwinEvent) error {
+	var updateNeighborhoodCommand model.UpdateNeighborhoodCommand
+	err := command.ToModel(&updateNeighborhoodCommand)
+	if err != nil {
+		return err
+	}
+
+	neighborhood := command.Causation()
+	if neighborhood == "" {
+		logger.Info("Neighborhood of the command not provided")
+		return nil
+	}
+
+	latestEvent, err := keventstore.GetLatestTwinEvent(command.TwinInterface, command.TwinInstance)
+	if err != nil {
+		return err
+	}
+
+	var city model.City
+	if latestEvent == nil {
+		latestEvent = ktwin.NewTwinEvent()
+		err = latestEvent.SetEvent(command.TwinInterface, command.TwinInstance, ktwin.RealEvent, city)
+		if err != nil {
+			return err
+		}
+	} else {
+		err = latestEvent.ToStoredModel(&city)
+		if err != nil {
+			return err
+		}
+	}
+
+	now := clock.Now()
+	city.UpdateNeighborhood(neighborhood, updateNeighborhoodCommand, now)
+	city.Aggregate(serviceConfig.WorstNeighborhoods)
+	city.DateModified = now
+
+	err = latestEvent.SetData(city)
+	if err != nil {
+		return err
+	}
+	return keventstore.UpdateTwinEvent(latestEvent)
+}
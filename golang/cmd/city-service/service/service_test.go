@@ -0,0 +1,187 @@
+package service
+
+import (
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/city-service/model"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/aqi"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/clock"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestCityServiceSuite(t *testing.T) {
+	suite.Run(t, new(CityServiceSuite))
+}
+
+type CityServiceSuite struct {
+	suite.Suite
+
+	brokerUrl     string
+	eventStoreUrl string
+}
+
+func (s *CityServiceSuite) SetupSuite() {
+	os.Setenv("ENV", "test")
+	config.LoadEnv()
+
+	s.brokerUrl = os.Getenv("KTWIN_BROKER")
+	s.eventStoreUrl = os.Getenv("KTWIN_EVENT_STORE")
+}
+
+// Builds the updateNeighborhood command of the city, caused by the neighborhood when it is set
+func (s *CityServiceSuite) newCommand(data, neighborhood string) *ktwin.TwinEvent {
+	twinEvent := ktwin.NewTwinEvent()
+	twinEvent.EventType = ktwin.CommandEvent
+	twinEvent.TwinInstance = "city-001"
+	twinEvent.TwinInterface = "s4city-city-city"
+	twinEvent.CommandName = "updateNeighborhood"
+
+	cloudEvent := cloudevents.NewEvent()
+	cloudEvent.SetData("application/json", []byte(data))
+	cloudEvent.SetID("")
+	cloudEvent.SetSource("city-001")
+	cloudEvent.SetType("ktwin.command.s4city-city-city.updateneighborhood")
+	cloudEvent.SetTime(*clock.Now())
+	if neighborhood != "" {
+		cloudEvent.SetExtension(ktwin.CausationExtension, neighborhood)
+	}
+
+	twinEvent.CloudEvent = &cloudEvent
+	return twinEvent
+}
+
+func (s *CityServiceSuite) Test_CityEvent() {
+	defer clock.ResetClockImplementation()
+
+	clock.NowFunc = func() *time.Time {
+		now, _ := time.Parse(time.RFC3339, "2024-01-01T00:00:00Z")
+		return &now
+	}
+	dateTime := clock.NowFunc()
+	dateTimeFormatted := dateTime.Format(time.RFC3339)
+	hourAgo := dateTime.Add(-time.Hour)
+	twenty, fifty := 20, 50
+
+	tests := []struct {
+		name                string
+		mockExternalService func()
+		twinEvent           func() *ktwin.TwinEvent
+		expectedError       error
+	}{
+		{
+			name: `Empty event`,
+			twinEvent: func() *ktwin.TwinEvent {
+				return &ktwin.TwinEvent{}
+			},
+			mockExternalService: func() {},
+			expectedError:       nil,
+		},
+		{
+			name: `
+				Given new command is received without the neighborhood that caused it
+				Should ignore the command
+			`,
+			twinEvent: func() *ktwin.TwinEvent {
+				return s.newCommand(`{"aqiLevel": "GOOD"}`, "")
+			},
+			mockExternalService: func() {},
+			expectedError:       nil,
+		},
+		{
+			name: `
+				Given new command is received and there is no previous event
+				When the neighborhood reports aqiLevel MODERATE
+				Should create the city dashboard with the neighborhood
+			`,
+			twinEvent: func() *ktwin.TwinEvent {
+				return s.newCommand(`{"aqiLevel": "MODERATE"}`, "s4city-city-neighborhood-nb001")
+			},
+			mockExternalService: func() {
+				gock.New(s.eventStoreUrl).
+					Get("/api/v1/twin-events/s4city-city-city/city-001/latest").
+					Reply(http.StatusNotFound)
+
+				gock.New(s.brokerUrl).
+					Post("/").
+					MatchHeader("Content-Type", "application/json").
+					MatchHeader("ce-time", dateTimeFormatted).
+					MatchHeader("ce-source", "city-001").
+					MatchHeader("ce-type", "ktwin.store.s4city-city-city").
+					BodyString(`{"neighborhoodCount":1,"aqiLevel":"MODERATE","aqiLevelCounts":{"MODERATE":1},"worstNeighborhoods":["s4city-city-neighborhood-nb001"],"dateModified":"2024-01-01T00:00:00Z","neighborhoods":{"s4city-city-neighborhood-nb001":{"aqiLevel":"MODERATE","dateModified":"2024-01-01T00:00:00Z"}}}`).
+					Reply(http.StatusAccepted)
+			},
+			expectedError: nil,
+		},
+		{
+			name: `
+				Given new command is received and there is a previous event
+				When a neighborhood reports its parking availability and KPIs
+				Should keep its aqiLevel and update the counts, totals and averages of the city
+			`,
+			twinEvent: func() *ktwin.TwinEvent {
+				return s.newCommand(`{"availableSpotNumber": 30, "totalSpotNumber": 100, "kpis": {"livability": 60}}`, "s4city-city-neighborhood-nb002")
+			},
+			mockExternalService: func() {
+				gock.New(s.eventStoreUrl).
+					Get("/api/v1/twin-events/s4city-city-city/city-001/latest").
+					Reply(http.StatusOK).
+					SetHeader("Content-Type", "application/json").
+					SetHeader("ce-specversion", "1.0").
+					SetHeader("ce-time", dateTimeFormatted).
+					SetHeader("ce-source", "city-001").
+					SetHeader("ce-type", "ktwin.real.s4city-city-city").
+					SetHeader("ce-subject", "").
+					JSON(model.City{
+						Neighborhoods: map[string]model.NeighborhoodState{
+							"s4city-city-neighborhood-nb001": {AqiLevel: aqi.Unhealthy, AvailableSpotNumber: &twenty, TotalSpotNumber: &fifty, Kpis: map[string]float64{"livability": 40}, DateModified: &hourAgo},
+							"s4city-city-neighborhood-nb002": {AqiLevel: aqi.Moderate, DateModified: &hourAgo},
+							"s4city-city-neighborhood-nb003": {AqiLevel: aqi.Good, DateModified: &hourAgo},
+						},
+					})
+
+				gock.New(s.brokerUrl).
+					Post("/").
+					MatchHeader("ce-source", "city-001").
+					MatchHeader("ce-type", "ktwin.store.s4city-city-city").
+					BodyString(`{"neighborhoodCount":3,"aqiLevel":"UNHEALTHY","aqiLevelCounts":{"GOOD":1,"MODERATE":1,"UNHEALTHY":1},"worstNeighborhoods":["s4city-city-neighborhood-nb001","s4city-city-neighborhood-nb002"],"availableSpotNumber":50,"totalSpotNumber":150,"kpis":{"livability":50},"dateModified":"2024-01-01T00:00:00Z","neighborhoods":{"s4city-city-neighborhood-nb001":{"aqiLevel":"UNHEALTHY","availableSpotNumber":20,"totalSpotNumber":50,"kpis":{"livability":40},"dateModified":"2023-12-31T23:00:00Z"},"s4city-city-neighborhood-nb002":{"aqiLevel":"MODERATE","availableSpotNumber":30,"totalSpotNumber":100,"kpis":{"livability":60},"dateModified":"2024-01-01T00:00:00Z"},"s4city-city-neighborhood-nb003":{"aqiLevel":"GOOD","dateModified":"2023-12-31T23:00:00Z"}}}`).
+					Reply(http.StatusAccepted)
+			},
+			expectedError: nil,
+		},
+	}
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			defer gock.Off()
+			tt.mockExternalService()
+
+			actualError := HandleEvent(tt.twinEvent())
+
+			s.Assert().Equal(tt.expectedError, actualError)
+			s.Assert().True(gock.IsDone())
+		})
+	}
+}
+
+func (s *CityServiceSuite) Test_WorstNeighborhoods() {
+	city := model.City{Neighborhoods: map[string]model.NeighborhoodState{
+		"nb001": {AqiLevel: aqi.Moderate},
+		"nb002": {AqiLevel: aqi.Hazardous},
+		"nb003": {AqiLevel: aqi.Good},
+		"nb004": {AqiLevel: aqi.Unhealthy},
+		"nb005": {AqiLevel: aqi.Moderate},
+	}}
+
+	city.Aggregate(3)
+
+	s.Assert().Equal([]string{"nb002", "nb004", "nb001"}, city.WorstNeighborhoods)
+	s.Assert().Equal(aqi.Hazardous, city.AqiLevel)
+	s.Assert().Equal(5, city.NeighborhoodCount)
+	s.Assert().Equal(2, city.AqiLevelCounts[aqi.Moderate])
+}
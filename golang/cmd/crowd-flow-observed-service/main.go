@@ -1,13 +1,22 @@
 package main
 
 import (
+	"os"
+
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/crowd-flow-observed-service/service"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
 
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/server"
 )
 
 func main() {
-	config.LoadEnv()
+	logger := logger.NewLogger()
+	if _, err := config.Load(os.Args[1:]); err != nil {
+		logger.Fatal("Error loading config", err)
+	}
+	if err := service.LoadConfig(); err != nil {
+		logger.Fatal("Error loading service config", err)
+	}
 	server.StartServer(service.HandleEvent)
 }
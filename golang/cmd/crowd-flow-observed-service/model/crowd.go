@@ -3,12 +3,18 @@ package model
 import "time"
 
 type CrowdFlowObservedEvent struct {
-	DateObservedFrom   *time.Time `json:"dateObservedFrom,omitempty"`   // Date and time when the observation started
-	DateObservedTo     *time.Time `json:"dateObservedTo,omitempty"`     // Date and time when the observation ended
-	Occupancy          bool       `json:"occupancy,omitempty"`          // Fraction of the observation time where a person has been occupying the observed walkway
-	AverageCrowdSpeed  float64    `json:"averageCrowdSpeed,omitempty"`  // Average speed of the crowd transiting during the observation period (Km/h)
-	Congested          bool       `json:"congested"`                    // Flags whether there was a crowd congestion during the observation period in the referred walkway
-	AverageHeadwayTime float64    `json:"averageHeadwayTime,omitempty"` // Average headway time (time elapsed between two consecutive persons) in seconds
-	Direction          string     `json:"direction,omitempty"`          // Usual direction of travel in the walkway referred by this observation with respect to the city center ("inbound" or "outbound")
-	PeopleCount        int        `json:"peopleCount,omitempty"`        // Number of people observed
+	DateObservedFrom   *time.Time `json:"dateObservedFrom,omitempty" protobuf:"1"`                            // Date and time when the observation started
+	DateObservedTo     *time.Time `json:"dateObservedTo,omitempty" protobuf:"2"`                              // Date and time when the observation ended
+	Occupancy          bool       `json:"occupancy,omitempty" protobuf:"3"`                                   // Fraction of the observation time where a person has been occupying the observed walkway
+	AverageCrowdSpeed  float64    `json:"averageCrowdSpeed,omitempty" validate:"min=0" protobuf:"4"`          // Average speed of the crowd transiting during the observation period (Km/h)
+	Congested          bool       `json:"congested" protobuf:"5"`                                             // Flags whether there was a crowd congestion during the observation period in the referred walkway
+	AverageHeadwayTime float64    `json:"averageHeadwayTime,omitempty" validate:"min=0" protobuf:"6"`         // Average headway time (time elapsed between two consecutive persons) in seconds
+	Direction          string     `json:"direction,omitempty" validate:"oneof=inbound outbound" protobuf:"7"` // Usual direction of travel in the walkway referred by this observation with respect to the city center ("inbound" or "outbound")
+	PeopleCount        int        `json:"peopleCount,omitempty" validate:"min=0" protobuf:"8"`                // Number of people observed
+}
+
+// UpdateCrowdFlowCommand is the summary of the crowd flow sent to the pole the sensor is attached to
+type UpdateCrowdFlowCommand struct {
+	Congested   bool `json:"congested,omitempty" protobuf:"1"`
+	PeopleCount int  `json:"peopleCount,omitempty" protobuf:"2"`
 }
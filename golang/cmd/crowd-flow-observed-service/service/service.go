@@ -3,18 +3,45 @@ package service
 import (
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/crowd-flow-observed-service/model"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kcommand"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kevent"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/keventstore"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kpolicy"
+	ktwingraph "github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/ktwingraph"
+	log "github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
 )
 
 var (
+	TWIN_INTERFACE_CITY_POLE           = "city-pole"
 	TWIN_INTERFACE_CROWD_FLOW_OBSERVED = "ngsi-ld-city-crowdflowobserved"
 
-	CROWD_FLOW_AVERAGE_CROWD_SPEED_THRESHOLD = 4
-	CROWD_FLOW_HEADWAY_TIME_THRESHOLD        = 2
+	// City Pole Update Crowd Flow Command, the pole references its crowd flow sensor
+	TWIN_COMMAND_CITY_POLE_UPDATE_CROWD_FLOW = "updateCrowdFlow"
+	TWIN_COMMAND_CITY_POLE_RELATIONSHIP_NAME = "refCrowdFlow"
 )
 
+var logger = log.NewLogger()
+var twinGraph *ktwin.TwinGraph
+
+func loadTwinGraph() error {
+	if twinGraph == nil {
+		var err error
+		graph, err := ktwingraph.LoadTwinGraphByInterfaces([]string{TWIN_INTERFACE_CITY_POLE})
+		if err != nil {
+			logger.Error("Error loading twin graph", err)
+			return err
+		}
+		twinGraph = &graph
+	}
+	return nil
+}
+
 func HandleEvent(event *ktwin.TwinEvent) error {
+	err := loadTwinGraph()
+	if err != nil {
+		return err
+	}
+
 	return kevent.HandleEvent(event, TWIN_INTERFACE_CROWD_FLOW_OBSERVED, handleCrowdFlowObservedEvent)
 }
 
@@ -26,15 +53,25 @@ func handleCrowdFlowObservedEvent(event *ktwin.TwinEvent) error {
 		return err
 	}
 
-	if crowdFlowObserved.AverageCrowdSpeed < float64(CROWD_FLOW_AVERAGE_CROWD_SPEED_THRESHOLD) {
+	policy := kpolicy.ResolveOrDefault(TWIN_INTERFACE_CROWD_FLOW_OBSERVED, event.TwinInstance, serviceConfig)
+	if crowdFlowObserved.AverageCrowdSpeed < policy.AverageSpeedThreshold {
 		crowdFlowObserved.Congested = true
-	} else if crowdFlowObserved.AverageHeadwayTime < float64(CROWD_FLOW_HEADWAY_TIME_THRESHOLD) {
+	} else if crowdFlowObserved.AverageHeadwayTime < policy.HeadwayTimeThreshold {
 		crowdFlowObserved.Congested = true
 	} else {
 		crowdFlowObserved.Congested = false
 	}
 
-	event.SetData(crowdFlowObserved)
+	err = event.SetData(crowdFlowObserved)
+	if err != nil {
+		return err
+	}
+	err = keventstore.UpdateTwinEvent(event)
+	if err != nil {
+		return err
+	}
 
-	return keventstore.UpdateTwinEvent(event)
+	// Sends the summary to the pole the sensor is attached to
+	command := model.UpdateCrowdFlowCommand{Congested: crowdFlowObserved.Congested, PeopleCount: crowdFlowObserved.PeopleCount}
+	return kcommand.PublishCommandToReferrer(TWIN_COMMAND_CITY_POLE_UPDATE_CROWD_FLOW, command, TWIN_COMMAND_CITY_POLE_RELATIONSHIP_NAME, event.TwinInstance, *twinGraph)
 }
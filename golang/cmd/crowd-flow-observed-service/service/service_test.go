@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/internal/kcommandtest"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/clock"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
@@ -102,6 +103,8 @@ func (s *CrowdFlowObservedServiceSuite) Test_CrowdFlowObservedEvent() {
 					MatchHeader("ce-subject", "").
 					BodyString(`{"averageCrowdSpeed":2,"congested":true,"averageHeadwayTime":1}`).
 					Reply(http.StatusAccepted)
+
+				kcommandtest.MockCommand(s.brokerUrl, "city-pole", "city-pole-nb001-p00007", "updateCrowdFlow", "ngsi-ld-city-crowdflowobserved-nb001-p00007", `{"congested":true}`)
 			},
 			expectedError: nil,
 		},
@@ -139,6 +142,8 @@ func (s *CrowdFlowObservedServiceSuite) Test_CrowdFlowObservedEvent() {
 					MatchHeader("ce-subject", "").
 					BodyString(`{"averageCrowdSpeed":2,"congested":true,"averageHeadwayTime":2}`).
 					Reply(http.StatusAccepted)
+
+				kcommandtest.MockCommand(s.brokerUrl, "city-pole", "city-pole-nb001-p00007", "updateCrowdFlow", "ngsi-ld-city-crowdflowobserved-nb001-p00007", `{"congested":true}`)
 			},
 			expectedError: nil,
 		},
@@ -176,6 +181,8 @@ func (s *CrowdFlowObservedServiceSuite) Test_CrowdFlowObservedEvent() {
 					MatchHeader("ce-subject", "").
 					BodyString(`{"averageCrowdSpeed":4,"congested":true,"averageHeadwayTime":1}`).
 					Reply(http.StatusAccepted)
+
+				kcommandtest.MockCommand(s.brokerUrl, "city-pole", "city-pole-nb001-p00007", "updateCrowdFlow", "ngsi-ld-city-crowdflowobserved-nb001-p00007", `{"congested":true}`)
 			},
 			expectedError: nil,
 		},
@@ -213,6 +220,8 @@ func (s *CrowdFlowObservedServiceSuite) Test_CrowdFlowObservedEvent() {
 					MatchHeader("ce-subject", "").
 					BodyString(`{"averageCrowdSpeed":5,"congested":false,"averageHeadwayTime":3}`).
 					Reply(http.StatusAccepted)
+
+				kcommandtest.MockCommand(s.brokerUrl, "city-pole", "city-pole-nb001-p00007", "updateCrowdFlow", "ngsi-ld-city-crowdflowobserved-nb001-p00007", `{}`)
 			},
 			expectedError: nil,
 		},
@@ -225,6 +234,7 @@ func (s *CrowdFlowObservedServiceSuite) Test_CrowdFlowObservedEvent() {
 			actualError := HandleEvent(tt.twinEvent())
 
 			s.Assert().Equal(tt.expectedError, actualError)
+			s.Assert().True(gock.IsDone())
 		})
 	}
 }
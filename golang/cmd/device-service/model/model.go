@@ -7,11 +7,11 @@ const (
 )
 
 type Device struct {
-	DataProvider         string     `json:"dataProvider,omitempty"`
-	BatteryLevel         float64    `json:"batteryLevel,omitempty"`
-	MeasurementFrequency int        `json:"measurementFrequency,omitempty"`
-	Source               string     `json:"source,omitempty"`
-	DateCreated          *time.Time `json:"dateCreated,omitempty"`
-	DateObserved         *time.Time `json:"dateObserved,omitempty"`
-	DateModified         *time.Time `json:"dateModified,omitempty"`
+	DataProvider         string     `json:"dataProvider,omitempty" protobuf:"1"`
+	BatteryLevel         float64    `json:"batteryLevel,omitempty" validate:"min=0,max=100" protobuf:"2"`
+	MeasurementFrequency int        `json:"measurementFrequency,omitempty" validate:"min=0" protobuf:"3"`
+	Source               string     `json:"source,omitempty" protobuf:"4"`
+	DateCreated          *time.Time `json:"dateCreated,omitempty" protobuf:"5"`
+	DateObserved         *time.Time `json:"dateObserved,omitempty" protobuf:"6"`
+	DateModified         *time.Time `json:"dateModified,omitempty" protobuf:"7"`
 }
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/device-service/model"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kpolicy"
+)
+
+// Config of the device service, the services.device section of the config
+type Config struct {
+	// Percentage of battery available below which the device measures in low frequency
+	BatteryThreshold float64 `yaml:"batteryThreshold" env:"KTWIN_DEVICE_BATTERY_THRESHOLD"`
+	// Measurement frequencies in minutes
+	HighFrequency int `yaml:"highFrequency" env:"KTWIN_DEVICE_HIGH_FREQUENCY"`
+	LowFrequency  int `yaml:"lowFrequency" env:"KTWIN_DEVICE_LOW_FREQUENCY"`
+}
+
+var serviceConfig = Config{
+	BatteryThreshold: 15,
+	HighFrequency:    15,
+	LowFrequency:     60,
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+	if c.BatteryThreshold < 0 || c.BatteryThreshold > 100 {
+		errs = append(errs, errors.New("batteryThreshold must be between 0 and 100"))
+	}
+	if c.HighFrequency <= 0 || c.LowFrequency <= 0 {
+		errs = append(errs, errors.New("highFrequency and lowFrequency must be greater than 0"))
+	}
+	return errors.Join(errs...)
+}
+
+func LoadConfig() error {
+	if err := config.LoadService("device", &serviceConfig); err != nil {
+		return err
+	}
+	return kpolicy.Register(model.TWIN_INTERFACE_DEVICE, serviceConfig)
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kevent"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/keventstore"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kpolicy"
 	log "github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
 )
 
@@ -18,43 +19,40 @@ func HandleEvent(event *ktwin.TwinEvent) error {
 }
 
 func handleDeviceEvent(event *ktwin.TwinEvent) error {
-	const (
-		HighFrequency    = 15 // 15 min
-		LowFrequency     = 60 // 60 min
-		BatteryThreshold = 15 // percentage of battery available
-	)
-
 	now := clock.Now()
 	device := model.Device{}
 	err := event.ToModel(&device)
 
 	if err != nil {
 		logger.Error("Error parsing event data", err)
-		return nil
+		return err
 	}
 
 	device.DateObserved = now
 	logger.Info(fmt.Sprintf("CloudEvent: %v", string(event.CloudEvent.DataEncoded)))
 
+	policy := kpolicy.ResolveOrDefault(model.TWIN_INTERFACE_DEVICE, event.TwinInstance, serviceConfig)
 	if device.BatteryLevel != 0 {
-		if device.BatteryLevel < BatteryThreshold {
+		if device.BatteryLevel < policy.BatteryThreshold {
 			// Propagate event to real device to measure in low frequency
-			device.MeasurementFrequency = LowFrequency
+			device.MeasurementFrequency = policy.LowFrequency
 			logger.Info(fmt.Sprintf("Battery Level below threshold. Sending event to real instance: %s", event.TwinInstance))
-			err := kevent.PublishToRealTwin(event.TwinInterface, event.TwinInstance, device)
+			err := kevent.ReplyToRealTwin(event, device)
 			if err != nil {
 				return err
 			}
-		} else if device.BatteryLevel > BatteryThreshold {
+		} else if device.BatteryLevel > policy.BatteryThreshold {
 			// Propagate event to real device to measure in high frequency
-			device.MeasurementFrequency = HighFrequency
+			device.MeasurementFrequency = policy.HighFrequency
 			logger.Info(fmt.Sprintf("Battery Level above threshold. Sending event to real instance: %s", event.TwinInstance))
-			err := kevent.PublishToRealTwin(event.TwinInterface, event.TwinInstance, device)
+			err := kevent.ReplyToRealTwin(event, device)
 			if err != nil {
 				return err
 			}
 		}
-		event.SetData(device)
+		if err := event.SetData(device); err != nil {
+			return err
+		}
 		return keventstore.UpdateTwinEvent(event)
 	} else {
 		logger.Info("Battery level was not provided")
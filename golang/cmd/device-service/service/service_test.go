@@ -166,6 +166,66 @@ func (s *DeviceServiceSuite) Test_DeviceEvent() {
 			},
 			expectedError: nil,
 		},
+		{
+			name: `
+				Given TwinEvent has a negative battery level
+				Should reject the event with a validation error
+			`,
+			twinEvent: func() *ktwin.TwinEvent {
+				twinEvent := ktwin.NewTwinEvent()
+				twinEvent.EventType = ktwin.RealEvent
+				twinEvent.TwinInstance = "ngsi-ld-city-device-nb001-ofp0003-s0012"
+				twinEvent.TwinInterface = "ngsi-ld-city-device"
+
+				cloudEvent := cloudevents.NewEvent()
+				cloudEvent.SetData("application/json", []byte(`{"batteryLevel": -5}`))
+				cloudEvent.SetID("e8e126f6-62fb-40fd-a7cd-8264ca8600d0")
+				cloudEvent.SetSource("ngsi-ld-city-device-nb001-ofp0003-s0012")
+				cloudEvent.SetType("ktwin.real.ngsi-ld-city-device")
+				cloudEvent.SetTime(*dateTime)
+
+				twinEvent.CloudEvent = &cloudEvent
+				return twinEvent
+			},
+			mockExternalService: func() {},
+			expectedError: &ktwin.ValidationError{
+				TwinInterface: "ngsi-ld-city-device",
+				TwinInstance:  "ngsi-ld-city-device-nb001-ofp0003-s0012",
+				Violations: []ktwin.FieldViolation{
+					{Field: "batteryLevel", Rule: "min", Message: "batteryLevel must be greater than or equal to 0, got -5"},
+				},
+			},
+		},
+		{
+			name: `
+				Given TwinEvent has an unknown attribute
+				Should reject the event with a validation error
+			`,
+			twinEvent: func() *ktwin.TwinEvent {
+				twinEvent := ktwin.NewTwinEvent()
+				twinEvent.EventType = ktwin.RealEvent
+				twinEvent.TwinInstance = "ngsi-ld-city-device-nb001-ofp0003-s0012"
+				twinEvent.TwinInterface = "ngsi-ld-city-device"
+
+				cloudEvent := cloudevents.NewEvent()
+				cloudEvent.SetData("application/json", []byte(`{"batteryLevel": 20, "battery": 20}`))
+				cloudEvent.SetID("e8e126f6-62fb-40fd-a7cd-8264ca8600d0")
+				cloudEvent.SetSource("ngsi-ld-city-device-nb001-ofp0003-s0012")
+				cloudEvent.SetType("ktwin.real.ngsi-ld-city-device")
+				cloudEvent.SetTime(*dateTime)
+
+				twinEvent.CloudEvent = &cloudEvent
+				return twinEvent
+			},
+			mockExternalService: func() {},
+			expectedError: &ktwin.ValidationError{
+				TwinInterface: "ngsi-ld-city-device",
+				TwinInstance:  "ngsi-ld-city-device-nb001-ofp0003-s0012",
+				Violations: []ktwin.FieldViolation{
+					{Field: "battery", Rule: "unknown", Message: "battery is not a known attribute"},
+				},
+			},
+		},
 	}
 	for _, tt := range tests {
 		s.Run(tt.name, func() {
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"os"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/ktwin-city/service"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/server"
+)
+
+func main() {
+	logger := logger.NewLogger()
+	if _, err := config.Load(os.Args[1:]); err != nil {
+		logger.Fatal("Error loading config", err)
+	}
+	if err := service.LoadServiceConfigs(); err != nil {
+		logger.Fatal("Error loading service config", err)
+	}
+
+	router := service.NewCityRouter()
+	server.StartServer(router.HandleEvent)
+}
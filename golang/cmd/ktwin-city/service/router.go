@@ -0,0 +1,85 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kevent"
+	log "github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/server"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+var logger = log.NewLogger()
+
+// Router dispatches twin events to the service handlers registered for the CloudEvent type.
+// Used as the ktwin Publisher, the events published for a registered type are delivered
+// in memory, while the other events (store, virtual, ...) leave the process.
+type Router struct {
+	routes  map[string][]server.HandlerEventFunc
+	pending sync.WaitGroup
+}
+
+func NewRouter() *Router {
+	return &Router{routes: map[string][]server.HandlerEventFunc{}}
+}
+
+func (r *Router) Handle(ceType string, handler server.HandlerEventFunc) {
+	r.routes[ceType] = append(r.routes[ceType], handler)
+}
+
+func (r *Router) IsRouted(ceType string) bool {
+	_, ok := r.routes[ceType]
+	return ok
+}
+
+// Dispatches the event to all handlers of its type
+func (r *Router) HandleEvent(twinEvent *ktwin.TwinEvent) error {
+	handlers, ok := r.routes[twinEvent.CloudEvent.Type()]
+	if !ok {
+		logger.Info(fmt.Sprintf("No route for event %s - Ce-Type: %s", twinEvent.CloudEvent.ID(), twinEvent.CloudEvent.Type()))
+		return nil
+	}
+
+	var errs []error
+	for _, handler := range handlers {
+		if err := handler(twinEvent); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Publishes the event in memory when its type is routed, like the broker it does not wait
+// for the event to be handled
+func (r *Router) Publish(event *cloudevents.Event) (bool, error) {
+	if !r.IsRouted(event.Type()) {
+		return false, nil
+	}
+
+	twinEvent, err := ktwin.NewTwinEventFromCloudEvent(event)
+	if err != nil {
+		return true, err
+	}
+
+	logger.Info(fmt.Sprintf("Routing event %s in memory - Ce-Type: %s - Ce-Source: %s", event.ID(), event.Type(), event.Source()))
+
+	r.pending.Add(1)
+	go func() {
+		defer r.pending.Done()
+		result := kevent.ProcessEvent(twinEvent, r.HandleEvent)
+		if result.Status != http.StatusOK {
+			logger.Error(fmt.Sprintf("Error handling event %s in memory: %s", result.ID, result.Error), nil)
+		}
+	}()
+
+	return true, nil
+}
+
+// Waits for the events published in memory to be handled
+func (r *Router) Wait() {
+	r.pending.Wait()
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"errors"
+
+	airquality "github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/air-quality-observed-service/service"
+	city "github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/city-service/service"
+	crowdflow "github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/crowd-flow-observed-service/service"
+	device "github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/device-service/service"
+	neighborhood "github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/neighborhood-service/service"
+	parking "github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/parking-service/service"
+	parkingspot "github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/parking-spot-service/service"
+	pole "github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/pole-service/service"
+	streetlight "github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/streetlight-service/service"
+	trafficflow "github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/traffic-flow-observed-service/service"
+	weather "github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/weather-observed-service/service"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+)
+
+// Registers the handler of every service for the CloudEvent types it subscribes to in the cluster
+func RegisterServices(router *Router) {
+	router.Handle("ktwin.real.ngsi-ld-city-device", device.HandleEvent)
+	router.Handle("ktwin.command.s4city-city-city.updateneighborhood", city.HandleEvent)
+	router.Handle("ktwin.command.s4city-city-neighborhood.updateairqualityindex", neighborhood.HandleEvent)
+	router.Handle("ktwin.command.s4city-city-neighborhood.updatenoiselevel", neighborhood.HandleEvent)
+	router.Handle("ktwin.command.s4city-city-neighborhood.updatetrafficflow", neighborhood.HandleEvent)
+	router.Handle("ktwin.command.s4city-city-neighborhood.updatecrowdflow", neighborhood.HandleEvent)
+	router.Handle("ktwin.command.s4city-city-neighborhood.updateparking", neighborhood.HandleEvent)
+	router.Handle("ktwin.command.s4city-city-neighborhood.updateweather", neighborhood.HandleEvent)
+	router.Handle("ktwin.command.s4city-city-neighborhood.updatepole", neighborhood.HandleEvent)
+	router.Handle("ktwin.timer.s4city-city-neighborhood.expireairquality", neighborhood.HandleEvent)
+	router.Handle("ktwin.command.ngsi-ld-city-offstreetparking.updatevehiclecount", parking.HandleEvent)
+	router.Handle("ktwin.command.ngsi-ld-city-onstreetparking.updatevehiclecount", parking.HandleEvent)
+	router.Handle("ktwin.real.ngsi-ld-city-parkingspot", parkingspot.HandleEvent)
+	router.Handle("ktwin.command.city-pole.updateairqualityindex", pole.HandleEvent)
+	router.Handle("ktwin.command.city-pole.updatenoiselevel", pole.HandleEvent)
+	router.Handle("ktwin.command.city-pole.updateweather", pole.HandleEvent)
+	router.Handle("ktwin.command.city-pole.updatecrowdflow", pole.HandleEvent)
+	router.Handle("ktwin.command.city-pole.updatetrafficflow", pole.HandleEvent)
+	router.Handle("ktwin.command.city-pole.updatestreetlight", pole.HandleEvent)
+	router.Handle("ktwin.command.city-pole.updateevchargingstation", pole.HandleEvent)
+	router.Handle("ktwin.real.ngsi-ld-city-airqualityobserved", airquality.HandleEvent)
+	router.Handle("ktwin.real.ngsi-ld-city-crowdflowobserved", crowdflow.HandleEvent)
+	router.Handle("ktwin.real.ngsi-ld-city-trafficflowobserved", trafficflow.HandleEvent)
+	router.Handle("ktwin.real.ngsi-ld-city-weatherobserved", weather.HandleEvent)
+	router.Handle("ktwin.real.ngsi-ld-city-streetlight", streetlight.HandleEvent)
+	router.Handle("ktwin.timer.ngsi-ld-city-streetlight.checkdefect", streetlight.HandleEvent)
+}
+
+// Loads the section of each service in the config
+func LoadServiceConfigs() error {
+	return errors.Join(
+		airquality.LoadConfig(),
+		city.LoadConfig(),
+		crowdflow.LoadConfig(),
+		device.LoadConfig(),
+		neighborhood.LoadConfig(),
+		parking.LoadConfig(),
+		pole.LoadConfig(),
+		streetlight.LoadConfig(),
+		trafficflow.LoadConfig(),
+	)
+}
+
+// Creates the router of all services, keeping the events between them in memory
+func NewCityRouter() *Router {
+	router := NewRouter()
+	RegisterServices(router)
+	ktwin.SetPublisher(router.Publish)
+	return router
+}
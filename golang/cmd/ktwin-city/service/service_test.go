@@ -0,0 +1,101 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestCityServiceSuite(t *testing.T) {
+	suite.Run(t, new(CityServiceSuite))
+}
+
+type CityServiceSuite struct {
+	suite.Suite
+
+	broker      *httptest.Server
+	mutex       sync.Mutex
+	brokerTypes []string
+}
+
+func (s *CityServiceSuite) SetupSuite() {
+	os.Setenv("ENV", "test")
+	config.LoadEnv()
+}
+
+func (s *CityServiceSuite) SetupTest() {
+	s.brokerTypes = nil
+	s.broker = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event, err := cloudevents.NewEventFromHTTPRequest(r)
+		s.Require().NoError(err)
+
+		s.mutex.Lock()
+		s.brokerTypes = append(s.brokerTypes, event.Type())
+		s.mutex.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	config.Get().Broker = s.broker.URL
+}
+
+func (s *CityServiceSuite) TearDownTest() {
+	ktwin.ResetPublisher()
+	s.broker.Close()
+}
+
+func (s *CityServiceSuite) Test_RegisterServices() {
+	router := NewRouter()
+	RegisterServices(router)
+
+	s.Assert().True(router.IsRouted("ktwin.real.ngsi-ld-city-airqualityobserved"))
+	s.Assert().True(router.IsRouted("ktwin.command.city-pole.updateairqualityindex"))
+	s.Assert().True(router.IsRouted("ktwin.command.city-pole.updateweather"))
+	s.Assert().True(router.IsRouted("ktwin.command.s4city-city-neighborhood.updatepole"))
+	s.Assert().True(router.IsRouted("ktwin.command.s4city-city-neighborhood.updateairqualityindex"))
+	s.Assert().True(router.IsRouted("ktwin.command.s4city-city-neighborhood.updatenoiselevel"))
+	s.Assert().True(router.IsRouted("ktwin.command.s4city-city-city.updateneighborhood"))
+	s.Assert().False(router.IsRouted("ktwin.store.ngsi-ld-city-airqualityobserved"))
+	s.Assert().False(router.IsRouted("ktwin.virtual.ngsi-ld-city-device"))
+}
+
+func (s *CityServiceSuite) Test_InMemoryRouting() {
+	var handledTypes []string
+	var handledMutex sync.Mutex
+	record := func(twinEvent *ktwin.TwinEvent) {
+		handledMutex.Lock()
+		handledTypes = append(handledTypes, twinEvent.CloudEvent.Type())
+		handledMutex.Unlock()
+	}
+
+	router := NewRouter()
+	router.Handle("ktwin.real.ngsi-ld-city-airqualityobserved", func(twinEvent *ktwin.TwinEvent) error {
+		record(twinEvent)
+		command := ktwin.BuildCloudEvent("ktwin.command.city-pole.updateairqualityindex", "city-pole-nb001-p00007", map[string]int{"airQualityIndex": 2})
+		if err := ktwin.PostCloudEvent(command, ktwin.GetBrokerURL()); err != nil {
+			return err
+		}
+		store := ktwin.BuildCloudEvent("ktwin.store.ngsi-ld-city-airqualityobserved", twinEvent.TwinInstance, map[string]int{"no2Density": 10})
+		return ktwin.PostCloudEvent(store, ktwin.GetBrokerURL())
+	})
+	router.Handle("ktwin.command.city-pole.updateairqualityindex", func(twinEvent *ktwin.TwinEvent) error {
+		record(twinEvent)
+		virtual := ktwin.BuildCloudEvent("ktwin.virtual.city-pole", twinEvent.TwinInstance, map[string]int{"airQualityIndex": 2})
+		return ktwin.PostCloudEvent(virtual, ktwin.GetBrokerURL())
+	})
+	ktwin.SetPublisher(router.Publish)
+
+	twinEvent := ktwin.NewTwinEvent()
+	s.Require().NoError(twinEvent.SetEvent("ngsi-ld-city-airqualityobserved", "ngsi-ld-city-airqualityobserved-nb001", ktwin.RealEvent, map[string]int{"no2Density": 10}))
+
+	s.Require().NoError(router.HandleEvent(twinEvent))
+	router.Wait()
+
+	s.Assert().Equal([]string{"ktwin.real.ngsi-ld-city-airqualityobserved", "ktwin.command.city-pole.updateairqualityindex"}, handledTypes)
+	s.Assert().ElementsMatch([]string{"ktwin.store.ngsi-ld-city-airqualityobserved", "ktwin.virtual.city-pole"}, s.brokerTypes)
+}
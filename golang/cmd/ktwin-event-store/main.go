@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/ktwin-event-store/service"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
+)
+
+// Settings of the event store, the services.ktwin-event-store section of the config
+type settings struct {
+	Addr string `yaml:"addr" env:"KTWIN_EVENT_STORE_ADDR"`
+	DB   string `yaml:"db" env:"KTWIN_EVENT_STORE_DB" path:"true"`
+}
+
+func main() {
+	logger := logger.NewLogger()
+	if _, err := config.Load(os.Args[1:]); err != nil {
+		logger.Fatal("Error loading config", err)
+	}
+
+	eventStoreSettings := settings{Addr: ":8082", DB: "ktwin-event-store.db"}
+	if err := config.LoadService("ktwin-event-store", &eventStoreSettings); err != nil {
+		logger.Fatal("Error loading event store config", err)
+	}
+
+	store, err := service.OpenEventStore(eventStoreSettings.DB)
+	if err != nil {
+		logger.Fatal("Error opening event store database", err)
+	}
+
+	logger.Info("Starting up event store...")
+	err = http.ListenAndServe(eventStoreSettings.Addr, service.NewHandler(store))
+	// Fatal exits without running deferred calls
+	store.Close()
+	logger.Fatal("Server error", err)
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	log "github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+)
+
+const twinEventsPath = "/api/v1/twin-events/"
+
+var logger = log.NewLogger()
+
+// Serves the twin-events API of the event store:
+//
+//	GET  /api/v1/twin-events/{interface}/{instance}/latest returns the latest event of the instance
+//	POST /                                                  stores a ktwin.store.<interface> event
+func NewHandler(store *EventStore) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(twinEventsPath, func(w http.ResponseWriter, r *http.Request) {
+		handleGetLatestTwinEvent(store, w, r)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		handleStoreTwinEvent(store, w, r)
+	})
+	return mux
+}
+
+func handleGetLatestTwinEvent(store *EventStore, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, twinEventsPath), "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] != "latest" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	event, err := store.Latest(parts[0], parts[1])
+	if err != nil {
+		logger.Error("Error reading twin event", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if event == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	err = cehttp.WriteResponseWriter(context.Background(), binding.ToMessage(event), http.StatusOK, w)
+	if err != nil {
+		logger.Error("Error writing twin event", err)
+	}
+}
+
+func handleStoreTwinEvent(store *EventStore, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	twinEvent := ktwin.NewTwinEvent()
+	if err := twinEvent.HandleRequest(r); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("Invalid cloud event request: " + err.Error()))
+		return
+	}
+
+	if twinEvent.EventType != ktwin.StoreEvent {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("Invalid cloud event request: expected a ktwin.store event, got %s", twinEvent.CloudEvent.Type())))
+		return
+	}
+
+	if err := store.Save(twinEvent.TwinInterface, twinEvent.TwinInstance, twinEvent.CloudEvent); err != nil {
+		logger.Error("Error storing twin event", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info(fmt.Sprintf("Stored twin event of %s", twinEvent.TwinInstance))
+	w.WriteHeader(http.StatusAccepted)
+}
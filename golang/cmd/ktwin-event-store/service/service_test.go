@@ -0,0 +1,101 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/keventstore"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestEventStoreSuite(t *testing.T) {
+	suite.Run(t, new(EventStoreSuite))
+}
+
+type EventStoreSuite struct {
+	suite.Suite
+
+	store  *EventStore
+	server *httptest.Server
+}
+
+func (s *EventStoreSuite) SetupTest() {
+	store, err := OpenEventStore(filepath.Join(s.T().TempDir(), "ktwin-event-store.db"))
+	s.Require().NoError(err)
+
+	s.store = store
+	s.server = httptest.NewServer(NewHandler(store))
+
+	// Services running locally with the http sink send their store events to the event store
+	config.Set(&config.Config{Env: config.EnvLocal, EventStore: s.server.URL})
+	ktwin.SetSink(&ktwin.HTTPSink{DirectStore: true})
+}
+
+func (s *EventStoreSuite) TearDownTest() {
+	s.server.Close()
+	s.store.Close()
+	config.Reset()
+	ktwin.ResetSink()
+}
+
+func (s *EventStoreSuite) buildTwinEvent(twinInstance string, batteryLevel int) *ktwin.TwinEvent {
+	twinEvent := ktwin.NewTwinEvent()
+	s.Require().NoError(twinEvent.SetEvent("ngsi-ld-city-device", twinInstance, ktwin.RealEvent, map[string]int{"batteryLevel": batteryLevel}))
+	return twinEvent
+}
+
+func (s *EventStoreSuite) Test_UpdateAndGetLatestTwinEvent() {
+	s.Require().NoError(keventstore.UpdateTwinEvent(s.buildTwinEvent("ngsi-ld-city-device-nb001-ofp0003-s0012", 20)))
+	s.Require().NoError(keventstore.UpdateTwinEvent(s.buildTwinEvent("ngsi-ld-city-device-nb001-ofp0003-s0012", 60)))
+	s.Require().NoError(keventstore.UpdateTwinEvent(s.buildTwinEvent("ngsi-ld-city-device-nb001-ofp0003-s0013", 10)))
+
+	twinEvent, err := keventstore.GetLatestTwinEvent("ngsi-ld-city-device", "ngsi-ld-city-device-nb001-ofp0003-s0012")
+	s.Require().NoError(err)
+	s.Require().NotNil(twinEvent)
+
+	s.Assert().Equal(ktwin.StoreEvent, twinEvent.EventType)
+	s.Assert().Equal("ngsi-ld-city-device-nb001-ofp0003-s0012", twinEvent.TwinInstance)
+	s.Assert().JSONEq(`{"batteryLevel":60}`, string(twinEvent.CloudEvent.Data()))
+}
+
+func (s *EventStoreSuite) Test_GetLatestTwinEventNotFound() {
+	twinEvent, err := keventstore.GetLatestTwinEvent("ngsi-ld-city-device", "ngsi-ld-city-device-nb001-ofp0003-s0012")
+	s.Assert().NoError(err)
+	s.Assert().Nil(twinEvent)
+}
+
+func (s *EventStoreSuite) Test_RejectNonStoreEvent() {
+	cloudEvent := ktwin.BuildCloudEvent("ktwin.real.ngsi-ld-city-device", "ngsi-ld-city-device-nb001-ofp0003-s0012", map[string]int{"batteryLevel": 20})
+	err := ktwin.SendCloudEvent(cloudEvent, s.server.URL)
+	s.Assert().ErrorContains(err, "status code: 400")
+
+	response, err := http.Get(s.server.URL + "/api/v1/twin-events/ngsi-ld-city-device/ngsi-ld-city-device-nb001-ofp0003-s0012/latest")
+	s.Require().NoError(err)
+	s.Assert().Equal(http.StatusNotFound, response.StatusCode)
+}
+
+func (s *EventStoreSuite) Test_StorePersistsAcrossReopen() {
+	path := filepath.Join(s.T().TempDir(), "reopen.db")
+	store, err := OpenEventStore(path)
+	s.Require().NoError(err)
+
+	event := cloudevents.NewEvent()
+	event.SetID("1")
+	event.SetType("ktwin.store.ngsi-ld-city-device")
+	event.SetSource("ngsi-ld-city-device-nb001-ofp0003-s0012")
+	s.Require().NoError(store.Save("ngsi-ld-city-device", "ngsi-ld-city-device-nb001-ofp0003-s0012", &event))
+	s.Require().NoError(store.Close())
+
+	store, err = OpenEventStore(path)
+	s.Require().NoError(err)
+	defer store.Close()
+
+	latest, err := store.Latest("ngsi-ld-city-device", "ngsi-ld-city-device-nb001-ofp0003-s0012")
+	s.Require().NoError(err)
+	s.Assert().Equal("1", latest.ID())
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"encoding/json"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	bolt "go.etcd.io/bbolt"
+)
+
+// EventStore keeps the latest twin event of each twin instance in a bbolt database,
+// with a bucket per twin interface keyed by twin instance
+type EventStore struct {
+	db *bolt.DB
+}
+
+func OpenEventStore(path string) (*EventStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	return &EventStore{db: db}, nil
+}
+
+func (s *EventStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *EventStore) Save(twinInterface, twinInstance string, event *cloudevents.Event) error {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(twinInterface))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(twinInstance), value)
+	})
+}
+
+// Gets the latest event of the twin instance, nil if no event was stored
+func (s *EventStore) Latest(twinInterface, twinInstance string) (*cloudevents.Event, error) {
+	var event *cloudevents.Event
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(twinInterface))
+		if bucket == nil {
+			return nil
+		}
+
+		value := bucket.Get([]byte(twinInstance))
+		if value == nil {
+			return nil
+		}
+
+		event = &cloudevents.Event{}
+		return json.Unmarshal(value, event)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
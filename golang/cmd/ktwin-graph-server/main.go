@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/ktwin-graph-server/service"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/ktwingraph"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
+)
+
+// Settings of the graph server, the services.ktwin-graph-server section of the config
+type settings struct {
+	Addr string `yaml:"addr" env:"KTWIN_GRAPH_SERVER_ADDR"`
+}
+
+func main() {
+	logger := logger.NewLogger()
+	ktwinConfig, err := config.Load(os.Args[1:])
+	if err != nil {
+		logger.Fatal("Error loading config", err)
+	}
+
+	graphServerSettings := settings{Addr: ":8083"}
+	if err := config.LoadService("ktwin-graph-server", &graphServerSettings); err != nil {
+		logger.Fatal("Error loading graph server config", err)
+	}
+
+	graphFile := ktwinConfig.Graph.File
+	if graphFile == "" {
+		graphFile = "twin-graph.txt"
+	}
+
+	twinGraph, err := ktwingraph.LoadTwinGraphFile(graphFile)
+	if err != nil {
+		logger.Fatal("Error loading twin graph", err)
+	}
+
+	logger.Info(fmt.Sprintf("Loaded %d twin instances from %s", len(twinGraph.TwinInstancesGraph), graphFile))
+	logger.Info("Starting up graph server...")
+	logger.Fatal("Server error", http.ListenAndServe(graphServerSettings.Addr, service.NewHandler(service.NewGraphIndex(*twinGraph))))
+}
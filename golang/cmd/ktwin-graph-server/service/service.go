@@ -0,0 +1,105 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/ktwingraph"
+	log "github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
+)
+
+const (
+	twinGraphPath     = "/api/v1/twin-graph/"
+	twinInstancesPath = "/api/v1/twin-instances/"
+)
+
+var logger = log.NewLogger()
+
+// GraphIndex indexes the twin graph by interface and instance
+type GraphIndex struct {
+	byInterface map[string][]ktwin.TwinInstanceGraph
+	byInstance  map[string]ktwin.TwinInstanceGraph
+}
+
+func NewGraphIndex(twinGraph ktwin.TwinGraph) *GraphIndex {
+	index := &GraphIndex{
+		byInterface: map[string][]ktwin.TwinInstanceGraph{},
+		byInstance:  map[string]ktwin.TwinInstanceGraph{},
+	}
+
+	for _, instance := range twinGraph.TwinInstancesGraph {
+		index.byInterface[instance.Interface] = append(index.byInterface[instance.Interface], instance)
+		index.byInstance[instance.Name] = instance
+	}
+
+	return index
+}
+
+// Serves the twin graph API:
+//
+//	GET /api/v1/twin-graph/{interface}                                   graph of the interface instances
+//	GET /api/v1/twin-instances/{instance}                                graph node of the instance
+//	GET /api/v1/twin-instances/{instance}/relationships/{relationship}   relationship of the instance
+func NewHandler(index *GraphIndex) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(twinGraphPath, func(w http.ResponseWriter, r *http.Request) {
+		handleGetTwinGraph(index, w, r)
+	})
+	mux.HandleFunc(twinInstancesPath, func(w http.ResponseWriter, r *http.Request) {
+		handleGetTwinInstance(index, w, r)
+	})
+	return mux
+}
+
+func handleGetTwinGraph(index *GraphIndex, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	twinInterface := strings.TrimPrefix(r.URL.Path, twinGraphPath)
+	instances, ok := index.byInterface[twinInterface]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, ktwin.TwinGraph{TwinInstancesGraph: instances})
+}
+
+func handleGetTwinInstance(index *GraphIndex, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, twinInstancesPath), "/")
+	instance, ok := index.byInstance[parts[0]]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case len(parts) == 1:
+		writeJSON(w, instance)
+	case len(parts) == 3 && parts[1] == "relationships":
+		relationship := ktwingraph.GetRelationshipFromGraph(instance.Name, parts[2], ktwin.TwinGraph{TwinInstancesGraph: []ktwin.TwinInstanceGraph{instance}})
+		if relationship == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeJSON(w, relationship)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		logger.Error("Error writing response", err)
+	}
+}
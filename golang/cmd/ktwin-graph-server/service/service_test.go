@@ -0,0 +1,89 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/ktwingraph"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestGraphServerSuite(t *testing.T) {
+	suite.Run(t, new(GraphServerSuite))
+}
+
+type GraphServerSuite struct {
+	suite.Suite
+
+	server *httptest.Server
+}
+
+func (s *GraphServerSuite) SetupSuite() {
+	os.Setenv("ENV", "test")
+	config.LoadEnv()
+
+	twinGraph, err := ktwingraph.LoadTwinGraphFile(os.Getenv("KTWIN_GRAPH_FILE"))
+	s.Require().NoError(err)
+
+	s.server = httptest.NewServer(NewHandler(NewGraphIndex(*twinGraph)))
+}
+
+func (s *GraphServerSuite) TearDownSuite() {
+	s.server.Close()
+}
+
+func (s *GraphServerSuite) getJSON(path string, value interface{}) int {
+	response, err := http.Get(s.server.URL + path)
+	s.Require().NoError(err)
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusOK {
+		s.Require().NoError(json.NewDecoder(response.Body).Decode(value))
+	}
+	return response.StatusCode
+}
+
+func (s *GraphServerSuite) Test_GetTwinGraphByInterface() {
+	var twinGraph ktwin.TwinGraph
+	s.Assert().Equal(http.StatusOK, s.getJSON("/api/v1/twin-graph/ngsi-ld-city-device", &twinGraph))
+	s.Assert().NotEmpty(twinGraph.TwinInstancesGraph)
+	for _, instance := range twinGraph.TwinInstancesGraph {
+		s.Assert().Equal("ngsi-ld-city-device", instance.Interface)
+	}
+
+	s.Assert().Equal(http.StatusNotFound, s.getJSON("/api/v1/twin-graph/unknown-interface", &twinGraph))
+}
+
+func (s *GraphServerSuite) Test_GetTwinInstance() {
+	var instance ktwin.TwinInstanceGraph
+	s.Assert().Equal(http.StatusOK, s.getJSON("/api/v1/twin-instances/ngsi-ld-city-device-nb001-ofp0003-s0012", &instance))
+	s.Assert().Equal("ngsi-ld-city-device", instance.Interface)
+
+	var relationship ktwin.TwinInstanceReference
+	s.Assert().Equal(http.StatusOK, s.getJSON("/api/v1/twin-instances/ngsi-ld-city-device-nb001-ofp0003-s0012/relationships/refDeviceModel", &relationship))
+	s.Assert().Equal(ktwin.TwinInstanceReference{
+		Name:      "refDeviceModel",
+		Interface: "ngsi-ld-city-devicemodel",
+		Instance:  "ngsi-ld-city-devicemodel-nb001-ofp0003-s0012",
+	}, relationship)
+
+	s.Assert().Equal(http.StatusNotFound, s.getJSON("/api/v1/twin-instances/ngsi-ld-city-device-nb001-ofp0003-s0012/relationships/unknown", &relationship))
+	s.Assert().Equal(http.StatusNotFound, s.getJSON("/api/v1/twin-instances/unknown-instance", &instance))
+}
+
+func (s *GraphServerSuite) Test_LoadTwinGraphFromServer() {
+	config.Set(&config.Config{Env: config.EnvLocal, Graph: config.GraphConfig{URL: s.server.URL + "/api/v1/twin-graph"}})
+	defer config.Reset()
+
+	twinGraph, err := ktwingraph.LoadTwinGraphByInterfaces([]string{"ngsi-ld-city-device", "unknown-interface"})
+	s.Require().NoError(err)
+
+	expectedGraph, err := ktwingraph.LoadTwinGraphFile(os.Getenv("KTWIN_GRAPH_FILE"))
+	s.Require().NoError(err)
+	s.Assert().Equal(ktwingraph.FilterTwinGraphByInterface(*expectedGraph, "ngsi-ld-city-device").TwinInstancesGraph, twinGraph.TwinInstancesGraph)
+}
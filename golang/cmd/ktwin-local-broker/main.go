@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/ktwin-local-broker/service"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
+)
+
+// Settings of the local broker, the services.ktwin-local-broker section of the config
+type settings struct {
+	Addr     string `yaml:"addr" env:"KTWIN_BROKER_ADDR"`
+	Triggers string `yaml:"triggers" env:"KTWIN_BROKER_CONFIG" path:"true"`
+}
+
+func main() {
+	logger := logger.NewLogger()
+	if _, err := config.Load(os.Args[1:]); err != nil {
+		logger.Fatal("Error loading config", err)
+	}
+
+	brokerSettings := settings{Addr: ":8081", Triggers: "triggers.yaml"}
+	if err := config.LoadService("ktwin-local-broker", &brokerSettings); err != nil {
+		logger.Fatal("Error loading local broker config", err)
+	}
+
+	brokerConfig, err := service.LoadConfig(brokerSettings.Triggers)
+	if err != nil {
+		logger.Fatal("Error loading broker config", err)
+	}
+
+	logger.Info(fmt.Sprintf("Loaded %d triggers from %s", len(brokerConfig.Triggers), brokerSettings.Triggers))
+	logger.Info("Starting up local broker...")
+	logger.Fatal("Server error", http.ListenAndServe(brokerSettings.Addr, service.NewBroker(brokerConfig)))
+}
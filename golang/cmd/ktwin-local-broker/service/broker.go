@@ -0,0 +1,116 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	log "github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+var logger = log.NewLogger()
+
+// Broker accepts CloudEvents and delivers them asynchronously to the subscribers
+// of the matching triggers, logging every hop
+type Broker struct {
+	triggers   []Trigger
+	deliveries sync.WaitGroup
+}
+
+func NewBroker(config *Config) *Broker {
+	return &Broker{triggers: config.Triggers}
+}
+
+func (b *Broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	events, err := readCloudEvents(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("Invalid cloud event request: " + err.Error()))
+		return
+	}
+
+	for _, event := range events {
+		b.Publish(event)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func readCloudEvents(r *http.Request) ([]*cloudevents.Event, error) {
+	if !ktwin.IsBatchRequest(r) {
+		event, err := cloudevents.NewEventFromHTTPRequest(r)
+		if err != nil {
+			return nil, err
+		}
+		return []*cloudevents.Event{event}, nil
+	}
+
+	twinEvents, errs, err := ktwin.HandleBatchRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []*cloudevents.Event
+	for i, twinEvent := range twinEvents {
+		if errs[i] != nil {
+			logger.Error(fmt.Sprintf("Dropping event %d of batch", i), errs[i])
+			continue
+		}
+		events = append(events, twinEvent.CloudEvent)
+	}
+	return events, nil
+}
+
+// Delivers the event to the subscribers of all matching triggers
+func (b *Broker) Publish(event *cloudevents.Event) {
+	matched := false
+	for _, trigger := range b.triggers {
+		if !trigger.Filter.Matches(event) {
+			continue
+		}
+
+		matched = true
+		b.deliveries.Add(1)
+		go func(trigger Trigger) {
+			defer b.deliveries.Done()
+			b.deliver(trigger, event)
+		}(trigger)
+	}
+
+	if !matched {
+		logger.Info(fmt.Sprintf("No trigger for event %s - Ce-Type: %s - Ce-Source: %s", event.ID(), event.Type(), event.Source()))
+	}
+}
+
+func (b *Broker) deliver(trigger Trigger, event *cloudevents.Event) {
+	hop := fmt.Sprintf("event %s - Ce-Type: %s - Ce-Source: %s -> %s (%s)", event.ID(), event.Type(), event.Source(), trigger.Name, trigger.Subscriber.URI)
+
+	client := ktwin.NewClient()
+	response, err := client.Post(trigger.Subscriber.URI, event)
+	if err != nil {
+		logger.Error("Error delivering "+hop, err)
+		return
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		body, _ := io.ReadAll(response.Body)
+		logger.Error(fmt.Sprintf("Error delivering %s: status code %d: %s", hop, response.StatusCode, string(body)), nil)
+		return
+	}
+
+	logger.Info(fmt.Sprintf("Delivered %s: status code %d", hop, response.StatusCode))
+}
+
+// Waits for the pending deliveries
+func (b *Broker) Wait() {
+	b.deliveries.Wait()
+}
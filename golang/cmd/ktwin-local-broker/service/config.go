@@ -0,0 +1,121 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Broker configuration, modelled after the Knative Trigger spec:
+//
+//	triggers:
+//	  - name: device-service
+//	    filter:
+//	      attributes:
+//	        type: ktwin.real.ngsi-ld-city-device
+//	    subscriber:
+//	      uri: http://localhost:8090
+//
+// Events are delivered to every trigger whose filter matches. The filter attributes must
+// match exactly and the prefix attributes must start with the given value. A trigger
+// without filter receives all events.
+type Config struct {
+	Triggers []Trigger `yaml:"triggers"`
+}
+
+type Trigger struct {
+	Name       string     `yaml:"name"`
+	Filter     Filter     `yaml:"filter"`
+	Subscriber Subscriber `yaml:"subscriber"`
+}
+
+type Filter struct {
+	Attributes map[string]string `yaml:"attributes"`
+	Prefix     map[string]string `yaml:"prefix"`
+}
+
+type Subscriber struct {
+	URI string `yaml:"uri"`
+}
+
+func LoadConfig(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("error parsing broker config %s: %w", path, err)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid broker config %s: %w", path, err)
+	}
+
+	return &config, nil
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+	names := map[string]bool{}
+
+	for i, trigger := range c.Triggers {
+		if trigger.Name == "" {
+			errs = append(errs, fmt.Errorf("trigger %d: name is required", i))
+		} else if names[trigger.Name] {
+			errs = append(errs, fmt.Errorf("trigger %s: duplicated name", trigger.Name))
+		}
+		names[trigger.Name] = true
+
+		if trigger.Subscriber.URI == "" {
+			errs = append(errs, fmt.Errorf("trigger %s: subscriber uri is required", trigger.Name))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (f *Filter) Matches(event *cloudevents.Event) bool {
+	for attribute, value := range f.Attributes {
+		if eventAttribute(event, attribute) != value {
+			return false
+		}
+	}
+
+	for attribute, prefix := range f.Prefix {
+		if !strings.HasPrefix(eventAttribute(event, attribute), prefix) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func eventAttribute(event *cloudevents.Event, attribute string) string {
+	switch attribute {
+	case "id":
+		return event.ID()
+	case "type":
+		return event.Type()
+	case "source":
+		return event.Source()
+	case "subject":
+		return event.Subject()
+	case "datacontenttype":
+		return event.DataContentType()
+	case "dataschema":
+		return event.DataSchema()
+	case "specversion":
+		return event.SpecVersion()
+	}
+
+	if value, ok := event.Extensions()[attribute]; ok {
+		return fmt.Sprintf("%v", value)
+	}
+	return ""
+}
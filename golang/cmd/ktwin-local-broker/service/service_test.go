@@ -0,0 +1,90 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestLocalBrokerSuite(t *testing.T) {
+	suite.Run(t, new(LocalBrokerSuite))
+}
+
+type LocalBrokerSuite struct {
+	suite.Suite
+
+	mutex    sync.Mutex
+	received map[string][]string
+}
+
+func (s *LocalBrokerSuite) SetupTest() {
+	s.received = map[string][]string{}
+}
+
+func (s *LocalBrokerSuite) subscriber(name string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event, err := cloudevents.NewEventFromHTTPRequest(r)
+		s.Require().NoError(err)
+
+		s.mutex.Lock()
+		s.received[name] = append(s.received[name], event.Type())
+		s.mutex.Unlock()
+	}))
+}
+
+func (s *LocalBrokerSuite) Test_LoadConfig() {
+	os.Setenv("ENV", "test")
+	config.LoadEnv()
+
+	brokerConfig, err := LoadConfig(os.Getenv("KTWIN_BROKER_CONFIG"))
+	s.Require().NoError(err)
+	s.Assert().NotEmpty(brokerConfig.Triggers)
+
+	invalidConfig := Config{Triggers: []Trigger{{Name: "pole-service"}, {Name: "pole-service", Subscriber: Subscriber{URI: "http://localhost:8094"}}}}
+	s.Assert().ErrorContains(invalidConfig.Validate(), "trigger pole-service: subscriber uri is required")
+	s.Assert().ErrorContains(invalidConfig.Validate(), "trigger pole-service: duplicated name")
+}
+
+func (s *LocalBrokerSuite) Test_RouteEvents() {
+	poleService := s.subscriber("pole-service")
+	defer poleService.Close()
+	auditService := s.subscriber("audit-service")
+	defer auditService.Close()
+
+	broker := NewBroker(&Config{Triggers: []Trigger{
+		{
+			Name:       "pole-service",
+			Filter:     Filter{Attributes: map[string]string{"type": "ktwin.command.city-pole.updateairqualityindex"}},
+			Subscriber: Subscriber{URI: poleService.URL},
+		},
+		{
+			Name:       "audit-service",
+			Filter:     Filter{Prefix: map[string]string{"type": "ktwin.real."}},
+			Subscriber: Subscriber{URI: auditService.URL},
+		},
+	}})
+	server := httptest.NewServer(broker)
+	defer server.Close()
+
+	events := []*cloudevents.Event{
+		ktwin.BuildCloudEvent("ktwin.command.city-pole.updateairqualityindex", "city-pole-nb001-p00007", map[string]int{"airQualityIndex": 2}),
+		ktwin.BuildCloudEvent("ktwin.real.ngsi-ld-city-device", "ngsi-ld-city-device-nb001-ofp0003-s0012", map[string]int{"batteryLevel": 20}),
+		ktwin.BuildCloudEvent("ktwin.virtual.ngsi-ld-city-device", "ngsi-ld-city-device-nb001-ofp0003-s0012", map[string]int{"measurementFrequency": 15}),
+	}
+	for _, event := range events {
+		s.Require().NoError(ktwin.SendCloudEvent(event, server.URL))
+	}
+	broker.Wait()
+
+	s.Assert().Equal(map[string][]string{
+		"pole-service":  {"ktwin.command.city-pole.updateairqualityindex"},
+		"audit-service": {"ktwin.real.ngsi-ld-city-device"},
+	}, s.received)
+}
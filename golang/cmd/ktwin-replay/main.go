@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	city "github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/ktwin-city/service"
+	eventstore "github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/ktwin-event-store/service"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/ktwin-replay/service"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/recording"
+)
+
+func main() {
+	os.Exit(run())
+}
+
+// Replays the recording and returns the exit code, 1 when the outbound events differ from the baseline
+func run() int {
+	logger := logger.NewLogger()
+
+	// The flags of the command are not config flags, the config comes from the env and KTWIN_CONFIG
+	ktwinConfig, err := config.Load(nil)
+	if err != nil {
+		logger.Fatal("Error loading config", err)
+	}
+
+	recordingFile := flag.String("recording", "recording.ndjson", "recording file, its rotated files are replayed first")
+	target := flag.String("target", "", "url of the service the records are replayed to, all services run in process when empty")
+	speed := flag.Float64("speed", 1, "replay speed, 1 for the original speed, 0 to replay as fast as possible")
+	recordedClock := flag.Bool("clock", false, "drive the clock with the recorded times, only in process")
+	captureAddr := flag.String("capture", ":8084", "address capturing the outbound events of the target, to set as its broker")
+	settle := flag.Duration("settle", 2*time.Second, "time waiting for the outbound events of the target after the replay")
+	output := flag.String("output", "", "file the outbound events are written to")
+	baseline := flag.String("baseline", "", "recording of outbound events the replay is compared to")
+	flag.Parse()
+
+	if *recordedClock && *target != "" {
+		logger.Fatal("The recorded clock is only available in process", nil)
+	}
+
+	records, err := recording.ReadRecording(*recordingFile)
+	if err != nil {
+		logger.Fatal("Error reading recording", err)
+	}
+
+	replayer := service.Replayer{Speed: *speed, RecordedClock: *recordedClock}
+	var capture *service.Capture
+	var wait func()
+
+	if *target != "" {
+		capture = service.NewCapture(nil)
+		go func() {
+			logger.Fatal("Capture server error", http.ListenAndServe(*captureAddr, capture))
+		}()
+		replayer.Deliver = service.DeliverHTTP(*target)
+		wait = func() { time.Sleep(*settle) }
+	} else {
+		// The services read back the events they store, so outbound events are kept in a
+		// temporary event store behind the capture
+		dir, err := os.MkdirTemp("", "ktwin-replay")
+		if err != nil {
+			logger.Fatal("Error creating event store directory", err)
+		}
+		defer os.RemoveAll(dir)
+
+		store, err := eventstore.OpenEventStore(filepath.Join(dir, "ktwin-event-store.db"))
+		if err != nil {
+			logger.Fatal("Error opening event store", err)
+		}
+		defer store.Close()
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			logger.Fatal("Error starting capture server", err)
+		}
+		capture = service.NewCapture(eventstore.NewHandler(store))
+		go http.Serve(listener, capture)
+
+		captureURL := "http://" + listener.Addr().String()
+		ktwinConfig.Broker = captureURL
+		ktwinConfig.EventStore = captureURL
+		ktwin.SetSink(&ktwin.HTTPSink{})
+
+		router := city.NewCityRouter()
+		defer ktwin.ResetPublisher()
+		replayer.Deliver = service.DeliverInProcess(router.HandleEvent, router.Wait)
+		wait = router.Wait
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	logger.Info(fmt.Sprintf("Replaying %d records of %s", len(records), *recordingFile))
+	fmt.Print(replayer.Run(ctx, records))
+	wait()
+
+	outbound := capture.Records()
+	fmt.Printf("outbound: %d requests\n", len(outbound))
+
+	if *output != "" {
+		if err := recording.WriteFile(*output, outbound); err != nil {
+			logger.Fatal("Error writing outbound events", err)
+		}
+	}
+
+	if *baseline != "" {
+		baselineRecords, err := recording.ReadRecording(*baseline)
+		if err != nil {
+			logger.Fatal("Error reading baseline", err)
+		}
+
+		result, err := service.Diff(baselineRecords, outbound)
+		if err != nil {
+			logger.Fatal("Error comparing outbound events", err)
+		}
+
+		fmt.Print(result)
+		if !result.Equal() {
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/recording"
+)
+
+// Capture records the outbound events posted by the replayed service, standing for the broker
+// and the event store. Reads and store events are forwarded to the next handler when it is set,
+// so the service can read back the events it stored. Other posts are accepted and reads not found.
+type Capture struct {
+	next http.Handler
+
+	mutex   sync.Mutex
+	records []recording.Record
+}
+
+func NewCapture(next http.Handler) *Capture {
+	return &Capture{next: next}
+}
+
+func (c *Capture) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	forward := c.next != nil
+	if r.Method == http.MethodPost {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		record := recording.NewRecord(r, body)
+		c.mutex.Lock()
+		c.records = append(c.records, record)
+		c.mutex.Unlock()
+
+		forward = forward && isStoreRecord(&record)
+	}
+
+	if forward {
+		c.next.ServeHTTP(w, r)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		w.WriteHeader(http.StatusAccepted)
+	} else {
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// Gets the outbound requests captured so far
+func (c *Capture) Records() []recording.Record {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]recording.Record(nil), c.records...)
+}
+
+func isStoreRecord(record *recording.Record) bool {
+	events, err := record.CloudEvents()
+	if err != nil || len(events) == 0 {
+		return false
+	}
+
+	for _, event := range events {
+		twinEventType, err := ktwin.ParseEventType(event.Type())
+		if err != nil || twinEventType.EventType != ktwin.StoreEvent {
+			return false
+		}
+	}
+	return true
+}
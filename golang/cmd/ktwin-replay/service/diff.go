@@ -0,0 +1,106 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/recording"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// DiffResult lists the outbound events of the baseline that were not produced by the replay,
+// and the ones produced that are not in the baseline
+type DiffResult struct {
+	Missing    []string
+	Unexpected []string
+}
+
+func (d DiffResult) Equal() bool {
+	return len(d.Missing) == 0 && len(d.Unexpected) == 0
+}
+
+func (d DiffResult) String() string {
+	if d.Equal() {
+		return "outbound events match the baseline\n"
+	}
+
+	var builder strings.Builder
+	for _, event := range d.Missing {
+		fmt.Fprintf(&builder, "- %s\n", event)
+	}
+	for _, event := range d.Unexpected {
+		fmt.Fprintf(&builder, "+ %s\n", event)
+	}
+	fmt.Fprintf(&builder, "%d missing, %d unexpected\n", len(d.Missing), len(d.Unexpected))
+	return builder.String()
+}
+
+// Compares the outbound events regardless of their order. The ids and times are generated
+// on each run, so events are compared by type, source and data.
+func Diff(baseline, actual []recording.Record) (DiffResult, error) {
+	baselineEvents, err := eventKeys(baseline)
+	if err != nil {
+		return DiffResult{}, fmt.Errorf("error reading baseline events: %w", err)
+	}
+	actualEvents, err := eventKeys(actual)
+	if err != nil {
+		return DiffResult{}, fmt.Errorf("error reading outbound events: %w", err)
+	}
+
+	counts := map[string]int{}
+	for _, key := range baselineEvents {
+		counts[key]++
+	}
+	for _, key := range actualEvents {
+		counts[key]--
+	}
+
+	var result DiffResult
+	for key, count := range counts {
+		for ; count > 0; count-- {
+			result.Missing = append(result.Missing, key)
+		}
+		for ; count < 0; count++ {
+			result.Unexpected = append(result.Unexpected, key)
+		}
+	}
+	sort.Strings(result.Missing)
+	sort.Strings(result.Unexpected)
+	return result, nil
+}
+
+func eventKeys(records []recording.Record) ([]string, error) {
+	var keys []string
+	for i := range records {
+		events, err := records[i].CloudEvents()
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range events {
+			key, err := eventKey(event)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func eventKey(event *cloudevents.Event) (string, error) {
+	data := string(event.Data())
+
+	// JSON data is re-encoded, so the order of the keys does not matter
+	var value interface{}
+	if json.Unmarshal(event.Data(), &value) == nil {
+		normalized, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		data = string(normalized)
+	}
+
+	return fmt.Sprintf("%s %s %s", event.Type(), event.Source(), data), nil
+}
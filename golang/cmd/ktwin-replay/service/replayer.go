@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/clock"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kevent"
+	log "github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/recording"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/server"
+)
+
+var logger = log.NewLogger()
+
+// DeliverFunc delivers a recorded request to the replayed service
+type DeliverFunc func(record *recording.Record) error
+
+// Replayer plays the records back in order, keeping the time between them
+type Replayer struct {
+	// Speed multiplies the original pace, 1 replays at the original speed and 0 as fast as possible
+	Speed float64
+	// Drives the clock with the time each record was received, the service must run in process
+	RecordedClock bool
+	Deliver       DeliverFunc
+}
+
+type Report struct {
+	Replayed int
+	Failed   int
+	Elapsed  time.Duration
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf("records: %d replayed, %d failed\nelapsed: %s\n", r.Replayed, r.Failed, r.Elapsed.Round(time.Millisecond))
+}
+
+func (r *Replayer) Run(ctx context.Context, records []recording.Record) Report {
+	if r.RecordedClock {
+		defer clock.ResetClockImplementation()
+	}
+
+	report := Report{}
+	start := time.Now()
+
+	for i := range records {
+		record := &records[i]
+
+		if r.Speed > 0 {
+			offset := time.Duration(float64(record.ReceivedAt.Sub(records[0].ReceivedAt)) / r.Speed)
+			select {
+			case <-ctx.Done():
+				report.Elapsed = time.Since(start)
+				return report
+			case <-time.After(time.Until(start.Add(offset))):
+			}
+		} else if ctx.Err() != nil {
+			break
+		}
+
+		if r.RecordedClock {
+			receivedAt := record.ReceivedAt
+			clock.NowFunc = func() *time.Time {
+				return &receivedAt
+			}
+		}
+
+		report.Replayed++
+		if err := r.Deliver(record); err != nil {
+			report.Failed++
+			logger.Error(fmt.Sprintf("Error replaying record %d received at %s", i, record.ReceivedAt.Format(time.RFC3339Nano)), err)
+		}
+	}
+
+	report.Elapsed = time.Since(start)
+	return report
+}
+
+// Delivers the records to a running service
+func DeliverHTTP(target string) DeliverFunc {
+	client := &http.Client{Timeout: 30 * time.Second}
+	return func(record *recording.Record) error {
+		request, err := record.ToRequest(target)
+		if err != nil {
+			return err
+		}
+
+		response, err := client.Do(request)
+		if err != nil {
+			return err
+		}
+		defer response.Body.Close()
+
+		if response.StatusCode < 200 || response.StatusCode > 299 {
+			body, _ := io.ReadAll(response.Body)
+			return fmt.Errorf("status code: %d. response body: %s", response.StatusCode, strings.TrimSpace(string(body)))
+		}
+		return nil
+	}
+}
+
+// Delivers the records to a handler in process. The wait function is called after each
+// record, so the events it published are handled before the clock moves to the next one.
+func DeliverInProcess(handler server.HandlerEventFunc, wait func()) DeliverFunc {
+	return func(record *recording.Record) error {
+		events, err := record.CloudEvents()
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			twinEvent, err := ktwin.NewTwinEventFromCloudEvent(event)
+			if err != nil {
+				return err
+			}
+
+			result := kevent.ProcessEvent(twinEvent, handler)
+			if wait != nil {
+				wait()
+			}
+			if result.Status != http.StatusOK {
+				return fmt.Errorf("event %s: %s", result.ID, result.Error)
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	city "github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/ktwin-city/service"
+	eventstore "github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/ktwin-event-store/service"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/clock"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/recording"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestReplaySuite(t *testing.T) {
+	suite.Run(t, new(ReplaySuite))
+}
+
+type ReplaySuite struct {
+	suite.Suite
+
+	start time.Time
+}
+
+func (s *ReplaySuite) SetupSuite() {
+	os.Setenv("ENV", "test")
+	config.LoadEnv()
+	s.start = time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+}
+
+func (s *ReplaySuite) TearDownTest() {
+	ktwin.ResetPublisher()
+	clock.ResetClockImplementation()
+}
+
+// Records the event as the server would receive it at the given time
+func (s *ReplaySuite) record(receivedAt time.Time, ceType, source string, data interface{}) recording.Record {
+	event := ktwin.BuildCloudEvent(ceType, source, data)
+	request := httptest.NewRequest(http.MethodPost, "http://service/", nil)
+	s.Require().NoError(cehttp.WriteRequest(context.Background(), binding.ToMessage(event), request))
+
+	clock.NowFunc = func() *time.Time { return &receivedAt }
+	defer clock.ResetClockImplementation()
+
+	content, err := io.ReadAll(request.Body)
+	s.Require().NoError(err)
+	return recording.NewRecord(request, content)
+}
+
+func (s *ReplaySuite) airQualityRecords() []recording.Record {
+	return []recording.Record{
+		s.record(s.start, "ktwin.real.ngsi-ld-city-airqualityobserved", "ngsi-ld-city-airqualityobserved-nb001-p00007", map[string]float64{"CODensity": 1, "PM10Density": 300, "PM25Density": 10, "SO2Density": 10, "O3Density": 10}),
+		s.record(s.start.Add(time.Minute), "ktwin.real.ngsi-ld-city-airqualityobserved", "ngsi-ld-city-airqualityobserved-nb001-p00007", map[string]float64{"CODensity": 1, "PM10Density": 10, "PM25Density": 10, "SO2Density": 10, "O3Density": 10}),
+	}
+}
+
+// Replays the records with all services in process, capturing the outbound events
+func (s *ReplaySuite) replayInProcess(records []recording.Record) (Report, []recording.Record) {
+	store, err := eventstore.OpenEventStore(filepath.Join(s.T().TempDir(), "ktwin-event-store.db"))
+	s.Require().NoError(err)
+	defer store.Close()
+
+	capture := NewCapture(eventstore.NewHandler(store))
+	server := httptest.NewServer(capture)
+	defer server.Close()
+	config.Get().Broker = server.URL
+	config.Get().EventStore = server.URL
+
+	router := city.NewCityRouter()
+	defer ktwin.ResetPublisher()
+
+	replayer := Replayer{RecordedClock: true, Deliver: DeliverInProcess(router.HandleEvent, router.Wait)}
+	report := replayer.Run(context.Background(), records)
+	return report, capture.Records()
+}
+
+func (s *ReplaySuite) Test_ReplayInProcessMatchesBaseline() {
+	records := s.airQualityRecords()
+
+	report, baseline := s.replayInProcess(records)
+	s.Assert().Equal(Report{Replayed: 2, Elapsed: report.Elapsed}, report)
+	s.Require().NotEmpty(baseline)
+
+	var types []string
+	for i := range baseline {
+		events, err := baseline[i].CloudEvents()
+		s.Require().NoError(err)
+		types = append(types, events[0].Type())
+	}
+	s.Assert().Contains(types, "ktwin.store.ngsi-ld-city-airqualityobserved")
+	s.Assert().Contains(types, "ktwin.store.s4city-city-neighborhood")
+
+	// The outbound events are received at the recorded times
+	s.Assert().Equal(s.start, baseline[0].ReceivedAt)
+
+	_, outbound := s.replayInProcess(records)
+	result, err := Diff(baseline, outbound)
+	s.Require().NoError(err)
+	s.Assert().True(result.Equal(), result.String())
+
+	_, outbound = s.replayInProcess(records[:1])
+	result, err = Diff(baseline, outbound)
+	s.Require().NoError(err)
+	s.Assert().False(result.Equal())
+	s.Assert().NotEmpty(result.Missing)
+	s.Assert().Empty(result.Unexpected)
+}
+
+func (s *ReplaySuite) Test_CaptureForwardsStoreEvents() {
+	store, err := eventstore.OpenEventStore(filepath.Join(s.T().TempDir(), "ktwin-event-store.db"))
+	s.Require().NoError(err)
+	defer store.Close()
+
+	capture := NewCapture(eventstore.NewHandler(store))
+	server := httptest.NewServer(capture)
+	defer server.Close()
+
+	virtual := ktwin.BuildCloudEvent("ktwin.virtual.city-pole", "city-pole-nb001-p00007", map[string]int{"airQualityIndex": 2})
+	s.Require().NoError(ktwin.SendCloudEvent(virtual, server.URL))
+	stored := ktwin.BuildCloudEvent("ktwin.store.city-pole", "city-pole-nb001-p00007", map[string]int{"airQualityIndex": 2})
+	s.Require().NoError(ktwin.SendCloudEvent(stored, server.URL))
+
+	latest, err := store.Latest("city-pole", "city-pole-nb001-p00007")
+	s.Require().NoError(err)
+	s.Require().NotNil(latest)
+	s.Assert().Equal(stored.ID(), latest.ID())
+	s.Assert().Len(capture.Records(), 2)
+}
+
+func (s *ReplaySuite) Test_ReplayHTTPAtSpeed() {
+	var mutex sync.Mutex
+	var received []time.Time
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := cloudevents.NewEventFromHTTPRequest(r)
+		s.Require().NoError(err)
+
+		mutex.Lock()
+		received = append(received, time.Now())
+		mutex.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer target.Close()
+
+	records := s.airQualityRecords()
+	records[1].ReceivedAt = records[0].ReceivedAt.Add(2 * time.Second)
+
+	replayer := Replayer{Speed: 10, Deliver: DeliverHTTP(target.URL)}
+	report := replayer.Run(context.Background(), records)
+
+	s.Assert().Equal(2, report.Replayed)
+	s.Assert().Equal(0, report.Failed)
+	s.Require().Len(received, 2)
+	s.Assert().GreaterOrEqual(received[1].Sub(received[0]), 150*time.Millisecond)
+	s.Assert().Less(report.Elapsed, time.Second)
+}
+
+func (s *ReplaySuite) Test_DiffIgnoresIdTimeAndKeyOrder() {
+	baseline := []recording.Record{
+		s.record(s.start, "ktwin.virtual.city-pole", "city-pole-nb001-p00007", map[string]int{"a": 1, "b": 2}),
+		s.record(s.start, "ktwin.virtual.city-pole", "city-pole-nb001-p00007", map[string]int{"a": 1, "b": 2}),
+	}
+	actual := []recording.Record{
+		s.record(s.start.Add(time.Hour), "ktwin.virtual.city-pole", "city-pole-nb001-p00007", map[string]int{"b": 2, "a": 1}),
+		s.record(s.start, "ktwin.virtual.city-pole", "city-pole-nb001-p00007", map[string]int{"a": 1, "b": 3}),
+	}
+
+	result, err := Diff(baseline, actual)
+	s.Require().NoError(err)
+	s.Assert().Equal(DiffResult{
+		Missing:    []string{`ktwin.virtual.city-pole city-pole-nb001-p00007 {"a":1,"b":2}`},
+		Unexpected: []string{`ktwin.virtual.city-pole city-pole-nb001-p00007 {"a":1,"b":3}`},
+	}, result)
+}
+
+func (s *ReplaySuite) Test_RecordingRotation() {
+	path := filepath.Join(s.T().TempDir(), "recording.ndjson")
+	recorder, err := recording.NewRecorder(path, 1, 3)
+	s.Require().NoError(err)
+
+	handler := recorder.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := cloudevents.NewEventFromHTTPRequest(r)
+		s.Require().NoError(err)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for i := 0; i < 4; i++ {
+		record := s.record(s.start.Add(time.Duration(i)*time.Second), "ktwin.real.ngsi-ld-city-device", "ngsi-ld-city-device-nb001", map[string]int{"batteryLevel": i})
+		request, err := record.ToRequest("http://service")
+		s.Require().NoError(err)
+
+		clock.NowFunc = func() *time.Time { return &record.ReceivedAt }
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		s.Require().Equal(http.StatusOK, response.Code)
+	}
+	s.Require().NoError(recorder.Close())
+
+	// Each record rotates the file, only the current and the 2 rotated files are kept
+	s.Assert().NoFileExists(path + ".3")
+	records, err := recording.ReadRecording(path)
+	s.Require().NoError(err)
+	s.Require().Len(records, 3)
+	for i, record := range records {
+		s.Assert().Equal(s.start.Add(time.Duration(i+1)*time.Second), record.ReceivedAt)
+		events, err := record.CloudEvents()
+		s.Require().NoError(err)
+		s.Assert().JSONEq(`{"batteryLevel":`+string(rune('1'+i))+`}`, string(events[0].Data()))
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/ktwin-simulator/service"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/ktwingraph"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
+)
+
+func main() {
+	logger := logger.NewLogger()
+
+	// The flags of the command are not config flags, the config comes from the env and KTWIN_CONFIG
+	ktwinConfig, err := config.Load(nil)
+	if err != nil {
+		logger.Fatal("Error loading config", err)
+	}
+
+	configFile := flag.String("config", "simulator.yaml", "simulator config file")
+	graphFile := flag.String("graph", ktwinConfig.Graph.File, "twin graph file or directory")
+	target := flag.String("target", ktwin.GetBrokerURL(), "url the events are posted to, the broker or a service")
+	rate := flag.Float64("rate", 100, "events per second, 0 to send as fast as possible")
+	workers := flag.Int("workers", 8, "concurrent requests")
+	startTime := flag.String("start", "", "simulated start time (RFC3339), defaults to now")
+	duration := flag.Duration("duration", 24*time.Hour, "simulated time span")
+	maxEvents := flag.Int("events", 0, "maximum number of events, 0 for no limit")
+	flag.Parse()
+
+	simulatorConfig, err := service.LoadConfig(*configFile)
+	if err != nil {
+		logger.Fatal("Error loading simulator config", err)
+	}
+
+	twinGraph, err := ktwingraph.LoadTwinGraphFile(*graphFile)
+	if err != nil {
+		logger.Fatal("Error loading twin graph", err)
+	}
+
+	start := time.Now()
+	if *startTime != "" {
+		start, err = time.Parse(time.RFC3339, *startTime)
+		if err != nil {
+			logger.Fatal("Error parsing start time", err)
+		}
+	}
+
+	generator := service.NewGenerator(simulatorConfig, *twinGraph, start)
+	runner := service.Runner{
+		Target:    *target,
+		Rate:      *rate,
+		Workers:   *workers,
+		End:       start.Add(*duration),
+		MaxEvents: *maxEvents,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	logger.Info(fmt.Sprintf("Simulating %d twin instances from %s to %s", generator.Instances(), start.Format(time.RFC3339), runner.End.Format(time.RFC3339)))
+	fmt.Print(runner.Run(ctx, generator))
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Simulator configuration:
+//
+//	seed: 42
+//	interfaces:
+//	  - interface: ngsi-ld-city-airqualityobserved
+//	    interval: 15m
+//	    attributes:
+//	      NO2Density:
+//	        distribution: normal
+//	        mean: 40
+//	        stddev: 10
+//	        min: 0
+//	        profile: [0.6, 0.5, 0.5, 0.5, 0.6, 0.8, 1.2, 1.6, 1.5, 1.1, 1, 1, 1, 1, 1, 1.1, 1.3, 1.6, 1.5, 1.2, 1, 0.9, 0.8, 0.7]
+//
+// Every instance of the interface in the twin graph emits an event each interval of simulated
+// time. The profile holds 24 hourly multipliers applied to the sampled numeric values, or to
+// the probability of bernoulli attributes, so values follow the daily cycle of the city.
+type Config struct {
+	Seed       int64             `yaml:"seed"`
+	Interfaces []InterfaceConfig `yaml:"interfaces"`
+}
+
+type InterfaceConfig struct {
+	Interface  string                  `yaml:"interface"`
+	Interval   time.Duration           `yaml:"interval"`
+	Attributes map[string]Distribution `yaml:"attributes"`
+}
+
+const (
+	ConstantDistribution  = "constant"
+	UniformDistribution   = "uniform"
+	NormalDistribution    = "normal"
+	BernoulliDistribution = "bernoulli"
+	ChoiceDistribution    = "choice"
+)
+
+type Distribution struct {
+	Distribution string      `yaml:"distribution"`
+	Value        interface{} `yaml:"value"`
+	Min          *float64    `yaml:"min"`
+	Max          *float64    `yaml:"max"`
+	Mean         float64     `yaml:"mean"`
+	StdDev       float64     `yaml:"stddev"`
+	Probability  float64     `yaml:"probability"`
+	Values       []string    `yaml:"values"`
+	Weights      []float64   `yaml:"weights"`
+	Integer      bool        `yaml:"integer"`
+	Profile      []float64   `yaml:"profile"`
+}
+
+func LoadConfig(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("error parsing simulator config %s: %w", path, err)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid simulator config %s: %w", path, err)
+	}
+
+	return &config, nil
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+	for _, interfaceConfig := range c.Interfaces {
+		if interfaceConfig.Interface == "" {
+			errs = append(errs, errors.New("interface is required"))
+		}
+		if interfaceConfig.Interval <= 0 {
+			errs = append(errs, fmt.Errorf("%s: interval must be greater than 0", interfaceConfig.Interface))
+		}
+		for name, distribution := range interfaceConfig.Attributes {
+			if err := distribution.Validate(); err != nil {
+				errs = append(errs, fmt.Errorf("%s.%s: %w", interfaceConfig.Interface, name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (d *Distribution) Validate() error {
+	if d.Profile != nil && len(d.Profile) != 24 {
+		return fmt.Errorf("profile must have 24 hourly values, got %d", len(d.Profile))
+	}
+
+	switch d.Distribution {
+	case ConstantDistribution:
+		if d.Value == nil {
+			return errors.New("constant distribution requires a value")
+		}
+	case UniformDistribution:
+		if d.Min == nil || d.Max == nil || *d.Min > *d.Max {
+			return errors.New("uniform distribution requires min lower than max")
+		}
+	case NormalDistribution:
+		if d.StdDev < 0 {
+			return errors.New("normal distribution requires a positive stddev")
+		}
+	case BernoulliDistribution:
+		if d.Probability < 0 || d.Probability > 1 {
+			return errors.New("bernoulli distribution requires a probability between 0 and 1")
+		}
+	case ChoiceDistribution:
+		if len(d.Values) == 0 {
+			return errors.New("choice distribution requires values")
+		}
+		if d.Weights != nil && len(d.Weights) != len(d.Values) {
+			return errors.New("choice distribution requires a weight for each value")
+		}
+	default:
+		return fmt.Errorf("unknown distribution %q", d.Distribution)
+	}
+	return nil
+}
+
+func (d *Distribution) profileFactor(t time.Time) float64 {
+	if d.Profile == nil {
+		return 1
+	}
+	return d.Profile[t.Hour()]
+}
+
+// Samples a value of the attribute at the simulated time
+func (d *Distribution) Sample(rng *rand.Rand, t time.Time) interface{} {
+	switch d.Distribution {
+	case ConstantDistribution:
+		return d.Value
+	case BernoulliDistribution:
+		return rng.Float64() < math.Min(d.Probability*d.profileFactor(t), 1)
+	case ChoiceDistribution:
+		return d.Values[d.choose(rng)]
+	}
+
+	var value float64
+	if d.Distribution == UniformDistribution {
+		value = *d.Min + rng.Float64()*(*d.Max-*d.Min)
+	} else {
+		value = rng.NormFloat64()*d.StdDev + d.Mean
+	}
+
+	value *= d.profileFactor(t)
+	if d.Min != nil {
+		value = math.Max(value, *d.Min)
+	}
+	if d.Max != nil {
+		value = math.Min(value, *d.Max)
+	}
+
+	if d.Integer {
+		return int(math.Round(value))
+	}
+	return math.Round(value*100) / 100
+}
+
+func (d *Distribution) choose(rng *rand.Rand) int {
+	if d.Weights == nil {
+		return rng.Intn(len(d.Values))
+	}
+
+	total := 0.0
+	for _, weight := range d.Weights {
+		total += weight
+	}
+
+	target := rng.Float64() * total
+	for i, weight := range d.Weights {
+		target -= weight
+		if target < 0 {
+			return i
+		}
+	}
+	return len(d.Values) - 1
+}
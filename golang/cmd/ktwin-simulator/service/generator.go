@@ -0,0 +1,132 @@
+package service
+
+import (
+	"container/heap"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/uuid"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// SimulatedEvent is a real twin event generated at a simulated time
+type SimulatedEvent struct {
+	Time          time.Time
+	TwinInterface string
+	TwinInstance  string
+	Data          map[string]interface{}
+}
+
+func (e *SimulatedEvent) ToCloudEvent() *cloudevents.Event {
+	event := cloudevents.NewEvent()
+	event.SetID(uuid.Uuid())
+	event.SetTime(e.Time)
+	event.SetType(fmt.Sprintf(ktwin.EventRealGenerated, e.TwinInterface))
+	event.SetSource(e.TwinInstance)
+	event.SetData(cloudevents.ApplicationJSON, e.Data)
+	return &event
+}
+
+type scheduledInstance struct {
+	next          time.Time
+	twinInstance  string
+	interfaceIdx  int
+	attributeKeys []string
+}
+
+type schedule []*scheduledInstance
+
+func (s schedule) Len() int { return len(s) }
+func (s schedule) Less(i, j int) bool {
+	if s[i].next.Equal(s[j].next) {
+		return s[i].twinInstance < s[j].twinInstance
+	}
+	return s[i].next.Before(s[j].next)
+}
+func (s schedule) Swap(i, j int)       { s[i], s[j] = s[j], s[i] }
+func (s *schedule) Push(x interface{}) { *s = append(*s, x.(*scheduledInstance)) }
+func (s *schedule) Pop() interface{} {
+	old := *s
+	item := old[len(old)-1]
+	*s = old[:len(old)-1]
+	return item
+}
+
+// Generator generates the events of all simulated twin instances in time order
+type Generator struct {
+	config   *Config
+	rng      *rand.Rand
+	schedule schedule
+}
+
+// Creates a generator for the instances of the twin graph whose interface is configured.
+// The first event of each instance is spread over its first interval after start.
+func NewGenerator(config *Config, twinGraph ktwin.TwinGraph, start time.Time) *Generator {
+	generator := &Generator{
+		config: config,
+		rng:    rand.New(rand.NewSource(config.Seed)),
+	}
+
+	interfaces := map[string]int{}
+	for i, interfaceConfig := range config.Interfaces {
+		interfaces[interfaceConfig.Interface] = i
+	}
+
+	for _, instance := range twinGraph.TwinInstancesGraph {
+		interfaceIdx, ok := interfaces[instance.Interface]
+		if !ok {
+			continue
+		}
+
+		interfaceConfig := config.Interfaces[interfaceIdx]
+		attributeKeys := make([]string, 0, len(interfaceConfig.Attributes))
+		for name := range interfaceConfig.Attributes {
+			attributeKeys = append(attributeKeys, name)
+		}
+		sort.Strings(attributeKeys)
+
+		offset := time.Duration(generator.rng.Int63n(int64(interfaceConfig.Interval)))
+		generator.schedule = append(generator.schedule, &scheduledInstance{
+			next:          start.Add(offset),
+			twinInstance:  instance.Name,
+			interfaceIdx:  interfaceIdx,
+			attributeKeys: attributeKeys,
+		})
+	}
+
+	heap.Init(&generator.schedule)
+	return generator
+}
+
+func (g *Generator) Instances() int {
+	return len(g.schedule)
+}
+
+// Gets the next event in simulated time, false when no instance is simulated
+func (g *Generator) Next() (*SimulatedEvent, bool) {
+	if len(g.schedule) == 0 {
+		return nil, false
+	}
+
+	instance := g.schedule[0]
+	interfaceConfig := g.config.Interfaces[instance.interfaceIdx]
+
+	event := &SimulatedEvent{
+		Time:          instance.next,
+		TwinInterface: interfaceConfig.Interface,
+		TwinInstance:  instance.twinInstance,
+		Data:          map[string]interface{}{},
+	}
+	for _, name := range instance.attributeKeys {
+		distribution := interfaceConfig.Attributes[name]
+		event.Data[name] = distribution.Sample(g.rng, instance.next)
+	}
+
+	instance.next = instance.next.Add(interfaceConfig.Interval)
+	heap.Fix(&g.schedule, 0)
+
+	return event, true
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	log "github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
+)
+
+var logger = log.NewLogger()
+
+// Runner sends the generated events to the target, the broker or a service, at a target rate
+type Runner struct {
+	Target string
+	// Events per second, 0 sends the events as fast as possible
+	Rate    float64
+	Workers int
+	// Stops after the simulated time reaches End, or after MaxEvents when it is set
+	End       time.Time
+	MaxEvents int
+}
+
+type Report struct {
+	Sent       int
+	Failed     int
+	Elapsed    time.Duration
+	Throughput float64
+	Latencies  map[string]time.Duration
+}
+
+func (r Report) String() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "events: %d sent, %d failed\n", r.Sent, r.Failed)
+	fmt.Fprintf(&builder, "elapsed: %s\n", r.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(&builder, "throughput: %.2f events/s\n", r.Throughput)
+	for _, percentile := range []string{"p50", "p90", "p99", "max"} {
+		fmt.Fprintf(&builder, "latency %s: %s\n", percentile, r.Latencies[percentile].Round(time.Microsecond))
+	}
+	return builder.String()
+}
+
+func (r *Runner) Run(ctx context.Context, generator *Generator) Report {
+	workers := r.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	events := make(chan *SimulatedEvent, workers)
+	var mutex sync.Mutex
+	var latencies []time.Duration
+	failed := 0
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client := ktwin.NewClient()
+			for event := range events {
+				latency, err := r.send(&client, event)
+
+				mutex.Lock()
+				latencies = append(latencies, latency)
+				if err != nil {
+					failed++
+					logger.Error(fmt.Sprintf("Error sending event of %s", event.TwinInstance), err)
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	var ticker *time.Ticker
+	if r.Rate > 0 {
+		ticker = time.NewTicker(time.Duration(float64(time.Second) / r.Rate))
+		defer ticker.Stop()
+	}
+
+	start := time.Now()
+	generated := 0
+
+loop:
+	for r.MaxEvents <= 0 || generated < r.MaxEvents {
+		event, ok := generator.Next()
+		if !ok || (!r.End.IsZero() && event.Time.After(r.End)) {
+			break
+		}
+
+		if ticker != nil {
+			select {
+			case <-ctx.Done():
+				break loop
+			case <-ticker.C:
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			break loop
+		case events <- event:
+			generated++
+		}
+	}
+
+	close(events)
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	return Report{
+		Sent:       len(latencies),
+		Failed:     failed,
+		Elapsed:    elapsed,
+		Throughput: float64(len(latencies)) / elapsed.Seconds(),
+		Latencies:  Percentiles(latencies),
+	}
+}
+
+func (r *Runner) send(client *ktwin.Client, event *SimulatedEvent) (time.Duration, error) {
+	start := time.Now()
+	response, err := client.Post(r.Target, event.ToCloudEvent())
+	latency := time.Since(start)
+	if err != nil {
+		return latency, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		body, _ := io.ReadAll(response.Body)
+		return latency, fmt.Errorf("status code: %d. response body: %s", response.StatusCode, string(body))
+	}
+	return latency, nil
+}
+
+// Gets the p50, p90, p99 and max of the latencies, using the nearest-rank method
+func Percentiles(latencies []time.Duration) map[string]time.Duration {
+	percentiles := map[string]time.Duration{}
+	if len(latencies) == 0 {
+		return percentiles
+	}
+
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := func(p float64) time.Duration {
+		index := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+		if index < 0 {
+			index = 0
+		}
+		return sorted[index]
+	}
+
+	percentiles["p50"] = rank(50)
+	percentiles["p90"] = rank(90)
+	percentiles["p99"] = rank(99)
+	percentiles["max"] = sorted[len(sorted)-1]
+	return percentiles
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/ktwingraph"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestSimulatorSuite(t *testing.T) {
+	suite.Run(t, new(SimulatorSuite))
+}
+
+type SimulatorSuite struct {
+	suite.Suite
+
+	config    *Config
+	twinGraph ktwin.TwinGraph
+	start     time.Time
+}
+
+func (s *SimulatorSuite) SetupSuite() {
+	os.Setenv("ENV", "test")
+	config.LoadEnv()
+
+	simulatorConfig, err := LoadConfig("../simulator.yaml")
+	s.Require().NoError(err)
+	s.config = simulatorConfig
+
+	twinGraph, err := ktwingraph.LoadTwinGraphFile(os.Getenv("KTWIN_GRAPH_FILE"))
+	s.Require().NoError(err)
+	s.twinGraph = *twinGraph
+
+	s.start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func (s *SimulatorSuite) Test_GeneratorIsTimeOrderedAndDeterministic() {
+	generator := NewGenerator(s.config, s.twinGraph, s.start)
+	other := NewGenerator(s.config, s.twinGraph, s.start)
+
+	previous := s.start
+	instances := map[string]bool{}
+	for i := 0; i < 5000; i++ {
+		event, ok := generator.Next()
+		s.Require().True(ok)
+		otherEvent, _ := other.Next()
+
+		s.Assert().False(event.Time.Before(previous))
+		s.Assert().Equal(event, otherEvent)
+		previous = event.Time
+		instances[event.TwinInterface] = true
+	}
+
+	s.Assert().Equal(map[string]bool{
+		"ngsi-ld-city-device":              true,
+		"ngsi-ld-city-parkingspot":         true,
+		"ngsi-ld-city-streetlight":         true,
+		"ngsi-ld-city-airqualityobserved":  true,
+		"ngsi-ld-city-weatherobserved":     true,
+		"ngsi-ld-city-trafficflowobserved": true,
+		"ngsi-ld-city-crowdflowobserved":   true,
+	}, instances)
+}
+
+func (s *SimulatorSuite) Test_DistributionSample() {
+	min, max := 0.0, 10.0
+	profile := make([]float64, 24)
+	for i := range profile {
+		profile[i] = 1
+	}
+	profile[8] = 100
+
+	distribution := Distribution{Distribution: NormalDistribution, Mean: 5, StdDev: 1, Min: &min, Max: &max, Profile: profile}
+	s.Require().NoError(distribution.Validate())
+
+	night := time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC)
+	peak := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	rng := newTestRand()
+	for i := 0; i < 100; i++ {
+		value := distribution.Sample(rng, night).(float64)
+		s.Assert().GreaterOrEqual(value, min)
+		s.Assert().LessOrEqual(value, max)
+		s.Assert().Equal(max, distribution.Sample(rng, peak))
+	}
+
+	s.Assert().Error((&Distribution{Distribution: ChoiceDistribution}).Validate())
+	s.Assert().Error((&Distribution{Distribution: NormalDistribution, Profile: []float64{1}}).Validate())
+}
+
+func (s *SimulatorSuite) Test_Percentiles() {
+	var latencies []time.Duration
+	for i := 100; i >= 1; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	s.Assert().Equal(map[string]time.Duration{
+		"p50": 50 * time.Millisecond,
+		"p90": 90 * time.Millisecond,
+		"p99": 99 * time.Millisecond,
+		"max": 100 * time.Millisecond,
+	}, Percentiles(latencies))
+}
+
+func (s *SimulatorSuite) Test_Runner() {
+	var mutex sync.Mutex
+	receivedTypes := map[string]int{}
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event, err := cloudevents.NewEventFromHTTPRequest(r)
+		s.Require().NoError(err)
+
+		mutex.Lock()
+		receivedTypes[event.Type()]++
+		mutex.Unlock()
+		if event.Type() == "ktwin.real.ngsi-ld-city-streetlight" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer target.Close()
+
+	generator := NewGenerator(s.config, s.twinGraph, s.start)
+	runner := Runner{Target: target.URL, Workers: 4, End: s.start.Add(30 * time.Minute)}
+	report := runner.Run(context.Background(), generator)
+
+	total := 0
+	for _, count := range receivedTypes {
+		total += count
+	}
+
+	s.Assert().Equal(total, report.Sent)
+	s.Assert().Equal(receivedTypes["ktwin.real.ngsi-ld-city-streetlight"], report.Failed)
+	s.Assert().Equal(100, receivedTypes["ktwin.real.ngsi-ld-city-streetlight"])
+	s.Assert().Equal(1150*2, receivedTypes["ktwin.real.ngsi-ld-city-device"])
+	s.Assert().Contains(report.Latencies, "p99")
+}
+
+func newTestRand() *rand.Rand {
+	return rand.New(rand.NewSource(1))
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/ktwin-watchdog/service"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/ktwingraph"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
+)
+
+func main() {
+	logger := logger.NewLogger()
+	if _, err := config.Load(os.Args[1:]); err != nil {
+		logger.Fatal("Error loading config", err)
+	}
+
+	watchdogConfig, err := service.LoadConfig()
+	if err != nil {
+		logger.Fatal("Error loading watchdog config", err)
+	}
+
+	twinGraph, err := ktwingraph.LoadTwinGraphByInterfaces(watchdogConfig.Interfaces())
+	if err != nil {
+		logger.Fatal("Error loading twin graph", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger.Info(fmt.Sprintf("Watching %d twin instances every %s", len(twinGraph.TwinInstancesGraph), watchdogConfig.CheckInterval))
+	service.NewWatchdog(watchdogConfig, twinGraph, service.NewAlerter(watchdogConfig.AlertWebhook)).Run(ctx)
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type AlertSeverity string
+
+const (
+	SEVERITY_WARNING  AlertSeverity = "warning"
+	SEVERITY_RESOLVED AlertSeverity = "resolved"
+)
+
+// Alert is raised when a twin instance goes offline, and resolved when it reports again
+type Alert struct {
+	Severity      AlertSeverity `json:"severity"`
+	TwinInterface string        `json:"twinInterface"`
+	TwinInstance  string        `json:"twinInstance"`
+	Message       string        `json:"message"`
+	DateCreated   *time.Time    `json:"dateCreated,omitempty"`
+}
+
+// Alerter delivers the alerts of the watchdog
+type Alerter interface {
+	Raise(alert Alert) error
+}
+
+// Creates the alerter posting the alerts to the webhook, or logging them when it is empty
+func NewAlerter(webhook string) Alerter {
+	if webhook == "" {
+		return logAlerter{}
+	}
+	return &webhookAlerter{url: webhook, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+type logAlerter struct{}
+
+func (logAlerter) Raise(alert Alert) error {
+	message := fmt.Sprintf("Alert %s of TwinInstance %s: %s", alert.Severity, alert.TwinInstance, alert.Message)
+	if alert.Severity == SEVERITY_RESOLVED {
+		logger.Info(message)
+	} else {
+		logger.Error(message, nil)
+	}
+	return nil
+}
+
+type webhookAlerter struct {
+	url    string
+	client *http.Client
+}
+
+func (a *webhookAlerter) Raise(alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	response, err := a.client.Post(a.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("alert webhook %s replied with status code %d", a.url, response.StatusCode)
+	}
+	return nil
+}
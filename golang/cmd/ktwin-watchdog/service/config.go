@@ -0,0 +1,65 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+)
+
+// Config of the watchdog, the services.watchdog section of the config
+type Config struct {
+	// Time between the checks of the watched twin instances
+	CheckInterval time.Duration `yaml:"checkInterval" env:"KTWIN_WATCHDOG_CHECK_INTERVAL"`
+	// Number of reporting intervals without reports after which an instance is offline
+	MissedIntervals int `yaml:"missedIntervals" env:"KTWIN_WATCHDOG_MISSED_INTERVALS"`
+	// Expected reporting interval of the instances of each watched interface. Instances whose latest
+	// event has a measurementFrequency, in minutes, are expected to report at that frequency instead.
+	Intervals map[string]time.Duration `yaml:"intervals"`
+	// URL the alerts are posted to, when it is empty they are only logged
+	AlertWebhook string `yaml:"alertWebhook" env:"KTWIN_WATCHDOG_ALERT_WEBHOOK"`
+}
+
+var serviceConfig = Config{
+	CheckInterval:   time.Minute,
+	MissedIntervals: 3,
+	Intervals: map[string]time.Duration{
+		"ngsi-ld-city-device":             15 * time.Minute,
+		"ngsi-ld-city-airqualityobserved": 15 * time.Minute,
+	},
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+	if c.CheckInterval <= 0 {
+		errs = append(errs, errors.New("checkInterval must be greater than 0"))
+	}
+	if c.MissedIntervals <= 0 {
+		errs = append(errs, errors.New("missedIntervals must be greater than 0"))
+	}
+	if len(c.Intervals) == 0 {
+		errs = append(errs, errors.New("intervals must have at least one interface"))
+	}
+	for twinInterface, interval := range c.Intervals {
+		if interval <= 0 {
+			errs = append(errs, fmt.Errorf("interval of %s must be greater than 0", twinInterface))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Loads the config of the watchdog
+func LoadConfig() (Config, error) {
+	err := config.LoadService("watchdog", &serviceConfig)
+	return serviceConfig, err
+}
+
+// Gets the watched interfaces
+func (c *Config) Interfaces() []string {
+	var interfaces []string
+	for twinInterface := range c.Intervals {
+		interfaces = append(interfaces, twinInterface)
+	}
+	return interfaces
+}
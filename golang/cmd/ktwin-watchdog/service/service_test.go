@@ -0,0 +1,219 @@
+package service
+
+import (
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/clock"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	ALERT_WEBHOOK = "http://alerts.local"
+)
+
+func TestWatchdogSuite(t *testing.T) {
+	suite.Run(t, new(WatchdogSuite))
+}
+
+type WatchdogSuite struct {
+	suite.Suite
+
+	brokerUrl     string
+	eventStoreUrl string
+}
+
+func (s *WatchdogSuite) SetupSuite() {
+	os.Setenv("ENV", "test")
+	config.LoadEnv()
+
+	s.brokerUrl = os.Getenv("KTWIN_BROKER")
+	s.eventStoreUrl = os.Getenv("KTWIN_EVENT_STORE")
+}
+
+func (s *WatchdogSuite) TearDownTest() {
+	clock.ResetClockImplementation()
+	gock.Off()
+}
+
+func (s *WatchdogSuite) mockLatestEvent(twinInterface, twinInstance string, reported time.Time, body string) {
+	gock.New(s.eventStoreUrl).
+		Get("/api/v1/twin-events/"+twinInterface+"/"+twinInstance+"/latest").
+		Reply(http.StatusOK).
+		SetHeader("Content-Type", "application/json").
+		SetHeader("ce-specversion", "1.0").
+		SetHeader("ce-id", "1").
+		SetHeader("ce-time", reported.Format(time.RFC3339)).
+		SetHeader("ce-source", twinInstance).
+		SetHeader("ce-type", "ktwin.store."+twinInterface).
+		BodyString(body)
+}
+
+func (s *WatchdogSuite) mockStatusEvent(twinInterface, twinInstance, body string) {
+	gock.New(s.brokerUrl).
+		Post("/").
+		MatchHeader("ce-source", twinInstance).
+		MatchHeader("ce-type", "ktwin.virtual."+twinInterface).
+		BodyString(body).
+		Reply(http.StatusAccepted)
+}
+
+func (s *WatchdogSuite) mockLastStatus(twinInterface, twinInstance, body string) {
+	request := gock.New(s.eventStoreUrl).
+		Get("/api/v1/twin-events/" + twinInterface + "-status/" + twinInstance + "/latest")
+	if body == "" {
+		request.Reply(http.StatusNotFound)
+		return
+	}
+	request.Reply(http.StatusOK).
+		SetHeader("Content-Type", "application/json").
+		SetHeader("ce-specversion", "1.0").
+		SetHeader("ce-id", "1").
+		SetHeader("ce-time", clock.Now().Format(time.RFC3339)).
+		SetHeader("ce-source", twinInstance).
+		SetHeader("ce-type", "ktwin.store."+twinInterface+"-status").
+		BodyString(body)
+}
+
+// The published status is stored in the status interface of the instance
+func (s *WatchdogSuite) mockStoreStatus(twinInterface, twinInstance, body string) {
+	gock.New(s.brokerUrl).
+		Post("/").
+		MatchHeader("ce-source", twinInstance).
+		MatchHeader("ce-type", "ktwin.store."+twinInterface+"-status").
+		BodyString(body).
+		Reply(http.StatusAccepted)
+}
+
+func (s *WatchdogSuite) mockAlert(body string) {
+	gock.New(ALERT_WEBHOOK).
+		Post("/").
+		BodyString(body).
+		Reply(http.StatusOK)
+}
+
+func (s *WatchdogSuite) Test_Watchdog() {
+	now, _ := time.Parse(time.RFC3339, "2024-01-01T01:00:00Z")
+	clock.NowFunc = func() *time.Time {
+		return &now
+	}
+
+	graph := ktwin.TwinGraph{TwinInstancesGraph: []ktwin.TwinInstanceGraph{
+		{Name: "ngsi-ld-city-device-001", Interface: "ngsi-ld-city-device"},
+		{Name: "ngsi-ld-city-airqualityobserved-001", Interface: "ngsi-ld-city-airqualityobserved"},
+		{Name: "ngsi-ld-city-airqualityobserved-002", Interface: "ngsi-ld-city-airqualityobserved"},
+		{Name: "city-pole-001", Interface: "city-pole"},
+	}}
+	watchdogConfig := Config{
+		CheckInterval:   time.Minute,
+		MissedIntervals: 3,
+		Intervals: map[string]time.Duration{
+			"ngsi-ld-city-device":             15 * time.Minute,
+			"ngsi-ld-city-airqualityobserved": 10 * time.Minute,
+		},
+	}
+	watchdog := NewWatchdog(watchdogConfig, graph, NewAlerter(ALERT_WEBHOOK))
+
+	// The device measures every 20 minutes, it missed 3 intervals. The air quality sensor missed 2
+	// intervals of 10 minutes and the second one never reported.
+	s.Run("Should mark the instance that missed 3 intervals offline and raise an alert", func() {
+		defer gock.Off()
+
+		s.mockLatestEvent("ngsi-ld-city-device", "ngsi-ld-city-device-001", now.Add(-time.Hour), `{"batteryLevel":10,"measurementFrequency":20}`)
+		s.mockLatestEvent("ngsi-ld-city-airqualityobserved", "ngsi-ld-city-airqualityobserved-001", now.Add(-25*time.Minute), `{"CODensity":1}`)
+		gock.New(s.eventStoreUrl).
+			Get("/api/v1/twin-events/ngsi-ld-city-airqualityobserved/ngsi-ld-city-airqualityobserved-002/latest").
+			Reply(http.StatusNotFound)
+		s.mockLastStatus("ngsi-ld-city-device", "ngsi-ld-city-device-001", "")
+		s.mockLastStatus("ngsi-ld-city-airqualityobserved", "ngsi-ld-city-airqualityobserved-001", "")
+
+		s.mockStatusEvent("ngsi-ld-city-device", "ngsi-ld-city-device-001", `{"status":"offline","reportingInterval":1200,"missedIntervals":3,"dateLastReported":"2024-01-01T00:00:00Z","dateModified":"2024-01-01T01:00:00Z"}`)
+		s.mockStoreStatus("ngsi-ld-city-device", "ngsi-ld-city-device-001", `{"status":"offline","reportingInterval":1200,"missedIntervals":3,"dateLastReported":"2024-01-01T00:00:00Z","dateModified":"2024-01-01T01:00:00Z"}`)
+		s.mockAlert(`{"severity":"warning","twinInterface":"ngsi-ld-city-device","twinInstance":"ngsi-ld-city-device-001","message":"no reports for 3 intervals of 20m0s since 2024-01-01T00:00:00Z","dateCreated":"2024-01-01T01:00:00Z"}`)
+
+		s.Require().NoError(watchdog.Check())
+		s.Assert().True(gock.IsDone())
+	})
+
+	s.Run("Should not announce the offline instance again", func() {
+		defer gock.Off()
+
+		s.mockLatestEvent("ngsi-ld-city-device", "ngsi-ld-city-device-001", now.Add(-time.Hour), `{"batteryLevel":10,"measurementFrequency":20}`)
+		s.mockLatestEvent("ngsi-ld-city-airqualityobserved", "ngsi-ld-city-airqualityobserved-001", now.Add(-25*time.Minute), `{"CODensity":1}`)
+		gock.New(s.eventStoreUrl).
+			Get("/api/v1/twin-events/ngsi-ld-city-airqualityobserved/ngsi-ld-city-airqualityobserved-002/latest").
+			Reply(http.StatusNotFound)
+
+		s.Require().NoError(watchdog.Check())
+		s.Assert().True(gock.IsDone())
+	})
+
+	s.Run("Should clear the offline state and resolve the alert when the instance reports again", func() {
+		defer gock.Off()
+
+		s.mockLatestEvent("ngsi-ld-city-device", "ngsi-ld-city-device-001", now.Add(-time.Minute), `{"batteryLevel":10,"measurementFrequency":20}`)
+		s.mockLatestEvent("ngsi-ld-city-airqualityobserved", "ngsi-ld-city-airqualityobserved-001", now.Add(-25*time.Minute), `{"CODensity":1}`)
+		gock.New(s.eventStoreUrl).
+			Get("/api/v1/twin-events/ngsi-ld-city-airqualityobserved/ngsi-ld-city-airqualityobserved-002/latest").
+			Reply(http.StatusNotFound)
+
+		s.mockStatusEvent("ngsi-ld-city-device", "ngsi-ld-city-device-001", `{"status":"online","reportingInterval":1200,"missedIntervals":0,"dateLastReported":"2024-01-01T00:59:00Z","dateModified":"2024-01-01T01:00:00Z"}`)
+		s.mockStoreStatus("ngsi-ld-city-device", "ngsi-ld-city-device-001", `{"status":"online","reportingInterval":1200,"missedIntervals":0,"dateLastReported":"2024-01-01T00:59:00Z","dateModified":"2024-01-01T01:00:00Z"}`)
+		s.mockAlert(`{"severity":"resolved","twinInterface":"ngsi-ld-city-device","twinInstance":"ngsi-ld-city-device-001","message":"reporting again since 2024-01-01T00:59:00Z","dateCreated":"2024-01-01T01:00:00Z"}`)
+
+		s.Require().NoError(watchdog.Check())
+		s.Assert().True(gock.IsDone())
+		s.Assert().Equal(STATUS_ONLINE, watchdog.statuses["ngsi-ld-city-device/ngsi-ld-city-device-001"])
+	})
+}
+
+func (s *WatchdogSuite) Test_WatchdogRestart() {
+	now, _ := time.Parse(time.RFC3339, "2024-01-01T01:00:00Z")
+	clock.NowFunc = func() *time.Time {
+		return &now
+	}
+
+	// Instances of different interfaces may have the same name
+	graph := ktwin.TwinGraph{TwinInstancesGraph: []ktwin.TwinInstanceGraph{
+		{Name: "nb001-p00007", Interface: "ngsi-ld-city-device"},
+		{Name: "nb001-p00007", Interface: "ngsi-ld-city-airqualityobserved"},
+	}}
+	watchdogConfig := Config{
+		CheckInterval:   time.Minute,
+		MissedIntervals: 3,
+		Intervals: map[string]time.Duration{
+			"ngsi-ld-city-device":             15 * time.Minute,
+			"ngsi-ld-city-airqualityobserved": 10 * time.Minute,
+		},
+	}
+
+	// The device was announced offline before the restart, the air quality sensor goes offline now
+	s.mockLatestEvent("ngsi-ld-city-device", "nb001-p00007", now.Add(-time.Hour), `{"batteryLevel":10}`)
+	s.mockLastStatus("ngsi-ld-city-device", "nb001-p00007", `{"status":"offline","reportingInterval":900,"missedIntervals":3,"dateLastReported":"2024-01-01T00:00:00Z","dateModified":"2024-01-01T00:45:00Z"}`)
+	s.mockLatestEvent("ngsi-ld-city-airqualityobserved", "nb001-p00007", now.Add(-time.Hour), `{"CODensity":1}`)
+	s.mockLastStatus("ngsi-ld-city-airqualityobserved", "nb001-p00007", "")
+
+	s.mockStatusEvent("ngsi-ld-city-airqualityobserved", "nb001-p00007", `{"status":"offline","reportingInterval":600,"missedIntervals":6,"dateLastReported":"2024-01-01T00:00:00Z","dateModified":"2024-01-01T01:00:00Z"}`)
+	s.mockStoreStatus("ngsi-ld-city-airqualityobserved", "nb001-p00007", `{"status":"offline","reportingInterval":600,"missedIntervals":6,"dateLastReported":"2024-01-01T00:00:00Z","dateModified":"2024-01-01T01:00:00Z"}`)
+	s.mockAlert(`{"severity":"warning","twinInterface":"ngsi-ld-city-airqualityobserved","twinInstance":"nb001-p00007","message":"no reports for 6 intervals of 10m0s since 2024-01-01T00:00:00Z","dateCreated":"2024-01-01T01:00:00Z"}`)
+
+	watchdog := NewWatchdog(watchdogConfig, graph, NewAlerter(ALERT_WEBHOOK))
+	s.Require().NoError(watchdog.Check())
+	s.Assert().True(gock.IsDone())
+	s.Assert().Equal(map[string]InstanceStatus{
+		"ngsi-ld-city-device/nb001-p00007":             STATUS_OFFLINE,
+		"ngsi-ld-city-airqualityobserved/nb001-p00007": STATUS_OFFLINE,
+	}, watchdog.statuses)
+}
+
+func (s *WatchdogSuite) Test_InvalidConfig() {
+	invalidConfig := Config{CheckInterval: time.Minute, MissedIntervals: 0, Intervals: map[string]time.Duration{"ngsi-ld-city-device": 0}}
+	s.Assert().ErrorContains(invalidConfig.Validate(), "missedIntervals must be greater than 0")
+	s.Assert().ErrorContains(invalidConfig.Validate(), "interval of ngsi-ld-city-device must be greater than 0")
+	s.Assert().NoError(serviceConfig.Validate())
+}
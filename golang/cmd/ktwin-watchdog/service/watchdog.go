@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/clock"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kevent"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/keventstore"
+	log "github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
+)
+
+var logger = log.NewLogger()
+
+type InstanceStatus string
+
+const (
+	STATUS_ONLINE  InstanceStatus = "online"
+	STATUS_OFFLINE InstanceStatus = "offline"
+
+	// The status published for a twin instance is stored as the latest event of the instance in the
+	// status interface of its interface, e.g. ngsi-ld-city-device-status
+	STATUS_INTERFACE_SUFFIX = "-status"
+)
+
+// StatusEvent is the virtual event published when a twin instance goes offline or reports again
+type StatusEvent struct {
+	Status InstanceStatus `json:"status"`
+	// Expected reporting interval in seconds
+	ReportingInterval int        `json:"reportingInterval"`
+	MissedIntervals   int        `json:"missedIntervals"`
+	DateLastReported  *time.Time `json:"dateLastReported,omitempty"`
+	DateModified      *time.Time `json:"dateModified,omitempty"`
+}
+
+// Watchdog checks the latest report of the watched twin instances of the graph, and marks as offline
+// the ones that missed too many reporting intervals. Instances that never reported are not watched.
+// The status of an instance is derived from the last status published for it, which is stored in the
+// event store, so the offline instances are not announced again after a restart.
+type Watchdog struct {
+	config  Config
+	graph   ktwin.TwinGraph
+	alerter Alerter
+	// Last published status of the twin instances, by interface and instance, read from the event
+	// store on the first check of each instance
+	statuses map[string]InstanceStatus
+}
+
+func NewWatchdog(config Config, graph ktwin.TwinGraph, alerter Alerter) *Watchdog {
+	return &Watchdog{
+		config:   config,
+		graph:    graph,
+		alerter:  alerter,
+		statuses: map[string]InstanceStatus{},
+	}
+}
+
+func statusKey(twinInterface, twinInstance string) string {
+	return twinInterface + "/" + twinInstance
+}
+
+// Checks the instances every check interval until the context is done
+func (w *Watchdog) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.Check(); err != nil {
+			logger.Error("Error checking the twin instances", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Checks the latest report of every watched instance
+func (w *Watchdog) Check() error {
+	now := clock.Now()
+
+	var errs []error
+	for _, instance := range w.graph.TwinInstancesGraph {
+		interval, ok := w.config.Intervals[instance.Interface]
+		if !ok {
+			continue
+		}
+		if err := w.checkInstance(instance.Interface, instance.Name, interval, *now); err != nil {
+			errs = append(errs, fmt.Errorf("TwinInstance %s: %w", instance.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Publishes the status of the instance and raises an alert when it goes offline or reports again
+func (w *Watchdog) checkInstance(twinInterface, twinInstance string, interval time.Duration, now time.Time) error {
+	latestEvent, err := keventstore.GetLatestTwinEvent(twinInterface, twinInstance)
+	if err != nil {
+		return err
+	}
+	if latestEvent == nil || latestEvent.CloudEvent.Time().IsZero() {
+		return nil
+	}
+
+	lastReported := latestEvent.CloudEvent.Time()
+	interval = reportingInterval(latestEvent, interval)
+	missedIntervals := int(now.Sub(lastReported) / interval)
+
+	offline := missedIntervals >= w.config.MissedIntervals
+	previousStatus, err := w.lastStatus(twinInterface, twinInstance)
+	if err != nil {
+		return err
+	}
+	if offline == (previousStatus == STATUS_OFFLINE) {
+		return nil
+	}
+
+	status := StatusEvent{
+		Status:            STATUS_ONLINE,
+		ReportingInterval: int(interval.Seconds()),
+		MissedIntervals:   missedIntervals,
+		DateLastReported:  &lastReported,
+		DateModified:      &now,
+	}
+	alert := Alert{
+		Severity:      SEVERITY_RESOLVED,
+		TwinInterface: twinInterface,
+		TwinInstance:  twinInstance,
+		Message:       fmt.Sprintf("reporting again since %s", lastReported.Format(time.RFC3339)),
+		DateCreated:   &now,
+	}
+	if offline {
+		status.Status = STATUS_OFFLINE
+		alert.Severity = SEVERITY_WARNING
+		alert.Message = fmt.Sprintf("no reports for %d intervals of %s since %s", missedIntervals, interval, lastReported.Format(time.RFC3339))
+	}
+
+	// The status is published to the real twin, as a ktwin.virtual event of the instance
+	if err := kevent.PublishToRealTwin(twinInterface, twinInstance, status); err != nil {
+		return err
+	}
+	if err := w.storeStatus(twinInterface, twinInstance, status); err != nil {
+		return err
+	}
+	return w.alerter.Raise(alert)
+}
+
+// Gets the last status published for the instance, online when none was
+func (w *Watchdog) lastStatus(twinInterface, twinInstance string) (InstanceStatus, error) {
+	key := statusKey(twinInterface, twinInstance)
+	if status, ok := w.statuses[key]; ok {
+		return status, nil
+	}
+
+	latestEvent, err := keventstore.GetLatestTwinEvent(twinInterface+STATUS_INTERFACE_SUFFIX, twinInstance)
+	if err != nil {
+		return "", err
+	}
+
+	status := StatusEvent{Status: STATUS_ONLINE}
+	if latestEvent != nil {
+		if err := latestEvent.ToStoredModel(&status); err != nil {
+			return "", err
+		}
+	}
+
+	w.statuses[key] = status.Status
+	return status.Status, nil
+}
+
+func (w *Watchdog) storeStatus(twinInterface, twinInstance string, status StatusEvent) error {
+	statusEvent := ktwin.NewTwinEvent()
+	if err := statusEvent.SetEvent(twinInterface+STATUS_INTERFACE_SUFFIX, twinInstance, ktwin.RealEvent, status); err != nil {
+		return err
+	}
+	if err := keventstore.UpdateTwinEvent(statusEvent); err != nil {
+		return err
+	}
+
+	w.statuses[statusKey(twinInterface, twinInstance)] = status.Status
+	return nil
+}
+
+// Gets the reporting interval of the instance, the measurementFrequency in minutes of its latest event
+// when it has one
+func reportingInterval(latestEvent *ktwin.TwinEvent, defaultInterval time.Duration) time.Duration {
+	var data struct {
+		MeasurementFrequency int `json:"measurementFrequency"`
+	}
+	if err := json.Unmarshal(latestEvent.CloudEvent.Data(), &data); err == nil && data.MeasurementFrequency > 0 {
+		return time.Duration(data.MeasurementFrequency) * time.Minute
+	}
+	return defaultInterval
+}
@@ -0,0 +1,207 @@
+package model
+
+import (
+	"math"
+	"time"
+)
+
+var (
+	TWIN_COMMAND_UPDATE_NOISE_LEVEL  = "updateNoiseLevel"
+	TWIN_COMMAND_UPDATE_TRAFFIC_FLOW = "updateTrafficFlow"
+	TWIN_COMMAND_UPDATE_CROWD_FLOW   = "updateCrowdFlow"
+	TWIN_COMMAND_UPDATE_PARKING      = "updateParking"
+	TWIN_COMMAND_UPDATE_WEATHER      = "updateWeather"
+)
+
+// Domains of the livability score
+const (
+	DOMAIN_AIR_QUALITY = "airQuality"
+	DOMAIN_NOISE       = "noise"
+	DOMAIN_TRAFFIC     = "traffic"
+	DOMAIN_CROWD       = "crowd"
+	DOMAIN_PARKING     = "parking"
+	DOMAIN_WEATHER     = "weather"
+)
+
+var Domains = []string{DOMAIN_AIR_QUALITY, DOMAIN_NOISE, DOMAIN_TRAFFIC, DOMAIN_CROWD, DOMAIN_PARKING, DOMAIN_WEATHER}
+
+type UpdateNoiseLevelCommand struct {
+	// Equivalent continuous sound level in dB(A)
+	LAeq float64 `json:"LAeq,omitempty" validate:"min=0" protobuf:"1"`
+}
+
+type UpdateTrafficFlowCommand struct {
+	Congested bool    `json:"congested,omitempty" protobuf:"1"`
+	Occupancy float64 `json:"occupancy,omitempty" validate:"min=0,max=1" protobuf:"2"`
+}
+
+type UpdateCrowdFlowCommand struct {
+	Congested   bool `json:"congested,omitempty" protobuf:"1"`
+	PeopleCount int  `json:"peopleCount,omitempty" validate:"min=0" protobuf:"2"`
+}
+
+type UpdateParkingCommand struct {
+	AvailableSpotNumber int `json:"availableSpotNumber,omitempty" validate:"min=0" protobuf:"1"`
+	TotalSpotNumber     int `json:"totalSpotNumber,omitempty" validate:"min=0" protobuf:"2"`
+}
+
+type UpdateWeatherCommand struct {
+	Temperature      float64 `json:"temperature,omitempty" protobuf:"1"`
+	RelativeHumidity float64 `json:"relativeHumidity,omitempty" validate:"min=0,max=100" protobuf:"2"`
+}
+
+// Thresholds of the domain subscores
+type LivabilityThresholds struct {
+	// Noise level in dB(A) up to which the noise subscore is 100, and from which it is 0
+	QuietNoiseLevel float64 `yaml:"quietNoiseLevel" protobuf:"1"`
+	LoudNoiseLevel  float64 `yaml:"loudNoiseLevel" protobuf:"2"`
+	// Temperature range in °C where the weather subscore is 100, it loses 10 points per degree outside
+	ComfortTemperatureMin float64 `yaml:"comfortTemperatureMin" protobuf:"3"`
+	ComfortTemperatureMax float64 `yaml:"comfortTemperatureMax" protobuf:"4"`
+}
+
+// Contribution is the latest subscore reported by a source of the neighborhood for a domain
+type Contribution struct {
+	Subscore            float64    `json:"subscore" protobuf:"1"`
+	AvailableSpotNumber int        `json:"availableSpotNumber,omitempty" protobuf:"2"`
+	TotalSpotNumber     int        `json:"totalSpotNumber,omitempty" protobuf:"3"`
+	DateObserved        *time.Time `json:"dateObserved,omitempty" protobuf:"4"`
+}
+
+// LivabilityRecord is a livability score of the history
+type LivabilityRecord struct {
+	Score        float64    `json:"score" protobuf:"1"`
+	DateObserved *time.Time `json:"dateObserved,omitempty" protobuf:"2"`
+}
+
+// Livability is the weighted score, from 0 to 100, of the domain subscores of the neighborhood
+type Livability struct {
+	Score float64 `json:"score" protobuf:"1"`
+	// Subscore of each domain with contributions, from 0 to 100
+	Subscores map[string]float64 `json:"subscores,omitempty" protobuf:"2"`
+	// Latest contribution of each source instance, by domain
+	Contributions map[string]map[string]Contribution `json:"contributions,omitempty" protobuf:"3"`
+	// Latest scores, the oldest first
+	History []LivabilityRecord `json:"history,omitempty" protobuf:"4"`
+}
+
+// Sets the contribution of the source to the domain
+func (l *Livability) Contribute(domain, source string, contribution Contribution) {
+	if l.Contributions == nil {
+		l.Contributions = map[string]map[string]Contribution{}
+	}
+	if l.Contributions[domain] == nil {
+		l.Contributions[domain] = map[string]Contribution{}
+	}
+	l.Contributions[domain][source] = contribution
+}
+
+// Drops the contributions observed before the time
+func (l *Livability) DropStaleContributions(observedAfter time.Time) {
+	for domain, sources := range l.Contributions {
+		for source, contribution := range sources {
+			if contribution.DateObserved == nil || contribution.DateObserved.Before(observedAfter) {
+				delete(sources, source)
+			}
+		}
+		if len(sources) == 0 {
+			delete(l.Contributions, domain)
+		}
+	}
+}
+
+// Gets the available and total parking spots of the contributions
+func (l *Livability) ParkingSpots() (int, int) {
+	available, total := 0, 0
+	for _, contribution := range l.Contributions[DOMAIN_PARKING] {
+		available += contribution.AvailableSpotNumber
+		total += contribution.TotalSpotNumber
+	}
+	return available, total
+}
+
+// Calculates the subscores, the average of the contributions of each domain, the parking one from the
+// spots of all the contributions, and the air quality one from the level, when it is set. The score
+// is their average weighted by the domain weights, domains without weight count 1. When the score
+// changes it is recorded in the history, which keeps the latest historySize scores.
+func (l *Livability) Calculate(aqiLevel AQICategory, weights map[string]float64, historySize int, now *time.Time) {
+	l.Subscores = map[string]float64{}
+	if level := GetQualityLevelInteger(aqiLevel); level > 0 {
+		l.Subscores[DOMAIN_AIR_QUALITY] = float64(len(categories)-level) / float64(len(categories)-1) * 100
+	}
+
+	for domain, sources := range l.Contributions {
+		if domain == DOMAIN_PARKING {
+			if available, total := l.ParkingSpots(); total > 0 {
+				l.Subscores[domain] = float64(available) / float64(total) * 100
+			}
+			continue
+		}
+
+		sum := 0.0
+		for _, contribution := range sources {
+			sum += contribution.Subscore
+		}
+		l.Subscores[domain] = sum / float64(len(sources))
+	}
+
+	sum, totalWeight := 0.0, 0.0
+	for domain, subscore := range l.Subscores {
+		weight, ok := weights[domain]
+		if !ok {
+			weight = 1
+		}
+		sum += weight * subscore
+		totalWeight += weight
+	}
+
+	score := 0.0
+	if totalWeight > 0 {
+		score = math.Round(sum/totalWeight*10) / 10
+	}
+
+	if len(l.History) == 0 || l.History[len(l.History)-1].Score != score {
+		l.History = append(l.History, LivabilityRecord{Score: score, DateObserved: now})
+		if len(l.History) > historySize {
+			l.History = l.History[len(l.History)-historySize:]
+		}
+	}
+	l.Score = score
+}
+
+// Subscore of the noise level, linear between the quiet and the loud levels
+func NoiseSubscore(command UpdateNoiseLevelCommand, thresholds LivabilityThresholds) float64 {
+	return clampSubscore((thresholds.LoudNoiseLevel - command.LAeq) / (thresholds.LoudNoiseLevel - thresholds.QuietNoiseLevel) * 100)
+}
+
+// Subscore of the traffic flow, the free fraction of the lane, halved when it is congested
+func TrafficSubscore(command UpdateTrafficFlowCommand) float64 {
+	subscore := (1 - command.Occupancy) * 100
+	if command.Congested {
+		subscore /= 2
+	}
+	return clampSubscore(subscore)
+}
+
+// Subscore of the crowd flow, 0 when the walkway is congested
+func CrowdSubscore(command UpdateCrowdFlowCommand) float64 {
+	if command.Congested {
+		return 0
+	}
+	return 100
+}
+
+// Subscore of the weather, 100 in the comfort temperature range and 10 points less per degree outside
+func WeatherSubscore(command UpdateWeatherCommand, thresholds LivabilityThresholds) float64 {
+	distance := 0.0
+	if command.Temperature < thresholds.ComfortTemperatureMin {
+		distance = thresholds.ComfortTemperatureMin - command.Temperature
+	} else if command.Temperature > thresholds.ComfortTemperatureMax {
+		distance = command.Temperature - thresholds.ComfortTemperatureMax
+	}
+	return clampSubscore(100 - 10*distance)
+}
+
+func clampSubscore(subscore float64) float64 {
+	return math.Max(0, math.Min(100, subscore))
+}
@@ -1,11 +1,23 @@
 package model
 
-import "time"
+import (
+	"math"
+	"sort"
+	"time"
+)
 
 var (
 	TWIN_INTERFACE_NEIGHBORHOOD           = "s4city-city-neighborhood"
 	TWIN_INTERFACE_CITY_POLE              = "city-pole"
 	TWIN_COMMAND_UPDATE_AIR_QUALITY_INDEX = "updateAirQualityIndex"
+
+	// Timer dropping the air quality of the poles once it expires without another command
+	TWIN_TIMER_EXPIRE_AIR_QUALITY = "expireAirQuality"
+
+	// City Update Neighborhood Command, the city references its neighborhoods
+	TWIN_INTERFACE_CITY                      = "s4city-city-city"
+	TWIN_COMMAND_CITY_UPDATE_NEIGHBORHOOD    = "updateNeighborhood"
+	TWIN_COMMAND_CITY_NEIGHBORHOODS_RELATION = "neighborhoods"
 )
 
 type AQICategory string
@@ -39,11 +51,162 @@ const (
 )
 
 type UpdateAirQualityIndexCommand struct {
-	AqiLevel AQICategory `json:"aqiLevel,omitempty"`
+	AqiLevel          AQICategory `json:"aqiLevel,omitempty" validate:"oneof=GOOD MODERATE UNHEALTHY_FOR_SENSITIVE_GROUPS UNHEALTHY VERY_UNHEALTHY HAZARDOUS" protobuf:"1"`
+	AirQualityIndex   int         `json:"airQualityIndex,omitempty" protobuf:"2"`
+	DominantPollutant string      `json:"dominantPollutant,omitempty" protobuf:"3"`
+}
+
+// UpdateNeighborhoodCommand is sent to the city when the KPIs of the neighborhood change
+type UpdateNeighborhoodCommand struct {
+	AqiLevel            AQICategory        `json:"aqiLevel,omitempty" protobuf:"1"`
+	AvailableSpotNumber *int               `json:"availableSpotNumber,omitempty" protobuf:"2"`
+	TotalSpotNumber     *int               `json:"totalSpotNumber,omitempty" protobuf:"3"`
+	Kpis                map[string]float64 `json:"kpis,omitempty" protobuf:"4"`
 }
 
 type Neighborhood struct {
-	AqiLevel     AQICategory `json:"aqiLevel,omitempty"`
-	DateObserved *time.Time  `json:"dateObserved,omitempty"`
-	DateModified *time.Time  `json:"dateModified,omitempty"`
+	AqiLevel       AQICategory `json:"aqiLevel,omitempty" validate:"oneof=GOOD MODERATE UNHEALTHY_FOR_SENSITIVE_GROUPS UNHEALTHY VERY_UNHEALTHY HAZARDOUS" protobuf:"1"`
+	AqiAggregation string      `json:"aqiAggregation,omitempty" protobuf:"2"`
+	DateObserved   *time.Time  `json:"dateObserved,omitempty" protobuf:"3"`
+	DateModified   *time.Time  `json:"dateModified,omitempty" protobuf:"4"`
+	// Latest air quality reported by each contributing pole, by pole instance
+	Poles map[string]PoleContribution `json:"poles,omitempty" protobuf:"5"`
+	// Sensors missing in each pole, by pole instance
+	MissingSensors map[string][]string `json:"missingSensors,omitempty" protobuf:"6"`
+	Livability     Livability          `json:"livability" protobuf:"7"`
+}
+
+// Sets the sensors missing in the pole
+func (n *Neighborhood) SetMissingSensors(pole string, sensors []string) {
+	if len(sensors) == 0 {
+		delete(n.MissingSensors, pole)
+		return
+	}
+	if n.MissingSensors == nil {
+		n.MissingSensors = map[string][]string{}
+	}
+	n.MissingSensors[pole] = sensors
+}
+
+// Gets the KPIs of the neighborhood sent to the city
+func (n *Neighborhood) CityCommand() UpdateNeighborhoodCommand {
+	command := UpdateNeighborhoodCommand{
+		AqiLevel: n.AqiLevel,
+		Kpis:     map[string]float64{"livability": n.Livability.Score},
+	}
+	if _, ok := n.Livability.Contributions[DOMAIN_PARKING]; ok {
+		available, total := n.Livability.ParkingSpots()
+		command.AvailableSpotNumber, command.TotalSpotNumber = &available, &total
+	}
+	return command
+}
+
+// Calculates the livability of the neighborhood. The air quality level is GOOD when no pole contributes,
+// so the air quality subscore is only added when a pole does.
+func (n *Neighborhood) CalculateLivability(weights map[string]float64, historySize int, now *time.Time) {
+	var aqiLevel AQICategory
+	if len(n.Poles) > 0 {
+		aqiLevel = n.AqiLevel
+	}
+	n.Livability.Calculate(aqiLevel, weights, historySize, now)
+}
+
+// PoleContribution is the latest air quality reported by a pole of the neighborhood
+type PoleContribution struct {
+	AqiLevel          AQICategory `json:"aqiLevel,omitempty" validate:"oneof=GOOD MODERATE UNHEALTHY_FOR_SENSITIVE_GROUPS UNHEALTHY VERY_UNHEALTHY HAZARDOUS" protobuf:"1"`
+	AirQualityIndex   int         `json:"airQualityIndex,omitempty" protobuf:"2"`
+	DominantPollutant string      `json:"dominantPollutant,omitempty" protobuf:"3"`
+	DateObserved      *time.Time  `json:"dateObserved,omitempty" protobuf:"4"`
+}
+
+const (
+	// Worst level of the poles
+	AGGREGATION_MAX = "max"
+	// Level at the percentile of the levels of the poles, by the nearest rank
+	AGGREGATION_PERCENTILE = "percentile"
+	// Average of the levels of the poles weighted by the population they serve, rounded to the nearest level
+	AGGREGATION_POPULATION = "population"
+)
+
+var categories = []AQICategory{GOOD, MODERATE, UNHEALTHY_FOR_SENSITIVE_GROUPS, UNHEALTHY, VERY_UNHEALTHY, HAZARDOUS}
+
+// Drops the contributions of the poles observed before the time
+func (n *Neighborhood) DropStalePoles(observedAfter time.Time) {
+	for pole, contribution := range n.Poles {
+		if contribution.DateObserved == nil || contribution.DateObserved.Before(observedAfter) {
+			delete(n.Poles, pole)
+		}
+	}
+}
+
+// Gets the time the oldest contribution of the poles expires, nil when no pole contributes
+func (n *Neighborhood) NextPoleExpiry(expiry time.Duration) *time.Time {
+	var next *time.Time
+	for _, contribution := range n.Poles {
+		if contribution.DateObserved == nil {
+			continue
+		}
+		expiresAt := contribution.DateObserved.Add(expiry)
+		if next == nil || expiresAt.Before(*next) {
+			next = &expiresAt
+		}
+	}
+	return next
+}
+
+// Calculates the level of the neighborhood from the levels of its poles. The percentile is used by
+// the percentile aggregation, the populations served by the poles by the population aggregation,
+// poles without population weight 1. It is GOOD when no pole contributes.
+func (n *Neighborhood) AggregateAqiLevel(aggregation string, percentile float64, populations map[string]float64) {
+	n.AqiAggregation = aggregation
+	if len(n.Poles) == 0 {
+		n.AqiLevel = GOOD
+		return
+	}
+
+	switch aggregation {
+	case AGGREGATION_PERCENTILE:
+		var levels []int
+		for _, contribution := range n.Poles {
+			levels = append(levels, GetQualityLevelInteger(contribution.AqiLevel))
+		}
+		sort.Ints(levels)
+
+		rank := int(math.Ceil(percentile / 100 * float64(len(levels))))
+		if rank < 1 {
+			rank = 1
+		}
+		n.AqiLevel = getQualityLevelCategory(levels[rank-1])
+	case AGGREGATION_POPULATION:
+		sum, weights := 0.0, 0.0
+		for pole, contribution := range n.Poles {
+			weight, ok := populations[pole]
+			if !ok {
+				weight = 1
+			}
+			sum += weight * float64(GetQualityLevelInteger(contribution.AqiLevel))
+			weights += weight
+		}
+
+		if weights == 0 {
+			n.AqiLevel = GOOD
+			return
+		}
+		n.AqiLevel = getQualityLevelCategory(int(math.Round(sum / weights)))
+	default:
+		level := 0
+		for _, contribution := range n.Poles {
+			if contributionLevel := GetQualityLevelInteger(contribution.AqiLevel); contributionLevel > level {
+				level = contributionLevel
+			}
+		}
+		n.AqiLevel = getQualityLevelCategory(level)
+	}
+}
+
+func getQualityLevelCategory(level int) AQICategory {
+	if level < 1 {
+		return GOOD
+	}
+	return categories[level-1]
 }
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestNeighborhoodSuite(t *testing.T) {
+	suite.Run(t, new(NeighborhoodSuite))
+}
+
+type NeighborhoodSuite struct {
+	suite.Suite
+}
+
+func (s *NeighborhoodSuite) Test_ProtobufRoundTrip() {
+	codec, err := ktwin.GetCodec(ktwin.ApplicationProtobuf)
+	s.Require().NoError(err)
+
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	expected := Neighborhood{
+		AqiLevel:       MODERATE,
+		AqiAggregation: AGGREGATION_MAX,
+		DateObserved:   &now,
+		Poles: map[string]PoleContribution{
+			"city-pole-nb001-p00001": {AqiLevel: MODERATE, AirQualityIndex: 60, DominantPollutant: "PM25", DateObserved: &now},
+			"city-pole-nb001-p00002": {AqiLevel: GOOD, DateObserved: &now},
+		},
+		MissingSensors: map[string][]string{"city-pole-nb001-p00001": {"refNoiseLevel", "refWeather"}},
+		Livability: Livability{
+			Score:     80,
+			Subscores: map[string]float64{DOMAIN_AIR_QUALITY: 80, DOMAIN_PARKING: 0},
+			Contributions: map[string]map[string]Contribution{
+				DOMAIN_PARKING: {"ngsi-ld-city-offstreetparking-nb001-ofp0001": {AvailableSpotNumber: 0, TotalSpotNumber: 50, DateObserved: &now}},
+			},
+			History: []LivabilityRecord{{Score: 80, DateObserved: &now}},
+		},
+	}
+
+	data, err := codec.Marshal(expected)
+	s.Require().NoError(err)
+	var actual Neighborhood
+	s.Require().NoError(codec.Unmarshal(data, &actual))
+	s.Assert().Equal(expected, actual)
+}
@@ -0,0 +1,14 @@
+package model
+
+var TWIN_COMMAND_UPDATE_POLE = "updatePole"
+
+// UpdatePoleCommand is the consolidated update of a pole, with the summaries of its sensors that are
+// not missing
+type UpdatePoleCommand struct {
+	AirQuality     *UpdateAirQualityIndexCommand `json:"airQuality,omitempty" protobuf:"1"`
+	NoiseLevel     *UpdateNoiseLevelCommand      `json:"noiseLevel,omitempty" protobuf:"2"`
+	Weather        *UpdateWeatherCommand         `json:"weather,omitempty" protobuf:"3"`
+	CrowdFlow      *UpdateCrowdFlowCommand       `json:"crowdFlow,omitempty" protobuf:"4"`
+	TrafficFlow    *UpdateTrafficFlowCommand     `json:"trafficFlow,omitempty" protobuf:"5"`
+	MissingSensors []string                      `json:"missingSensors,omitempty" protobuf:"6"`
+}
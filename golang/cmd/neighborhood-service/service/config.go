@@ -0,0 +1,110 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/neighborhood-service/model"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kpolicy"
+)
+
+// Config of the neighborhood service, the services.neighborhood section of the config.
+// The aggregation can be chosen per neighborhood with the policy overrides.
+type Config struct {
+	// Time after which the level reported by a pole is stale, and no longer contributes to the neighborhood
+	AqiExpiry time.Duration `yaml:"aqiExpiry" env:"KTWIN_NEIGHBORHOOD_AQI_EXPIRY"`
+	// Aggregation of the levels of the poles: max, percentile or population
+	AqiAggregation string `yaml:"aqiAggregation" env:"KTWIN_NEIGHBORHOOD_AQI_AGGREGATION"`
+	// Percentile of the percentile aggregation
+	AqiPercentile float64 `yaml:"aqiPercentile" env:"KTWIN_NEIGHBORHOOD_AQI_PERCENTILE"`
+	// Population served by each pole instance, for the population aggregation
+	Populations map[string]float64 `yaml:"populations"`
+	// Weight of each domain in the livability score, domains without weight count 1
+	LivabilityWeights map[string]float64 `yaml:"livabilityWeights"`
+	// Time after which the contribution of a source is stale, and no longer counts in the livability score
+	LivabilityExpiry time.Duration `yaml:"livabilityExpiry" env:"KTWIN_NEIGHBORHOOD_LIVABILITY_EXPIRY"`
+	// Number of livability scores kept in the history
+	LivabilityHistorySize int                        `yaml:"livabilityHistorySize" env:"KTWIN_NEIGHBORHOOD_LIVABILITY_HISTORY_SIZE"`
+	LivabilityThresholds  model.LivabilityThresholds `yaml:"livabilityThresholds"`
+}
+
+var serviceConfig = Config{
+	AqiExpiry:      60 * time.Minute,
+	AqiAggregation: model.AGGREGATION_MAX,
+	AqiPercentile:  90,
+	LivabilityWeights: map[string]float64{
+		model.DOMAIN_AIR_QUALITY: 3,
+		model.DOMAIN_NOISE:       2,
+		model.DOMAIN_TRAFFIC:     1,
+		model.DOMAIN_CROWD:       1,
+		model.DOMAIN_PARKING:     1,
+		model.DOMAIN_WEATHER:     1,
+	},
+	LivabilityExpiry:      60 * time.Minute,
+	LivabilityHistorySize: 24,
+	LivabilityThresholds: model.LivabilityThresholds{
+		QuietNoiseLevel:       45,
+		LoudNoiseLevel:        75,
+		ComfortTemperatureMin: 18,
+		ComfortTemperatureMax: 26,
+	},
+}
+
+func (c *Config) Validate() error {
+	if c.AqiExpiry <= 0 {
+		return errors.New("aqiExpiry must be greater than 0")
+	}
+	switch c.AqiAggregation {
+	case model.AGGREGATION_MAX, model.AGGREGATION_PERCENTILE, model.AGGREGATION_POPULATION:
+	default:
+		return fmt.Errorf("invalid aqiAggregation %q, must be one of %s, %s or %s", c.AqiAggregation, model.AGGREGATION_MAX, model.AGGREGATION_PERCENTILE, model.AGGREGATION_POPULATION)
+	}
+	if c.AqiPercentile <= 0 || c.AqiPercentile > 100 {
+		return errors.New("aqiPercentile must be greater than 0 and at most 100")
+	}
+	for pole, population := range c.Populations {
+		if population < 0 {
+			return fmt.Errorf("population of %s must not be negative", pole)
+		}
+	}
+	for domain, weight := range c.LivabilityWeights {
+		if !isDomain(domain) {
+			return fmt.Errorf("unknown livability domain %q, must be one of %s", domain, strings.Join(model.Domains, ", "))
+		}
+		if weight < 0 {
+			return fmt.Errorf("livability weight of %s must not be negative", domain)
+		}
+	}
+	if c.LivabilityExpiry <= 0 {
+		return errors.New("livabilityExpiry must be greater than 0")
+	}
+	if c.LivabilityHistorySize <= 0 {
+		return errors.New("livabilityHistorySize must be greater than 0")
+	}
+	if c.LivabilityThresholds.QuietNoiseLevel >= c.LivabilityThresholds.LoudNoiseLevel {
+		return errors.New("livabilityThresholds.quietNoiseLevel must be lower than loudNoiseLevel")
+	}
+	if c.LivabilityThresholds.ComfortTemperatureMin > c.LivabilityThresholds.ComfortTemperatureMax {
+		return errors.New("livabilityThresholds.comfortTemperatureMin must not be greater than comfortTemperatureMax")
+	}
+	return nil
+}
+
+func LoadConfig() error {
+	if err := config.LoadService("neighborhood", &serviceConfig); err != nil {
+		return err
+	}
+	return kpolicy.Register(model.TWIN_INTERFACE_NEIGHBORHOOD, serviceConfig)
+}
+
+func isDomain(name string) bool {
+	for _, domain := range model.Domains {
+		if domain == name {
+			return true
+		}
+	}
+	return false
+}
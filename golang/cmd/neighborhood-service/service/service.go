@@ -1,6 +1,8 @@
 package service
 
 import (
+	"fmt"
+	"reflect"
 	"time"
 
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/neighborhood-service/model"
@@ -8,6 +10,8 @@ import (
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kcommand"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/keventstore"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kpolicy"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kscheduler"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/ktwingraph"
 	log "github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
 )
@@ -18,7 +22,7 @@ var twinGraph *ktwin.TwinGraph
 func loadTwinGraph() error {
 	if twinGraph == nil {
 		var err error
-		graph, err := ktwingraph.LoadTwinGraphByInterfaces([]string{model.TWIN_INTERFACE_NEIGHBORHOOD})
+		graph, err := ktwingraph.LoadTwinGraphByInterfaces([]string{model.TWIN_INTERFACE_NEIGHBORHOOD, model.TWIN_INTERFACE_CITY})
 		if err != nil {
 			logger.Error("Error loading twin graph", err)
 			return err
@@ -28,63 +32,272 @@ func loadTwinGraph() error {
 	return nil
 }
 
+// Handlers of the commands of the neighborhood, the air quality index, the domains of the livability score
+// and the consolidated update of the poles
+var commandHandlers = map[string]func(*ktwin.TwinEvent) error{
+	model.TWIN_COMMAND_UPDATE_AIR_QUALITY_INDEX: handleUpdateAirQualityIndex,
+	model.TWIN_COMMAND_UPDATE_NOISE_LEVEL:       handleUpdateNoiseLevel,
+	model.TWIN_COMMAND_UPDATE_TRAFFIC_FLOW:      handleUpdateTrafficFlow,
+	model.TWIN_COMMAND_UPDATE_CROWD_FLOW:        handleUpdateCrowdFlow,
+	model.TWIN_COMMAND_UPDATE_PARKING:           handleUpdateParking,
+	model.TWIN_COMMAND_UPDATE_WEATHER:           handleUpdateWeather,
+	model.TWIN_COMMAND_UPDATE_POLE:              handleUpdatePole,
+}
+
 func HandleEvent(event *ktwin.TwinEvent) error {
 	err := loadTwinGraph()
 	if err != nil {
 		return err
 	}
-	return kcommand.HandleCommand(event, model.TWIN_INTERFACE_NEIGHBORHOOD, model.TWIN_COMMAND_UPDATE_AIR_QUALITY_INDEX, *twinGraph, handleUpdateAirQualityIndex)
+
+	if event.EventType == ktwin.TimerEvent {
+		return kscheduler.HandleTimer(event, model.TWIN_INTERFACE_NEIGHBORHOOD, model.TWIN_TIMER_EXPIRE_AIR_QUALITY, handleExpireAirQuality)
+	}
+
+	for command, handler := range commandHandlers {
+		if err := kcommand.HandleCommand(event, model.TWIN_INTERFACE_NEIGHBORHOOD, command, *twinGraph, handler); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func handleUpdateAirQualityIndex(command *ktwin.TwinEvent) error {
-	latestEvent, err := keventstore.GetLatestTwinEvent(command.TwinInterface, command.TwinInstance)
+	var updateAirQualityIndexCommand model.UpdateAirQualityIndexCommand
+	err := command.ToModel(&updateAirQualityIndexCommand)
+	if err != nil {
+		return err
+	}
+
+	if updateAirQualityIndexCommand.AqiLevel == "" {
+		logger.Info("AqiLevel not provided")
+		return nil
+	}
+
+	return updateNeighborhood(command, func(neighborhood *model.Neighborhood, source string, now *time.Time, policy Config) {
+		contributeAirQuality(neighborhood, source, now, updateAirQualityIndexCommand)
+	})
+}
+
+func handleUpdateNoiseLevel(command *ktwin.TwinEvent) error {
+	var updateNoiseLevelCommand model.UpdateNoiseLevelCommand
+	if err := command.ToModel(&updateNoiseLevelCommand); err != nil {
+		return err
+	}
+
+	return updateNeighborhood(command, func(neighborhood *model.Neighborhood, source string, now *time.Time, policy Config) {
+		contributeNoiseLevel(neighborhood, source, now, policy, updateNoiseLevelCommand)
+	})
+}
 
+func handleUpdateTrafficFlow(command *ktwin.TwinEvent) error {
+	var updateTrafficFlowCommand model.UpdateTrafficFlowCommand
+	if err := command.ToModel(&updateTrafficFlowCommand); err != nil {
+		return err
+	}
+
+	return updateNeighborhood(command, func(neighborhood *model.Neighborhood, source string, now *time.Time, policy Config) {
+		contributeTrafficFlow(neighborhood, source, now, updateTrafficFlowCommand)
+	})
+}
+
+func handleUpdateCrowdFlow(command *ktwin.TwinEvent) error {
+	var updateCrowdFlowCommand model.UpdateCrowdFlowCommand
+	if err := command.ToModel(&updateCrowdFlowCommand); err != nil {
+		return err
+	}
+
+	return updateNeighborhood(command, func(neighborhood *model.Neighborhood, source string, now *time.Time, policy Config) {
+		contributeCrowdFlow(neighborhood, source, now, updateCrowdFlowCommand)
+	})
+}
+
+func handleUpdateParking(command *ktwin.TwinEvent) error {
+	var updateParkingCommand model.UpdateParkingCommand
+	if err := command.ToModel(&updateParkingCommand); err != nil {
+		return err
+	}
+
+	return updateNeighborhood(command, func(neighborhood *model.Neighborhood, source string, now *time.Time, policy Config) {
+		neighborhood.Livability.Contribute(model.DOMAIN_PARKING, source, model.Contribution{
+			AvailableSpotNumber: updateParkingCommand.AvailableSpotNumber,
+			TotalSpotNumber:     updateParkingCommand.TotalSpotNumber,
+			DateObserved:        now,
+		})
+	})
+}
+
+func handleUpdateWeather(command *ktwin.TwinEvent) error {
+	var updateWeatherCommand model.UpdateWeatherCommand
+	if err := command.ToModel(&updateWeatherCommand); err != nil {
+		return err
+	}
+
+	return updateNeighborhood(command, func(neighborhood *model.Neighborhood, source string, now *time.Time, policy Config) {
+		contributeWeather(neighborhood, source, now, policy, updateWeatherCommand)
+	})
+}
+
+// Handles the consolidated update of a pole, with the summaries of its sensors in a single update
+func handleUpdatePole(command *ktwin.TwinEvent) error {
+	var updatePoleCommand model.UpdatePoleCommand
+	if err := command.ToModel(&updatePoleCommand); err != nil {
+		return err
+	}
+
+	return updateNeighborhood(command, func(neighborhood *model.Neighborhood, source string, now *time.Time, policy Config) {
+		if updatePoleCommand.AirQuality != nil && updatePoleCommand.AirQuality.AqiLevel != "" {
+			contributeAirQuality(neighborhood, source, now, *updatePoleCommand.AirQuality)
+		}
+		if updatePoleCommand.NoiseLevel != nil {
+			contributeNoiseLevel(neighborhood, source, now, policy, *updatePoleCommand.NoiseLevel)
+		}
+		if updatePoleCommand.TrafficFlow != nil {
+			contributeTrafficFlow(neighborhood, source, now, *updatePoleCommand.TrafficFlow)
+		}
+		if updatePoleCommand.CrowdFlow != nil {
+			contributeCrowdFlow(neighborhood, source, now, *updatePoleCommand.CrowdFlow)
+		}
+		if updatePoleCommand.Weather != nil {
+			contributeWeather(neighborhood, source, now, policy, *updatePoleCommand.Weather)
+		}
+		neighborhood.SetMissingSensors(source, updatePoleCommand.MissingSensors)
+	})
+}
+
+// Aggregates the neighborhood again once the air quality of a pole expires, without waiting for the next command
+func handleExpireAirQuality(timer *ktwin.TwinEvent) error {
+	return updateNeighborhood(timer, func(neighborhood *model.Neighborhood, source string, now *time.Time, policy Config) {})
+}
+
+func contributeAirQuality(neighborhood *model.Neighborhood, source string, now *time.Time, command model.UpdateAirQualityIndexCommand) {
+	if neighborhood.Poles == nil {
+		neighborhood.Poles = map[string]model.PoleContribution{}
+	}
+	neighborhood.Poles[source] = model.PoleContribution{
+		AqiLevel:          command.AqiLevel,
+		AirQualityIndex:   command.AirQualityIndex,
+		DominantPollutant: command.DominantPollutant,
+		DateObserved:      now,
+	}
+}
+
+func contributeNoiseLevel(neighborhood *model.Neighborhood, source string, now *time.Time, policy Config, command model.UpdateNoiseLevelCommand) {
+	subscore := model.NoiseSubscore(command, policy.LivabilityThresholds)
+	neighborhood.Livability.Contribute(model.DOMAIN_NOISE, source, model.Contribution{Subscore: subscore, DateObserved: now})
+}
+
+func contributeTrafficFlow(neighborhood *model.Neighborhood, source string, now *time.Time, command model.UpdateTrafficFlowCommand) {
+	subscore := model.TrafficSubscore(command)
+	neighborhood.Livability.Contribute(model.DOMAIN_TRAFFIC, source, model.Contribution{Subscore: subscore, DateObserved: now})
+}
+
+func contributeCrowdFlow(neighborhood *model.Neighborhood, source string, now *time.Time, command model.UpdateCrowdFlowCommand) {
+	subscore := model.CrowdSubscore(command)
+	neighborhood.Livability.Contribute(model.DOMAIN_CROWD, source, model.Contribution{Subscore: subscore, DateObserved: now})
+}
+
+func contributeWeather(neighborhood *model.Neighborhood, source string, now *time.Time, policy Config, command model.UpdateWeatherCommand) {
+	subscore := model.WeatherSubscore(command, policy.LivabilityThresholds)
+	neighborhood.Livability.Contribute(model.DOMAIN_WEATHER, source, model.Contribution{Subscore: subscore, DateObserved: now})
+}
+
+// Applies the update of the command to the latest neighborhood, with the source instance of the command,
+// then aggregates its air quality and livability, stores it, and sends its KPIs to the city when they change.
+// Commands without causation are contributions of the neighborhood itself.
+func updateNeighborhood(command *ktwin.TwinEvent, update func(neighborhood *model.Neighborhood, source string, now *time.Time, policy Config)) error {
+	latestEvent, err := keventstore.GetLatestTwinEvent(command.TwinInterface, command.TwinInstance)
 	if err != nil {
 		return err
 	}
 
+	now := clock.Now()
 	var neighborhood model.Neighborhood
 
-	if latestEvent == nil {
-		now := clock.Now()
+	isNew := latestEvent == nil
+	if isNew && command.EventType == ktwin.TimerEvent {
+		return nil
+	}
+	if isNew {
 		neighborhood = model.Neighborhood{
 			AqiLevel:     model.GOOD,
-			DateObserved: now,
 			DateModified: now,
 		}
 		latestEvent = ktwin.NewTwinEvent()
-		latestEvent.SetEvent(command.TwinInterface, command.TwinInstance, ktwin.RealEvent, neighborhood)
+		err = latestEvent.SetEvent(command.TwinInterface, command.TwinInstance, ktwin.RealEvent, neighborhood)
+		if err != nil {
+			return err
+		}
 	} else {
-		err = latestEvent.ToModel(&neighborhood)
+		err = latestEvent.ToStoredModel(&neighborhood)
 		if err != nil {
 			return err
 		}
 	}
 
-	var updateAirQualityIndexCommand model.UpdateAirQualityIndexCommand
-	err = command.ToModel(&updateAirQualityIndexCommand)
+	source := command.Causation()
+	if source == "" {
+		source = command.TwinInstance
+	}
+
+	policy := kpolicy.ResolveOrDefault(model.TWIN_INTERFACE_NEIGHBORHOOD, command.TwinInstance, serviceConfig)
+	latestCityCommand := neighborhood.CityCommand()
+
+	update(&neighborhood, source, now, policy)
+
+	neighborhood.DropStalePoles(now.Add(-policy.AqiExpiry))
+	scheduleAirQualityExpiry(command.TwinInstance, neighborhood.NextPoleExpiry(policy.AqiExpiry))
+	neighborhood.AggregateAqiLevel(policy.AqiAggregation, policy.AqiPercentile, policy.Populations)
+	neighborhood.Livability.DropStaleContributions(now.Add(-policy.LivabilityExpiry))
+	neighborhood.CalculateLivability(policy.LivabilityWeights, policy.LivabilityHistorySize, now)
+	neighborhood.DateObserved = now
+
+	cityCommand := neighborhood.CityCommand()
+	changed := isNew || !reflect.DeepEqual(cityCommand, latestCityCommand)
+	if changed {
+		neighborhood.DateModified = now
+	}
+
+	err = latestEvent.SetData(neighborhood)
+	if err != nil {
+		return err
+	}
+	err = keventstore.UpdateTwinEvent(latestEvent)
 	if err != nil {
 		return err
 	}
 
-	if updateAirQualityIndexCommand.AqiLevel == "" {
-		logger.Info("AqiLevel not provided")
+	if !changed {
 		return nil
 	}
+	return publishUpdateNeighborhood(command.TwinInstance, cityCommand)
+}
 
-	newQualityIndexInt := model.GetQualityLevelInteger(updateAirQualityIndexCommand.AqiLevel)
-	latestQualityIndexInt := model.GetQualityLevelInteger(neighborhood.AqiLevel)
-
-	if newQualityIndexInt > latestQualityIndexInt || hasTimeExpired(clock.Now(), neighborhood.DateObserved, 60) {
-		neighborhood.AqiLevel = updateAirQualityIndexCommand.AqiLevel
-		neighborhood.DateModified = clock.Now()
+// Schedules the expiry timer at the time the oldest air quality of the poles expires, cancelling it
+// when no pole contributes
+func scheduleAirQualityExpiry(twinInstance string, expiresAt *time.Time) {
+	var err error
+	if expiresAt == nil {
+		err = kscheduler.Get().Cancel(model.TWIN_INTERFACE_NEIGHBORHOOD, twinInstance, model.TWIN_TIMER_EXPIRE_AIR_QUALITY)
+	} else {
+		err = kscheduler.Get().Schedule(model.TWIN_INTERFACE_NEIGHBORHOOD, twinInstance, model.TWIN_TIMER_EXPIRE_AIR_QUALITY, *expiresAt, nil)
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error scheduling the air quality expiry of TwinInstance %s", twinInstance), err)
 	}
-
-	latestEvent.SetData(neighborhood)
-
-	return keventstore.UpdateTwinEvent(latestEvent)
 }
 
-func hasTimeExpired(datetimeNow *time.Time, datetimeObserved *time.Time, minutes int) bool {
-	return datetimeNow.Sub(*datetimeObserved).Minutes() > float64(minutes)
+// Sends the KPIs of the neighborhood to its city
+func publishUpdateNeighborhood(twinInstance string, updateNeighborhoodCommand model.UpdateNeighborhoodCommand) error {
+	if ktwingraph.GetReverseRelationshipFromGraph(twinInstance, model.TWIN_COMMAND_CITY_NEIGHBORHOODS_RELATION, *twinGraph) == nil {
+		logger.Info(fmt.Sprintf("TwinInstance %s is not a neighborhood of a city", twinInstance))
+		return nil
+	}
+
+	err := kcommand.PublishCommandByReverseRelationship(model.TWIN_COMMAND_CITY_UPDATE_NEIGHBORHOOD, updateNeighborhoodCommand, model.TWIN_COMMAND_CITY_NEIGHBORHOODS_RELATION, twinInstance, *twinGraph)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error executing command %s in relation %s in TwinInstance %s\n", model.TWIN_COMMAND_CITY_UPDATE_NEIGHBORHOOD, model.TWIN_COMMAND_CITY_NEIGHBORHOODS_RELATION, twinInstance), err)
+		return err
+	}
+	return nil
 }
@@ -1,15 +1,23 @@
 package service
 
 import (
+	"encoding/json"
+	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	cityModel "github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/city-service/model"
+	cityService "github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/city-service/service"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/neighborhood-service/model"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/clock"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kpolicy"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kscheduler"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/ktwingraph"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/uuid"
 	cloudevents "github.com/cloudevents/sdk-go/v2"
 	"github.com/h2non/gock"
@@ -21,7 +29,6 @@ const (
 )
 
 func TestNeighborhoodServiceSuite(t *testing.T) {
-
 	suite.Run(t, new(NeighborhoodServiceSuite))
 }
 
@@ -40,6 +47,74 @@ func (s *NeighborhoodServiceSuite) SetupSuite() {
 	s.eventStoreUrl = os.Getenv("KTWIN_EVENT_STORE")
 }
 
+func (s *NeighborhoodServiceSuite) SetupTest() {
+	scheduler, _ := kscheduler.NewScheduler(kscheduler.NewMemoryStore())
+	kscheduler.Set(scheduler)
+}
+
+func (s *NeighborhoodServiceSuite) TearDownTest() {
+	kscheduler.Reset()
+}
+
+// Builds the command of the neighborhood, caused by the source instance when it is set
+func (s *NeighborhoodServiceSuite) newCommand(commandName, data, source string) *ktwin.TwinEvent {
+	twinEvent := ktwin.NewTwinEvent()
+	twinEvent.EventType = ktwin.CommandEvent
+	twinEvent.TwinInstance = "s4city-city-neighborhood-nb001"
+	twinEvent.TwinInterface = "s4city-city-neighborhood"
+	twinEvent.CommandName = commandName
+
+	cloudEvent := cloudevents.NewEvent()
+	cloudEvent.SetData("application/json", []byte(data))
+	cloudEvent.SetID("")
+	cloudEvent.SetSource("s4city-city-neighborhood-nb001")
+	cloudEvent.SetType("ktwin.command.s4city-city-neighborhood." + commandName)
+	cloudEvent.SetTime(*clock.Now())
+	if source != "" {
+		cloudEvent.SetExtension(ktwin.CausationExtension, source)
+	}
+
+	twinEvent.CloudEvent = &cloudEvent
+	return twinEvent
+}
+
+func (s *NeighborhoodServiceSuite) mockLatestEvent(neighborhood model.Neighborhood) {
+	gock.New(s.eventStoreUrl).
+		Get("/api/v1/twin-events/s4city-city-neighborhood/s4city-city-neighborhood-nb001/latest").
+		Reply(http.StatusOK).
+		SetHeader("Content-Type", "application/json").
+		SetHeader("ce-specversion", "1.0").
+		SetHeader("ce-time", clock.Now().Format(time.RFC3339)).
+		SetHeader("ce-source", "s4city-city-neighborhood-nb001").
+		SetHeader("ce-type", "ktwin.real.s4city-city-neighborhood").
+		SetHeader("ce-subject", "").
+		JSON(neighborhood)
+}
+
+func (s *NeighborhoodServiceSuite) mockStoredEvent(body string) {
+	gock.New(s.brokerUrl).
+		Post("/").
+		MatchHeader("Content-Type", "application/json").
+		MatchHeader("ce-id", "").
+		MatchHeader("ce-specversion", "1.0").
+		MatchHeader("ce-time", clock.Now().Format(time.RFC3339)).
+		MatchHeader("ce-source", "s4city-city-neighborhood-nb001").
+		MatchHeader("ce-type", "ktwin.store.s4city-city-neighborhood").
+		MatchHeader("ce-subject", "").
+		BodyString(body).
+		Reply(http.StatusAccepted)
+}
+
+func (s *NeighborhoodServiceSuite) mockCityCommand(body string) {
+	gock.New(s.brokerUrl).
+		Post("/").
+		MatchHeader("ce-source", "city-001").
+		MatchHeader("ce-type", "ktwin.command.s4city-city-city.updateneighborhood").
+		MatchHeader("ce-ktwincausation", "s4city-city-neighborhood-nb001").
+		BodyString(body).
+		Reply(http.StatusAccepted)
+}
+
 func (s *NeighborhoodServiceSuite) Test_NeighborhoodEvent() {
 	defer clock.ResetClockImplementation()
 	defer uuid.ResetUuidImplementation()
@@ -53,7 +128,13 @@ func (s *NeighborhoodServiceSuite) Test_NeighborhoodEvent() {
 		return &now
 	}
 	dateTime := clock.NowFunc()
-	dateTimeFormatted := dateTime.Format(time.RFC3339)
+	tenMinutesAgo := dateTime.Add(-10 * time.Minute)
+	twoHoursAgo := dateTime.Add(-2 * time.Hour)
+	unhealthyLivability := model.Livability{
+		Score:     40,
+		Subscores: map[string]float64{model.DOMAIN_AIR_QUALITY: 40},
+		History:   []model.LivabilityRecord{{Score: 40, DateObserved: &tenMinutesAgo}},
+	}
 
 	tests := []struct {
 		name                string
@@ -72,150 +153,91 @@ func (s *NeighborhoodServiceSuite) Test_NeighborhoodEvent() {
 		{
 			name: `
 				Given new command is received and there is no previous event
-				When command has aqiLevel GOOD
-				Should create neighborhood event with aqiLevel GOOD and dateObserved and dateModified set to current time
+				When command has aqiLevel GOOD and no causation
+				Should create neighborhood event with aqiLevel GOOD contributed by the neighborhood itself
 			`,
 			twinEvent: func() *ktwin.TwinEvent {
-				twinEvent := ktwin.NewTwinEvent()
-				twinEvent.EventType = ktwin.CommandEvent
-				twinEvent.TwinInstance = "s4city-city-neighborhood-nb001"
-				twinEvent.TwinInterface = "s4city-city-neighborhood"
-				twinEvent.CommandName = "updateAirQualityIndex"
-
-				cloudEvent := cloudevents.NewEvent()
-				cloudEvent.SetData("application/json", []byte(`{"aqiLevel": "GOOD"}`))
-				cloudEvent.SetID("")
-				cloudEvent.SetSource("s4city-city-neighborhood-nb001")
-				cloudEvent.SetType("ktwin.command.s4city-city-neighborhood.updateAirQualityIndex")
-				cloudEvent.SetTime(*dateTime)
-
-				twinEvent.CloudEvent = &cloudEvent
-				return twinEvent
+				return s.newCommand("updateAirQualityIndex", `{"aqiLevel": "GOOD"}`, "")
 			},
 			mockExternalService: func() {
 				gock.New(s.eventStoreUrl).
 					Get("/api/v1/twin-events/s4city-city-neighborhood/s4city-city-neighborhood-nb001/latest").
 					Reply(http.StatusNotFound)
 
-				gock.New(s.brokerUrl).
-					Post("/").
-					MatchHeader("Content-Type", "application/json").
-					MatchHeader("ce-id", "").
-					MatchHeader("ce-specversion", "1.0").
-					MatchHeader("ce-time", dateTimeFormatted).
-					MatchHeader("ce-source", "s4city-city-neighborhood-nb001").
-					MatchHeader("ce-type", "ktwin.store.s4city-city-neighborhood").
-					MatchHeader("ce-subject", "").
-					BodyString(`{"aqiLevel":"GOOD","dateObserved":"2024-01-01T00:00:00Z","dateModified":"2024-01-01T00:00:00Z"}`).
-					Reply(http.StatusAccepted)
+				s.mockStoredEvent(`{"aqiLevel":"GOOD","aqiAggregation":"max","dateObserved":"2024-01-01T00:00:00Z","dateModified":"2024-01-01T00:00:00Z","poles":{"s4city-city-neighborhood-nb001":{"aqiLevel":"GOOD","dateObserved":"2024-01-01T00:00:00Z"}},"livability":{"score":100,"subscores":{"airQuality":100},"history":[{"score":100,"dateObserved":"2024-01-01T00:00:00Z"}]}}`)
+				s.mockCityCommand(`{"aqiLevel":"GOOD","kpis":{"livability":100}}`)
 			},
 			expectedError: nil,
 		},
 		{
 			name: `
 				Given new command is received and there a previous event
-				When the new command has aqiLevel GOOD
-				AND previous event has aqiLevel UNHEALTHY
-				AND the time difference between the previous event and the current time is less than 60 minutes
-				Should update neighborhood event with aqiLevel UNHEALTHY
+				When a pole reports aqiLevel GOOD
+				AND another pole reported aqiLevel UNHEALTHY less than 60 minutes ago
+				Should keep the neighborhood aqiLevel UNHEALTHY without updating the city
 			`,
 			twinEvent: func() *ktwin.TwinEvent {
-				twinEvent := ktwin.NewTwinEvent()
-				twinEvent.EventType = ktwin.CommandEvent
-				twinEvent.TwinInstance = "s4city-city-neighborhood-nb001"
-				twinEvent.TwinInterface = "s4city-city-neighborhood"
-				twinEvent.CommandName = "updateAirQualityIndex"
-
-				cloudEvent := cloudevents.NewEvent()
-				cloudEvent.SetData("application/json", []byte(`{"aqiLevel": "GOOD"}`))
-				cloudEvent.SetID("")
-				cloudEvent.SetSource("s4city-city-neighborhood-nb001")
-				cloudEvent.SetType("ktwin.command.s4city-city-neighborhood.updateAirQualityIndex")
-				cloudEvent.SetTime(*dateTime)
-
-				twinEvent.CloudEvent = &cloudEvent
-				return twinEvent
+				return s.newCommand("updateAirQualityIndex", `{"aqiLevel": "GOOD", "airQualityIndex": 20, "dominantPollutant": "PM25"}`, "city-pole-nb001-p00002")
 			},
 			mockExternalService: func() {
-				gock.New(s.eventStoreUrl).
-					Get("/api/v1/twin-events/s4city-city-neighborhood/s4city-city-neighborhood-nb001/latest").
-					Reply(http.StatusOK).
-					SetHeader("Content-Type", "application/json").
-					SetHeader("ce-specversion", "1.0").
-					SetHeader("ce-time", dateTimeFormatted).
-					SetHeader("ce-source", "s4city-city-neighborhood-nb001").
-					SetHeader("ce-type", "ktwin.real.s4city-city-neighborhood").
-					SetHeader("ce-subject", "").
-					JSON(model.Neighborhood{
-						AqiLevel:     model.UNHEALTHY,
-						DateObserved: dateTime,
-					})
-
-				gock.New(s.brokerUrl).
-					Post("/").
-					MatchHeader("Content-Type", "application/json").
-					MatchHeader("ce-id", "").
-					MatchHeader("ce-specversion", "1.0").
-					MatchHeader("ce-time", dateTimeFormatted).
-					MatchHeader("ce-source", "s4city-city-neighborhood-nb001").
-					MatchHeader("ce-type", "ktwin.store.s4city-city-neighborhood").
-					MatchHeader("ce-subject", "").
-					BodyString(`{"aqiLevel":"UNHEALTHY","dateObserved":"2024-01-01T00:00:00Z"}`).
-					Reply(http.StatusAccepted)
+				s.mockLatestEvent(model.Neighborhood{
+					AqiLevel:     model.UNHEALTHY,
+					DateObserved: &tenMinutesAgo,
+					Poles: map[string]model.PoleContribution{
+						"city-pole-nb001-p00001": {AqiLevel: model.UNHEALTHY, AirQualityIndex: 160, DateObserved: &tenMinutesAgo},
+					},
+					Livability: unhealthyLivability,
+				})
+
+				s.mockStoredEvent(`{"aqiLevel":"UNHEALTHY","aqiAggregation":"max","dateObserved":"2024-01-01T00:00:00Z","poles":{"city-pole-nb001-p00001":{"aqiLevel":"UNHEALTHY","airQualityIndex":160,"dateObserved":"2023-12-31T23:50:00Z"},"city-pole-nb001-p00002":{"aqiLevel":"GOOD","airQualityIndex":20,"dominantPollutant":"PM25","dateObserved":"2024-01-01T00:00:00Z"}},"livability":{"score":40,"subscores":{"airQuality":40},"history":[{"score":40,"dateObserved":"2023-12-31T23:50:00Z"}]}}`)
 			},
 			expectedError: nil,
 		},
 		{
 			name: `
 				Given new command is received and there a previous event
-				When the new command has aqiLevel UNHEALTHY
-				AND previous event has aqiLevel GOOD
-				AND the time difference between the previous event and the current time is less than 60 minutes
-				Should update neighborhood event with aqiLevel UNHEALTHY
+				When the pole that reported aqiLevel UNHEALTHY reports aqiLevel GOOD
+				Should lower the neighborhood aqiLevel to GOOD at once and update the city
 			`,
 			twinEvent: func() *ktwin.TwinEvent {
-				twinEvent := ktwin.NewTwinEvent()
-				twinEvent.EventType = ktwin.CommandEvent
-				twinEvent.TwinInstance = "s4city-city-neighborhood-nb001"
-				twinEvent.TwinInterface = "s4city-city-neighborhood"
-				twinEvent.CommandName = "updateAirQualityIndex"
-
-				cloudEvent := cloudevents.NewEvent()
-				cloudEvent.SetData("application/json", []byte(`{"aqiLevel": "UNHEALTHY"}`))
-				cloudEvent.SetID("")
-				cloudEvent.SetSource("s4city-city-neighborhood-nb001")
-				cloudEvent.SetType("ktwin.command.s4city-city-neighborhood.updateAirQualityIndex")
-				cloudEvent.SetTime(*dateTime)
-
-				twinEvent.CloudEvent = &cloudEvent
-				return twinEvent
+				return s.newCommand("updateAirQualityIndex", `{"aqiLevel": "GOOD"}`, "city-pole-nb001-p00001")
 			},
 			mockExternalService: func() {
-				gock.New(s.eventStoreUrl).
-					Get("/api/v1/twin-events/s4city-city-neighborhood/s4city-city-neighborhood-nb001/latest").
-					Reply(http.StatusOK).
-					SetHeader("Content-Type", "application/json").
-					SetHeader("ce-specversion", "1.0").
-					SetHeader("ce-time", dateTimeFormatted).
-					SetHeader("ce-source", "s4city-city-neighborhood-nb001").
-					SetHeader("ce-type", "ktwin.real.s4city-city-neighborhood").
-					SetHeader("ce-subject", "").
-					JSON(model.Neighborhood{
-						AqiLevel:     model.GOOD,
-						DateObserved: dateTime,
-					})
-
-				gock.New(s.brokerUrl).
-					Post("/").
-					MatchHeader("Content-Type", "application/json").
-					MatchHeader("ce-id", "").
-					MatchHeader("ce-specversion", "1.0").
-					MatchHeader("ce-time", dateTimeFormatted).
-					MatchHeader("ce-source", "s4city-city-neighborhood-nb001").
-					MatchHeader("ce-type", "ktwin.store.s4city-city-neighborhood").
-					MatchHeader("ce-subject", "").
-					BodyString(`{"aqiLevel":"UNHEALTHY","dateObserved":"2024-01-01T00:00:00Z","dateModified":"2024-01-01T00:00:00Z"}`).
-					Reply(http.StatusAccepted)
+				s.mockLatestEvent(model.Neighborhood{
+					AqiLevel:     model.UNHEALTHY,
+					DateObserved: &tenMinutesAgo,
+					Poles: map[string]model.PoleContribution{
+						"city-pole-nb001-p00001": {AqiLevel: model.UNHEALTHY, DateObserved: &tenMinutesAgo},
+					},
+					Livability: unhealthyLivability,
+				})
+
+				s.mockStoredEvent(`{"aqiLevel":"GOOD","aqiAggregation":"max","dateObserved":"2024-01-01T00:00:00Z","dateModified":"2024-01-01T00:00:00Z","poles":{"city-pole-nb001-p00001":{"aqiLevel":"GOOD","dateObserved":"2024-01-01T00:00:00Z"}},"livability":{"score":100,"subscores":{"airQuality":100},"history":[{"score":40,"dateObserved":"2023-12-31T23:50:00Z"},{"score":100,"dateObserved":"2024-01-01T00:00:00Z"}]}}`)
+				s.mockCityCommand(`{"aqiLevel":"GOOD","kpis":{"livability":100}}`)
+			},
+			expectedError: nil,
+		},
+		{
+			name: `
+				Given new command is received and there a previous event
+				When a pole reports aqiLevel MODERATE
+				AND another pole reported aqiLevel HAZARDOUS more than 60 minutes ago
+				Should drop the stale pole and update neighborhood event with aqiLevel MODERATE
+			`,
+			twinEvent: func() *ktwin.TwinEvent {
+				return s.newCommand("updateAirQualityIndex", `{"aqiLevel": "MODERATE"}`, "city-pole-nb001-p00002")
+			},
+			mockExternalService: func() {
+				s.mockLatestEvent(model.Neighborhood{
+					AqiLevel:     model.HAZARDOUS,
+					DateObserved: &twoHoursAgo,
+					Poles: map[string]model.PoleContribution{
+						"city-pole-nb001-p00001": {AqiLevel: model.HAZARDOUS, DateObserved: &twoHoursAgo},
+					},
+				})
+
+				s.mockStoredEvent(`{"aqiLevel":"MODERATE","aqiAggregation":"max","dateObserved":"2024-01-01T00:00:00Z","dateModified":"2024-01-01T00:00:00Z","poles":{"city-pole-nb001-p00002":{"aqiLevel":"MODERATE","dateObserved":"2024-01-01T00:00:00Z"}},"livability":{"score":80,"subscores":{"airQuality":80},"history":[{"score":80,"dateObserved":"2024-01-01T00:00:00Z"}]}}`)
+				s.mockCityCommand(`{"aqiLevel":"MODERATE","kpis":{"livability":80}}`)
 			},
 			expectedError: nil,
 		},
@@ -228,6 +250,412 @@ func (s *NeighborhoodServiceSuite) Test_NeighborhoodEvent() {
 			actualError := HandleEvent(tt.twinEvent())
 
 			s.Assert().Equal(tt.expectedError, actualError)
+			s.Assert().True(gock.IsDone())
+		})
+	}
+}
+
+func (s *NeighborhoodServiceSuite) Test_AirQualityExpiryTimer() {
+	defer clock.ResetClockImplementation()
+	defer gock.Off()
+
+	now, _ := time.Parse(time.RFC3339, "2024-01-01T00:00:00Z")
+	clock.NowFunc = func() *time.Time {
+		return &now
+	}
+	tenMinutesAgo := now.Add(-10 * time.Minute)
+	unhealthyLivability := model.Livability{
+		Score:     40,
+		Subscores: map[string]float64{model.DOMAIN_AIR_QUALITY: 40},
+		History:   []model.LivabilityRecord{{Score: 40, DateObserved: &tenMinutesAgo}},
+	}
+
+	// The command schedules the expiry at the time the oldest pole expires
+	s.mockLatestEvent(model.Neighborhood{
+		AqiLevel:     model.UNHEALTHY,
+		DateObserved: &tenMinutesAgo,
+		Poles: map[string]model.PoleContribution{
+			"city-pole-nb001-p00001": {AqiLevel: model.UNHEALTHY, DateObserved: &tenMinutesAgo},
+		},
+		Livability: unhealthyLivability,
+	})
+	s.mockStoredEvent(`{"aqiLevel":"UNHEALTHY","aqiAggregation":"max","dateObserved":"2024-01-01T00:00:00Z","poles":{"city-pole-nb001-p00001":{"aqiLevel":"UNHEALTHY","dateObserved":"2023-12-31T23:50:00Z"},"city-pole-nb001-p00002":{"aqiLevel":"GOOD","dateObserved":"2024-01-01T00:00:00Z"}},"livability":{"score":40,"subscores":{"airQuality":40},"history":[{"score":40,"dateObserved":"2023-12-31T23:50:00Z"}]}}`)
+
+	s.Require().NoError(HandleEvent(s.newCommand("updateAirQualityIndex", `{"aqiLevel": "GOOD"}`, "city-pole-nb001-p00002")))
+	s.Require().True(gock.IsDone())
+
+	timers := kscheduler.Get().Timers()
+	s.Require().Len(timers, 1)
+	s.Assert().Equal(now.Add(50*time.Minute), timers[0].Next)
+
+	// No command arrives before the UNHEALTHY pole expires, the timer lowers the level and updates the city
+	observed := now
+	now = now.Add(55 * time.Minute)
+	s.mockLatestEvent(model.Neighborhood{
+		AqiLevel:     model.UNHEALTHY,
+		DateObserved: &observed,
+		Poles: map[string]model.PoleContribution{
+			"city-pole-nb001-p00001": {AqiLevel: model.UNHEALTHY, DateObserved: &tenMinutesAgo},
+			"city-pole-nb001-p00002": {AqiLevel: model.GOOD, DateObserved: &observed},
+		},
+		Livability: unhealthyLivability,
+	})
+	s.mockStoredEvent(`{"aqiLevel":"GOOD","aqiAggregation":"max","dateObserved":"2024-01-01T00:55:00Z","dateModified":"2024-01-01T00:55:00Z","poles":{"city-pole-nb001-p00002":{"aqiLevel":"GOOD","dateObserved":"2024-01-01T00:00:00Z"}},"livability":{"score":100,"subscores":{"airQuality":100},"history":[{"score":40,"dateObserved":"2023-12-31T23:50:00Z"},{"score":100,"dateObserved":"2024-01-01T00:55:00Z"}]}}`)
+	s.mockCityCommand(`{"aqiLevel":"GOOD","kpis":{"livability":100}}`)
+
+	kscheduler.Get().Fire(now, HandleEvent)
+	s.Assert().True(gock.IsDone())
+
+	// The timer is scheduled again for the remaining pole
+	timers = kscheduler.Get().Timers()
+	s.Require().Len(timers, 1)
+	s.Assert().Equal(observed.Add(60*time.Minute), timers[0].Next)
+}
+
+func (s *NeighborhoodServiceSuite) Test_NeighborhoodAggregations() {
+	defer clock.ResetClockImplementation()
+	defer kpolicy.Reset()
+
+	clock.NowFunc = func() *time.Time {
+		now, _ := time.Parse(time.RFC3339, "2024-01-01T00:00:00Z")
+		return &now
+	}
+	dateTime := clock.NowFunc()
+
+	poles := func() map[string]model.PoleContribution {
+		return map[string]model.PoleContribution{
+			"city-pole-nb001-p00001": {AqiLevel: model.HAZARDOUS, DateObserved: dateTime},
+			"city-pole-nb001-p00002": {AqiLevel: model.GOOD, DateObserved: dateTime},
+			"city-pole-nb001-p00003": {AqiLevel: model.GOOD, DateObserved: dateTime},
+			"city-pole-nb001-p00004": {AqiLevel: model.MODERATE, DateObserved: dateTime},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		policy   string
+		expected model.AQICategory
+		// Livability score of the air quality alone
+		expectedScore string
+	}{
+		{"max", "{aqiAggregation: max}", model.HAZARDOUS, "0"},
+		{"median", "{aqiAggregation: percentile, aqiPercentile: 50}", model.GOOD, "100"},
+		{"90th percentile", "{aqiAggregation: percentile, aqiPercentile: 90}", model.HAZARDOUS, "0"},
+		{"population", "{aqiAggregation: population, populations: {city-pole-nb001-p00001: 100, city-pole-nb001-p00002: 4000, city-pole-nb001-p00003: 1000}}", model.GOOD, "100"},
+		{"population of the hazardous pole", "{aqiAggregation: population, populations: {city-pole-nb001-p00001: 1000, city-pole-nb001-p00002: 100, city-pole-nb001-p00003: 100, city-pole-nb001-p00004: 0}}", model.VERY_UNHEALTHY, "20"},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			defer gock.Off()
+
+			policyFile := filepath.Join(s.T().TempDir(), "policies.yaml")
+			s.Require().NoError(os.WriteFile(policyFile, []byte("overrides:\n  s4city-city-neighborhood-nb001:\n    s4city-city-neighborhood: "+tt.policy+"\n"), 0644))
+			registry := kpolicy.NewRegistry(policyFile, ktwingraph.LoadTwinGraphByInterfaces)
+			s.Require().NoError(registry.Load())
+			kpolicy.Set(registry)
+
+			s.mockLatestEvent(model.Neighborhood{AqiLevel: model.HAZARDOUS, DateObserved: dateTime, Poles: poles()})
+
+			var stored model.Neighborhood
+			gock.New(s.brokerUrl).
+				Post("/").
+				MatchHeader("ce-type", "ktwin.store.s4city-city-neighborhood").
+				AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+					body, err := io.ReadAll(req.Body)
+					if err != nil {
+						return false, err
+					}
+					return true, json.Unmarshal(body, &stored)
+				}).
+				Reply(http.StatusAccepted)
+
+			// The city is only updated when the level of the neighborhood changes
+			if tt.expected != model.HAZARDOUS {
+				s.mockCityCommand(`{"aqiLevel":"` + string(tt.expected) + `","kpis":{"livability":` + tt.expectedScore + `}}`)
+			}
+
+			s.Require().NoError(HandleEvent(s.newCommand("updateAirQualityIndex", `{"aqiLevel": "MODERATE"}`, "city-pole-nb001-p00004")))
+			s.Assert().True(gock.IsDone())
+			s.Assert().Equal(tt.expected, stored.AqiLevel)
+			s.Assert().Len(stored.Poles, 4)
+		})
+	}
+}
+
+func (s *NeighborhoodServiceSuite) Test_NeighborhoodLivability() {
+	defer clock.ResetClockImplementation()
+
+	clock.NowFunc = func() *time.Time {
+		now, _ := time.Parse(time.RFC3339, "2024-01-01T00:00:00Z")
+		return &now
+	}
+	dateTime := clock.NowFunc()
+	tenMinutesAgo := dateTime.Add(-10 * time.Minute)
+	twoHoursAgo := dateTime.Add(-2 * time.Hour)
+
+	// Air quality 100 with weight 3, noise 50 with weight 2 and parking 25 with weight 1 make 70.8
+	neighborhood := func() model.Neighborhood {
+		return model.Neighborhood{
+			AqiLevel:     model.GOOD,
+			DateObserved: &tenMinutesAgo,
+			Poles: map[string]model.PoleContribution{
+				"city-pole-nb001-p00001": {AqiLevel: model.GOOD, DateObserved: &tenMinutesAgo},
+			},
+			Livability: model.Livability{
+				Score: 70.8,
+				Contributions: map[string]map[string]model.Contribution{
+					model.DOMAIN_NOISE: {
+						"city-pole-nb001-p00001": {Subscore: 50, DateObserved: &tenMinutesAgo},
+					},
+					model.DOMAIN_PARKING: {
+						"parking-001": {AvailableSpotNumber: 10, TotalSpotNumber: 40, DateObserved: &tenMinutesAgo},
+					},
+					model.DOMAIN_WEATHER: {
+						"city-pole-nb001-p00001": {Subscore: 0, DateObserved: &twoHoursAgo},
+					},
+				},
+				History: []model.LivabilityRecord{{Score: 70.8, DateObserved: &tenMinutesAgo}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name              string
+		commandName       string
+		data              string
+		source            string
+		expectedScore     float64
+		expectedSubscores map[string]float64
+		expectedCity      string
+	}{
+		{
+			name:              "Noise of another pole is averaged with the noise of the first one",
+			commandName:       "updateNoiseLevel",
+			data:              `{"LAeq": 45}`,
+			source:            "city-pole-nb001-p00002",
+			expectedScore:     79.2,
+			expectedSubscores: map[string]float64{model.DOMAIN_AIR_QUALITY: 100, model.DOMAIN_NOISE: 75, model.DOMAIN_PARKING: 25},
+			expectedCity:      `{"aqiLevel":"GOOD","availableSpotNumber":10,"totalSpotNumber":40,"kpis":{"livability":79.2}}`,
+		},
+		{
+			name:              "Parking spots of the facilities are added up",
+			commandName:       "updateParking",
+			data:              `{"availableSpotNumber": 30, "totalSpotNumber": 60}`,
+			source:            "parking-002",
+			expectedScore:     73.3,
+			expectedSubscores: map[string]float64{model.DOMAIN_AIR_QUALITY: 100, model.DOMAIN_NOISE: 50, model.DOMAIN_PARKING: 40},
+			expectedCity:      `{"aqiLevel":"GOOD","availableSpotNumber":40,"totalSpotNumber":100,"kpis":{"livability":73.3}}`,
+		},
+		{
+			name:              "Weather out of the comfort range replaces the stale weather",
+			commandName:       "updateWeather",
+			data:              `{"temperature": 30}`,
+			source:            "city-pole-nb001-p00002",
+			expectedScore:     69.3,
+			expectedSubscores: map[string]float64{model.DOMAIN_AIR_QUALITY: 100, model.DOMAIN_NOISE: 50, model.DOMAIN_PARKING: 25, model.DOMAIN_WEATHER: 60},
+			expectedCity:      `{"aqiLevel":"GOOD","availableSpotNumber":10,"totalSpotNumber":40,"kpis":{"livability":69.3}}`,
+		},
+		{
+			name:              "Congested traffic halves the traffic subscore",
+			commandName:       "updateTrafficFlow",
+			data:              `{"congested": true, "occupancy": 0.5}`,
+			source:            "city-pole-nb001-p00002",
+			expectedScore:     64.3,
+			expectedSubscores: map[string]float64{model.DOMAIN_AIR_QUALITY: 100, model.DOMAIN_NOISE: 50, model.DOMAIN_PARKING: 25, model.DOMAIN_TRAFFIC: 25},
+			expectedCity:      `{"aqiLevel":"GOOD","availableSpotNumber":10,"totalSpotNumber":40,"kpis":{"livability":64.3}}`,
+		},
+		{
+			name:              "Congested crowd makes the crowd subscore 0",
+			commandName:       "updateCrowdFlow",
+			data:              `{"congested": true, "peopleCount": 120}`,
+			source:            "city-pole-nb001-p00002",
+			expectedScore:     60.7,
+			expectedSubscores: map[string]float64{model.DOMAIN_AIR_QUALITY: 100, model.DOMAIN_NOISE: 50, model.DOMAIN_PARKING: 25, model.DOMAIN_CROWD: 0},
+			expectedCity:      `{"aqiLevel":"GOOD","availableSpotNumber":10,"totalSpotNumber":40,"kpis":{"livability":60.7}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			defer gock.Off()
+
+			s.mockLatestEvent(neighborhood())
+
+			var stored model.Neighborhood
+			gock.New(s.brokerUrl).
+				Post("/").
+				MatchHeader("ce-type", "ktwin.store.s4city-city-neighborhood").
+				AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+					body, err := io.ReadAll(req.Body)
+					if err != nil {
+						return false, err
+					}
+					return true, json.Unmarshal(body, &stored)
+				}).
+				Reply(http.StatusAccepted)
+			s.mockCityCommand(tt.expectedCity)
+
+			s.Require().NoError(HandleEvent(s.newCommand(tt.commandName, tt.data, tt.source)))
+			s.Assert().True(gock.IsDone())
+			s.Assert().Equal(tt.expectedScore, stored.Livability.Score)
+			s.Assert().Equal(tt.expectedSubscores, stored.Livability.Subscores)
+			s.Assert().Equal([]model.LivabilityRecord{
+				{Score: 70.8, DateObserved: &tenMinutesAgo},
+				{Score: tt.expectedScore, DateObserved: dateTime},
+			}, stored.Livability.History)
+			s.Assert().Equal(dateTime, stored.DateModified)
 		})
 	}
 }
+
+func (s *NeighborhoodServiceSuite) Test_NeighborhoodLivabilityWithoutPoles() {
+	defer clock.ResetClockImplementation()
+	defer gock.Off()
+
+	clock.NowFunc = func() *time.Time {
+		now, _ := time.Parse(time.RFC3339, "2024-01-01T00:00:00Z")
+		return &now
+	}
+	dateTime := clock.NowFunc()
+	tenMinutesAgo := dateTime.Add(-10 * time.Minute)
+
+	s.mockLatestEvent(model.Neighborhood{
+		AqiLevel:     model.GOOD,
+		DateObserved: &tenMinutesAgo,
+		Livability: model.Livability{
+			Score: 50,
+			Contributions: map[string]map[string]model.Contribution{
+				model.DOMAIN_NOISE: {
+					"city-pole-nb001-p00001": {Subscore: 50, DateObserved: &tenMinutesAgo},
+				},
+			},
+			History: []model.LivabilityRecord{{Score: 50, DateObserved: &tenMinutesAgo}},
+		},
+	})
+
+	var stored model.Neighborhood
+	gock.New(s.brokerUrl).
+		Post("/").
+		MatchHeader("ce-type", "ktwin.store.s4city-city-neighborhood").
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				return false, err
+			}
+			return true, json.Unmarshal(body, &stored)
+		}).
+		Reply(http.StatusAccepted)
+	s.mockCityCommand(`{"aqiLevel":"GOOD","kpis":{"livability":75}}`)
+
+	// Without air quality of the poles the score is of the noise alone
+	s.Require().NoError(HandleEvent(s.newCommand("updateNoiseLevel", `{"LAeq": 45}`, "city-pole-nb001-p00002")))
+	s.Assert().True(gock.IsDone())
+	s.Assert().Equal(map[string]float64{model.DOMAIN_NOISE: 75}, stored.Livability.Subscores)
+	s.Assert().Equal(75.0, stored.Livability.Score)
+}
+
+func (s *NeighborhoodServiceSuite) Test_NeighborhoodPoleUpdate() {
+	defer clock.ResetClockImplementation()
+	defer gock.Off()
+
+	clock.NowFunc = func() *time.Time {
+		now, _ := time.Parse(time.RFC3339, "2024-01-01T00:00:00Z")
+		return &now
+	}
+	dateTime := clock.NowFunc()
+
+	gock.New(s.eventStoreUrl).
+		Get("/api/v1/twin-events/s4city-city-neighborhood/s4city-city-neighborhood-nb001/latest").
+		Reply(http.StatusNotFound)
+
+	var stored model.Neighborhood
+	gock.New(s.brokerUrl).
+		Post("/").
+		MatchHeader("ce-type", "ktwin.store.s4city-city-neighborhood").
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				return false, err
+			}
+			return true, json.Unmarshal(body, &stored)
+		}).
+		Reply(http.StatusAccepted)
+
+	// Air quality 80 with weight 3, noise 50 with weight 2 and weather 100 with weight 1 make 73.3
+	s.mockCityCommand(`{"aqiLevel":"MODERATE","kpis":{"livability":73.3}}`)
+
+	data := `{"airQuality": {"aqiLevel": "MODERATE", "airQualityIndex": 75}, "noiseLevel": {"LAeq": 60}, "weather": {"temperature": 22}, "missingSensors": ["refCrowdFlow"]}`
+	s.Require().NoError(HandleEvent(s.newCommand("updatePole", data, "city-pole-nb001-p00002")))
+	s.Assert().True(gock.IsDone())
+
+	s.Assert().Equal(model.MODERATE, stored.AqiLevel)
+	s.Assert().Equal(map[string]model.PoleContribution{
+		"city-pole-nb001-p00002": {AqiLevel: model.MODERATE, AirQualityIndex: 75, DateObserved: dateTime},
+	}, stored.Poles)
+	s.Assert().Equal(map[string]float64{model.DOMAIN_AIR_QUALITY: 80, model.DOMAIN_NOISE: 50, model.DOMAIN_WEATHER: 100}, stored.Livability.Subscores)
+	s.Assert().Contains(stored.Livability.Contributions[model.DOMAIN_NOISE], "city-pole-nb001-p00002")
+	s.Assert().Equal(map[string][]string{"city-pole-nb001-p00002": {"refCrowdFlow"}}, stored.MissingSensors)
+}
+
+// Captures the body of the broker request matching the type and source
+func (s *NeighborhoodServiceSuite) captureEvent(ceType, ceSource string, body *[]byte) {
+	gock.New(s.brokerUrl).
+		Post("/").
+		MatchHeader("ce-type", ceType).
+		MatchHeader("ce-source", ceSource).
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			content, err := io.ReadAll(req.Body)
+			*body = content
+			return err == nil, err
+		}).
+		Reply(http.StatusAccepted)
+}
+
+// The parking spots reported to a neighborhood reach the dashboard of the city it belongs to in the twin graph
+func (s *NeighborhoodServiceSuite) Test_ParkingReachesCity() {
+	defer clock.ResetClockImplementation()
+	defer gock.Off()
+
+	clock.NowFunc = func() *time.Time {
+		now, _ := time.Parse(time.RFC3339, "2024-01-01T00:00:00Z")
+		return &now
+	}
+
+	gock.New(s.eventStoreUrl).
+		Get("/api/v1/twin-events/s4city-city-neighborhood/s4city-city-neighborhood-nb001/latest").
+		Reply(http.StatusNotFound)
+	var storedNeighborhood, cityCommand []byte
+	s.captureEvent("ktwin.store.s4city-city-neighborhood", "s4city-city-neighborhood-nb001", &storedNeighborhood)
+	s.captureEvent("ktwin.command.s4city-city-city.updateneighborhood", "city-001", &cityCommand)
+
+	parking := s.newCommand("updateParking", `{"availableSpotNumber": 12, "totalSpotNumber": 50}`, "ngsi-ld-city-offstreetparking-nb001-ofp0005")
+	s.Require().NoError(HandleEvent(parking))
+	s.Require().True(gock.IsDone())
+	s.Require().NotEmpty(cityCommand)
+
+	// The command published by the neighborhood is handled by the city service
+	cloudEvent := cloudevents.NewEvent()
+	s.Require().NoError(cloudEvent.SetData(cloudevents.ApplicationJSON, cityCommand))
+	cloudEvent.SetSource("city-001")
+	cloudEvent.SetType("ktwin.command.s4city-city-city.updateneighborhood")
+	cloudEvent.SetTime(*clock.Now())
+	cloudEvent.SetExtension(ktwin.CausationExtension, "s4city-city-neighborhood-nb001")
+	command, err := ktwin.NewTwinEventFromCloudEvent(&cloudEvent)
+	s.Require().NoError(err)
+
+	gock.New(s.eventStoreUrl).
+		Get("/api/v1/twin-events/s4city-city-city/city-001/latest").
+		Reply(http.StatusNotFound)
+	var storedCity []byte
+	s.captureEvent("ktwin.store.s4city-city-city", "city-001", &storedCity)
+
+	s.Require().NoError(cityService.HandleEvent(command))
+	s.Require().True(gock.IsDone())
+
+	var city cityModel.City
+	s.Require().NoError(json.Unmarshal(storedCity, &city))
+	s.Assert().Equal(12, city.AvailableSpotNumber)
+	s.Assert().Equal(50, city.TotalSpotNumber)
+	s.Assert().Contains(city.Neighborhoods, "s4city-city-neighborhood-nb001")
+}
@@ -1,11 +1,22 @@
 package model
 
+import "math"
+
 const (
 	TWIN_INTERFACE_ON_STREET_PARKING       = "ngsi-ld-city-onstreetparking"
 	TWIN_INTERFACE_OFF_STREET_PARKING      = "ngsi-ld-city-offstreetparking"
 	TWIN_INTERFACE_ON_STREET_PARKING_SPOT  = "ngsi-ld-city-onstreetparkingspot"
 	TWIN_INTERFACE_OFF_STREET_PARKING_SPOT = "ngsi-ld-city-offstreetparkingspot"
 	TWIN_COMMAND_UPDATE_VEHICLE_COUNT      = "updateVehicleCount"
+	TWIN_COMMAND_SET_STATUS                = "setStatus"
+
+	// The parking spots reference the parking they belong to
+	TWIN_INTERFACE_PARKING_SPOT          = "ngsi-ld-city-parkingspot"
+	TWIN_RELATIONSHIP_OFF_STREET_PARKING = "refOffStreetParking"
+
+	// The parkings reference the neighborhood they belong to, which scores their available spots
+	TWIN_RELATIONSHIP_NEIGHBORHOOD           = "refNeighborhood"
+	TWIN_COMMAND_NEIGHBORHOOD_UPDATE_PARKING = "updateParking"
 )
 
 type Facility string
@@ -80,8 +91,10 @@ const (
 )
 
 type UpdateVehicleCountCommand struct {
-	VehicleEntranceCount int `json:"vehicleEntranceCount,omitempty"`
-	VehicleExitCount     int `json:"vehicleExitCount,omitempty"`
+	VehicleEntranceCount int `json:"vehicleEntranceCount,omitempty" validate:"min=0" protobuf:"1"`
+	VehicleExitCount     int `json:"vehicleExitCount,omitempty" validate:"min=0" protobuf:"2"`
+	// Type of the entering vehicle, when the spot detects it
+	VehicleType VehicleType `json:"vehicleType,omitempty" protobuf:"3"`
 }
 
 func NewOffStreetParking() OffStreetParking {
@@ -89,32 +102,41 @@ func NewOffStreetParking() OffStreetParking {
 }
 
 type OffStreetParking struct {
-	AccessModified      string            `json:"accessModified,omitempty"`
-	AggregateRating     float64           `json:"aggregateRating,omitempty"`
-	HighestFloor        int               `json:"highestFloor,omitempty"`
-	Images              string            `json:"images,omitempty"`
-	LowestFloor         int               `json:"lowestFloor,omitempty"`
-	OpeningHours        string            `json:"openingHours,omitempty"`
-	PriceCurrency       string            `json:"priceCurrency,omitempty"`
-	PriceRatePerMinute  float64           `json:"priceRatePerMinute,omitempty"`
-	Provider            string            `json:"provider,omitempty"`
-	Facilities          []Facility        `json:"facilities,omitempty"`
-	Layout              Layout            `json:"layout,omitempty"`
-	UsageScenario       UsageScenario     `json:"usageScenario,omitempty"`
-	Security            []Security        `json:"security,omitempty"`
-	SpecialLocation     []SpecialLocation `json:"specialLocation,omitempty"`
-	Category            string            `json:"category,omitempty"`
-	ExtCategory         string            `json:"extCategory,omitempty"`
-	FirstAvailableFloor int               `json:"firstAvailableFloor,omitempty"`
-	MeasuresPeriod      float64           `json:"measuresPeriod,omitempty"`
-	MeasuresPeriodUnit  string            `json:"measuresPeriodUnit,omitempty"`
-	Occupancy           float64           `json:"occupancy,omitempty"`
-	OccupancyModified   float64           `json:"occupancyModified,omitempty"`
-	OccupiedSpotNumber  int               `json:"occupiedSpotNumber"`
-	TotalSpotNumber     int               `json:"totalSpotNumber"`
-	Status              string            `json:"status,omitempty"`
+	AccessModified      string            `json:"accessModified,omitempty" protobuf:"1"`
+	AggregateRating     float64           `json:"aggregateRating,omitempty" validate:"min=0,max=1" protobuf:"2"`
+	HighestFloor        int               `json:"highestFloor,omitempty" protobuf:"3"`
+	Images              string            `json:"images,omitempty" protobuf:"4"`
+	LowestFloor         int               `json:"lowestFloor,omitempty" protobuf:"5"`
+	OpeningHours        string            `json:"openingHours,omitempty" protobuf:"6"`
+	PriceCurrency       string            `json:"priceCurrency,omitempty" protobuf:"7"`
+	PriceRatePerMinute  float64           `json:"priceRatePerMinute,omitempty" validate:"min=0" protobuf:"8"`
+	Provider            string            `json:"provider,omitempty" protobuf:"9"`
+	Facilities          []Facility        `json:"facilities,omitempty" protobuf:"10"`
+	Layout              Layout            `json:"layout,omitempty" protobuf:"11"`
+	UsageScenario       UsageScenario     `json:"usageScenario,omitempty" protobuf:"12"`
+	Security            []Security        `json:"security,omitempty" protobuf:"13"`
+	SpecialLocation     []SpecialLocation `json:"specialLocation,omitempty" protobuf:"14"`
+	Category            string            `json:"category,omitempty" validate:"oneof=offStreet onStreet" protobuf:"15"`
+	ExtCategory         string            `json:"extCategory,omitempty" protobuf:"16"`
+	FirstAvailableFloor int               `json:"firstAvailableFloor,omitempty" protobuf:"17"`
+	MeasuresPeriod      float64           `json:"measuresPeriod,omitempty" validate:"min=0" protobuf:"18"`
+	MeasuresPeriodUnit  string            `json:"measuresPeriodUnit,omitempty" protobuf:"19"`
+	Occupancy           float64           `json:"occupancy,omitempty" validate:"min=0,max=1" protobuf:"20"`
+	OccupancyModified   float64           `json:"occupancyModified,omitempty" protobuf:"21"`
+	OccupiedSpotNumber  int               `json:"occupiedSpotNumber" validate:"min=0" protobuf:"22"`
+	TotalSpotNumber     int               `json:"totalSpotNumber" validate:"min=0" protobuf:"23"`
+	Status              ParkingStatus     `json:"status,omitempty" validate:"oneof=open almostFull full closed" protobuf:"24"`
 }
 
+type ParkingStatus string
+
+const (
+	ParkingOpen       ParkingStatus = "open"
+	ParkingAlmostFull ParkingStatus = "almostFull"
+	ParkingFull       ParkingStatus = "full"
+	ParkingClosed     ParkingStatus = "closed"
+)
+
 func (o *OffStreetParking) IncrementOccupiedSpotNumber() {
 	if o.OccupiedSpotNumber < o.TotalSpotNumber {
 		o.OccupiedSpotNumber++
@@ -127,6 +149,92 @@ func (o *OffStreetParking) DecrementOccupiedSpotNumber() {
 	}
 }
 
+// Sets the capacity of the parking, the occupied spots can not exceed it
+func (o *OffStreetParking) SetTotalSpotNumber(totalSpotNumber int) {
+	o.TotalSpotNumber = totalSpotNumber
+	if o.OccupiedSpotNumber > totalSpotNumber {
+		o.OccupiedSpotNumber = totalSpotNumber
+	}
+}
+
+// Sets the occupancy, the fraction of occupied spots, and the status from the occupancy from which the
+// parking is almost full and full. A closed parking stays closed until its operator opens it.
+func (o *OffStreetParking) UpdateOccupancy(almostFullOccupancy, fullOccupancy float64) {
+	o.Occupancy = occupancy(o.OccupiedSpotNumber, o.TotalSpotNumber)
+	o.Status = status(o.Status, o.Occupancy, almostFullOccupancy, fullOccupancy)
+}
+
+// Closes the parking or opens it, see statusByOperator
+func (o *OffStreetParking) SetOperatorStatus(operatorStatus ParkingStatus, almostFullOccupancy, fullOccupancy float64) {
+	o.Status = statusByOperator(operatorStatus, o.Occupancy, almostFullOccupancy, fullOccupancy)
+}
+
+func (o *OffStreetParking) StatusEvent() ParkingStatusEvent {
+	return newParkingStatusEvent(o.Status, o.Occupancy, o.OccupiedSpotNumber, o.TotalSpotNumber)
+}
+
+func occupancy(occupiedSpotNumber, totalSpotNumber int) float64 {
+	if totalSpotNumber <= 0 {
+		return 0
+	}
+	return math.Round(float64(occupiedSpotNumber)/float64(totalSpotNumber)*10000) / 10000
+}
+
+// Gets the status set by the operator of the parking. A closed parking stays closed, an opened one
+// gets the status of its occupancy.
+func statusByOperator(operatorStatus ParkingStatus, occupancy, almostFullOccupancy, fullOccupancy float64) ParkingStatus {
+	if operatorStatus == ParkingClosed {
+		return ParkingClosed
+	}
+	return status(ParkingOpen, occupancy, almostFullOccupancy, fullOccupancy)
+}
+
+func status(current ParkingStatus, occupancy, almostFullOccupancy, fullOccupancy float64) ParkingStatus {
+	switch {
+	case current == ParkingClosed:
+		return ParkingClosed
+	case occupancy >= fullOccupancy:
+		return ParkingFull
+	case occupancy >= almostFullOccupancy:
+		return ParkingAlmostFull
+	default:
+		return ParkingOpen
+	}
+}
+
+// SetStatusCommand is sent by the operator of the parking to close it, or to open it again
+type SetStatusCommand struct {
+	Status ParkingStatus `json:"status" validate:"required,oneof=open closed" protobuf:"1"`
+}
+
+// ParkingStatusEvent is published to the real twin, e.g. the signage, when the status of the parking changes
+type ParkingStatusEvent struct {
+	Status              ParkingStatus `json:"status" protobuf:"1"`
+	Occupancy           float64       `json:"occupancy" protobuf:"2"`
+	AvailableSpotNumber int           `json:"availableSpotNumber" protobuf:"3"`
+	TotalSpotNumber     int           `json:"totalSpotNumber" protobuf:"4"`
+}
+
+func newParkingStatusEvent(status ParkingStatus, occupancy float64, occupiedSpotNumber, totalSpotNumber int) ParkingStatusEvent {
+	return ParkingStatusEvent{
+		Status:              status,
+		Occupancy:           occupancy,
+		AvailableSpotNumber: totalSpotNumber - occupiedSpotNumber,
+		TotalSpotNumber:     totalSpotNumber,
+	}
+}
+
+// Gets the available spots of the parking for its neighborhood
+func (e ParkingStatusEvent) NeighborhoodCommand() UpdateParkingCommand {
+	return UpdateParkingCommand{AvailableSpotNumber: e.AvailableSpotNumber, TotalSpotNumber: e.TotalSpotNumber}
+}
+
+// UpdateParkingCommand reports the available spots of the parking to its neighborhood
+type UpdateParkingCommand struct {
+	AvailableSpotNumber int `json:"availableSpotNumber" protobuf:"1"`
+	TotalSpotNumber     int `json:"totalSpotNumber" protobuf:"2"`
+}
+
 type Category string
 type Status string
 
@@ -140,12 +248,12 @@ const (
 )
 
 type ParkingSpot struct {
-	DateObserved float64  `json:"dateObserved,omitempty"`
-	Width        float64  `json:"width,omitempty"`
-	Length       float64  `json:"length,omitempty"`
-	TimeInstant  string   `json:"timeInstant,omitempty"`
-	Image        string   `json:"image,omitempty"`
-	Color        string   `json:"color,omitempty"`
-	Category     Category `json:"category,omitempty"`
-	Status       Status   `json:"status,omitempty"`
+	DateObserved float64  `json:"dateObserved,omitempty" protobuf:"1"`
+	Width        float64  `json:"width,omitempty" validate:"min=0" protobuf:"2"`
+	Length       float64  `json:"length,omitempty" validate:"min=0" protobuf:"3"`
+	TimeInstant  string   `json:"timeInstant,omitempty" protobuf:"4"`
+	Image        string   `json:"image,omitempty" protobuf:"5"`
+	Color        string   `json:"color,omitempty" protobuf:"6"`
+	Category     Category `json:"category,omitempty" validate:"oneof=offStreet onStreet" protobuf:"7"`
+	Status       Status   `json:"status,omitempty" validate:"oneof=occupied free closed unknown" protobuf:"8"`
 }
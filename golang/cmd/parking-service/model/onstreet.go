@@ -0,0 +1,155 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const TWIN_RELATIONSHIP_ON_STREET_PARKING = "refOnStreetParking"
+
+type VehicleType string
+
+const (
+	Bicycle                          VehicleType = "bicycle"
+	Bus                              VehicleType = "bus"
+	Car                              VehicleType = "car"
+	Caravan                          VehicleType = "caravan"
+	CarWithCaravan                   VehicleType = "carWithCaravan"
+	CarWithTrailer                   VehicleType = "carWithTrailer"
+	ConstructionOrMaintenanceVehicle VehicleType = "constructionOrMaintenanceVehicle"
+	Lorry                            VehicleType = "lorry"
+	Moped                            VehicleType = "moped"
+	Motorcycle                       VehicleType = "motorcycle"
+	MotorcycleWithSideCar            VehicleType = "motorcycleWithSideCar"
+	Motorscooter                     VehicleType = "motorscooter"
+	Tanker                           VehicleType = "tanker"
+	Trailer                          VehicleType = "trailer"
+	Van                              VehicleType = "van"
+	AnyVehicle                       VehicleType = "anyVehicle"
+)
+
+// OccupiedSpot is a parking spot of the street taken by a vehicle
+type OccupiedSpot struct {
+	Since       time.Time   `json:"since" protobuf:"1"`
+	VehicleType VehicleType `json:"vehicleType,omitempty" protobuf:"2"`
+}
+
+func NewOnStreetParking() OnStreetParking {
+	return OnStreetParking{}
+}
+
+// OnStreetParking is a street segment whose parking spots report when they are taken and freed
+type OnStreetParking struct {
+	Name               string        `json:"name,omitempty" protobuf:"1"`
+	AreaServed         string        `json:"areaServed,omitempty" protobuf:"2"`
+	Category           []string      `json:"category,omitempty" protobuf:"3"`
+	AllowedVehicleType []VehicleType `json:"allowedVehicleType,omitempty" protobuf:"4"`
+	// Longest time a vehicle may stay, an ISO 8601 duration such as PT2H. Without it there is no limit.
+	MaximumParkingDuration string  `json:"maximumParkingDuration,omitempty" protobuf:"5"`
+	PermitActiveHours      string  `json:"permitActiveHours,omitempty" protobuf:"6"`
+	Occupancy              float64 `json:"occupancy,omitempty" validate:"min=0,max=1" protobuf:"7"`
+	OccupiedSpotNumber     int     `json:"occupiedSpotNumber" validate:"min=0" protobuf:"8"`
+	TotalSpotNumber        int     `json:"totalSpotNumber" validate:"min=0" protobuf:"9"`
+	// Occupied spots by parking spot instance
+	OccupiedSpots map[string]OccupiedSpot `json:"occupiedSpots,omitempty" protobuf:"10"`
+	// Spots whose vehicle stays longer than the maximum parking duration
+	OverstayingSpots []string `json:"overstayingSpots,omitempty" protobuf:"11"`
+	// Spots taken by a vehicle type that is not allowed
+	UnpermittedSpots []string      `json:"unpermittedSpots,omitempty" protobuf:"12"`
+	Status           ParkingStatus `json:"status,omitempty" validate:"oneof=open almostFull full closed" protobuf:"13"`
+}
+
+// Whether the vehicle type may park in the street, any may when the allowed types are not set
+func (o *OnStreetParking) IsPermitted(vehicleType VehicleType) bool {
+	if len(o.AllowedVehicleType) == 0 || vehicleType == "" {
+		return true
+	}
+	for _, allowed := range o.AllowedVehicleType {
+		if allowed == AnyVehicle || allowed == vehicleType {
+			return true
+		}
+	}
+	return false
+}
+
+// Takes the spot at the time, a spot already taken keeps the time it was taken
+func (o *OnStreetParking) Occupy(spot string, vehicleType VehicleType, now time.Time) {
+	if o.OccupiedSpots == nil {
+		o.OccupiedSpots = map[string]OccupiedSpot{}
+	}
+	if _, ok := o.OccupiedSpots[spot]; ok {
+		return
+	}
+	o.OccupiedSpots[spot] = OccupiedSpot{Since: now, VehicleType: vehicleType}
+}
+
+func (o *OnStreetParking) Free(spot string) {
+	delete(o.OccupiedSpots, spot)
+}
+
+// Gets the maximum parking duration, 0 when it is not set
+func (o *OnStreetParking) MaximumDuration() (time.Duration, error) {
+	if o.MaximumParkingDuration == "" {
+		return 0, nil
+	}
+	return ParseISODuration(o.MaximumParkingDuration)
+}
+
+// Sets the occupied spot number, the occupancy and the status like UpdateOccupancy of OffStreetParking,
+// and the spots overstaying the maximum duration at the time, when it is greater than 0, or taken by
+// a vehicle type that is not allowed
+func (o *OnStreetParking) UpdateOccupancy(almostFullOccupancy, fullOccupancy float64, maximumDuration time.Duration, now time.Time) {
+	o.OccupiedSpotNumber = len(o.OccupiedSpots)
+	if o.OccupiedSpotNumber > o.TotalSpotNumber {
+		o.OccupiedSpotNumber = o.TotalSpotNumber
+	}
+	o.Occupancy = occupancy(o.OccupiedSpotNumber, o.TotalSpotNumber)
+	o.Status = status(o.Status, o.Occupancy, almostFullOccupancy, fullOccupancy)
+
+	o.OverstayingSpots, o.UnpermittedSpots = nil, nil
+	for spot, occupied := range o.OccupiedSpots {
+		if maximumDuration > 0 && now.Sub(occupied.Since) > maximumDuration {
+			o.OverstayingSpots = append(o.OverstayingSpots, spot)
+		}
+		if !o.IsPermitted(occupied.VehicleType) {
+			o.UnpermittedSpots = append(o.UnpermittedSpots, spot)
+		}
+	}
+	sort.Strings(o.OverstayingSpots)
+	sort.Strings(o.UnpermittedSpots)
+}
+
+// Closes the parking or opens it, like SetOperatorStatus of OffStreetParking
+func (o *OnStreetParking) SetOperatorStatus(operatorStatus ParkingStatus, almostFullOccupancy, fullOccupancy float64) {
+	o.Status = statusByOperator(operatorStatus, o.Occupancy, almostFullOccupancy, fullOccupancy)
+}
+
+func (o *OnStreetParking) StatusEvent() ParkingStatusEvent {
+	return newParkingStatusEvent(o.Status, o.Occupancy, o.OccupiedSpotNumber, o.TotalSpotNumber)
+}
+
+var isoDuration = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// Parses an ISO 8601 duration of days, hours, minutes and seconds, such as PT2H or P1DT12H
+func ParseISODuration(value string) (time.Duration, error) {
+	matches := isoDuration.FindStringSubmatch(value)
+	if matches == nil || value == "P" || value[len(value)-1] == 'T' {
+		return 0, fmt.Errorf("invalid ISO 8601 duration %q", value)
+	}
+
+	var duration time.Duration
+	for i, unit := range []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if matches[i+1] == "" {
+			continue
+		}
+		amount, err := strconv.Atoi(matches[i+1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid ISO 8601 duration %q: %w", value, err)
+		}
+		duration += time.Duration(amount) * unit
+	}
+	return duration, nil
+}
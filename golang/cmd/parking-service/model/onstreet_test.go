@@ -0,0 +1,115 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestOnStreetParkingSuite(t *testing.T) {
+	suite.Run(t, new(OnStreetParkingSuite))
+}
+
+type OnStreetParkingSuite struct {
+	suite.Suite
+
+	now time.Time
+}
+
+func (s *OnStreetParkingSuite) SetupTest() {
+	s.now = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+}
+
+func (s *OnStreetParkingSuite) Test_ParseISODuration() {
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"PT2H", 2 * time.Hour},
+		{"PT90M", 90 * time.Minute},
+		{"P1DT12H", 36 * time.Hour},
+		{"PT1H30M15S", time.Hour + 30*time.Minute + 15*time.Second},
+		{"P2D", 48 * time.Hour},
+	}
+	for _, tt := range tests {
+		duration, err := ParseISODuration(tt.value)
+		s.Require().NoError(err, tt.value)
+		s.Assert().Equal(tt.expected, duration, tt.value)
+	}
+
+	for _, value := range []string{"", "P", "PT", "P1DT", "2h", "PT-1H", "P1W"} {
+		_, err := ParseISODuration(value)
+		s.Assert().Error(err, value)
+	}
+}
+
+func (s *OnStreetParkingSuite) Test_IsPermitted() {
+	parking := NewOnStreetParking()
+	s.Assert().True(parking.IsPermitted(Lorry))
+
+	parking.AllowedVehicleType = []VehicleType{Car, Motorcycle}
+	s.Assert().True(parking.IsPermitted(Car))
+	s.Assert().False(parking.IsPermitted(Lorry))
+	// The vehicle type is not always known
+	s.Assert().True(parking.IsPermitted(""))
+
+	parking.AllowedVehicleType = []VehicleType{AnyVehicle}
+	s.Assert().True(parking.IsPermitted(Bus))
+}
+
+func (s *OnStreetParkingSuite) Test_UpdateOccupancy() {
+	parking := OnStreetParking{TotalSpotNumber: 2, AllowedVehicleType: []VehicleType{Car}}
+
+	parking.Occupy("s0001", Car, s.now)
+	parking.UpdateOccupancy(0.5, 1, time.Hour, s.now)
+	s.Assert().Equal(1, parking.OccupiedSpotNumber)
+	s.Assert().Equal(0.5, parking.Occupancy)
+	s.Assert().Equal(ParkingAlmostFull, parking.Status)
+
+	// A spot taken again keeps the time it was first taken
+	later := s.now.Add(2 * time.Hour)
+	parking.Occupy("s0001", Car, later)
+	parking.Occupy("s0002", Van, later)
+	parking.UpdateOccupancy(0.5, 1, time.Hour, later)
+	s.Assert().Equal(ParkingFull, parking.Status)
+	s.Assert().Equal([]string{"s0001"}, parking.OverstayingSpots)
+	s.Assert().Equal([]string{"s0002"}, parking.UnpermittedSpots)
+	s.Assert().Equal(ParkingStatusEvent{Status: ParkingFull, Occupancy: 1, AvailableSpotNumber: 0, TotalSpotNumber: 2}, parking.StatusEvent())
+
+	// Without a maximum duration no spot overstays
+	parking.Free("s0002")
+	parking.UpdateOccupancy(0.5, 1, 0, later)
+	s.Assert().Equal(ParkingAlmostFull, parking.Status)
+	s.Assert().Empty(parking.OverstayingSpots)
+	s.Assert().Empty(parking.UnpermittedSpots)
+
+	// The occupied spots can not exceed the total
+	parking.TotalSpotNumber = 0
+	parking.UpdateOccupancy(0.5, 1, 0, later)
+	s.Assert().Equal(0, parking.OccupiedSpotNumber)
+}
+
+func (s *OnStreetParkingSuite) Test_ProtobufRoundTrip() {
+	codec, err := ktwin.GetCodec(ktwin.ApplicationProtobuf)
+	s.Require().NoError(err)
+
+	expected := OnStreetParking{
+		AllowedVehicleType: []VehicleType{Car},
+		OccupiedSpotNumber: 2,
+		TotalSpotNumber:    10,
+		OccupiedSpots: map[string]OccupiedSpot{
+			"ngsi-ld-city-parkingspot-nb001-p00001": {Since: s.now, VehicleType: Car},
+			"ngsi-ld-city-parkingspot-nb001-p00002": {Since: s.now.Add(-3 * time.Hour)},
+		},
+		OverstayingSpots: []string{"ngsi-ld-city-parkingspot-nb001-p00002"},
+		Status:           ParkingOpen,
+	}
+
+	data, err := codec.Marshal(expected)
+	s.Require().NoError(err)
+	var actual OnStreetParking
+	s.Require().NoError(codec.Unmarshal(data, &actual))
+	s.Assert().Equal(expected, actual)
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/parking-service/model"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kpolicy"
+)
+
+// Config of the parking service, the services.parking section of the config
+type Config struct {
+	// Number of spots of a parking without parking spots in the twin graph
+	DefaultTotalSpotNumber int `yaml:"defaultTotalSpotNumber" env:"KTWIN_PARKING_DEFAULT_TOTAL_SPOT_NUMBER"`
+	// Occupancy, from 0 to 1, from which the parking is almost full and full
+	AlmostFullOccupancy float64 `yaml:"almostFullOccupancy" env:"KTWIN_PARKING_ALMOST_FULL_OCCUPANCY"`
+	FullOccupancy       float64 `yaml:"fullOccupancy" env:"KTWIN_PARKING_FULL_OCCUPANCY"`
+	// Longest stay in the on-street parkings without maximumParkingDuration, 0 for no limit
+	MaximumParkingDuration time.Duration `yaml:"maximumParkingDuration" env:"KTWIN_PARKING_MAXIMUM_PARKING_DURATION"`
+}
+
+var serviceConfig = Config{
+	DefaultTotalSpotNumber: 50,
+	AlmostFullOccupancy:    0.9,
+	FullOccupancy:          1,
+}
+
+func (c *Config) Validate() error {
+	if c.DefaultTotalSpotNumber <= 0 {
+		return errors.New("defaultTotalSpotNumber must be greater than 0")
+	}
+	if c.AlmostFullOccupancy <= 0 || c.FullOccupancy > 1 || c.AlmostFullOccupancy > c.FullOccupancy {
+		return errors.New("almostFullOccupancy and fullOccupancy must satisfy 0 < almostFullOccupancy <= fullOccupancy <= 1")
+	}
+	if c.MaximumParkingDuration < 0 {
+		return errors.New("maximumParkingDuration must not be negative")
+	}
+	return nil
+}
+
+// Loads the config and registers it as the defaults of the twin instance policies of both parkings
+func LoadConfig() error {
+	if err := config.LoadService("parking", &serviceConfig); err != nil {
+		return err
+	}
+	return errors.Join(
+		kpolicy.Register(model.TWIN_INTERFACE_OFF_STREET_PARKING, serviceConfig),
+		kpolicy.Register(model.TWIN_INTERFACE_ON_STREET_PARKING, serviceConfig),
+	)
+}
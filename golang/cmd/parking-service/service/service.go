@@ -1,10 +1,15 @@
 package service
 
 import (
+	"fmt"
+
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/parking-service/model"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/clock"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kcommand"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kevent"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/keventstore"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kpolicy"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/ktwingraph"
 	log "github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
 )
@@ -15,7 +20,7 @@ var twinGraph *ktwin.TwinGraph
 func loadTwinGraph() error {
 	if twinGraph == nil {
 		var err error
-		graph, err := ktwingraph.LoadTwinGraphByInterfaces([]string{model.TWIN_INTERFACE_OFF_STREET_PARKING})
+		graph, err := ktwingraph.LoadTwinGraphByInterfaces([]string{model.TWIN_INTERFACE_OFF_STREET_PARKING, model.TWIN_INTERFACE_ON_STREET_PARKING, model.TWIN_INTERFACE_PARKING_SPOT})
 		if err != nil {
 			logger.Error("Error loading twin graph", err)
 			return err
@@ -25,17 +30,41 @@ func loadTwinGraph() error {
 	return nil
 }
 
+// Handlers of the vehicle count command of each parking interface
+var commandHandlers = map[string]func(*ktwin.TwinEvent) error{
+	model.TWIN_INTERFACE_OFF_STREET_PARKING: handleUpdateVehicleCountCommand,
+	model.TWIN_INTERFACE_ON_STREET_PARKING:  handleOnStreetUpdateVehicleCountCommand,
+}
+
+// Handlers of the status command of each parking interface, sent by the operator of the parking
+var statusCommandHandlers = map[string]func(*ktwin.TwinEvent) error{
+	model.TWIN_INTERFACE_OFF_STREET_PARKING: handleSetStatusCommand[model.OffStreetParking],
+	model.TWIN_INTERFACE_ON_STREET_PARKING:  handleSetStatusCommand[model.OnStreetParking],
+}
+
 func HandleEvent(event *ktwin.TwinEvent) error {
 	err := loadTwinGraph()
 	if err != nil {
 		return err
 	}
-	return kcommand.HandleCommand(event, model.TWIN_INTERFACE_OFF_STREET_PARKING, model.TWIN_COMMAND_UPDATE_VEHICLE_COUNT, *twinGraph, handleUpdateVehicleCountCommand)
+
+	for twinInterface, handler := range commandHandlers {
+		if err := kcommand.HandleCommand(event, twinInterface, model.TWIN_COMMAND_UPDATE_VEHICLE_COUNT, *twinGraph, handler); err != nil {
+			return err
+		}
+	}
+	for twinInterface, handler := range statusCommandHandlers {
+		if err := kcommand.HandleCommand(event, twinInterface, model.TWIN_COMMAND_SET_STATUS, *twinGraph, handler); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func handleUpdateVehicleCountCommand(command *ktwin.TwinEvent) error {
-	var parking model.OffStreetParking
+	parking := model.NewOffStreetParking()
 	var commandPayload model.UpdateVehicleCountCommand
+
 	err := command.ToModel(&commandPayload)
 	if err != nil {
 		return err
@@ -47,31 +76,26 @@ func handleUpdateVehicleCountCommand(command *ktwin.TwinEvent) error {
 	}
 
 	latestEvent, err := keventstore.GetLatestTwinEvent(command.TwinInterface, command.TwinInstance)
-
 	if err != nil {
 		return err
 	}
 
 	if latestEvent == nil {
-		parking.TotalSpotNumber = 50 // default value
-		parking.OccupiedSpotNumber = 0
-
-		if commandPayload.VehicleEntranceCount != 0 {
-			parking.IncrementOccupiedSpotNumber()
-		} else {
-			parking.DecrementOccupiedSpotNumber()
+		latestEvent = ktwin.NewTwinEvent()
+		err = latestEvent.SetEvent(command.TwinInterface, command.TwinInstance, ktwin.RealEvent, parking)
+		if err != nil {
+			return err
+		}
+	} else {
+		err = latestEvent.ToStoredModel(&parking)
+		if err != nil {
+			return err
 		}
-
-		newEvent := ktwin.NewTwinEvent()
-		newEvent.SetEvent(command.TwinInterface, command.TwinInstance, ktwin.RealEvent, parking)
-		newEvent.SetData(parking)
-		return keventstore.UpdateTwinEvent(newEvent)
 	}
 
-	err = latestEvent.ToModel(&parking)
-	if err != nil {
-		return err
-	}
+	previous := parking.StatusEvent()
+	policy := kpolicy.ResolveOrDefault(command.TwinInterface, command.TwinInstance, serviceConfig)
+	parking.SetTotalSpotNumber(totalSpotNumber(command.TwinInstance, model.TWIN_RELATIONSHIP_OFF_STREET_PARKING, policy))
 
 	if commandPayload.VehicleEntranceCount == 0 {
 		logger.Info("Vehicle entrance count is 0, no need to update the twin")
@@ -85,6 +109,175 @@ func handleUpdateVehicleCountCommand(command *ktwin.TwinEvent) error {
 		parking.DecrementOccupiedSpotNumber()
 	}
 
-	latestEvent.SetData(parking)
-	return keventstore.UpdateTwinEvent(latestEvent)
+	parking.UpdateOccupancy(policy.AlmostFullOccupancy, policy.FullOccupancy)
+
+	err = latestEvent.SetData(parking)
+	if err != nil {
+		return err
+	}
+	err = keventstore.UpdateTwinEvent(latestEvent)
+	if err != nil {
+		return err
+	}
+
+	return publishParkingChanges(command.TwinInterface, command.TwinInstance, previous, parking.StatusEvent())
+}
+
+// Takes or frees the parking spot that sent the command, the causation of the command. The time a spot
+// is taken is kept to find the vehicles staying longer than the maximum parking duration.
+func handleOnStreetUpdateVehicleCountCommand(command *ktwin.TwinEvent) error {
+	parking := model.NewOnStreetParking()
+	var commandPayload model.UpdateVehicleCountCommand
+
+	err := command.ToModel(&commandPayload)
+	if err != nil {
+		return err
+	}
+
+	if commandPayload.VehicleEntranceCount == 0 && commandPayload.VehicleExitCount == 0 {
+		logger.Info("Vehicle entrance and exit count are 0, no need to update the twin")
+		return nil
+	}
+
+	spot := command.Causation()
+	if spot == "" {
+		logger.Info(fmt.Sprintf("Command to TwinInstance %s has no parking spot causation, no need to update the twin", command.TwinInstance))
+		return nil
+	}
+
+	latestEvent, err := keventstore.GetLatestTwinEvent(command.TwinInterface, command.TwinInstance)
+	if err != nil {
+		return err
+	}
+
+	if latestEvent == nil {
+		latestEvent = ktwin.NewTwinEvent()
+		err = latestEvent.SetEvent(command.TwinInterface, command.TwinInstance, ktwin.RealEvent, parking)
+		if err != nil {
+			return err
+		}
+	} else {
+		err = latestEvent.ToStoredModel(&parking)
+		if err != nil {
+			return err
+		}
+	}
+
+	now := clock.Now()
+	previous := parking.StatusEvent()
+	policy := kpolicy.ResolveOrDefault(command.TwinInterface, command.TwinInstance, serviceConfig)
+	parking.TotalSpotNumber = totalSpotNumber(command.TwinInstance, model.TWIN_RELATIONSHIP_ON_STREET_PARKING, policy)
+
+	if commandPayload.VehicleEntranceCount != 0 {
+		parking.Occupy(spot, commandPayload.VehicleType, *now)
+	}
+	if commandPayload.VehicleExitCount != 0 {
+		parking.Free(spot)
+	}
+
+	maximumDuration, err := parking.MaximumDuration()
+	if err != nil {
+		logger.Error(fmt.Sprintf("TwinInstance %s has an invalid maximumParkingDuration, using the configured one", command.TwinInstance), err)
+	}
+	if err != nil || maximumDuration == 0 {
+		maximumDuration = policy.MaximumParkingDuration
+	}
+
+	parking.UpdateOccupancy(policy.AlmostFullOccupancy, policy.FullOccupancy, maximumDuration, *now)
+
+	err = latestEvent.SetData(parking)
+	if err != nil {
+		return err
+	}
+	err = keventstore.UpdateTwinEvent(latestEvent)
+	if err != nil {
+		return err
+	}
+
+	return publishParkingChanges(command.TwinInterface, command.TwinInstance, previous, parking.StatusEvent())
+}
+
+// A parking model whose status can be set by its operator
+type operatedParking[T any] interface {
+	*T
+	SetOperatorStatus(operatorStatus model.ParkingStatus, almostFullOccupancy, fullOccupancy float64)
+	StatusEvent() model.ParkingStatusEvent
+}
+
+// Closes the parking, or opens it with the status of its occupancy. The vehicle count commands keep a
+// closed parking closed, so it is only opened by this command.
+func handleSetStatusCommand[T any, P operatedParking[T]](command *ktwin.TwinEvent) error {
+	var commandPayload model.SetStatusCommand
+	err := command.ToModel(&commandPayload)
+	if err != nil {
+		return err
+	}
+
+	var parking T
+	latestEvent, err := keventstore.GetLatestTwinEvent(command.TwinInterface, command.TwinInstance)
+	if err != nil {
+		return err
+	}
+
+	if latestEvent == nil {
+		latestEvent = ktwin.NewTwinEvent()
+		err = latestEvent.SetEvent(command.TwinInterface, command.TwinInstance, ktwin.RealEvent, parking)
+		if err != nil {
+			return err
+		}
+	} else {
+		err = latestEvent.ToStoredModel(&parking)
+		if err != nil {
+			return err
+		}
+	}
+
+	previous := P(&parking).StatusEvent()
+	policy := kpolicy.ResolveOrDefault(command.TwinInterface, command.TwinInstance, serviceConfig)
+	P(&parking).SetOperatorStatus(commandPayload.Status, policy.AlmostFullOccupancy, policy.FullOccupancy)
+
+	if P(&parking).StatusEvent().Status == previous.Status {
+		logger.Info(fmt.Sprintf("TwinInstance %s is already %q, no need to update the twin", command.TwinInstance, previous.Status))
+		return nil
+	}
+
+	err = latestEvent.SetData(parking)
+	if err != nil {
+		return err
+	}
+	err = keventstore.UpdateTwinEvent(latestEvent)
+	if err != nil {
+		return err
+	}
+
+	return publishParkingChanges(command.TwinInterface, command.TwinInstance, previous, P(&parking).StatusEvent())
+}
+
+// Publishes the status of the parking to the real twin when it changed, and its available spots to
+// its neighborhood when they changed. Parkings without neighborhood in the twin graph are not reported.
+func publishParkingChanges(twinInterface, twinInstance string, previous, current model.ParkingStatusEvent) error {
+	if current.AvailableSpotNumber != previous.AvailableSpotNumber || current.TotalSpotNumber != previous.TotalSpotNumber {
+		if ktwingraph.GetRelationshipFromGraph(twinInstance, model.TWIN_RELATIONSHIP_NEIGHBORHOOD, *twinGraph) == nil {
+			logger.Info(fmt.Sprintf("TwinInstance %s has no relation %s, no need to update the neighborhood", twinInstance, model.TWIN_RELATIONSHIP_NEIGHBORHOOD))
+		} else if err := kcommand.PublishCommand(model.TWIN_COMMAND_NEIGHBORHOOD_UPDATE_PARKING, current.NeighborhoodCommand(), model.TWIN_RELATIONSHIP_NEIGHBORHOOD, twinInstance, *twinGraph); err != nil {
+			return err
+		}
+	}
+
+	if current.Status == previous.Status {
+		return nil
+	}
+
+	logger.Info(fmt.Sprintf("TwinInstance %s status changed from %q to %q", twinInstance, previous.Status, current.Status))
+	return kevent.PublishToRealTwin(twinInterface, twinInstance, current)
+}
+
+// Gets the capacity of the parking, the number of parking spots referencing it through the relationship
+// in the twin graph or the configured one when there is none
+func totalSpotNumber(twinInstance, relationshipName string, policy Config) int {
+	spots := ktwingraph.GetReverseRelationshipsFromGraph(twinInstance, relationshipName, *twinGraph)
+	if len(spots) == 0 {
+		return policy.DefaultTotalSpotNumber
+	}
+	return len(spots)
 }
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/parking-service/model"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/internal/kcommandtest"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/clock"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
@@ -18,6 +19,9 @@ import (
 
 const (
 	DEFAULT_UUID = "e8e126f6-62fb-40fd-a7cd-8264ca8600d0"
+
+	offStreetParking = "ngsi-ld-city-offstreetparking"
+	onStreetParking  = "ngsi-ld-city-onstreetparking"
 )
 
 func TestParkingServiceSuite(t *testing.T) {
@@ -99,7 +103,7 @@ func (s *ParkingServiceSuite) Test_ParkingEvent() {
 			name: `
 				Given new command is received and there is no previous event
 				When command has a valid vehicleEntranceCount property
-				Should create parking event and set OccupiedSpotNumber and TotalSpotNumber fields
+				Should create parking event, set OccupiedSpotNumber, TotalSpotNumber from the parking spots, Occupancy and Status and publish the Status
 			`,
 			twinEvent: func() *ktwin.TwinEvent {
 				twinEvent := ktwin.NewTwinEvent()
@@ -132,8 +136,11 @@ func (s *ParkingServiceSuite) Test_ParkingEvent() {
 					MatchHeader("ce-source", "ngsi-ld-city-offstreetparking-nb001-ofp0005").
 					MatchHeader("ce-type", "ktwin.store.ngsi-ld-city-offstreetparking").
 					MatchHeader("ce-subject", "").
-					BodyString(`{"occupiedSpotNumber":1,"totalSpotNumber":50}`).
+					BodyString(`{"occupancy":0.02,"occupiedSpotNumber":1,"totalSpotNumber":50,"status":"open"}`).
 					Reply(http.StatusAccepted)
+
+				s.mockStatusEvent(offStreetParking, "ngsi-ld-city-offstreetparking-nb001-ofp0005", `{"status":"open","occupancy":0.02,"availableSpotNumber":49,"totalSpotNumber":50}`)
+				s.mockNeighborhoodParking("ngsi-ld-city-offstreetparking-nb001-ofp0005", `{"availableSpotNumber":49,"totalSpotNumber":50}`)
 			},
 			expectedError: nil,
 		},
@@ -141,7 +148,7 @@ func (s *ParkingServiceSuite) Test_ParkingEvent() {
 			name: `
 				Given new command is received and there is no previous event
 				When command has a valid vehicleExitCount property
-				Should create parking event and set OccupiedSpotNumber and TotalSpotNumber fields
+				Should create parking event, set OccupiedSpotNumber, TotalSpotNumber from the parking spots, Occupancy and Status and publish the Status
 			`,
 			twinEvent: func() *ktwin.TwinEvent {
 				twinEvent := ktwin.NewTwinEvent()
@@ -174,8 +181,11 @@ func (s *ParkingServiceSuite) Test_ParkingEvent() {
 					MatchHeader("ce-source", "ngsi-ld-city-offstreetparking-nb001-ofp0005").
 					MatchHeader("ce-type", "ktwin.store.ngsi-ld-city-offstreetparking").
 					MatchHeader("ce-subject", "").
-					BodyString(`{"occupiedSpotNumber":0,"totalSpotNumber":50}`).
+					BodyString(`{"occupiedSpotNumber":0,"totalSpotNumber":50,"status":"open"}`).
 					Reply(http.StatusAccepted)
+
+				s.mockStatusEvent(offStreetParking, "ngsi-ld-city-offstreetparking-nb001-ofp0005", `{"status":"open","occupancy":0,"availableSpotNumber":50,"totalSpotNumber":50}`)
+				s.mockNeighborhoodParking("ngsi-ld-city-offstreetparking-nb001-ofp0005", `{"availableSpotNumber":50,"totalSpotNumber":50}`)
 			},
 			expectedError: nil,
 		},
@@ -183,7 +193,7 @@ func (s *ParkingServiceSuite) Test_ParkingEvent() {
 			name: `
 				Given new command is received and there is previous event
 				When command has a valid vehicleExitCount property
-				Should update parking event and decrement OccupiedSpotNumber field
+				Should update parking event and decrement OccupiedSpotNumber field without publishing the unchanged Status
 			`,
 			twinEvent: func() *ktwin.TwinEvent {
 				twinEvent := ktwin.NewTwinEvent()
@@ -215,6 +225,7 @@ func (s *ParkingServiceSuite) Test_ParkingEvent() {
 					JSON(model.OffStreetParking{
 						OccupiedSpotNumber: 1,
 						TotalSpotNumber:    50,
+						Status:             model.ParkingOpen,
 					})
 
 				gock.New(s.brokerUrl).
@@ -226,8 +237,10 @@ func (s *ParkingServiceSuite) Test_ParkingEvent() {
 					MatchHeader("ce-source", "ngsi-ld-city-offstreetparking-nb001-ofp0005").
 					MatchHeader("ce-type", "ktwin.store.ngsi-ld-city-offstreetparking").
 					MatchHeader("ce-subject", "").
-					BodyString(`{"occupiedSpotNumber":0,"totalSpotNumber":50}`).
+					BodyString(`{"occupiedSpotNumber":0,"totalSpotNumber":50,"status":"open"}`).
 					Reply(http.StatusAccepted)
+
+				s.mockNeighborhoodParking("ngsi-ld-city-offstreetparking-nb001-ofp0005", `{"availableSpotNumber":50,"totalSpotNumber":50}`)
 			},
 			expectedError: nil,
 		},
@@ -235,7 +248,7 @@ func (s *ParkingServiceSuite) Test_ParkingEvent() {
 			name: `
 			Given new command is received and there is previous event
 			When command has a valid vehicleEntranceCount property
-			Should update parking event and increment OccupiedSpotNumber field
+			Should update parking event, increment OccupiedSpotNumber field and publish the new Status
 			`,
 			twinEvent: func() *ktwin.TwinEvent {
 				twinEvent := ktwin.NewTwinEvent()
@@ -278,8 +291,77 @@ func (s *ParkingServiceSuite) Test_ParkingEvent() {
 					MatchHeader("ce-source", "ngsi-ld-city-offstreetparking-nb001-ofp0005").
 					MatchHeader("ce-type", "ktwin.store.ngsi-ld-city-offstreetparking").
 					MatchHeader("ce-subject", "").
-					BodyString(`{"occupiedSpotNumber":2,"totalSpotNumber":50}`).
+					BodyString(`{"occupancy":0.04,"occupiedSpotNumber":2,"totalSpotNumber":50,"status":"open"}`).
 					Reply(http.StatusAccepted)
+
+				s.mockStatusEvent(offStreetParking, "ngsi-ld-city-offstreetparking-nb001-ofp0005", `{"status":"open","occupancy":0.04,"availableSpotNumber":48,"totalSpotNumber":50}`)
+				s.mockNeighborhoodParking("ngsi-ld-city-offstreetparking-nb001-ofp0005", `{"availableSpotNumber":48,"totalSpotNumber":50}`)
+			},
+			expectedError: nil,
+		},
+		{
+			name: `
+			Given new command is received and there is previous event
+			When the occupancy reaches the almost full occupancy
+			Should update parking event and publish the almostFull Status
+			`,
+			twinEvent: func() *ktwin.TwinEvent {
+				return s.newCommand(offStreetParking, "ngsi-ld-city-offstreetparking-nb001-ofp0005", `{"vehicleEntranceCount": 1}`)
+			},
+			mockExternalService: func() {
+				s.mockLatestParking(offStreetParking, "ngsi-ld-city-offstreetparking-nb001-ofp0005", model.OffStreetParking{OccupiedSpotNumber: 44, TotalSpotNumber: 50, Status: model.ParkingOpen})
+				s.mockStoreParking(offStreetParking, "ngsi-ld-city-offstreetparking-nb001-ofp0005", `{"occupancy":0.9,"occupiedSpotNumber":45,"totalSpotNumber":50,"status":"almostFull"}`)
+				s.mockStatusEvent(offStreetParking, "ngsi-ld-city-offstreetparking-nb001-ofp0005", `{"status":"almostFull","occupancy":0.9,"availableSpotNumber":5,"totalSpotNumber":50}`)
+				s.mockNeighborhoodParking("ngsi-ld-city-offstreetparking-nb001-ofp0005", `{"availableSpotNumber":5,"totalSpotNumber":50}`)
+			},
+			expectedError: nil,
+		},
+		{
+			name: `
+			Given new command is received and there is previous event
+			When the last spot is taken
+			Should update parking event and publish the full Status
+			`,
+			twinEvent: func() *ktwin.TwinEvent {
+				return s.newCommand(offStreetParking, "ngsi-ld-city-offstreetparking-nb001-ofp0005", `{"vehicleEntranceCount": 1}`)
+			},
+			mockExternalService: func() {
+				s.mockLatestParking(offStreetParking, "ngsi-ld-city-offstreetparking-nb001-ofp0005", model.OffStreetParking{OccupiedSpotNumber: 49, TotalSpotNumber: 50, Status: model.ParkingAlmostFull})
+				s.mockStoreParking(offStreetParking, "ngsi-ld-city-offstreetparking-nb001-ofp0005", `{"occupancy":1,"occupiedSpotNumber":50,"totalSpotNumber":50,"status":"full"}`)
+				s.mockStatusEvent(offStreetParking, "ngsi-ld-city-offstreetparking-nb001-ofp0005", `{"status":"full","occupancy":1,"availableSpotNumber":0,"totalSpotNumber":50}`)
+				s.mockNeighborhoodParking("ngsi-ld-city-offstreetparking-nb001-ofp0005", `{"availableSpotNumber":0,"totalSpotNumber":50}`)
+			},
+			expectedError: nil,
+		},
+		{
+			name: `
+			Given new command is received and the parking is closed
+			When a vehicle exits
+			Should update the occupancy and keep the closed Status
+			`,
+			twinEvent: func() *ktwin.TwinEvent {
+				return s.newCommand(offStreetParking, "ngsi-ld-city-offstreetparking-nb001-ofp0005", `{"vehicleExitCount": 1}`)
+			},
+			mockExternalService: func() {
+				s.mockLatestParking(offStreetParking, "ngsi-ld-city-offstreetparking-nb001-ofp0005", model.OffStreetParking{OccupiedSpotNumber: 50, TotalSpotNumber: 50, Status: model.ParkingClosed})
+				s.mockStoreParking(offStreetParking, "ngsi-ld-city-offstreetparking-nb001-ofp0005", `{"occupancy":0.98,"occupiedSpotNumber":49,"totalSpotNumber":50,"status":"closed"}`)
+				s.mockNeighborhoodParking("ngsi-ld-city-offstreetparking-nb001-ofp0005", `{"availableSpotNumber":1,"totalSpotNumber":50}`)
+			},
+			expectedError: nil,
+		},
+		{
+			name: `
+			Given new command is received for a parking without parking spots in the twin graph
+			When command has a valid vehicleEntranceCount property
+			Should set the TotalSpotNumber from the config, the occupied spots can not exceed it
+			`,
+			twinEvent: func() *ktwin.TwinEvent {
+				return s.newCommand(offStreetParking, "ngsi-ld-city-offstreetparking-nb001-ofp9999", `{"vehicleEntranceCount": 1}`)
+			},
+			mockExternalService: func() {
+				s.mockLatestParking(offStreetParking, "ngsi-ld-city-offstreetparking-nb001-ofp9999", model.OffStreetParking{OccupiedSpotNumber: 60, TotalSpotNumber: 120, Status: model.ParkingOpen})
+				s.mockStoreParking(offStreetParking, "ngsi-ld-city-offstreetparking-nb001-ofp9999", `{"occupancy":1,"occupiedSpotNumber":50,"totalSpotNumber":50,"status":"full"}`)
+				s.mockStatusEvent(offStreetParking, "ngsi-ld-city-offstreetparking-nb001-ofp9999", `{"status":"full","occupancy":1,"availableSpotNumber":0,"totalSpotNumber":50}`)
 			},
 			expectedError: nil,
 		},
@@ -292,6 +374,274 @@ func (s *ParkingServiceSuite) Test_ParkingEvent() {
 			actualError := HandleEvent(tt.twinEvent())
 
 			s.Assert().Equal(tt.expectedError, actualError)
+			s.Assert().True(gock.IsDone())
 		})
 	}
 }
+
+func (s *ParkingServiceSuite) Test_OnStreetParkingEvent() {
+	defer clock.ResetClockImplementation()
+
+	now, _ := time.Parse(time.RFC3339, "2024-01-01T12:00:00Z")
+	clock.NowFunc = func() *time.Time {
+		return &now
+	}
+
+	const parking = "ngsi-ld-city-onstreetparking-nb001-onp0010"
+	const spot = "ngsi-ld-city-onstreetparkingspot-nb001-onp0010-s0050"
+	const otherSpot = "ngsi-ld-city-onstreetparkingspot-nb001-onp0010-s0001"
+
+	tests := []struct {
+		name                string
+		mockExternalService func()
+		twinEvent           func() *ktwin.TwinEvent
+		expectedError       error
+	}{
+		{
+			name: `
+			Given new command is received without the parking spot causing it
+			Should not update the parking
+			`,
+			twinEvent: func() *ktwin.TwinEvent {
+				return s.newCommand(onStreetParking, parking, `{"vehicleEntranceCount": 1}`)
+			},
+			mockExternalService: func() {},
+			expectedError:       nil,
+		},
+		{
+			name: `
+			Given new command is received and there is no previous event
+			When a parking spot is taken
+			Should create parking event with the occupied spot, TotalSpotNumber from the parking spots and publish the Status
+			`,
+			twinEvent: func() *ktwin.TwinEvent {
+				return s.newSpotCommand(parking, spot, `{"vehicleEntranceCount": 1, "vehicleType": "car"}`)
+			},
+			mockExternalService: func() {
+				gock.New(s.eventStoreUrl).
+					Get("/api/v1/twin-events/" + onStreetParking + "/" + parking + "/latest").
+					Reply(http.StatusNotFound)
+				s.mockStoreParking(onStreetParking, parking, `{"occupancy":0.02,"occupiedSpotNumber":1,"totalSpotNumber":50,"occupiedSpots":{"`+spot+`":{"since":"2024-01-01T12:00:00Z","vehicleType":"car"}},"status":"open"}`)
+				s.mockStatusEvent(onStreetParking, parking, `{"status":"open","occupancy":0.02,"availableSpotNumber":49,"totalSpotNumber":50}`)
+				s.mockNeighborhoodParking(parking, `{"availableSpotNumber":49,"totalSpotNumber":50}`)
+			},
+			expectedError: nil,
+		},
+		{
+			name: `
+			Given new command is received and there is previous event with allowed vehicle types and a maximum parking duration
+			When a parking spot is taken by a vehicle type not allowed
+			Should set the unpermitted spots and the spots overstaying the maximum parking duration
+			`,
+			twinEvent: func() *ktwin.TwinEvent {
+				return s.newSpotCommand(parking, spot, `{"vehicleEntranceCount": 1, "vehicleType": "lorry"}`)
+			},
+			mockExternalService: func() {
+				s.mockLatestParking(onStreetParking, parking, model.OnStreetParking{
+					AllowedVehicleType:     []model.VehicleType{model.Car},
+					MaximumParkingDuration: "PT2H",
+					OccupiedSpots:          map[string]model.OccupiedSpot{otherSpot: {Since: now.Add(-3 * time.Hour), VehicleType: model.Car}},
+					OccupiedSpotNumber:     1,
+					TotalSpotNumber:        50,
+					Status:                 model.ParkingOpen,
+				})
+				s.mockNeighborhoodParking(parking, `{"availableSpotNumber":48,"totalSpotNumber":50}`)
+				s.mockStoreParking(onStreetParking, parking, `{"allowedVehicleType":["car"],"maximumParkingDuration":"PT2H","occupancy":0.04,"occupiedSpotNumber":2,"totalSpotNumber":50,"occupiedSpots":{"`+otherSpot+`":{"since":"2024-01-01T09:00:00Z","vehicleType":"car"},"`+spot+`":{"since":"2024-01-01T12:00:00Z","vehicleType":"lorry"}},"overstayingSpots":["`+otherSpot+`"],"unpermittedSpots":["`+spot+`"],"status":"open"}`)
+			},
+			expectedError: nil,
+		},
+		{
+			name: `
+			Given new command is received and there is previous event with an invalid maximum parking duration
+			When a parking spot is freed
+			Should remove the occupied spot and use the configured maximum parking duration
+			`,
+			twinEvent: func() *ktwin.TwinEvent {
+				return s.newSpotCommand(parking, spot, `{"vehicleExitCount": 1}`)
+			},
+			mockExternalService: func() {
+				s.mockLatestParking(onStreetParking, parking, model.OnStreetParking{
+					MaximumParkingDuration: "2 hours",
+					OccupiedSpots: map[string]model.OccupiedSpot{
+						otherSpot: {Since: now.Add(-3 * time.Hour)},
+						spot:      {Since: now.Add(-time.Hour)},
+					},
+					OccupiedSpotNumber: 2,
+					TotalSpotNumber:    50,
+					Status:             model.ParkingOpen,
+				})
+				s.mockNeighborhoodParking(parking, `{"availableSpotNumber":49,"totalSpotNumber":50}`)
+				s.mockStoreParking(onStreetParking, parking, `{"maximumParkingDuration":"2 hours","occupancy":0.02,"occupiedSpotNumber":1,"totalSpotNumber":50,"occupiedSpots":{"`+otherSpot+`":{"since":"2024-01-01T09:00:00Z"}},"status":"open"}`)
+			},
+			expectedError: nil,
+		},
+	}
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			defer gock.Off()
+			tt.mockExternalService()
+
+			actualError := HandleEvent(tt.twinEvent())
+
+			s.Assert().Equal(tt.expectedError, actualError)
+			s.Assert().True(gock.IsDone())
+		})
+	}
+}
+
+func (s *ParkingServiceSuite) Test_SetStatusCommand() {
+	defer clock.ResetClockImplementation()
+
+	now, _ := time.Parse(time.RFC3339, "2024-01-01T12:00:00Z")
+	clock.NowFunc = func() *time.Time {
+		return &now
+	}
+
+	const offStreetInstance = "ngsi-ld-city-offstreetparking-nb001-ofp0005"
+	const onStreetInstance = "ngsi-ld-city-onstreetparking-nb001-onp0010"
+
+	tests := []struct {
+		name                string
+		mockExternalService func()
+		twinEvent           func() *ktwin.TwinEvent
+	}{
+		{
+			name: `
+			Given the operator closes an open parking
+			Should store and publish the closed Status, the available spots are unchanged
+			`,
+			twinEvent: func() *ktwin.TwinEvent {
+				return s.newStatusCommand(offStreetParking, offStreetInstance, `{"status": "closed"}`)
+			},
+			mockExternalService: func() {
+				s.mockLatestParking(offStreetParking, offStreetInstance, model.OffStreetParking{Occupancy: 0.5, OccupiedSpotNumber: 25, TotalSpotNumber: 50, Status: model.ParkingOpen})
+				s.mockStoreParking(offStreetParking, offStreetInstance, `{"occupancy":0.5,"occupiedSpotNumber":25,"totalSpotNumber":50,"status":"closed"}`)
+				s.mockStatusEvent(offStreetParking, offStreetInstance, `{"status":"closed","occupancy":0.5,"availableSpotNumber":25,"totalSpotNumber":50}`)
+			},
+		},
+		{
+			name: `
+			Given the operator opens a closed parking
+			Should set the Status of its occupancy
+			`,
+			twinEvent: func() *ktwin.TwinEvent {
+				return s.newStatusCommand(offStreetParking, offStreetInstance, `{"status": "open"}`)
+			},
+			mockExternalService: func() {
+				s.mockLatestParking(offStreetParking, offStreetInstance, model.OffStreetParking{Occupancy: 0.9, OccupiedSpotNumber: 45, TotalSpotNumber: 50, Status: model.ParkingClosed})
+				s.mockStoreParking(offStreetParking, offStreetInstance, `{"occupancy":0.9,"occupiedSpotNumber":45,"totalSpotNumber":50,"status":"almostFull"}`)
+				s.mockStatusEvent(offStreetParking, offStreetInstance, `{"status":"almostFull","occupancy":0.9,"availableSpotNumber":5,"totalSpotNumber":50}`)
+			},
+		},
+		{
+			name: `
+			Given the operator opens a parking that is not closed
+			Should not update the parking
+			`,
+			twinEvent: func() *ktwin.TwinEvent {
+				return s.newStatusCommand(offStreetParking, offStreetInstance, `{"status": "open"}`)
+			},
+			mockExternalService: func() {
+				s.mockLatestParking(offStreetParking, offStreetInstance, model.OffStreetParking{Occupancy: 1, OccupiedSpotNumber: 50, TotalSpotNumber: 50, Status: model.ParkingFull})
+			},
+		},
+		{
+			name: `
+			Given the operator closes an on-street parking without previous event
+			Should create the parking event with the closed Status
+			`,
+			twinEvent: func() *ktwin.TwinEvent {
+				return s.newStatusCommand(onStreetParking, onStreetInstance, `{"status": "closed"}`)
+			},
+			mockExternalService: func() {
+				gock.New(s.eventStoreUrl).
+					Get("/api/v1/twin-events/" + onStreetParking + "/" + onStreetInstance + "/latest").
+					Reply(http.StatusNotFound)
+				s.mockStoreParking(onStreetParking, onStreetInstance, `{"occupiedSpotNumber":0,"totalSpotNumber":0,"status":"closed"}`)
+				s.mockStatusEvent(onStreetParking, onStreetInstance, `{"status":"closed","occupancy":0,"availableSpotNumber":0,"totalSpotNumber":0}`)
+			},
+		},
+	}
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			defer gock.Off()
+			tt.mockExternalService()
+
+			s.Assert().NoError(HandleEvent(tt.twinEvent()))
+			s.Assert().True(gock.IsDone())
+		})
+	}
+}
+
+func (s *ParkingServiceSuite) Test_SetStatusCommandRejectsOccupancyStatus() {
+	err := HandleEvent(s.newStatusCommand(offStreetParking, "ngsi-ld-city-offstreetparking-nb001-ofp0005", `{"status": "full"}`))
+
+	var validationError *ktwin.ValidationError
+	s.Require().ErrorAs(err, &validationError)
+	s.Assert().Equal("status", validationError.Violations[0].Field)
+	s.Assert().Equal("oneof", validationError.Violations[0].Rule)
+}
+
+func (s *ParkingServiceSuite) newCommand(twinInterface, twinInstance, data string) *ktwin.TwinEvent {
+	cloudEvent := cloudevents.NewEvent()
+	cloudEvent.SetData("application/json", []byte(data))
+	cloudEvent.SetID("")
+	cloudEvent.SetSource(twinInstance)
+	cloudEvent.SetType("ktwin.command." + twinInterface + ".updatevehiclecount")
+	cloudEvent.SetTime(*clock.Now())
+
+	twinEvent, err := ktwin.NewTwinEventFromCloudEvent(&cloudEvent)
+	s.Require().NoError(err)
+	return twinEvent
+}
+
+// The status commands are sent by the operator of the parking
+func (s *ParkingServiceSuite) newStatusCommand(twinInterface, twinInstance, data string) *ktwin.TwinEvent {
+	twinEvent := s.newCommand(twinInterface, twinInstance, data)
+	twinEvent.CloudEvent.SetType("ktwin.command." + twinInterface + ".setstatus")
+	twinEvent.CommandName = "setstatus"
+	return twinEvent
+}
+
+// The on-street parking commands are caused by the parking spot taken or freed
+func (s *ParkingServiceSuite) newSpotCommand(twinInstance, spot, data string) *ktwin.TwinEvent {
+	twinEvent := s.newCommand(onStreetParking, twinInstance, data)
+	twinEvent.CloudEvent.SetExtension(ktwin.CausationExtension, spot)
+	return twinEvent
+}
+
+func (s *ParkingServiceSuite) mockLatestParking(twinInterface, twinInstance string, parking interface{}) {
+	gock.New(s.eventStoreUrl).
+		Get("/api/v1/twin-events/"+twinInterface+"/"+twinInstance+"/latest").
+		Reply(http.StatusOK).
+		SetHeader("Content-Type", "application/json").
+		SetHeader("ce-specversion", "1.0").
+		SetHeader("ce-time", clock.Now().Format(time.RFC3339)).
+		SetHeader("ce-source", twinInstance).
+		SetHeader("ce-type", "ktwin.store."+twinInterface).
+		JSON(parking)
+}
+
+func (s *ParkingServiceSuite) mockStoreParking(twinInterface, twinInstance, body string) {
+	gock.New(s.brokerUrl).
+		Post("/").
+		MatchHeader("ce-source", twinInstance).
+		MatchHeader("ce-type", "ktwin.store."+twinInterface).
+		BodyString(body).
+		Reply(http.StatusAccepted)
+}
+
+// The status changes are published to the real twin
+func (s *ParkingServiceSuite) mockStatusEvent(twinInterface, twinInstance, body string) {
+	gock.New(s.brokerUrl).
+		Post("/").
+		MatchHeader("ce-source", twinInstance).
+		MatchHeader("ce-type", "ktwin.virtual."+twinInterface).
+		BodyString(body).
+		Reply(http.StatusAccepted)
+}
+
+// The available spots are reported to the neighborhood of the parking when they change
+func (s *ParkingServiceSuite) mockNeighborhoodParking(twinInstance, body string) {
+	kcommandtest.MockCommand(s.brokerUrl, "s4city-city-neighborhood", "s4city-city-neighborhood-nb001", model.TWIN_COMMAND_NEIGHBORHOOD_UPDATE_PARKING, twinInstance, body)
+}
@@ -1,12 +1,17 @@
 package model
 
-import "time"
+import (
+	"time"
+
+	parkingModel "github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/parking-service/model"
+)
 
 var (
 	TWIN_INTERFACE_ON_STREET_PARKING_SPOT = "ngsi-ld-city-onstreetparkingspot"
 
 	TWIN_INTERFACE_PARKING_SPOT                    = "ngsi-ld-city-parkingspot"
 	TWIN_INTERFACE_OFF_STREET_PARKING_RELATIONSHIP = "refOffStreetParking"
+	TWIN_INTERFACE_ON_STREET_PARKING_RELATIONSHIP  = "refOnStreetParking"
 	TWIN_COMMAND_PARKING_UPDATE_VEHICLE_COUNT      = "updateVehicleCount"
 )
 
@@ -24,12 +29,14 @@ const (
 )
 
 type ParkingSpot struct {
-	DateObserved float64   `json:"dateObserved"`
-	Width        float64   `json:"width"`
-	Length       float64   `json:"length"`
-	TimeInstant  time.Time `json:"timeInstant"`
-	Image        string    `json:"image"`
-	Color        string    `json:"color"`
-	Category     Category  `json:"category"`
-	Status       Status    `json:"status"`
+	DateObserved float64   `json:"dateObserved" protobuf:"1"`
+	Width        float64   `json:"width" validate:"min=0" protobuf:"2"`
+	Length       float64   `json:"length" validate:"min=0" protobuf:"3"`
+	TimeInstant  time.Time `json:"timeInstant" protobuf:"4"`
+	Image        string    `json:"image" protobuf:"5"`
+	Color        string    `json:"color" protobuf:"6"`
+	Category     Category  `json:"category" validate:"oneof=offStreet onStreet" protobuf:"7"`
+	Status       Status    `json:"status" validate:"oneof=occupied free closed unknown" protobuf:"8"`
+	// Type of the vehicle taking the spot, checked against the allowed vehicle types of on-street parkings
+	VehicleType parkingModel.VehicleType `json:"vehicleType,omitempty" protobuf:"9"`
 }
@@ -50,21 +50,38 @@ func handleParkingSpotEvent(event *ktwin.TwinEvent) error {
 		return nil
 	}
 
+	relationshipName := parkingRelationship(event.TwinInstance)
+	if relationshipName == "" {
+		logger.Info(fmt.Sprintf("ParkingSpot instance %s has no parking relationship", event.TwinInstance))
+		return nil
+	}
+
 	if parkingSpot.Status == model.Occupied {
 		updateCommand := parkingModel.UpdateVehicleCountCommand{
 			VehicleEntranceCount: 1,
+			VehicleType:          parkingSpot.VehicleType,
 		}
-		return kcommand.PublishCommand(model.TWIN_COMMAND_PARKING_UPDATE_VEHICLE_COUNT, updateCommand, model.TWIN_INTERFACE_OFF_STREET_PARKING_RELATIONSHIP, event.TwinInstance, *twinGraph)
+		return kcommand.PublishCommand(model.TWIN_COMMAND_PARKING_UPDATE_VEHICLE_COUNT, updateCommand, relationshipName, event.TwinInstance, *twinGraph)
 	}
 
 	if parkingSpot.Status == model.Free {
 		updateCommand := parkingModel.UpdateVehicleCountCommand{
 			VehicleExitCount: 1,
 		}
-		return kcommand.PublishCommand(model.TWIN_COMMAND_PARKING_UPDATE_VEHICLE_COUNT, updateCommand, model.TWIN_INTERFACE_OFF_STREET_PARKING_RELATIONSHIP, event.TwinInstance, *twinGraph)
+		return kcommand.PublishCommand(model.TWIN_COMMAND_PARKING_UPDATE_VEHICLE_COUNT, updateCommand, relationshipName, event.TwinInstance, *twinGraph)
 	}
 
 	logger.Info(fmt.Sprintf("ParkingSpot status is not recognized for instance %s", event.TwinInstance))
 
 	return nil
 }
+
+// Gets the relationship of the parking spot to its parking, on-street or off-street, empty when it has none
+func parkingRelationship(twinInstance string) string {
+	for _, relationshipName := range []string{model.TWIN_INTERFACE_OFF_STREET_PARKING_RELATIONSHIP, model.TWIN_INTERFACE_ON_STREET_PARKING_RELATIONSHIP} {
+		if ktwingraph.GetRelationshipFromGraph(twinInstance, relationshipName, *twinGraph) != nil {
+			return relationshipName
+		}
+	}
+	return ""
+}
@@ -168,6 +168,43 @@ func (s *ParkingSpotServiceSuite) Test_ParkingSpotEvent() {
 			},
 			expectedError: nil,
 		},
+		{
+			name: `
+				Given new parking spot event is received
+				When the parking spot is of an on-street parking and has status as occupied
+				Should generate command to the on-street parking with the vehicle type
+			`,
+			twinEvent: func() *ktwin.TwinEvent {
+				twinEvent := ktwin.NewTwinEvent()
+				twinEvent.EventType = ktwin.CommandEvent
+				twinEvent.TwinInstance = "ngsi-ld-city-onstreetparkingspot-nb001-onp0010-s0050"
+				twinEvent.TwinInterface = "ngsi-ld-city-parkingspot"
+
+				cloudEvent := cloudevents.NewEvent()
+				cloudEvent.SetData("application/json", []byte(`{"status": "occupied", "vehicleType": "car"}`))
+				cloudEvent.SetID("")
+				cloudEvent.SetSource("ngsi-ld-city-onstreetparkingspot-nb001-onp0010-s0050")
+				cloudEvent.SetType("ktwin.real.ngsi-ld-city-parkingspot")
+				cloudEvent.SetTime(*dateTime)
+
+				twinEvent.CloudEvent = &cloudEvent
+				return twinEvent
+			},
+			mockExternalService: func() {
+				gock.New(s.brokerUrl).
+					Post("/").
+					MatchHeader("Content-Type", "application/json").
+					MatchHeader("ce-id", DEFAULT_UUID).
+					MatchHeader("ce-specversion", "1.0").
+					MatchHeader("ce-time", dateTimeFormatted).
+					MatchHeader("ce-source", "ngsi-ld-city-onstreetparking-nb001-onp0010").
+					MatchHeader("ce-type", "ktwin.command.ngsi-ld-city-onstreetparking.updatevehiclecount").
+					MatchHeader("ce-subject", "").
+					BodyString(`{"vehicleEntranceCount":1,"vehicleType":"car"}`).
+					Reply(http.StatusAccepted)
+			},
+			expectedError: nil,
+		},
 	}
 	for _, tt := range tests {
 		s.Run(tt.name, func() {
@@ -0,0 +1,119 @@
+package model
+
+import (
+	"sort"
+	"time"
+)
+
+var (
+	TWIN_INTERFACE_CITY_POLE = "city-pole"
+
+	TWIN_COMMAND_UPDATE_AIR_QUALITY_INDEX   = "updateAirQualityIndex"
+	TWIN_COMMAND_UPDATE_NOISE_LEVEL         = "updateNoiseLevel"
+	TWIN_COMMAND_UPDATE_WEATHER             = "updateWeather"
+	TWIN_COMMAND_UPDATE_CROWD_FLOW          = "updateCrowdFlow"
+	TWIN_COMMAND_UPDATE_TRAFFIC_FLOW        = "updateTrafficFlow"
+	TWIN_COMMAND_UPDATE_STREETLIGHT         = "updateStreetlight"
+	TWIN_COMMAND_UPDATE_EV_CHARGING_STATION = "updateEVChargingStation"
+	TWIN_COMMAND_NEIGHBORHOOD_UPDATE_POLE   = "updatePole"
+	TWIN_COMMAND_NEIGHBORHOOD_RELATIONSHIP  = "refNeighborhood"
+)
+
+// Relationships of the pole to its attached sensors
+const (
+	SENSOR_AIR_QUALITY         = "refAirQualityObserved"
+	SENSOR_NOISE_LEVEL         = "refNoiseLevel"
+	SENSOR_WEATHER             = "refWeather"
+	SENSOR_CROWD_FLOW          = "refCrowdFlow"
+	SENSOR_TRAFFIC_FLOW        = "refTrafficFlow"
+	SENSOR_STREETLIGHT         = "refStreetlight"
+	SENSOR_EV_CHARGING_STATION = "refEVChargingStation"
+)
+
+// SensorReport is the latest report of an attached sensor
+type SensorReport struct {
+	Instance     string     `json:"instance,omitempty" protobuf:"1"`
+	DateObserved *time.Time `json:"dateObserved,omitempty" protobuf:"2"`
+}
+
+// CityPole is the composite state of the sensors attached to the pole, with the latest summary of each
+type CityPole struct {
+	AirQuality        *UpdateAirQualityIndexCommand   `json:"airQuality,omitempty" protobuf:"1"`
+	NoiseLevel        *UpdateNoiseLevelCommand        `json:"noiseLevel,omitempty" protobuf:"2"`
+	Weather           *UpdateWeatherCommand           `json:"weather,omitempty" protobuf:"3"`
+	CrowdFlow         *UpdateCrowdFlowCommand         `json:"crowdFlow,omitempty" protobuf:"4"`
+	TrafficFlow       *UpdateTrafficFlowCommand       `json:"trafficFlow,omitempty" protobuf:"5"`
+	Streetlight       *UpdateStreetlightCommand       `json:"streetlight,omitempty" protobuf:"6"`
+	EVChargingStation *UpdateEVChargingStationCommand `json:"evChargingStation,omitempty" protobuf:"7"`
+	// Latest report of each attached sensor, by relationship
+	Sensors map[string]SensorReport `json:"sensors,omitempty" protobuf:"8"`
+	// Relationships of the attached sensors whose latest report is stale
+	MissingSensors []string   `json:"missingSensors,omitempty" protobuf:"9"`
+	DateModified   *time.Time `json:"dateModified,omitempty" protobuf:"10"`
+}
+
+// Records the report of the sensor of the relationship
+func (p *CityPole) Report(sensor, instance string, now *time.Time) {
+	if p.Sensors == nil {
+		p.Sensors = map[string]SensorReport{}
+	}
+	p.Sensors[sensor] = SensorReport{Instance: instance, DateObserved: now}
+}
+
+// Sets the missing sensors, the attached ones without a report observed after the time. Sensors that
+// never reported are not missing, the graph relates poles to sensors that are not deployed.
+func (p *CityPole) SetMissingSensors(attached []string, observedAfter time.Time) {
+	p.MissingSensors = nil
+	for _, sensor := range attached {
+		if _, reported := p.Sensors[sensor]; reported && !p.isReporting(sensor, observedAfter) {
+			p.MissingSensors = append(p.MissingSensors, sensor)
+		}
+	}
+	sort.Strings(p.MissingSensors)
+}
+
+func (p *CityPole) isReporting(sensor string, observedAfter time.Time) bool {
+	report, ok := p.Sensors[sensor]
+	return ok && report.DateObserved != nil && !report.DateObserved.Before(observedAfter)
+}
+
+// UpdatePoleCommand is the consolidated update of the pole sent to the neighborhood, with the summaries
+// of the sensors the neighborhood aggregates that are not missing
+type UpdatePoleCommand struct {
+	AirQuality     *UpdateAirQualityIndexCommand `json:"airQuality,omitempty" protobuf:"1"`
+	NoiseLevel     *UpdateNoiseLevelCommand      `json:"noiseLevel,omitempty" protobuf:"2"`
+	Weather        *UpdateWeatherCommand         `json:"weather,omitempty" protobuf:"3"`
+	CrowdFlow      *UpdateCrowdFlowCommand       `json:"crowdFlow,omitempty" protobuf:"4"`
+	TrafficFlow    *UpdateTrafficFlowCommand     `json:"trafficFlow,omitempty" protobuf:"5"`
+	MissingSensors []string                      `json:"missingSensors,omitempty" protobuf:"6"`
+}
+
+// Gets the consolidated update of the pole for the neighborhood
+func (p *CityPole) NeighborhoodCommand() UpdatePoleCommand {
+	command := UpdatePoleCommand{MissingSensors: p.MissingSensors}
+	if !p.isMissing(SENSOR_AIR_QUALITY) {
+		command.AirQuality = p.AirQuality
+	}
+	if !p.isMissing(SENSOR_NOISE_LEVEL) {
+		command.NoiseLevel = p.NoiseLevel
+	}
+	if !p.isMissing(SENSOR_WEATHER) {
+		command.Weather = p.Weather
+	}
+	if !p.isMissing(SENSOR_CROWD_FLOW) {
+		command.CrowdFlow = p.CrowdFlow
+	}
+	if !p.isMissing(SENSOR_TRAFFIC_FLOW) {
+		command.TrafficFlow = p.TrafficFlow
+	}
+	return command
+}
+
+func (p *CityPole) isMissing(sensor string) bool {
+	for _, missing := range p.MissingSensors {
+		if missing == sensor {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestCityPoleSuite(t *testing.T) {
+	suite.Run(t, new(CityPoleSuite))
+}
+
+type CityPoleSuite struct {
+	suite.Suite
+}
+
+func (s *CityPoleSuite) Test_ProtobufRoundTrip() {
+	codec, err := ktwin.GetCodec(ktwin.ApplicationProtobuf)
+	s.Require().NoError(err)
+
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	twoHoursAgo := now.Add(-2 * time.Hour)
+	expected := CityPole{
+		AirQuality: &UpdateAirQualityIndexCommand{AqiLevel: "GOOD"},
+		Weather:    &UpdateWeatherCommand{Temperature: 21},
+		Sensors: map[string]SensorReport{
+			SENSOR_AIR_QUALITY: {Instance: "ngsi-ld-city-airqualityobserved-nb001-p00007", DateObserved: &now},
+			SENSOR_WEATHER:     {Instance: "ngsi-ld-city-weatherobserved-nb001-p00007", DateObserved: &twoHoursAgo},
+		},
+		MissingSensors: []string{SENSOR_WEATHER},
+		DateModified:   &now,
+	}
+
+	data, err := codec.Marshal(expected)
+	s.Require().NoError(err)
+	var actual CityPole
+	s.Require().NoError(codec.Unmarshal(data, &actual))
+	s.Assert().Equal(expected, actual)
+}
@@ -0,0 +1,32 @@
+package model
+
+type UpdateNoiseLevelCommand struct {
+	// Equivalent continuous sound level in dB(A)
+	LAeq float64 `json:"LAeq,omitempty" validate:"min=0" protobuf:"1"`
+}
+
+type UpdateWeatherCommand struct {
+	Temperature      float64 `json:"temperature,omitempty" protobuf:"1"`
+	RelativeHumidity float64 `json:"relativeHumidity,omitempty" validate:"min=0,max=100" protobuf:"2"`
+}
+
+type UpdateCrowdFlowCommand struct {
+	Congested   bool `json:"congested,omitempty" protobuf:"1"`
+	PeopleCount int  `json:"peopleCount,omitempty" validate:"min=0" protobuf:"2"`
+}
+
+type UpdateTrafficFlowCommand struct {
+	Congested bool    `json:"congested,omitempty" protobuf:"1"`
+	Occupancy float64 `json:"occupancy,omitempty" validate:"min=0,max=1" protobuf:"2"`
+}
+
+type UpdateStreetlightCommand struct {
+	PowerState string `json:"powerState,omitempty" validate:"oneof=on off low bootingUp" protobuf:"1"`
+	Status     string `json:"status,omitempty" validate:"oneof=ok oik defectiveLamp columnIssue brokenLantern" protobuf:"2"`
+}
+
+type UpdateEVChargingStationCommand struct {
+	Status            string `json:"status,omitempty" protobuf:"1"`
+	AvailableCapacity int    `json:"availableCapacity,omitempty" validate:"min=0" protobuf:"2"`
+	Capacity          int    `json:"capacity,omitempty" validate:"min=0" protobuf:"3"`
+}
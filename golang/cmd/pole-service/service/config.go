@@ -0,0 +1,34 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/pole-service/model"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kpolicy"
+)
+
+// Config of the pole service, the services.pole section of the config
+type Config struct {
+	// Time after which an attached sensor that has not reported is missing
+	SensorExpiry time.Duration `yaml:"sensorExpiry" env:"KTWIN_POLE_SENSOR_EXPIRY"`
+}
+
+var serviceConfig = Config{
+	SensorExpiry: 60 * time.Minute,
+}
+
+func (c *Config) Validate() error {
+	if c.SensorExpiry <= 0 {
+		return errors.New("sensorExpiry must be greater than 0")
+	}
+	return nil
+}
+
+func LoadConfig() error {
+	if err := config.LoadService("pole", &serviceConfig); err != nil {
+		return err
+	}
+	return kpolicy.Register(model.TWIN_INTERFACE_CITY_POLE, serviceConfig)
+}
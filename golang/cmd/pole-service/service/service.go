@@ -4,28 +4,22 @@ import (
 	"fmt"
 
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/pole-service/model"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/clock"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kcommand"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/keventstore"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kpolicy"
 	ktwingraph "github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/ktwingraph"
-
 	log "github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
 )
 
-var (
-	TWIN_INTERFACE_CITY_POLE = "city-pole"
-
-	// City Pole Update Air Quality Index Command
-	TWIN_COMMAND_CITY_POLE_NEIGHBORHOOD_UPDATE_AIR_QUALITY_INDEX = "updateAirQualityIndex"
-	TWIN_COMMAND_CITY_POLE_NEIGHBORHOOD_RELATIONSHIP_NAME        = "refNeighborhood"
-)
-
 var logger = log.NewLogger()
 var twinGraph *ktwin.TwinGraph
 
 func loadTwinGraph() error {
 	if twinGraph == nil {
 		var err error
-		graph, err := ktwingraph.LoadTwinGraphByInterfaces([]string{TWIN_INTERFACE_CITY_POLE})
+		graph, err := ktwingraph.LoadTwinGraphByInterfaces([]string{model.TWIN_INTERFACE_CITY_POLE})
 		if err != nil {
 			logger.Error("Error loading twin graph", err)
 			return err
@@ -35,34 +29,122 @@ func loadTwinGraph() error {
 	return nil
 }
 
+// Handlers of the commands of the attached sensors, each one sets the summary of its sensor
+var commandHandlers = map[string]func(*ktwin.TwinEvent) error{
+	model.TWIN_COMMAND_UPDATE_AIR_QUALITY_INDEX: func(command *ktwin.TwinEvent) error {
+		var summary model.UpdateAirQualityIndexCommand
+		return updatePole(command, model.SENSOR_AIR_QUALITY, &summary, func(pole *model.CityPole) { pole.AirQuality = &summary })
+	},
+	model.TWIN_COMMAND_UPDATE_NOISE_LEVEL: func(command *ktwin.TwinEvent) error {
+		var summary model.UpdateNoiseLevelCommand
+		return updatePole(command, model.SENSOR_NOISE_LEVEL, &summary, func(pole *model.CityPole) { pole.NoiseLevel = &summary })
+	},
+	model.TWIN_COMMAND_UPDATE_WEATHER: func(command *ktwin.TwinEvent) error {
+		var summary model.UpdateWeatherCommand
+		return updatePole(command, model.SENSOR_WEATHER, &summary, func(pole *model.CityPole) { pole.Weather = &summary })
+	},
+	model.TWIN_COMMAND_UPDATE_CROWD_FLOW: func(command *ktwin.TwinEvent) error {
+		var summary model.UpdateCrowdFlowCommand
+		return updatePole(command, model.SENSOR_CROWD_FLOW, &summary, func(pole *model.CityPole) { pole.CrowdFlow = &summary })
+	},
+	model.TWIN_COMMAND_UPDATE_TRAFFIC_FLOW: func(command *ktwin.TwinEvent) error {
+		var summary model.UpdateTrafficFlowCommand
+		return updatePole(command, model.SENSOR_TRAFFIC_FLOW, &summary, func(pole *model.CityPole) { pole.TrafficFlow = &summary })
+	},
+	model.TWIN_COMMAND_UPDATE_STREETLIGHT: func(command *ktwin.TwinEvent) error {
+		var summary model.UpdateStreetlightCommand
+		return updatePole(command, model.SENSOR_STREETLIGHT, &summary, func(pole *model.CityPole) { pole.Streetlight = &summary })
+	},
+	model.TWIN_COMMAND_UPDATE_EV_CHARGING_STATION: func(command *ktwin.TwinEvent) error {
+		var summary model.UpdateEVChargingStationCommand
+		return updatePole(command, model.SENSOR_EV_CHARGING_STATION, &summary, func(pole *model.CityPole) { pole.EVChargingStation = &summary })
+	},
+}
+
 func HandleEvent(event *ktwin.TwinEvent) error {
 	err := loadTwinGraph()
 	if err != nil {
 		return err
 	}
 
-	return kcommand.HandleCommand(event, TWIN_INTERFACE_CITY_POLE, TWIN_COMMAND_CITY_POLE_NEIGHBORHOOD_UPDATE_AIR_QUALITY_INDEX, *twinGraph, handleCityPoleCommand)
+	for command, handler := range commandHandlers {
+		if err := kcommand.HandleCommand(event, model.TWIN_INTERFACE_CITY_POLE, command, *twinGraph, handler); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func handleCityPoleCommand(event *ktwin.TwinEvent) error {
-	var updateAirQualityIndexCommand model.UpdateAirQualityIndexCommand
-	err := event.ToModel(&updateAirQualityIndexCommand)
+// Decodes the summary of the sensor of the relationship in the command and sets it in the latest state
+// of the pole, then stores the pole and sends its consolidated update to the neighborhood
+func updatePole(command *ktwin.TwinEvent, sensor string, summary interface{}, update func(pole *model.CityPole)) error {
+	err := command.ToModel(summary)
+	if err != nil {
+		return err
+	}
 
+	latestEvent, err := keventstore.GetLatestTwinEvent(command.TwinInterface, command.TwinInstance)
 	if err != nil {
 		return err
 	}
 
-	if twinGraph == nil {
-		logger.Error("Twin Graph not loaded", nil)
-		return nil
+	var pole model.CityPole
+	if latestEvent == nil {
+		latestEvent = ktwin.NewTwinEvent()
+		err = latestEvent.SetEvent(command.TwinInterface, command.TwinInstance, ktwin.RealEvent, pole)
+		if err != nil {
+			return err
+		}
+	} else {
+		err = latestEvent.ToStoredModel(&pole)
+		if err != nil {
+			return err
+		}
 	}
 
-	err = kcommand.PublishCommand(TWIN_COMMAND_CITY_POLE_NEIGHBORHOOD_UPDATE_AIR_QUALITY_INDEX, updateAirQualityIndexCommand, TWIN_COMMAND_CITY_POLE_NEIGHBORHOOD_RELATIONSHIP_NAME, event.TwinInstance, *twinGraph)
+	now := clock.Now()
+	instance := command.Causation()
+	if instance == "" {
+		if relationship := ktwingraph.GetRelationshipFromGraph(command.TwinInstance, sensor, *twinGraph); relationship != nil {
+			instance = relationship.Instance
+		}
+	}
 
+	update(&pole)
+	pole.Report(sensor, instance, now)
+	policy := kpolicy.ResolveOrDefault(model.TWIN_INTERFACE_CITY_POLE, command.TwinInstance, serviceConfig)
+	pole.SetMissingSensors(attachedSensors(command.TwinInstance), now.Add(-policy.SensorExpiry))
+	pole.DateModified = now
+
+	err = latestEvent.SetData(pole)
+	if err != nil {
+		return err
+	}
+	err = keventstore.UpdateTwinEvent(latestEvent)
 	if err != nil {
-		logger.Error(fmt.Sprintf("Error executing command %s in relation %s in TwinInstance %s\n", TWIN_COMMAND_CITY_POLE_NEIGHBORHOOD_UPDATE_AIR_QUALITY_INDEX, TWIN_COMMAND_CITY_POLE_NEIGHBORHOOD_RELATIONSHIP_NAME, event.TwinInstance), err)
 		return err
 	}
 
+	err = kcommand.PublishCommand(model.TWIN_COMMAND_NEIGHBORHOOD_UPDATE_POLE, pole.NeighborhoodCommand(), model.TWIN_COMMAND_NEIGHBORHOOD_RELATIONSHIP, command.TwinInstance, *twinGraph)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error executing command %s in relation %s in TwinInstance %s\n", model.TWIN_COMMAND_NEIGHBORHOOD_UPDATE_POLE, model.TWIN_COMMAND_NEIGHBORHOOD_RELATIONSHIP, command.TwinInstance), err)
+		return err
+	}
 	return nil
 }
+
+// Gets the relationships of the sensors attached to the pole in the graph
+func attachedSensors(twinInstance string) []string {
+	var sensors []string
+	for _, instance := range twinGraph.TwinInstancesGraph {
+		if instance.Name != twinInstance {
+			continue
+		}
+		for _, relationship := range instance.Relationships {
+			if relationship.Name != model.TWIN_COMMAND_NEIGHBORHOOD_RELATIONSHIP {
+				sensors = append(sensors, relationship.Name)
+			}
+		}
+	}
+	return sensors
+}
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/pole-service/model"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/clock"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
@@ -20,7 +21,6 @@ const (
 )
 
 func TestPoleServiceSuite(t *testing.T) {
-
 	suite.Run(t, new(PoleServiceSuite))
 }
 
@@ -39,7 +39,69 @@ func (s *PoleServiceSuite) SetupSuite() {
 	s.eventStoreUrl = os.Getenv("KTWIN_EVENT_STORE")
 }
 
-func (s *PoleServiceSuite) Test_PoleUpdateAirQualityCommandEvent() {
+// Builds the command of the pole, caused by the sensor instance when it is set
+func (s *PoleServiceSuite) newCommand(commandName, data, sensor string) *ktwin.TwinEvent {
+	twinEvent := ktwin.NewTwinEvent()
+	twinEvent.EventType = ktwin.CommandEvent
+	twinEvent.TwinInstance = "city-pole-nb001-p00007"
+	twinEvent.TwinInterface = "city-pole"
+	twinEvent.CommandName = commandName
+
+	cloudEvent := cloudevents.NewEvent()
+	cloudEvent.SetData("application/json", []byte(data))
+	cloudEvent.SetID("")
+	cloudEvent.SetSource("city-pole-nb001-p00007")
+	cloudEvent.SetType("ktwin.command.city-pole." + commandName)
+	cloudEvent.SetTime(*clock.Now())
+	if sensor != "" {
+		cloudEvent.SetExtension(ktwin.CausationExtension, sensor)
+	}
+
+	twinEvent.CloudEvent = &cloudEvent
+	return twinEvent
+}
+
+func (s *PoleServiceSuite) mockLatestEvent(pole model.CityPole) {
+	gock.New(s.eventStoreUrl).
+		Get("/api/v1/twin-events/city-pole/city-pole-nb001-p00007/latest").
+		Reply(http.StatusOK).
+		SetHeader("Content-Type", "application/json").
+		SetHeader("ce-specversion", "1.0").
+		SetHeader("ce-time", clock.Now().Format(time.RFC3339)).
+		SetHeader("ce-source", "city-pole-nb001-p00007").
+		SetHeader("ce-type", "ktwin.real.city-pole").
+		SetHeader("ce-subject", "").
+		JSON(pole)
+}
+
+func (s *PoleServiceSuite) mockStoredEvent(body string) {
+	gock.New(s.brokerUrl).
+		Post("/").
+		MatchHeader("Content-Type", "application/json").
+		MatchHeader("ce-specversion", "1.0").
+		MatchHeader("ce-time", clock.Now().Format(time.RFC3339)).
+		MatchHeader("ce-source", "city-pole-nb001-p00007").
+		MatchHeader("ce-type", "ktwin.store.city-pole").
+		BodyString(body).
+		Reply(http.StatusAccepted)
+}
+
+func (s *PoleServiceSuite) mockNeighborhoodCommand(body string) {
+	gock.New(s.brokerUrl).
+		Post("/").
+		MatchHeader("Content-Type", "application/json").
+		MatchHeader("ce-id", DEFAULT_UUID).
+		MatchHeader("ce-specversion", "1.0").
+		MatchHeader("ce-time", clock.Now().Format(time.RFC3339)).
+		MatchHeader("ce-source", "s4city-city-neighborhood-nb001").
+		MatchHeader("ce-ktwincausation", "city-pole-nb001-p00007").
+		MatchHeader("ce-type", "ktwin.command.s4city-city-neighborhood.updatepole").
+		MatchHeader("ce-subject", "").
+		BodyString(body).
+		Reply(http.StatusAccepted)
+}
+
+func (s *PoleServiceSuite) Test_PoleEvent() {
 	defer clock.ResetClockImplementation()
 	defer uuid.ResetUuidImplementation()
 
@@ -52,7 +114,8 @@ func (s *PoleServiceSuite) Test_PoleUpdateAirQualityCommandEvent() {
 		return &now
 	}
 	dateTime := clock.NowFunc()
-	dateTimeFormatted := dateTime.Format(time.RFC3339)
+	tenMinutesAgo := dateTime.Add(-10 * time.Minute)
+	twoHoursAgo := dateTime.Add(-2 * time.Hour)
 
 	tests := []struct {
 		name                string
@@ -70,39 +133,69 @@ func (s *PoleServiceSuite) Test_PoleUpdateAirQualityCommandEvent() {
 		},
 		{
 			name: `
-				Given update air quality command event is received
-				When value contains AQI levels
-				Should propagate AQI levels to the neighborhood
+				Given update air quality command event is received and there is no previous event
+				When value contains AQI levels and no causation
+				Should record the air quality sensor of the pole in the graph
+				AND send the AQI levels to the neighborhood, the sensors that never reported are not missing
 			`,
 			twinEvent: func() *ktwin.TwinEvent {
-				twinEvent := ktwin.NewTwinEvent()
-				twinEvent.EventType = ktwin.CommandEvent
-				twinEvent.TwinInstance = "city-pole-nb001-p00007"
-				twinEvent.TwinInterface = "city-pole"
-				twinEvent.CommandName = "updateAirQualityIndex"
-
-				cloudEvent := cloudevents.NewEvent()
-				cloudEvent.SetData("application/json", []byte(`{"aqiLevel":"MODERATE"}`))
-				cloudEvent.SetID("")
-				cloudEvent.SetSource("city-pole-nb001-p00007")
-				cloudEvent.SetType("ktwin.real.city-pole")
-				cloudEvent.SetTime(*dateTime)
-
-				twinEvent.CloudEvent = &cloudEvent
-				return twinEvent
+				return s.newCommand("updateAirQualityIndex", `{"aqiLevel":"MODERATE"}`, "")
 			},
 			mockExternalService: func() {
-				gock.New(s.brokerUrl).
-					Post("/").
-					MatchHeader("Content-Type", "application/json").
-					MatchHeader("ce-id", DEFAULT_UUID).
-					MatchHeader("ce-specversion", "1.0").
-					MatchHeader("ce-time", dateTimeFormatted).
-					MatchHeader("ce-source", "s4city-city-neighborhood-nb001").
-					MatchHeader("ce-type", "ktwin.command.s4city-city-neighborhood.updateairqualityindex").
-					MatchHeader("ce-subject", "").
-					BodyString(`{"aqiLevel":"MODERATE"}`).
-					Reply(http.StatusAccepted)
+				gock.New(s.eventStoreUrl).
+					Get("/api/v1/twin-events/city-pole/city-pole-nb001-p00007/latest").
+					Reply(http.StatusNotFound)
+
+				s.mockStoredEvent(`{"airQuality":{"aqiLevel":"MODERATE"},"sensors":{"refAirQualityObserved":{"instance":"ngsi-ld-city-airqualityobserved-nb001-p00007","dateObserved":"2024-01-01T00:00:00Z"}},"dateModified":"2024-01-01T00:00:00Z"}`)
+				s.mockNeighborhoodCommand(`{"airQuality":{"aqiLevel":"MODERATE"}}`)
+			},
+			expectedError: nil,
+		},
+		{
+			name: `
+				Given update weather command event is received and there a previous event
+				When the air quality sensor reported less than 60 minutes ago
+				AND the traffic flow sensor reported more than 60 minutes ago
+				Should send the weather and the air quality to the neighborhood, with the traffic flow sensor missing
+			`,
+			twinEvent: func() *ktwin.TwinEvent {
+				return s.newCommand("updateWeather", `{"temperature": 21.5, "relativeHumidity": 60}`, "ngsi-ld-city-weatherobserved-nb001-p00007")
+			},
+			mockExternalService: func() {
+				s.mockLatestEvent(model.CityPole{
+					AirQuality:  &model.UpdateAirQualityIndexCommand{AqiLevel: "GOOD", AirQualityIndex: 20},
+					TrafficFlow: &model.UpdateTrafficFlowCommand{Congested: true, Occupancy: 0.9},
+					Sensors: map[string]model.SensorReport{
+						model.SENSOR_AIR_QUALITY:         {Instance: "ngsi-ld-city-airqualityobserved-nb001-p00007", DateObserved: &tenMinutesAgo},
+						model.SENSOR_TRAFFIC_FLOW:        {Instance: "ngsi-ld-city-trafficflowobserved-nb001-p00007", DateObserved: &twoHoursAgo},
+						model.SENSOR_CROWD_FLOW:          {Instance: "ngsi-ld-city-crowdflowobserved-nb001-p00007", DateObserved: &tenMinutesAgo},
+						model.SENSOR_NOISE_LEVEL:         {Instance: "ngsi-ld-city-noiselevelobserved-nb001-p00007", DateObserved: &tenMinutesAgo},
+						model.SENSOR_STREETLIGHT:         {Instance: "ngsi-ld-city-streetlight-nb001-p00007", DateObserved: &tenMinutesAgo},
+						model.SENSOR_EV_CHARGING_STATION: {Instance: "ngsi-ld-city-evchargingstation-nb001-p00007", DateObserved: &tenMinutesAgo},
+					},
+				})
+
+				s.mockStoredEvent(`{"airQuality":{"aqiLevel":"GOOD","airQualityIndex":20},"weather":{"temperature":21.5,"relativeHumidity":60},"trafficFlow":{"congested":true,"occupancy":0.9},"sensors":{"refAirQualityObserved":{"instance":"ngsi-ld-city-airqualityobserved-nb001-p00007","dateObserved":"2023-12-31T23:50:00Z"},"refCrowdFlow":{"instance":"ngsi-ld-city-crowdflowobserved-nb001-p00007","dateObserved":"2023-12-31T23:50:00Z"},"refEVChargingStation":{"instance":"ngsi-ld-city-evchargingstation-nb001-p00007","dateObserved":"2023-12-31T23:50:00Z"},"refNoiseLevel":{"instance":"ngsi-ld-city-noiselevelobserved-nb001-p00007","dateObserved":"2023-12-31T23:50:00Z"},"refStreetlight":{"instance":"ngsi-ld-city-streetlight-nb001-p00007","dateObserved":"2023-12-31T23:50:00Z"},"refTrafficFlow":{"instance":"ngsi-ld-city-trafficflowobserved-nb001-p00007","dateObserved":"2023-12-31T22:00:00Z"},"refWeather":{"instance":"ngsi-ld-city-weatherobserved-nb001-p00007","dateObserved":"2024-01-01T00:00:00Z"}},"missingSensors":["refTrafficFlow"],"dateModified":"2024-01-01T00:00:00Z"}`)
+				s.mockNeighborhoodCommand(`{"airQuality":{"aqiLevel":"GOOD","airQualityIndex":20},"weather":{"temperature":21.5,"relativeHumidity":60},"missingSensors":["refTrafficFlow"]}`)
+			},
+			expectedError: nil,
+		},
+		{
+			name: `
+				Given update streetlight command event is received and there is no previous event
+				When the streetlight status is the FIWARE "ok"
+				Should accept the command and record the streetlight
+			`,
+			twinEvent: func() *ktwin.TwinEvent {
+				return s.newCommand("updateStreetlight", `{"powerState":"on","status":"ok"}`, "ngsi-ld-city-streetlight-nb001-p00007")
+			},
+			mockExternalService: func() {
+				gock.New(s.eventStoreUrl).
+					Get("/api/v1/twin-events/city-pole/city-pole-nb001-p00007/latest").
+					Reply(http.StatusNotFound)
+
+				s.mockStoredEvent(`{"streetlight":{"powerState":"on","status":"ok"},"sensors":{"refStreetlight":{"instance":"ngsi-ld-city-streetlight-nb001-p00007","dateObserved":"2024-01-01T00:00:00Z"}},"dateModified":"2024-01-01T00:00:00Z"}`)
+				s.mockNeighborhoodCommand(`{}`)
 			},
 			expectedError: nil,
 		},
@@ -115,6 +208,7 @@ func (s *PoleServiceSuite) Test_PoleUpdateAirQualityCommandEvent() {
 			actualError := HandleEvent(tt.twinEvent())
 
 			s.Assert().Equal(tt.expectedError, actualError)
+			s.Assert().True(gock.IsDone())
 		})
 	}
 }
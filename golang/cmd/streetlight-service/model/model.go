@@ -17,24 +17,32 @@ const (
 )
 
 const (
-	LampStatusOk            LampStatus = "oik"
+	LampStatusOk            LampStatus = "ok"
 	LampStatusDefective     LampStatus = "defectiveLamp"
 	LampStatusColumnIssue   LampStatus = "columnIssue"
 	LampStatusBrokenLantern LampStatus = "brokenLantern"
+	// Misspelled ok stored by the previous versions, accepted until the stored streetlights report again
+	LampStatusOkLegacy LampStatus = "oik"
 )
 
 type Streetlight struct {
-	Circuit              string     `json:"circuit,omitempty"`
-	Status               LampStatus `json:"status,omitempty"`
-	PowerState           PowerState `json:"powerState,omitempty"`
-	DateLastLampChange   *time.Time `json:"dateLastLampChange,omitempty"`
-	DateLastSwitchingOn  *time.Time `json:"dateLastSwitchingOn,omitempty"`
-	DateLastSwitchingOff *time.Time `json:"dateLastSwitchingOff,omitempty"`
-	ControllingMethod    string     `json:"controllingMethod,omitempty"`
-	DateServiceStarted   *time.Time `json:"dateServiceStarted,omitempty"`
-	Image                string     `json:"image,omitempty"`
-	Annotations          string     `json:"annotations,omitempty"`
-	LanternHeight        float64    `json:"lanternHeight,omitempty"`
-	IlluminanceLevel     float64    `json:"illuminanceLevel,omitempty"`
-	LocationCategory     string     `json:"locationCategory,omitempty"`
+	Circuit              string     `json:"circuit,omitempty" protobuf:"1"`
+	Status               LampStatus `json:"status,omitempty" validate:"oneof=ok oik defectiveLamp columnIssue brokenLantern" protobuf:"2"`
+	PowerState           PowerState `json:"powerState,omitempty" validate:"oneof=on off low bootingUp" protobuf:"3"`
+	DateLastLampChange   *time.Time `json:"dateLastLampChange,omitempty" protobuf:"4"`
+	DateLastSwitchingOn  *time.Time `json:"dateLastSwitchingOn,omitempty" protobuf:"5"`
+	DateLastSwitchingOff *time.Time `json:"dateLastSwitchingOff,omitempty" protobuf:"6"`
+	ControllingMethod    string     `json:"controllingMethod,omitempty" protobuf:"7"`
+	DateServiceStarted   *time.Time `json:"dateServiceStarted,omitempty" protobuf:"8"`
+	Image                string     `json:"image,omitempty" protobuf:"9"`
+	Annotations          string     `json:"annotations,omitempty" protobuf:"10"`
+	LanternHeight        float64    `json:"lanternHeight,omitempty" validate:"min=0" protobuf:"11"`
+	IlluminanceLevel     float64    `json:"illuminanceLevel,omitempty" validate:"min=0" protobuf:"12"`
+	LocationCategory     string     `json:"locationCategory,omitempty" protobuf:"13"`
+}
+
+// UpdateStreetlightCommand is the state of the streetlight sent to the pole it is attached to
+type UpdateStreetlightCommand struct {
+	PowerState PowerState `json:"powerState,omitempty" protobuf:"1"`
+	Status     LampStatus `json:"status,omitempty" protobuf:"2"`
 }
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+)
+
+// Config of the streetlight service, the services.streetlight section of the config
+type Config struct {
+	// Time without a change of the power state after which the lamp is considered defective
+	DefectWindow time.Duration `yaml:"defectWindow" env:"KTWIN_STREETLIGHT_DEFECT_WINDOW"`
+}
+
+var serviceConfig = Config{
+	DefectWindow: 48 * time.Hour,
+}
+
+func (c *Config) Validate() error {
+	if c.DefectWindow <= 0 {
+		return errors.New("defectWindow must be greater than 0")
+	}
+	return nil
+}
+
+func LoadConfig() error {
+	return config.LoadService("streetlight", &serviceConfig)
+}
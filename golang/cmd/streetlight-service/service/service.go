@@ -1,19 +1,57 @@
 package service
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/streetlight-service/model"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/clock"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kcommand"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kevent"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/keventstore"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kscheduler"
+	ktwingraph "github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/ktwingraph"
 	log "github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
 )
 
+var (
+	TWIN_INTERFACE_CITY_POLE = "city-pole"
+
+	// City Pole Update Streetlight Command, the pole references its streetlight
+	TWIN_COMMAND_CITY_POLE_UPDATE_STREETLIGHT = "updateStreetlight"
+	TWIN_COMMAND_CITY_POLE_RELATIONSHIP_NAME  = "refStreetlight"
+
+	// Timer checking the lamp once the defect window elapses without another event
+	TWIN_TIMER_CHECK_DEFECT = "checkDefect"
+)
+
 var logger = log.NewLogger()
+var twinGraph *ktwin.TwinGraph
+
+func loadTwinGraph() error {
+	if twinGraph == nil {
+		var err error
+		graph, err := ktwingraph.LoadTwinGraphByInterfaces([]string{TWIN_INTERFACE_CITY_POLE})
+		if err != nil {
+			logger.Error("Error loading twin graph", err)
+			return err
+		}
+		twinGraph = &graph
+	}
+	return nil
+}
 
 func HandleEvent(event *ktwin.TwinEvent) error {
+	err := loadTwinGraph()
+	if err != nil {
+		return err
+	}
+
+	if event.EventType == ktwin.TimerEvent {
+		return kscheduler.HandleTimer(event, model.STREETLIGHT_INTERFACE_ID, TWIN_TIMER_CHECK_DEFECT, handleCheckDefect)
+	}
+
 	return kevent.HandleEvent(event, model.STREETLIGHT_INTERFACE_ID, handleStreetLightEvent)
 }
 
@@ -25,7 +63,7 @@ func handleStreetLightEvent(event *ktwin.TwinEvent) error {
 
 	if err != nil {
 		logger.Error("Error parsing event data", err)
-		return nil
+		return err
 	}
 
 	if currentStreetlight.PowerState == "" {
@@ -39,6 +77,11 @@ func handleStreetLightEvent(event *ktwin.TwinEvent) error {
 		return err
 	}
 
+	err = kscheduler.Get().Schedule(model.STREETLIGHT_INTERFACE_ID, event.TwinInstance, TWIN_TIMER_CHECK_DEFECT, timeNow.Add(serviceConfig.DefectWindow), nil)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error scheduling the defect check of TwinInstance %s", event.TwinInstance), err)
+	}
+
 	if latestEvent == nil {
 		if currentStreetlight.PowerState == model.PowerOn {
 			currentStreetlight.DateLastSwitchingOn = timeNow
@@ -46,12 +89,11 @@ func handleStreetLightEvent(event *ktwin.TwinEvent) error {
 		if currentStreetlight.PowerState == model.PowerOff {
 			currentStreetlight.DateLastSwitchingOff = timeNow
 		}
-		event.SetData(currentStreetlight)
-		return keventstore.UpdateTwinEvent(event)
+		return updateStreetlight(event, currentStreetlight)
 	}
 
 	var latestStreetlight model.Streetlight
-	err = latestEvent.ToModel(&latestStreetlight)
+	err = latestEvent.ToStoredModel(&latestStreetlight)
 
 	if err != nil {
 		return err
@@ -72,15 +114,63 @@ func handleStreetLightEvent(event *ktwin.TwinEvent) error {
 		}
 	}
 
-	event.SetData(currentStreetlight)
-	return keventstore.UpdateTwinEvent(event)
+	return updateStreetlight(event, currentStreetlight)
+}
+
+// Marks the lamp defective when its power state did not change during the defect window
+func handleCheckDefect(event *ktwin.TwinEvent) error {
+	latestEvent, err := keventstore.GetLatestTwinEvent(event.TwinInterface, event.TwinInstance)
+	if err != nil || latestEvent == nil {
+		return err
+	}
+
+	var streetlight model.Streetlight
+	err = latestEvent.ToStoredModel(&streetlight)
+	if err != nil {
+		return err
+	}
+
+	if streetlight.Status == model.LampStatusDefective {
+		return nil
+	}
+
+	var dateLastSwitching *time.Time
+	switch streetlight.PowerState {
+	case model.PowerOn:
+		dateLastSwitching = streetlight.DateLastSwitchingOn
+	case model.PowerOff:
+		dateLastSwitching = streetlight.DateLastSwitchingOff
+	}
+
+	if !isWithDefect(clock.Now(), dateLastSwitching) {
+		return nil
+	}
+
+	logger.Info(fmt.Sprintf("TwinInstance %s has not switched since %s, marking its lamp defective", event.TwinInstance, dateLastSwitching.Format(time.RFC3339)))
+	streetlight.Status = model.LampStatusDefective
+	return updateStreetlight(event, streetlight)
+}
+
+// Stores the streetlight and sends its state to the pole it is attached to
+func updateStreetlight(event *ktwin.TwinEvent, streetlight model.Streetlight) error {
+	err := event.SetData(streetlight)
+	if err != nil {
+		return err
+	}
+	err = keventstore.UpdateTwinEvent(event)
+	if err != nil {
+		return err
+	}
+
+	command := model.UpdateStreetlightCommand{PowerState: streetlight.PowerState, Status: streetlight.Status}
+	return kcommand.PublishCommandToReferrer(TWIN_COMMAND_CITY_POLE_UPDATE_STREETLIGHT, command, TWIN_COMMAND_CITY_POLE_RELATIONSHIP_NAME, event.TwinInstance, *twinGraph)
 }
 
-// In case of 48h of no change in the state, we consider that lamp with a defect
+// In case of no change in the state during the defect window (48h by default), we consider that lamp with a defect
 func isWithDefect(datetimeNow *time.Time, dateLastSwitching *time.Time) bool {
 	if dateLastSwitching == nil {
 		return false
 	}
 	timeDifference := datetimeNow.Sub(*dateLastSwitching)
-	return timeDifference > time.Hour*48
+	return timeDifference > serviceConfig.DefectWindow
 }
@@ -7,9 +7,11 @@ import (
 	"time"
 
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/streetlight-service/model"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/internal/kcommandtest"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/clock"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kscheduler"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/uuid"
 	cloudevents "github.com/cloudevents/sdk-go/v2"
 	"github.com/h2non/gock"
@@ -40,6 +42,15 @@ func (s *StreetlightServiceSuite) SetupSuite() {
 	s.eventStoreUrl = os.Getenv("KTWIN_EVENT_STORE")
 }
 
+func (s *StreetlightServiceSuite) SetupTest() {
+	scheduler, _ := kscheduler.NewScheduler(kscheduler.NewMemoryStore())
+	kscheduler.Set(scheduler)
+}
+
+func (s *StreetlightServiceSuite) TearDownTest() {
+	kscheduler.Reset()
+}
+
 func (s *StreetlightServiceSuite) Test_StreetlightEvent() {
 	defer clock.ResetClockImplementation()
 	defer uuid.ResetUuidImplementation()
@@ -107,6 +118,46 @@ func (s *StreetlightServiceSuite) Test_StreetlightEvent() {
 					MatchHeader("ce-subject", "").
 					BodyString(`{"powerState":"on","dateLastSwitchingOn":"2024-01-01T00:00:00Z"}`).
 					Reply(http.StatusAccepted)
+
+				kcommandtest.MockCommand(s.brokerUrl, "city-pole", "city-pole-nb001-p00007", "updateStreetlight", "ngsi-ld-city-streetlight-nb001-p00007", `{"powerState":"on"}`)
+			},
+			expectedError: nil,
+		},
+		{
+			name: `
+				Given event is published and no previous event was published
+				When event has the FIWARE status "ok"
+				Should accept the status and send it to the pole
+			`,
+			twinEvent: func() *ktwin.TwinEvent {
+				twinEvent := ktwin.NewTwinEvent()
+				twinEvent.EventType = ktwin.RealEvent
+				twinEvent.TwinInstance = "ngsi-ld-city-streetlight-nb001-p00007"
+				twinEvent.TwinInterface = "ngsi-ld-city-streetlight"
+
+				cloudEvent := cloudevents.NewEvent()
+				cloudEvent.SetData("application/json", []byte(`{"powerState": "on", "status": "ok"}`))
+				cloudEvent.SetID("")
+				cloudEvent.SetSource("ngsi-ld-city-streetlight-nb001-p00007")
+				cloudEvent.SetType("ktwin.real.ngsi-ld-city-streetlight")
+				cloudEvent.SetTime(*dateTime)
+
+				twinEvent.CloudEvent = &cloudEvent
+				return twinEvent
+			},
+			mockExternalService: func() {
+				gock.New(s.eventStoreUrl).
+					Get("/api/v1/twin-events/ngsi-ld-city-streetlight/ngsi-ld-city-streetlight-nb001-p00007/latest").
+					Reply(http.StatusNotFound)
+
+				gock.New(s.brokerUrl).
+					Post("/").
+					MatchHeader("ce-source", "ngsi-ld-city-streetlight-nb001-p00007").
+					MatchHeader("ce-type", "ktwin.store.ngsi-ld-city-streetlight").
+					BodyString(`{"status":"ok","powerState":"on","dateLastSwitchingOn":"2024-01-01T00:00:00Z"}`).
+					Reply(http.StatusAccepted)
+
+				kcommandtest.MockCommand(s.brokerUrl, "city-pole", "city-pole-nb001-p00007", "updateStreetlight", "ngsi-ld-city-streetlight-nb001-p00007", `{"powerState":"on","status":"ok"}`)
 			},
 			expectedError: nil,
 		},
@@ -149,6 +200,8 @@ func (s *StreetlightServiceSuite) Test_StreetlightEvent() {
 					BodyString(`{"powerState":"off","dateLastSwitchingOff":"2024-01-01T00:00:00Z"}`).
 					Reply(http.StatusAccepted)
 
+				kcommandtest.MockCommand(s.brokerUrl, "city-pole", "city-pole-nb001-p00007", "updateStreetlight", "ngsi-ld-city-streetlight-nb001-p00007", `{"powerState":"off"}`)
+
 			},
 			expectedError: nil,
 		},
@@ -201,6 +254,8 @@ func (s *StreetlightServiceSuite) Test_StreetlightEvent() {
 					BodyString(`{"powerState":"off","dateLastSwitchingOff":"2024-01-01T00:00:00Z"}`).
 					Reply(http.StatusAccepted)
 
+				kcommandtest.MockCommand(s.brokerUrl, "city-pole", "city-pole-nb001-p00007", "updateStreetlight", "ngsi-ld-city-streetlight-nb001-p00007", `{"powerState":"off"}`)
+
 			},
 			expectedError: nil,
 		},
@@ -254,6 +309,8 @@ func (s *StreetlightServiceSuite) Test_StreetlightEvent() {
 					BodyString(`{"status":"defectiveLamp","powerState":"off","dateLastSwitchingOff":"2024-01-01T00:00:00Z"}`).
 					Reply(http.StatusAccepted)
 
+				kcommandtest.MockCommand(s.brokerUrl, "city-pole", "city-pole-nb001-p00007", "updateStreetlight", "ngsi-ld-city-streetlight-nb001-p00007", `{"powerState":"off","status":"defectiveLamp"}`)
+
 			},
 			expectedError: nil,
 		},
@@ -306,6 +363,8 @@ func (s *StreetlightServiceSuite) Test_StreetlightEvent() {
 					BodyString(`{"powerState":"on","dateLastSwitchingOn":"2024-01-01T00:00:00Z"}`).
 					Reply(http.StatusAccepted)
 
+				kcommandtest.MockCommand(s.brokerUrl, "city-pole", "city-pole-nb001-p00007", "updateStreetlight", "ngsi-ld-city-streetlight-nb001-p00007", `{"powerState":"on"}`)
+
 			},
 			expectedError: nil,
 		},
@@ -359,6 +418,8 @@ func (s *StreetlightServiceSuite) Test_StreetlightEvent() {
 					BodyString(`{"status":"defectiveLamp","powerState":"on","dateLastSwitchingOn":"2024-01-01T00:00:00Z"}`).
 					Reply(http.StatusAccepted)
 
+				kcommandtest.MockCommand(s.brokerUrl, "city-pole", "city-pole-nb001-p00007", "updateStreetlight", "ngsi-ld-city-streetlight-nb001-p00007", `{"powerState":"on","status":"defectiveLamp"}`)
+
 			},
 			expectedError: nil,
 		},
@@ -371,6 +432,65 @@ func (s *StreetlightServiceSuite) Test_StreetlightEvent() {
 			actualError := HandleEvent(tt.twinEvent())
 
 			s.Assert().Equal(tt.expectedError, actualError)
+			s.Assert().True(gock.IsDone())
 		})
 	}
 }
+
+func (s *StreetlightServiceSuite) Test_DefectCheckTimer() {
+	defer clock.ResetClockImplementation()
+	defer uuid.ResetUuidImplementation()
+	defer gock.Off()
+
+	uuid.NewUuid = func() string {
+		return DEFAULT_UUID
+	}
+
+	now, _ := time.Parse(time.RFC3339, "2024-01-01T00:00:00Z")
+	clock.NowFunc = func() *time.Time {
+		return &now
+	}
+
+	// The event schedules the defect check at the end of the defect window
+	gock.New(s.eventStoreUrl).
+		Get("/api/v1/twin-events/ngsi-ld-city-streetlight/ngsi-ld-city-streetlight-nb001-p00007/latest").
+		Reply(http.StatusNotFound)
+	gock.New(s.brokerUrl).
+		Post("/").
+		MatchHeader("ce-type", "ktwin.store.ngsi-ld-city-streetlight").
+		BodyString(`{"powerState":"on","dateLastSwitchingOn":"2024-01-01T00:00:00Z"}`).
+		Reply(http.StatusAccepted)
+	kcommandtest.MockCommand(s.brokerUrl, "city-pole", "city-pole-nb001-p00007", "updateStreetlight", "ngsi-ld-city-streetlight-nb001-p00007", `{"powerState":"on"}`)
+
+	twinEvent, err := ktwin.NewTwinEventFromCloudEvent(ktwin.BuildCloudEvent("ktwin.real.ngsi-ld-city-streetlight", "ngsi-ld-city-streetlight-nb001-p00007", model.Streetlight{PowerState: model.PowerOn}))
+	s.Require().NoError(err)
+	s.Require().NoError(HandleEvent(twinEvent))
+	s.Require().True(gock.IsDone())
+
+	timers := kscheduler.Get().Timers()
+	s.Require().Len(timers, 1)
+	s.Assert().Equal(now.Add(48*time.Hour), timers[0].Next)
+
+	// No event arrives during the window, the timer marks the lamp defective
+	now = now.Add(49 * time.Hour)
+	gock.New(s.eventStoreUrl).
+		Get("/api/v1/twin-events/ngsi-ld-city-streetlight/ngsi-ld-city-streetlight-nb001-p00007/latest").
+		Reply(http.StatusOK).
+		SetHeader("Content-Type", "application/json").
+		SetHeader("ce-specversion", "1.0").
+		SetHeader("ce-time", "2024-01-01T00:00:00Z").
+		SetHeader("ce-source", "ngsi-ld-city-streetlight-nb001-p00007").
+		SetHeader("ce-type", "ktwin.store.ngsi-ld-city-streetlight").
+		BodyString(`{"powerState":"on","dateLastSwitchingOn":"2024-01-01T00:00:00Z"}`)
+	gock.New(s.brokerUrl).
+		Post("/").
+		MatchHeader("ce-source", "ngsi-ld-city-streetlight-nb001-p00007").
+		MatchHeader("ce-type", "ktwin.store.ngsi-ld-city-streetlight").
+		BodyString(`{"status":"defectiveLamp","powerState":"on","dateLastSwitchingOn":"2024-01-01T00:00:00Z"}`).
+		Reply(http.StatusAccepted)
+	kcommandtest.MockCommand(s.brokerUrl, "city-pole", "city-pole-nb001-p00007", "updateStreetlight", "ngsi-ld-city-streetlight-nb001-p00007", `{"powerState":"on","status":"defectiveLamp"}`)
+
+	kscheduler.Get().Fire(now, HandleEvent)
+	s.Assert().True(gock.IsDone())
+	s.Assert().Empty(kscheduler.Get().Timers())
+}
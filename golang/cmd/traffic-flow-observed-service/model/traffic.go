@@ -3,18 +3,24 @@ package model
 import "time"
 
 type TrafficFlowObservedEvent struct {
-	AreaServed           string     `json:"areaServed,omitempty"`           // The geographic area where a service or offered item is provided
-	AverageGapDistance   float64    `json:"averageGapDistance,omitempty"`   // Average gap distance between consecutive vehicles
-	AverageHeadwayTime   float64    `json:"averageHeadwayTime,omitempty"`   // Average headway time (time elapsed between two consecutive vehicles)
-	AverageVehicleLength float64    `json:"averageVehicleLength,omitempty"` // Average length of the vehicles transiting during the observation period
-	AverageVehicleSpeed  float64    `json:"averageVehicleSpeed,omitempty"`  // Average speed of the vehicles transiting during the observation period
-	Congested            bool       `json:"congested"`                      // Flags whether there was a traffic congestion during the observation period in the referred lane
-	DateObservedFrom     *time.Time `json:"dateObservedFrom,omitempty"`     // Observation period start date and time
-	DateObservedTo       *time.Time `json:"dateObservedTo,omitempty"`       // Observation period end date and time
-	Intensity            int        `json:"intensity,omitempty"`            // Total number of vehicles detected during this observation period
-	LaneDirection        string     `json:"laneDirection,omitempty"`        // Usual direction of travel in the lane referred by this observation ("forward" or "backward")
-	Occupancy            float64    `json:"occupancy,omitempty"`            // Fraction of the observation time where a vehicle has been occupying the observed lane
-	ReversedLane         bool       `json:"reversedLane,omitempty"`         // Flags whether traffic in the lane was reversed during the observation period
-	VehicleSubType       string     `json:"vehicleSubType,omitempty"`       // Subtype of vehicleType, provides more specific information about the type of vehicle
-	VehicleType          string     `json:"vehicleType,omitempty"`          // Type of vehicle from the point of view of its structural characteristics
+	AreaServed           string     `json:"areaServed,omitempty" protobuf:"1"`                                       // The geographic area where a service or offered item is provided
+	AverageGapDistance   float64    `json:"averageGapDistance,omitempty" validate:"min=0" protobuf:"2"`              // Average gap distance between consecutive vehicles
+	AverageHeadwayTime   float64    `json:"averageHeadwayTime,omitempty" validate:"min=0" protobuf:"3"`              // Average headway time (time elapsed between two consecutive vehicles)
+	AverageVehicleLength float64    `json:"averageVehicleLength,omitempty" validate:"min=0" protobuf:"4"`            // Average length of the vehicles transiting during the observation period
+	AverageVehicleSpeed  float64    `json:"averageVehicleSpeed,omitempty" validate:"min=0" protobuf:"5"`             // Average speed of the vehicles transiting during the observation period
+	Congested            bool       `json:"congested" protobuf:"6"`                                                  // Flags whether there was a traffic congestion during the observation period in the referred lane
+	DateObservedFrom     *time.Time `json:"dateObservedFrom,omitempty" protobuf:"7"`                                 // Observation period start date and time
+	DateObservedTo       *time.Time `json:"dateObservedTo,omitempty" protobuf:"8"`                                   // Observation period end date and time
+	Intensity            int        `json:"intensity,omitempty" validate:"min=0" protobuf:"9"`                       // Total number of vehicles detected during this observation period
+	LaneDirection        string     `json:"laneDirection,omitempty" validate:"oneof=forward backward" protobuf:"10"` // Usual direction of travel in the lane referred by this observation ("forward" or "backward")
+	Occupancy            float64    `json:"occupancy,omitempty" validate:"min=0,max=1" protobuf:"11"`                // Fraction of the observation time where a vehicle has been occupying the observed lane
+	ReversedLane         bool       `json:"reversedLane,omitempty" protobuf:"12"`                                    // Flags whether traffic in the lane was reversed during the observation period
+	VehicleSubType       string     `json:"vehicleSubType,omitempty" protobuf:"13"`                                  // Subtype of vehicleType, provides more specific information about the type of vehicle
+	VehicleType          string     `json:"vehicleType,omitempty" protobuf:"14"`                                     // Type of vehicle from the point of view of its structural characteristics
+}
+
+// UpdateTrafficFlowCommand is the summary of the traffic flow sent to the pole the sensor is attached to
+type UpdateTrafficFlowCommand struct {
+	Congested bool    `json:"congested,omitempty" protobuf:"1"`
+	Occupancy float64 `json:"occupancy,omitempty" protobuf:"2"`
 }
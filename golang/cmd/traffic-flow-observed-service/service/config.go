@@ -0,0 +1,34 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kpolicy"
+)
+
+// Config of the traffic flow observed service, the services.traffic-flow section of the config.
+// The flow is congested when its average speed or its average headway time is below the thresholds.
+type Config struct {
+	AverageSpeedThreshold float64 `yaml:"averageSpeedThreshold" env:"KTWIN_TRAFFIC_FLOW_AVERAGE_SPEED_THRESHOLD"`
+	HeadwayTimeThreshold  float64 `yaml:"headwayTimeThreshold" env:"KTWIN_TRAFFIC_FLOW_HEADWAY_TIME_THRESHOLD"`
+}
+
+var serviceConfig = Config{
+	AverageSpeedThreshold: 12,
+	HeadwayTimeThreshold:  2,
+}
+
+func (c *Config) Validate() error {
+	if c.AverageSpeedThreshold < 0 || c.HeadwayTimeThreshold < 0 {
+		return errors.New("averageSpeedThreshold and headwayTimeThreshold must not be negative")
+	}
+	return nil
+}
+
+func LoadConfig() error {
+	if err := config.LoadService("traffic-flow", &serviceConfig); err != nil {
+		return err
+	}
+	return kpolicy.Register(TWIN_INTERFACE_TRAFFIC_FLOW_OBSERVED, serviceConfig)
+}
@@ -3,18 +3,45 @@ package service
 import (
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/traffic-flow-observed-service/model"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kcommand"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kevent"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/keventstore"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kpolicy"
+	ktwingraph "github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/ktwingraph"
+	log "github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
 )
 
 var (
+	TWIN_INTERFACE_CITY_POLE             = "city-pole"
 	TWIN_INTERFACE_TRAFFIC_FLOW_OBSERVED = "ngsi-ld-city-trafficflowobserved"
 
-	TRAFFIC_FLOW_AVERAGE_TRAFFIC_SPEED_THRESHOLD = 12
-	TRAFFIC_FLOW_HEADWAY_TIME_THRESHOLD          = 2
+	// City Pole Update Traffic Flow Command, the pole references its traffic flow sensor
+	TWIN_COMMAND_CITY_POLE_UPDATE_TRAFFIC_FLOW = "updateTrafficFlow"
+	TWIN_COMMAND_CITY_POLE_RELATIONSHIP_NAME   = "refTrafficFlow"
 )
 
+var logger = log.NewLogger()
+var twinGraph *ktwin.TwinGraph
+
+func loadTwinGraph() error {
+	if twinGraph == nil {
+		var err error
+		graph, err := ktwingraph.LoadTwinGraphByInterfaces([]string{TWIN_INTERFACE_CITY_POLE})
+		if err != nil {
+			logger.Error("Error loading twin graph", err)
+			return err
+		}
+		twinGraph = &graph
+	}
+	return nil
+}
+
 func HandleEvent(event *ktwin.TwinEvent) error {
+	err := loadTwinGraph()
+	if err != nil {
+		return err
+	}
+
 	return kevent.HandleEvent(event, TWIN_INTERFACE_TRAFFIC_FLOW_OBSERVED, handleTrafficFlowObservedEvent)
 }
 
@@ -26,14 +53,25 @@ func handleTrafficFlowObservedEvent(event *ktwin.TwinEvent) error {
 		return err
 	}
 
-	if trafficFlowObserved.AverageVehicleSpeed < float64(TRAFFIC_FLOW_AVERAGE_TRAFFIC_SPEED_THRESHOLD) {
+	policy := kpolicy.ResolveOrDefault(TWIN_INTERFACE_TRAFFIC_FLOW_OBSERVED, event.TwinInstance, serviceConfig)
+	if trafficFlowObserved.AverageVehicleSpeed < policy.AverageSpeedThreshold {
 		trafficFlowObserved.Congested = true
-	} else if trafficFlowObserved.AverageHeadwayTime < float64(TRAFFIC_FLOW_HEADWAY_TIME_THRESHOLD) {
+	} else if trafficFlowObserved.AverageHeadwayTime < policy.HeadwayTimeThreshold {
 		trafficFlowObserved.Congested = true
 	} else {
 		trafficFlowObserved.Congested = false
 	}
 
-	event.SetData(trafficFlowObserved)
-	return keventstore.UpdateTwinEvent(event)
+	err = event.SetData(trafficFlowObserved)
+	if err != nil {
+		return err
+	}
+	err = keventstore.UpdateTwinEvent(event)
+	if err != nil {
+		return err
+	}
+
+	// Sends the summary to the pole the sensor is attached to
+	command := model.UpdateTrafficFlowCommand{Congested: trafficFlowObserved.Congested, Occupancy: trafficFlowObserved.Occupancy}
+	return kcommand.PublishCommandToReferrer(TWIN_COMMAND_CITY_POLE_UPDATE_TRAFFIC_FLOW, command, TWIN_COMMAND_CITY_POLE_RELATIONSHIP_NAME, event.TwinInstance, *twinGraph)
 }
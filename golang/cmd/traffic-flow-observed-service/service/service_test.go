@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/internal/kcommandtest"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/clock"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
@@ -102,6 +103,8 @@ func (s *TrafficFlowObservedServiceSuite) Test_TrafficFlowObservedEvent() {
 					MatchHeader("ce-subject", "").
 					BodyString(`{"averageVehicleSpeed":3,"congested":true,"averageHeadwayTime":1}`).
 					Reply(http.StatusAccepted)
+
+				kcommandtest.MockCommand(s.brokerUrl, "city-pole", "city-pole-nb001-p00007", "updateTrafficFlow", "ngsi-ld-city-trafficflowobserved-nb001-p00007", `{"congested":true}`)
 			},
 			expectedError: nil,
 		},
@@ -139,6 +142,8 @@ func (s *TrafficFlowObservedServiceSuite) Test_TrafficFlowObservedEvent() {
 					MatchHeader("ce-subject", "").
 					BodyString(`{"averageVehicleSpeed":3,"congested":true,"averageHeadwayTime":3}`).
 					Reply(http.StatusAccepted)
+
+				kcommandtest.MockCommand(s.brokerUrl, "city-pole", "city-pole-nb001-p00007", "updateTrafficFlow", "ngsi-ld-city-trafficflowobserved-nb001-p00007", `{"congested":true}`)
 			},
 			expectedError: nil,
 		},
@@ -176,6 +181,8 @@ func (s *TrafficFlowObservedServiceSuite) Test_TrafficFlowObservedEvent() {
 					MatchHeader("ce-subject", "").
 					BodyString(`{"averageVehicleSpeed":13,"congested":true,"averageHeadwayTime":1}`).
 					Reply(http.StatusAccepted)
+
+				kcommandtest.MockCommand(s.brokerUrl, "city-pole", "city-pole-nb001-p00007", "updateTrafficFlow", "ngsi-ld-city-trafficflowobserved-nb001-p00007", `{"congested":true}`)
 			},
 			expectedError: nil,
 		},
@@ -213,6 +220,8 @@ func (s *TrafficFlowObservedServiceSuite) Test_TrafficFlowObservedEvent() {
 					MatchHeader("ce-subject", "").
 					BodyString(`{"averageVehicleSpeed":13,"congested":false,"averageHeadwayTime":3}`).
 					Reply(http.StatusAccepted)
+
+				kcommandtest.MockCommand(s.brokerUrl, "city-pole", "city-pole-nb001-p00007", "updateTrafficFlow", "ngsi-ld-city-trafficflowobserved-nb001-p00007", `{}`)
 			},
 			expectedError: nil,
 		},
@@ -225,6 +234,7 @@ func (s *TrafficFlowObservedServiceSuite) Test_TrafficFlowObservedEvent() {
 			actualError := HandleEvent(tt.twinEvent())
 
 			s.Assert().Equal(tt.expectedError, actualError)
+			s.Assert().True(gock.IsDone())
 		})
 	}
 }
@@ -18,24 +18,24 @@ const (
 
 // WeatherObservedEvent represents the structure for an weather event
 type WeatherObservedEvent struct {
-	WeatherType          string           `json:"weatherType,omitempty"`          // Type of weather
-	StationCode          string           `json:"stationCode,omitempty"`          // Code of the weather station
-	StationName          string           `json:"stationName,omitempty"`          // Name of the weather station
-	PressureTendency     PressureTendency `json:"pressureTendency,omitempty"`     // Pressure tendency (Raising, Steady, Falling)
-	AtmosphericPressure  float64          `json:"atmosphericPressure,omitempty"`  // Atmospheric pressure in hPa
-	Dewpoint             float64          `json:"dewpoint,omitempty"`             // Dewpoint temperature in Celsius
-	FeelsLikeTemperature float64          `json:"feelsLikeTemperature,omitempty"` // Feels like temperature in Celsius
-	Temperature          float64          `json:"temperature,omitempty"`          // Temperature in Celsius
-	Illuminance          float64          `json:"illuminance,omitempty"`          // Illuminance in lux
-	Precipitation        float64          `json:"precipitation,omitempty"`        // Precipitation in mm
-	RelativeHumidity     float64          `json:"relativeHumidity,omitempty"`     // Relative humidity in percentage
-	SnowHeight           float64          `json:"snowHeight,omitempty"`           // Snow height in cm
-	SolarRadiation       float64          `json:"solarRadiation,omitempty"`       // Solar radiation in W/m^2
-	StreamGauge          float64          `json:"streamGauge,omitempty"`          // Stream gauge in m
-	UVIndexMax           float64          `json:"uvIndexMax,omitempty"`           // Maximum UV index
-	Visibility           float64          `json:"visibility,omitempty"`           // Visibility in km
-	WindDirection        float64          `json:"windDirection,omitempty"`        // Wind direction in degrees
-	WindSpeed            float64          `json:"windSpeed,omitempty"`            // Wind speed in m/s
+	WeatherType          string           `json:"weatherType,omitempty" protobuf:"1"`                                              // Type of weather
+	StationCode          string           `json:"stationCode,omitempty" protobuf:"2"`                                              // Code of the weather station
+	StationName          string           `json:"stationName,omitempty" protobuf:"3"`                                              // Name of the weather station
+	PressureTendency     PressureTendency `json:"pressureTendency,omitempty" validate:"oneof=raising steady falling" protobuf:"4"` // Pressure tendency (Raising, Steady, Falling)
+	AtmosphericPressure  float64          `json:"atmosphericPressure,omitempty" validate:"min=0" protobuf:"5"`                     // Atmospheric pressure in hPa
+	Dewpoint             float64          `json:"dewpoint,omitempty" protobuf:"6"`                                                 // Dewpoint temperature in Celsius
+	FeelsLikeTemperature float64          `json:"feelsLikeTemperature,omitempty" protobuf:"7"`                                     // Feels like temperature in Celsius
+	Temperature          float64          `json:"temperature,omitempty" protobuf:"8"`                                              // Temperature in Celsius
+	Illuminance          float64          `json:"illuminance,omitempty" validate:"min=0" protobuf:"9"`                             // Illuminance in lux
+	Precipitation        float64          `json:"precipitation,omitempty" validate:"min=0" protobuf:"10"`                          // Precipitation in mm
+	RelativeHumidity     float64          `json:"relativeHumidity,omitempty" validate:"min=0,max=100" protobuf:"11"`               // Relative humidity in percentage
+	SnowHeight           float64          `json:"snowHeight,omitempty" validate:"min=0" protobuf:"12"`                             // Snow height in cm
+	SolarRadiation       float64          `json:"solarRadiation,omitempty" validate:"min=0" protobuf:"13"`                         // Solar radiation in W/m^2
+	StreamGauge          float64          `json:"streamGauge,omitempty" validate:"min=0" protobuf:"14"`                            // Stream gauge in m
+	UVIndexMax           float64          `json:"uvIndexMax,omitempty" validate:"min=0" protobuf:"15"`                             // Maximum UV index
+	Visibility           float64          `json:"visibility,omitempty" validate:"min=0" protobuf:"16"`                             // Visibility in km
+	WindDirection        float64          `json:"windDirection,omitempty" validate:"min=0,max=360" protobuf:"17"`                  // Wind direction in degrees
+	WindSpeed            float64          `json:"windSpeed,omitempty" validate:"min=0" protobuf:"18"`                              // Wind speed in m/s
 }
 
 func (w *WeatherObservedEvent) SetPressureTendency(latestAtmosphericPressure float64) {
@@ -58,3 +58,9 @@ func (w *WeatherObservedEvent) SetFeelsLikeTemperature(temperature float64, wind
 func (w *WeatherObservedEvent) SetDewpoint(temperature float64, relativeHumidity float64) {
 	w.Dewpoint = temperature - ((100 - relativeHumidity) / 5)
 }
+
+// UpdateWeatherCommand is the summary of the weather sent to the pole the sensor is attached to
+type UpdateWeatherCommand struct {
+	Temperature      float64 `json:"temperature,omitempty" protobuf:"1"`
+	RelativeHumidity float64 `json:"relativeHumidity,omitempty" protobuf:"2"`
+}
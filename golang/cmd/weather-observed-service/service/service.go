@@ -3,15 +3,44 @@ package service
 import (
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/weather-observed-service/model"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kcommand"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kevent"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/keventstore"
+	ktwingraph "github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/ktwingraph"
+	log "github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
 )
 
 var (
+	TWIN_INTERFACE_CITY_POLE        = "city-pole"
 	TWIN_INTERFACE_WEATHER_OBSERVED = "ngsi-ld-city-weatherobserved"
+
+	// City Pole Update Weather Command, the pole references its weather sensor
+	TWIN_COMMAND_CITY_POLE_UPDATE_WEATHER    = "updateWeather"
+	TWIN_COMMAND_CITY_POLE_RELATIONSHIP_NAME = "refWeather"
 )
 
+var logger = log.NewLogger()
+var twinGraph *ktwin.TwinGraph
+
+func loadTwinGraph() error {
+	if twinGraph == nil {
+		var err error
+		graph, err := ktwingraph.LoadTwinGraphByInterfaces([]string{TWIN_INTERFACE_CITY_POLE})
+		if err != nil {
+			logger.Error("Error loading twin graph", err)
+			return err
+		}
+		twinGraph = &graph
+	}
+	return nil
+}
+
 func HandleEvent(event *ktwin.TwinEvent) error {
+	err := loadTwinGraph()
+	if err != nil {
+		return err
+	}
+
 	return kevent.HandleEvent(event, TWIN_INTERFACE_WEATHER_OBSERVED, handleWeatherObservedEvent)
 }
 
@@ -27,7 +56,7 @@ func handleWeatherObservedEvent(event *ktwin.TwinEvent) error {
 	}
 
 	var latestWeatherObserved model.WeatherObservedEvent
-	err = latestEvent.ToModel(&latestWeatherObserved)
+	err = latestEvent.ToStoredModel(&latestWeatherObserved)
 
 	if err != nil {
 		return err
@@ -44,6 +73,16 @@ func handleWeatherObservedEvent(event *ktwin.TwinEvent) error {
 	weatherObserved.SetFeelsLikeTemperature(weatherObserved.Temperature, weatherObserved.WindSpeed)
 	weatherObserved.SetDewpoint(weatherObserved.Temperature, weatherObserved.RelativeHumidity)
 
-	event.SetData(weatherObserved)
-	return keventstore.UpdateTwinEvent(event)
+	err = event.SetData(weatherObserved)
+	if err != nil {
+		return err
+	}
+	err = keventstore.UpdateTwinEvent(event)
+	if err != nil {
+		return err
+	}
+
+	// Sends the summary to the pole the sensor is attached to
+	command := model.UpdateWeatherCommand{Temperature: weatherObserved.Temperature, RelativeHumidity: weatherObserved.RelativeHumidity}
+	return kcommand.PublishCommandToReferrer(TWIN_COMMAND_CITY_POLE_UPDATE_WEATHER, command, TWIN_COMMAND_CITY_POLE_RELATIONSHIP_NAME, event.TwinInstance, *twinGraph)
 }
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/cmd/weather-observed-service/model"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/internal/kcommandtest"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/clock"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
@@ -107,6 +108,8 @@ func (s *WeatherObservedServiceSuite) Test_WeatherObservedEvent() {
 					MatchHeader("ce-subject", "").
 					BodyString(`{"pressureTendency":"steady","atmosphericPressure":10,"dewpoint":-10.399999999999999,"feelsLikeTemperature":-1.9253082357521691,"temperature":8,"relativeHumidity":8,"windSpeed":8}`).
 					Reply(http.StatusAccepted)
+
+				kcommandtest.MockCommand(s.brokerUrl, "city-pole", "city-pole-nb001-p00007", "updateWeather", "ngsi-ld-city-weatherobserved-nb001-p00007", `{"temperature":8,"relativeHumidity":8}`)
 			},
 			expectedError: nil,
 		},
@@ -160,6 +163,8 @@ func (s *WeatherObservedServiceSuite) Test_WeatherObservedEvent() {
 					MatchHeader("ce-subject", "").
 					BodyString(`{"pressureTendency":"raising","atmosphericPressure":10,"dewpoint":-10.399999999999999,"feelsLikeTemperature":-1.9253082357521691,"temperature":8,"relativeHumidity":8,"windSpeed":8}`).
 					Reply(http.StatusAccepted)
+
+				kcommandtest.MockCommand(s.brokerUrl, "city-pole", "city-pole-nb001-p00007", "updateWeather", "ngsi-ld-city-weatherobserved-nb001-p00007", `{"temperature":8,"relativeHumidity":8}`)
 			},
 			expectedError: nil,
 		},
@@ -213,6 +218,8 @@ func (s *WeatherObservedServiceSuite) Test_WeatherObservedEvent() {
 					MatchHeader("ce-subject", "").
 					BodyString(`{"pressureTendency":"falling","atmosphericPressure":10,"dewpoint":-10.399999999999999,"feelsLikeTemperature":-1.9253082357521691,"temperature":8,"relativeHumidity":8,"windSpeed":8}`).
 					Reply(http.StatusAccepted)
+
+				kcommandtest.MockCommand(s.brokerUrl, "city-pole", "city-pole-nb001-p00007", "updateWeather", "ngsi-ld-city-weatherobserved-nb001-p00007", `{"temperature":8,"relativeHumidity":8}`)
 			},
 			expectedError: nil,
 		},
@@ -266,6 +273,58 @@ func (s *WeatherObservedServiceSuite) Test_WeatherObservedEvent() {
 					MatchHeader("ce-subject", "").
 					BodyString(`{"pressureTendency":"steady","atmosphericPressure":10,"dewpoint":-10.399999999999999,"feelsLikeTemperature":-1.9253082357521691,"temperature":8,"relativeHumidity":8,"windSpeed":8}`).
 					Reply(http.StatusAccepted)
+
+				kcommandtest.MockCommand(s.brokerUrl, "city-pole", "city-pole-nb001-p00007", "updateWeather", "ngsi-ld-city-weatherobserved-nb001-p00007", `{"temperature":8,"relativeHumidity":8}`)
+			},
+			expectedError: nil,
+		},
+		{
+			name: `
+				Given new weather observed event is received
+				When the latest event in event store has attributes no longer declared in the model and out of range values
+				Should decode the latest event leniently and store the new event in event store
+			`,
+			twinEvent: func() *ktwin.TwinEvent {
+				twinEvent := ktwin.NewTwinEvent()
+				twinEvent.EventType = ktwin.CommandEvent
+				twinEvent.TwinInstance = "ngsi-ld-city-weatherobserved-nb001-p00007"
+				twinEvent.TwinInterface = "ngsi-ld-city-weatherobserved"
+
+				cloudEvent := cloudevents.NewEvent()
+				cloudEvent.SetData("application/json", []byte(`{"atmosphericPressure": 10, "temperature": 8, "relativeHumidity": 8, "windSpeed": 8}`))
+				cloudEvent.SetID("")
+				cloudEvent.SetSource("ngsi-ld-city-weatherobserved-nb001-p00007")
+				cloudEvent.SetType("ktwin.real.ngsi-ld-city-weatherobserved")
+				cloudEvent.SetTime(*dateTime)
+
+				twinEvent.CloudEvent = &cloudEvent
+				return twinEvent
+			},
+			mockExternalService: func() {
+				gock.New(s.eventStoreUrl).
+					Get("/api/v1/twin-events/ngsi-ld-city-weatherobserved/ngsi-ld-city-weatherobserved-nb001-p00007/latest").
+					Reply(http.StatusOK).
+					SetHeader("Content-Type", "application/json").
+					SetHeader("ce-specversion", "1.0").
+					SetHeader("ce-time", dateTimeFormatted).
+					SetHeader("ce-source", "ngsi-ld-city-weatherobserved-nb001-p00007").
+					SetHeader("ce-type", "ktwin.real.ngsi-ld-city-weatherobserved").
+					SetHeader("ce-subject", "").
+					BodyString(`{"atmosphericPressure":2,"temperature":2,"relativeHumidity":120,"windSpeed":2,"legacyIndex":3}`)
+
+				gock.New(s.brokerUrl).
+					Post("/").
+					MatchHeader("Content-Type", "application/json").
+					MatchHeader("ce-id", "").
+					MatchHeader("ce-specversion", "1.0").
+					MatchHeader("ce-time", dateTimeFormatted).
+					MatchHeader("ce-source", "ngsi-ld-city-weatherobserved-nb001-p00007").
+					MatchHeader("ce-type", "ktwin.store.ngsi-ld-city-weatherobserved").
+					MatchHeader("ce-subject", "").
+					BodyString(`{"pressureTendency":"raising","atmosphericPressure":10,"dewpoint":-10.399999999999999,"feelsLikeTemperature":-1.9253082357521691,"temperature":8,"relativeHumidity":8,"windSpeed":8}`).
+					Reply(http.StatusAccepted)
+
+				kcommandtest.MockCommand(s.brokerUrl, "city-pole", "city-pole-nb001-p00007", "updateWeather", "ngsi-ld-city-weatherobserved-nb001-p00007", `{"temperature":8,"relativeHumidity":8}`)
 			},
 			expectedError: nil,
 		},
@@ -278,6 +337,7 @@ func (s *WeatherObservedServiceSuite) Test_WeatherObservedEvent() {
 			actualError := HandleEvent(tt.twinEvent())
 
 			s.Assert().Equal(tt.expectedError, actualError)
+			s.Assert().True(gock.IsDone())
 		})
 	}
 }
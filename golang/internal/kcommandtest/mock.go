@@ -0,0 +1,22 @@
+// Package kcommandtest holds the broker mocks shared by the service tests, it is only imported by _test.go files
+package kcommandtest
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/h2non/gock"
+)
+
+// Mocks the broker receiving the command for the twin instance, published on behalf of the causation instance
+func MockCommand(brokerUrl, twinInterface, twinInstance, command, causation, body string) {
+	gock.New(brokerUrl).
+		Post("/").
+		MatchHeader("ce-source", twinInstance).
+		MatchHeader("ce-type", fmt.Sprintf(ktwin.EventCommandExecuted, twinInterface, strings.ToLower(command))).
+		MatchHeader("ce-ktwincausation", causation).
+		BodyString(body).
+		Reply(http.StatusAccepted)
+}
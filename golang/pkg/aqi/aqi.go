@@ -0,0 +1,191 @@
+// Package aqi calculates the Air Quality Index of the pollutant concentrations with a regional
+// standard: the US EPA AQI, the EU CAQI, the UK DAQI or the India NAQI. The bands of each standard
+// are mapped onto the categories of the US EPA AQI, which are the ones of the twin commands.
+package aqi
+
+import "fmt"
+
+type Category string
+
+const (
+	Good                        Category = "GOOD"
+	Moderate                    Category = "MODERATE"
+	UnhealthyForSensitiveGroups Category = "UNHEALTHY_FOR_SENSITIVE_GROUPS"
+	Unhealthy                   Category = "UNHEALTHY"
+	VeryUnhealthy               Category = "VERY_UNHEALTHY"
+	Hazardous                   Category = "HAZARDOUS"
+)
+
+// Categories from the best to the worst
+var Categories = []Category{Good, Moderate, UnhealthyForSensitiveGroups, Unhealthy, VeryUnhealthy, Hazardous}
+
+// Gets the level of the category, from 1 for good to 6 for hazardous, 0 when it is not known
+func (c Category) Level() int {
+	for i, category := range Categories {
+		if c == category {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Gets the worst of the categories, empty when there is none
+func Worst(categories ...Category) Category {
+	var worst Category
+	for _, category := range categories {
+		if category.Level() > worst.Level() {
+			worst = category
+		}
+	}
+	return worst
+}
+
+type Pollutant string
+
+const (
+	CO   Pollutant = "CO"
+	NO2  Pollutant = "NO2"
+	O3   Pollutant = "O3"
+	PM10 Pollutant = "PM10"
+	PM25 Pollutant = "PM25"
+	SO2  Pollutant = "SO2"
+)
+
+// Pollutants in the order the dominant pollutant is chosen when their AQI values are equal
+var Pollutants = []Pollutant{PM25, PM10, O3, NO2, SO2, CO}
+
+// Units of the concentrations given to the standards, the ones of the US EPA tables.
+// They are converted to the units of the table of each standard.
+var InputUnits = map[Pollutant]Unit{
+	CO:   PPM,
+	NO2:  PPB,
+	O3:   PPB,
+	PM10: MicrogramsPerCubicMeter,
+	PM25: MicrogramsPerCubicMeter,
+	SO2:  PPB,
+}
+
+// US EPA AQI, as in the Technical Assistance Document for the Reporting of Daily Air Quality
+// (EPA-454/B-24-002, 2024). O3 and CO use the 8-hour tables, PM10 and PM2.5 the 24-hour tables,
+// and NO2 and SO2 the 1-hour tables. The 8-hour O3 table does not define AQI values above 300.
+var EPA = &TableStandard{
+	StandardName: "epa",
+	Bands: []Band{
+		{"Good", 50, Good},
+		{"Moderate", 100, Moderate},
+		{"Unhealthy for Sensitive Groups", 150, UnhealthyForSensitiveGroups},
+		{"Unhealthy", 200, Unhealthy},
+		{"Very Unhealthy", 300, VeryUnhealthy},
+		{"Hazardous", 500, Hazardous},
+	},
+	Tables: EPATables,
+}
+
+var EPATables = map[Pollutant]Table{
+	O3: {Unit: PPB, Decimals: 0, Breakpoints: []Breakpoint{
+		{0, 54, 0, 50},
+		{55, 70, 51, 100},
+		{71, 85, 101, 150},
+		{86, 105, 151, 200},
+		{106, 200, 201, 300},
+	}},
+	PM25: {Unit: MicrogramsPerCubicMeter, Decimals: 1, Breakpoints: []Breakpoint{
+		{0, 9.0, 0, 50},
+		{9.1, 35.4, 51, 100},
+		{35.5, 55.4, 101, 150},
+		{55.5, 125.4, 151, 200},
+		{125.5, 225.4, 201, 300},
+		{225.5, 325.4, 301, 500},
+	}},
+	PM10: {Unit: MicrogramsPerCubicMeter, Decimals: 0, Breakpoints: []Breakpoint{
+		{0, 54, 0, 50},
+		{55, 154, 51, 100},
+		{155, 254, 101, 150},
+		{255, 354, 151, 200},
+		{355, 424, 201, 300},
+		{425, 604, 301, 500},
+	}},
+	CO: {Unit: PPM, Decimals: 1, Breakpoints: []Breakpoint{
+		{0, 4.4, 0, 50},
+		{4.5, 9.4, 51, 100},
+		{9.5, 12.4, 101, 150},
+		{12.5, 15.4, 151, 200},
+		{15.5, 30.4, 201, 300},
+		{30.5, 50.4, 301, 500},
+	}},
+	SO2: {Unit: PPB, Decimals: 0, Breakpoints: []Breakpoint{
+		{0, 35, 0, 50},
+		{36, 75, 51, 100},
+		{76, 185, 101, 150},
+		{186, 304, 151, 200},
+		{305, 604, 201, 300},
+		{605, 1004, 301, 500},
+	}},
+	NO2: {Unit: PPB, Decimals: 0, Breakpoints: []Breakpoint{
+		{0, 53, 0, 50},
+		{54, 100, 51, 100},
+		{101, 360, 101, 150},
+		{361, 649, 151, 200},
+		{650, 1249, 201, 300},
+		{1250, 2049, 301, 500},
+	}},
+}
+
+// Result is the AQI of a pollutant
+type Result struct {
+	Pollutant     Pollutant
+	Concentration float64
+	Index         int
+	Band          string
+	Category      Category
+}
+
+// Summary is the overall AQI of the pollutants, the highest one, which is the AQI of the dominant pollutant
+type Summary struct {
+	Standard   string
+	Index      int
+	Band       string
+	Category   Category
+	Dominant   Pollutant
+	Pollutants map[Pollutant]Result
+}
+
+// Calculates with the standard the AQI of each pollutant concentration, in the InputUnits, and the
+// overall AQI. The pollutants the standard has no breakpoints for are skipped.
+func Calculate(standard Standard, concentrations map[Pollutant]float64) (Summary, error) {
+	for pollutant := range concentrations {
+		if _, ok := InputUnits[pollutant]; !ok {
+			return Summary{}, fmt.Errorf("unknown pollutant %s", pollutant)
+		}
+	}
+
+	summary := Summary{Standard: standard.Name(), Pollutants: map[Pollutant]Result{}}
+	found := false
+
+	for _, pollutant := range Pollutants {
+		concentration, ok := concentrations[pollutant]
+		if !ok || !standard.Supports(pollutant) {
+			continue
+		}
+
+		index, err := standard.Index(pollutant, concentration)
+		if err != nil {
+			return Summary{}, err
+		}
+
+		band := standard.Band(index)
+		summary.Pollutants[pollutant] = Result{pollutant, concentration, index, band.Name, band.Category}
+		if !found || index > summary.Index {
+			summary.Index = index
+			summary.Dominant = pollutant
+			found = true
+		}
+	}
+
+	if found {
+		band := standard.Band(summary.Index)
+		summary.Band = band.Name
+		summary.Category = band.Category
+	}
+	return summary, nil
+}
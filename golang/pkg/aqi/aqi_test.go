@@ -0,0 +1,223 @@
+package aqi
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestAQISuite(t *testing.T) {
+	suite.Run(t, new(AQISuite))
+}
+
+type AQISuite struct {
+	suite.Suite
+}
+
+// Breakpoints of the EPA reference tables, each concentration boundary with its AQI value
+func (s *AQISuite) Test_IndexAtEPABreakpoints() {
+	tests := []struct {
+		pollutant     Pollutant
+		concentration float64
+		index         int
+		category      Category
+	}{
+		{PM25, 0, 0, Good},
+		{PM25, 9.0, 50, Good},
+		{PM25, 9.1, 51, Moderate},
+		{PM25, 35.4, 100, Moderate},
+		{PM25, 35.5, 101, UnhealthyForSensitiveGroups},
+		{PM25, 55.4, 150, UnhealthyForSensitiveGroups},
+		{PM25, 55.5, 151, Unhealthy},
+		{PM25, 125.4, 200, Unhealthy},
+		{PM25, 125.5, 201, VeryUnhealthy},
+		{PM25, 225.4, 300, VeryUnhealthy},
+		{PM25, 225.5, 301, Hazardous},
+		{PM25, 325.4, 500, Hazardous},
+		{PM10, 54, 50, Good},
+		{PM10, 55, 51, Moderate},
+		{PM10, 154, 100, Moderate},
+		{PM10, 155, 101, UnhealthyForSensitiveGroups},
+		{PM10, 254, 150, UnhealthyForSensitiveGroups},
+		{PM10, 355, 201, VeryUnhealthy},
+		{PM10, 604, 500, Hazardous},
+		{O3, 54, 50, Good},
+		{O3, 55, 51, Moderate},
+		{O3, 70, 100, Moderate},
+		{O3, 71, 101, UnhealthyForSensitiveGroups},
+		{O3, 85, 150, UnhealthyForSensitiveGroups},
+		{O3, 86, 151, Unhealthy},
+		{O3, 105, 200, Unhealthy},
+		{O3, 106, 201, VeryUnhealthy},
+		{O3, 200, 300, VeryUnhealthy},
+		{CO, 4.4, 50, Good},
+		{CO, 4.5, 51, Moderate},
+		{CO, 9.4, 100, Moderate},
+		{CO, 9.5, 101, UnhealthyForSensitiveGroups},
+		{CO, 12.5, 151, Unhealthy},
+		{CO, 15.5, 201, VeryUnhealthy},
+		{CO, 30.5, 301, Hazardous},
+		{CO, 50.4, 500, Hazardous},
+		{SO2, 35, 50, Good},
+		{SO2, 36, 51, Moderate},
+		{SO2, 75, 100, Moderate},
+		{SO2, 76, 101, UnhealthyForSensitiveGroups},
+		{SO2, 186, 151, Unhealthy},
+		{SO2, 305, 201, VeryUnhealthy},
+		{SO2, 1004, 500, Hazardous},
+		{NO2, 53, 50, Good},
+		{NO2, 54, 51, Moderate},
+		{NO2, 100, 100, Moderate},
+		{NO2, 101, 101, UnhealthyForSensitiveGroups},
+		{NO2, 360, 150, UnhealthyForSensitiveGroups},
+		{NO2, 361, 151, Unhealthy},
+		{NO2, 650, 201, VeryUnhealthy},
+		{NO2, 1250, 301, Hazardous},
+		{NO2, 2049, 500, Hazardous},
+	}
+
+	for _, tt := range tests {
+		s.Run(fmt.Sprintf("%s %v", tt.pollutant, tt.concentration), func() {
+			index, err := EPA.Index(tt.pollutant, tt.concentration)
+			s.Require().NoError(err)
+			s.Assert().Equal(tt.index, index)
+			s.Assert().Equal(tt.category, EPA.Band(index).Category)
+		})
+	}
+}
+
+// Worked examples of the EPA guide, with the concentrations truncated to the table decimals
+func (s *AQISuite) Test_IndexOfEPAExamples() {
+	tests := []struct {
+		pollutant     Pollutant
+		concentration float64
+		index         int
+	}{
+		{O3, 78, 126},
+		{PM25, 35.98, 102},
+		{CO, 8.47, 90},
+		{PM10, 154.9, 100},
+		{PM10, 700, 500},
+	}
+
+	for _, tt := range tests {
+		index, err := EPA.Index(tt.pollutant, tt.concentration)
+		s.Require().NoError(err)
+		s.Assert().Equal(tt.index, index, "%s %v", tt.pollutant, tt.concentration)
+	}
+}
+
+func (s *AQISuite) Test_IndexErrors() {
+	_, err := EPA.Index(PM25, -1)
+	s.Assert().Error(err)
+
+	_, err = EPA.Index("NO", 1)
+	s.Assert().Error(err)
+}
+
+func (s *AQISuite) Test_Calculate() {
+	summary, err := Calculate(EPA, map[Pollutant]float64{CO: 8, NO2: 120, O3: 8, PM10: 8, PM25: 8, SO2: 8})
+	s.Require().NoError(err)
+
+	s.Assert().Equal(105, summary.Index)
+	s.Assert().Equal(UnhealthyForSensitiveGroups, summary.Category)
+	s.Assert().Equal("Unhealthy for Sensitive Groups", summary.Band)
+	s.Assert().Equal(NO2, summary.Dominant)
+	s.Assert().Len(summary.Pollutants, 6)
+	s.Assert().Equal(Result{CO, 8, 86, "Moderate", Moderate}, summary.Pollutants[CO])
+	s.Assert().Equal(Result{PM25, 8, 44, "Good", Good}, summary.Pollutants[PM25])
+
+	// Equal values are dominated by the first pollutant of Pollutants
+	summary, err = Calculate(EPA, map[Pollutant]float64{CO: 0, PM25: 0})
+	s.Require().NoError(err)
+	s.Assert().Equal(PM25, summary.Dominant)
+	s.Assert().Equal(Good, summary.Category)
+
+	summary, err = Calculate(EPA, nil)
+	s.Require().NoError(err)
+	s.Assert().Equal(Summary{Standard: "epa", Pollutants: map[Pollutant]Result{}}, summary)
+
+	_, err = Calculate(EPA, map[Pollutant]float64{"NO": 1})
+	s.Assert().Error(err)
+}
+
+func (s *AQISuite) Test_RegionalStandards() {
+	tests := []struct {
+		standard      Standard
+		pollutant     Pollutant
+		concentration float64
+		index         int
+		band          string
+		category      Category
+	}{
+		{CAQI, PM10, 20, 20, "Very low", Good},
+		{CAQI, PM10, 70, 63, "Medium", UnhealthyForSensitiveGroups},
+		{CAQI, PM25, 110, 100, "High", Unhealthy},
+		{CAQI, PM10, 270, 125, "Very high", VeryUnhealthy},
+		// 106.3 ppb of NO2 are 200 µg/m³
+		{CAQI, NO2, 106.3, 75, "Medium", UnhealthyForSensitiveGroups},
+		{DAQI, PM25, 11, 1, "Low", Good},
+		{DAQI, PM25, 36, 4, "Moderate", Moderate},
+		{DAQI, PM10, 80, 7, "High", Unhealthy},
+		{DAQI, PM10, 300, 10, "Very High", VeryUnhealthy},
+		{NAQI, PM10, 120, 114, "Moderate", UnhealthyForSensitiveGroups},
+		{NAQI, PM25, 25, 42, "Good", Good},
+		{NAQI, PM25, 250, 400, "Very Poor", VeryUnhealthy},
+		{NAQI, PM25, 300, 439, "Severe", Hazardous},
+		{NAQI, PM25, 1000, 500, "Severe", Hazardous},
+		// 1.8 ppm of CO are 2.0 mg/m³
+		{NAQI, CO, 1.8, 100, "Satisfactory", Moderate},
+	}
+
+	for _, tt := range tests {
+		s.Run(fmt.Sprintf("%s %s %v", tt.standard.Name(), tt.pollutant, tt.concentration), func() {
+			index, err := tt.standard.Index(tt.pollutant, tt.concentration)
+			s.Require().NoError(err)
+			s.Assert().Equal(tt.index, index)
+			s.Assert().Equal(tt.band, tt.standard.Band(index).Name)
+			s.Assert().Equal(tt.category, tt.standard.Band(index).Category)
+		})
+	}
+}
+
+func (s *AQISuite) Test_CalculateSkipsUnsupportedPollutants() {
+	summary, err := Calculate(DAQI, map[Pollutant]float64{CO: 40, PM10: 20})
+	s.Require().NoError(err)
+
+	s.Assert().Equal(2, summary.Index)
+	s.Assert().Equal("Low", summary.Band)
+	s.Assert().Equal(PM10, summary.Dominant)
+	s.Assert().NotContains(summary.Pollutants, CO)
+
+	_, err = DAQI.Index(CO, 40)
+	s.Assert().Error(err)
+}
+
+func (s *AQISuite) Test_GetStandard() {
+	standard, err := GetStandard("CAQI")
+	s.Require().NoError(err)
+	s.Assert().Same(CAQI, standard)
+
+	_, err = GetStandard("who")
+	s.Assert().ErrorContains(err, "caqi, daqi, epa, naqi")
+}
+
+func (s *AQISuite) Test_Convert() {
+	value, err := Convert(NO2, 100, PPB, MicrogramsPerCubicMeter)
+	s.Require().NoError(err)
+	s.Assert().InDelta(188.2, value, 0.1)
+
+	value, err = Convert(CO, 1, PPM, MilligramsPerCubicMeter)
+	s.Require().NoError(err)
+	s.Assert().InDelta(1.146, value, 0.001)
+
+	_, err = Convert(PM25, 1, PPB, MicrogramsPerCubicMeter)
+	s.Assert().Error(err)
+}
+
+func (s *AQISuite) Test_Worst() {
+	s.Assert().Equal(Unhealthy, Worst(Good, Unhealthy, Moderate))
+	s.Assert().Equal(Good, Worst(Good, ""))
+	s.Assert().Equal(Category(""), Worst())
+}
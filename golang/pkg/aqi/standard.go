@@ -0,0 +1,156 @@
+package aqi
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Standard is a regional air quality index
+type Standard interface {
+	Name() string
+	// Whether the standard has breakpoints for the pollutant
+	Supports(pollutant Pollutant) bool
+	// Calculates the index of the pollutant concentration, in the InputUnits
+	Index(pollutant Pollutant, concentration float64) (int, error)
+	// Gets the band of the index
+	Band(index int) Band
+}
+
+// Band is a range of index values of a standard, mapped onto a category
+type Band struct {
+	Name      string
+	IndexHigh int
+	Category  Category
+}
+
+// Breakpoint maps a concentration range to an index range. The last breakpoint of a table may be
+// open, with an infinite high concentration, its index is extrapolated with the slope of the
+// previous breakpoint up to its high index.
+type Breakpoint struct {
+	ConcentrationLow  float64
+	ConcentrationHigh float64
+	IndexLow          int
+	IndexHigh         int
+}
+
+// Table is the breakpoints of a pollutant. Concentrations are truncated to the decimals of the table.
+type Table struct {
+	Unit        Unit
+	Decimals    int
+	Breakpoints []Breakpoint
+}
+
+// TableStandard is a standard defined by the breakpoint tables of its pollutants
+type TableStandard struct {
+	StandardName string
+	// Bands from the best to the worst, the last one holds the higher indexes
+	Bands  []Band
+	Tables map[Pollutant]Table
+}
+
+func (s *TableStandard) Name() string {
+	return s.StandardName
+}
+
+func (s *TableStandard) Supports(pollutant Pollutant) bool {
+	_, ok := s.Tables[pollutant]
+	return ok
+}
+
+// Calculates the index of the concentration by linear interpolation in its breakpoint. Concentrations
+// above the table are beyond the index, and reported as the highest index of the table.
+func (s *TableStandard) Index(pollutant Pollutant, concentration float64) (int, error) {
+	table, ok := s.Tables[pollutant]
+	if !ok {
+		return 0, fmt.Errorf("pollutant %s is not supported by the %s standard", pollutant, s.StandardName)
+	}
+	if concentration < 0 || math.IsNaN(concentration) {
+		return 0, fmt.Errorf("invalid %s concentration %v", pollutant, concentration)
+	}
+
+	concentration, err := Convert(pollutant, concentration, InputUnits[pollutant], table.Unit)
+	if err != nil {
+		return 0, err
+	}
+
+	concentration = truncate(concentration, table.Decimals)
+	for i, breakpoint := range table.Breakpoints {
+		if concentration > breakpoint.ConcentrationHigh {
+			continue
+		}
+
+		if math.IsInf(breakpoint.ConcentrationHigh, 1) && i > 0 {
+			previous := table.Breakpoints[i-1]
+			extended := Breakpoint{breakpoint.ConcentrationLow, breakpoint.ConcentrationLow + previous.ConcentrationHigh - previous.ConcentrationLow, breakpoint.IndexLow, breakpoint.IndexLow + previous.IndexHigh - previous.IndexLow}
+			index := extended.index(concentration)
+			if index > breakpoint.IndexHigh {
+				index = breakpoint.IndexHigh
+			}
+			return index, nil
+		}
+		return breakpoint.index(concentration), nil
+	}
+	return table.Breakpoints[len(table.Breakpoints)-1].IndexHigh, nil
+}
+
+func (s *TableStandard) Band(index int) Band {
+	for _, band := range s.Bands {
+		if index <= band.IndexHigh {
+			return band
+		}
+	}
+	return s.Bands[len(s.Bands)-1]
+}
+
+// Linear interpolation of the concentration in the breakpoint, rounded to the nearest integer
+func (b Breakpoint) index(concentration float64) int {
+	slope := float64(b.IndexHigh-b.IndexLow) / (b.ConcentrationHigh - b.ConcentrationLow)
+	return int(math.Round(slope*(concentration-b.ConcentrationLow) + float64(b.IndexLow)))
+}
+
+func truncate(value float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	// The epsilon keeps values such as 35.4, stored as 35.399999..., from being truncated down
+	return math.Floor(value*scale+1e-9) / scale
+}
+
+var (
+	standards = map[string]Standard{
+		EPA.Name():  EPA,
+		CAQI.Name(): CAQI,
+		DAQI.Name(): DAQI,
+		NAQI.Name(): NAQI,
+	}
+	standardsMutex sync.RWMutex
+)
+
+// Registers a standard, which can then be chosen by its name
+func RegisterStandard(standard Standard) {
+	standardsMutex.Lock()
+	defer standardsMutex.Unlock()
+	standards[strings.ToLower(standard.Name())] = standard
+}
+
+// Gets the standard by its name, case insensitive
+func GetStandard(name string) (Standard, error) {
+	standardsMutex.RLock()
+	defer standardsMutex.RUnlock()
+
+	standard, ok := standards[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown AQI standard %q, must be one of %s", name, strings.Join(standardNames(), ", "))
+	}
+	return standard, nil
+}
+
+func standardNames() []string {
+	var names []string
+	for name := range standards {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
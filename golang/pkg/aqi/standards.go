@@ -0,0 +1,197 @@
+package aqi
+
+import "math"
+
+// EU Common Air Quality Index (CAQI), with the hourly background grid of the CiteAir project.
+// Its index has no upper bound, concentrations above the grid extend the Very high band.
+var CAQI = &TableStandard{
+	StandardName: "caqi",
+	Bands: []Band{
+		{"Very low", 25, Good},
+		{"Low", 50, Moderate},
+		{"Medium", 75, UnhealthyForSensitiveGroups},
+		{"High", 100, Unhealthy},
+		{"Very high", math.MaxInt32, VeryUnhealthy},
+	},
+	Tables: map[Pollutant]Table{
+		NO2: {Unit: MicrogramsPerCubicMeter, Breakpoints: []Breakpoint{
+			{0, 50, 0, 25},
+			{50, 100, 25, 50},
+			{100, 200, 50, 75},
+			{200, 400, 75, 100},
+			{400, math.Inf(1), 100, math.MaxInt32},
+		}},
+		PM10: {Unit: MicrogramsPerCubicMeter, Breakpoints: []Breakpoint{
+			{0, 25, 0, 25},
+			{25, 50, 25, 50},
+			{50, 90, 50, 75},
+			{90, 180, 75, 100},
+			{180, math.Inf(1), 100, math.MaxInt32},
+		}},
+		PM25: {Unit: MicrogramsPerCubicMeter, Breakpoints: []Breakpoint{
+			{0, 15, 0, 25},
+			{15, 30, 25, 50},
+			{30, 55, 50, 75},
+			{55, 110, 75, 100},
+			{110, math.Inf(1), 100, math.MaxInt32},
+		}},
+		O3: {Unit: MicrogramsPerCubicMeter, Breakpoints: []Breakpoint{
+			{0, 60, 0, 25},
+			{60, 120, 25, 50},
+			{120, 180, 50, 75},
+			{180, 240, 75, 100},
+			{240, math.Inf(1), 100, math.MaxInt32},
+		}},
+		CO: {Unit: MicrogramsPerCubicMeter, Breakpoints: []Breakpoint{
+			{0, 5000, 0, 25},
+			{5000, 7500, 25, 50},
+			{7500, 10000, 50, 75},
+			{10000, 20000, 75, 100},
+			{20000, math.Inf(1), 100, math.MaxInt32},
+		}},
+		SO2: {Unit: MicrogramsPerCubicMeter, Breakpoints: []Breakpoint{
+			{0, 50, 0, 25},
+			{50, 100, 25, 50},
+			{100, 350, 50, 75},
+			{350, 500, 75, 100},
+			{500, math.Inf(1), 100, math.MaxInt32},
+		}},
+	},
+}
+
+// UK Daily Air Quality Index (DAQI) of the Department for Environment, Food and Rural Affairs.
+// Its index goes from 1 to 10, there are no CO breakpoints.
+var DAQI = &TableStandard{
+	StandardName: "daqi",
+	Bands: []Band{
+		{"Low", 3, Good},
+		{"Moderate", 6, Moderate},
+		{"High", 9, Unhealthy},
+		{"Very High", 10, VeryUnhealthy},
+	},
+	Tables: map[Pollutant]Table{
+		O3: {Unit: MicrogramsPerCubicMeter, Breakpoints: []Breakpoint{
+			{0, 33, 1, 1},
+			{34, 66, 2, 2},
+			{67, 100, 3, 3},
+			{101, 120, 4, 4},
+			{121, 140, 5, 5},
+			{141, 160, 6, 6},
+			{161, 187, 7, 7},
+			{188, 213, 8, 8},
+			{214, 240, 9, 9},
+			{241, math.Inf(1), 10, 10},
+		}},
+		NO2: {Unit: MicrogramsPerCubicMeter, Breakpoints: []Breakpoint{
+			{0, 67, 1, 1},
+			{68, 134, 2, 2},
+			{135, 200, 3, 3},
+			{201, 267, 4, 4},
+			{268, 334, 5, 5},
+			{335, 400, 6, 6},
+			{401, 467, 7, 7},
+			{468, 534, 8, 8},
+			{535, 600, 9, 9},
+			{601, math.Inf(1), 10, 10},
+		}},
+		SO2: {Unit: MicrogramsPerCubicMeter, Breakpoints: []Breakpoint{
+			{0, 88, 1, 1},
+			{89, 177, 2, 2},
+			{178, 266, 3, 3},
+			{267, 354, 4, 4},
+			{355, 443, 5, 5},
+			{444, 532, 6, 6},
+			{533, 710, 7, 7},
+			{711, 887, 8, 8},
+			{888, 1064, 9, 9},
+			{1065, math.Inf(1), 10, 10},
+		}},
+		PM25: {Unit: MicrogramsPerCubicMeter, Breakpoints: []Breakpoint{
+			{0, 11, 1, 1},
+			{12, 23, 2, 2},
+			{24, 35, 3, 3},
+			{36, 41, 4, 4},
+			{42, 47, 5, 5},
+			{48, 53, 6, 6},
+			{54, 58, 7, 7},
+			{59, 64, 8, 8},
+			{65, 70, 9, 9},
+			{71, math.Inf(1), 10, 10},
+		}},
+		PM10: {Unit: MicrogramsPerCubicMeter, Breakpoints: []Breakpoint{
+			{0, 16, 1, 1},
+			{17, 33, 2, 2},
+			{34, 50, 3, 3},
+			{51, 58, 4, 4},
+			{59, 66, 5, 5},
+			{67, 75, 6, 6},
+			{76, 83, 7, 7},
+			{84, 91, 8, 8},
+			{92, 100, 9, 9},
+			{101, math.Inf(1), 10, 10},
+		}},
+	},
+}
+
+// India National Air Quality Index (NAQI) of the Central Pollution Control Board
+var NAQI = &TableStandard{
+	StandardName: "naqi",
+	Bands: []Band{
+		{"Good", 50, Good},
+		{"Satisfactory", 100, Moderate},
+		{"Moderate", 200, UnhealthyForSensitiveGroups},
+		{"Poor", 300, Unhealthy},
+		{"Very Poor", 400, VeryUnhealthy},
+		{"Severe", 500, Hazardous},
+	},
+	Tables: map[Pollutant]Table{
+		PM10: {Unit: MicrogramsPerCubicMeter, Breakpoints: []Breakpoint{
+			{0, 50, 0, 50},
+			{51, 100, 51, 100},
+			{101, 250, 101, 200},
+			{251, 350, 201, 300},
+			{351, 430, 301, 400},
+			{431, math.Inf(1), 401, 500},
+		}},
+		PM25: {Unit: MicrogramsPerCubicMeter, Breakpoints: []Breakpoint{
+			{0, 30, 0, 50},
+			{31, 60, 51, 100},
+			{61, 90, 101, 200},
+			{91, 120, 201, 300},
+			{121, 250, 301, 400},
+			{251, math.Inf(1), 401, 500},
+		}},
+		NO2: {Unit: MicrogramsPerCubicMeter, Breakpoints: []Breakpoint{
+			{0, 40, 0, 50},
+			{41, 80, 51, 100},
+			{81, 180, 101, 200},
+			{181, 280, 201, 300},
+			{281, 400, 301, 400},
+			{401, math.Inf(1), 401, 500},
+		}},
+		O3: {Unit: MicrogramsPerCubicMeter, Breakpoints: []Breakpoint{
+			{0, 50, 0, 50},
+			{51, 100, 51, 100},
+			{101, 168, 101, 200},
+			{169, 208, 201, 300},
+			{209, 748, 301, 400},
+			{749, math.Inf(1), 401, 500},
+		}},
+		CO: {Unit: MilligramsPerCubicMeter, Decimals: 1, Breakpoints: []Breakpoint{
+			{0, 1.0, 0, 50},
+			{1.1, 2.0, 51, 100},
+			{2.1, 10, 101, 200},
+			{10.1, 17, 201, 300},
+			{17.1, 34, 301, 400},
+			{34.1, math.Inf(1), 401, 500},
+		}},
+		SO2: {Unit: MicrogramsPerCubicMeter, Breakpoints: []Breakpoint{
+			{0, 40, 0, 50},
+			{41, 80, 51, 100},
+			{81, 380, 101, 200},
+			{381, 800, 201, 300},
+			{801, 1600, 301, 400},
+			{1601, math.Inf(1), 401, 500},
+		}},
+	},
+}
@@ -0,0 +1,70 @@
+package aqi
+
+import "fmt"
+
+type Unit string
+
+const (
+	PPB                     Unit = "ppb"
+	PPM                     Unit = "ppm"
+	MicrogramsPerCubicMeter Unit = "µg/m³"
+	MilligramsPerCubicMeter Unit = "mg/m³"
+)
+
+// Volume of a mole of gas in liters at 25 °C and 1 atm, used by the US EPA conversions
+const molarVolume = 24.45
+
+// Molecular weights of the gases in g/mol
+var molecularWeights = map[Pollutant]float64{
+	CO:  28.01,
+	NO2: 46.01,
+	O3:  48.00,
+	SO2: 64.07,
+}
+
+// Converts the concentration of the pollutant between units. Gases are converted between volume
+// and mass concentrations at 25 °C, particulate matter is only given in mass concentrations.
+func Convert(pollutant Pollutant, concentration float64, from, to Unit) (float64, error) {
+	if from == to {
+		return concentration, nil
+	}
+
+	// Convert to µg/m³, then to the target unit
+	var massConcentration float64
+	switch from {
+	case MicrogramsPerCubicMeter:
+		massConcentration = concentration
+	case MilligramsPerCubicMeter:
+		massConcentration = concentration * 1000
+	case PPB, PPM:
+		weight, ok := molecularWeights[pollutant]
+		if !ok {
+			return 0, fmt.Errorf("%s can not be converted from %s", pollutant, from)
+		}
+		massConcentration = concentration * weight / molarVolume
+		if from == PPM {
+			massConcentration *= 1000
+		}
+	default:
+		return 0, fmt.Errorf("unknown unit %s", from)
+	}
+
+	switch to {
+	case MicrogramsPerCubicMeter:
+		return massConcentration, nil
+	case MilligramsPerCubicMeter:
+		return massConcentration / 1000, nil
+	case PPB, PPM:
+		weight, ok := molecularWeights[pollutant]
+		if !ok {
+			return 0, fmt.Errorf("%s can not be converted to %s", pollutant, to)
+		}
+		volumeConcentration := massConcentration * molarVolume / weight
+		if to == PPM {
+			volumeConcentration /= 1000
+		}
+		return volumeConcentration, nil
+	default:
+		return 0, fmt.Errorf("unknown unit %s", to)
+	}
+}
@@ -0,0 +1,169 @@
+package aqi
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// WindowHours is the number of hours kept by a window, the longest averaging period
+const WindowHours = 24
+
+// Averaging periods in hours of the US EPA AQI: 8-hour O3 and CO, 24-hour PM and 1-hour NO2 and SO2
+var AveragingHours = map[Pollutant]int{
+	CO:   8,
+	NO2:  1,
+	O3:   8,
+	PM10: 24,
+	PM25: 24,
+	SO2:  1,
+}
+
+// HourlyAverage is the average concentration of the readings of each pollutant in an hour
+type HourlyAverage struct {
+	Hour           time.Time             `json:"hour" protobuf:"1"`
+	Concentrations map[Pollutant]float64 `json:"concentrations" protobuf:"2"`
+	Counts         map[Pollutant]int     `json:"counts" protobuf:"3"`
+}
+
+// Window keeps the hourly averages of the readings of the last WindowHours hours
+type Window struct {
+	// Hours from the oldest to the latest
+	Hours []HourlyAverage `json:"hours"`
+}
+
+// Creates a window with a copy of the hourly averages, oldest first
+func NewWindow(hours []HourlyAverage) *Window {
+	window := &Window{}
+	for _, hour := range hours {
+		bucket := HourlyAverage{Hour: hour.Hour, Concentrations: map[Pollutant]float64{}, Counts: map[Pollutant]int{}}
+		for pollutant, concentration := range hour.Concentrations {
+			bucket.Concentrations[pollutant] = concentration
+		}
+		for pollutant, count := range hour.Counts {
+			bucket.Counts[pollutant] = count
+		}
+		window.Hours = append(window.Hours, bucket)
+	}
+	return window
+}
+
+// Adds the readings at the time, dropping the hours out of the window
+func (w *Window) Add(at time.Time, concentrations map[Pollutant]float64) {
+	hour := at.UTC().Truncate(time.Hour)
+
+	i := sort.Search(len(w.Hours), func(i int) bool { return !w.Hours[i].Hour.Before(hour) })
+	if i == len(w.Hours) || !w.Hours[i].Hour.Equal(hour) {
+		bucket := HourlyAverage{Hour: hour, Concentrations: map[Pollutant]float64{}, Counts: map[Pollutant]int{}}
+		w.Hours = append(w.Hours[:i], append([]HourlyAverage{bucket}, w.Hours[i:]...)...)
+	}
+
+	bucket := &w.Hours[i]
+	for pollutant, concentration := range concentrations {
+		count := bucket.Counts[pollutant]
+		average := bucket.Concentrations[pollutant]
+		bucket.Concentrations[pollutant] = average + (concentration-average)/float64(count+1)
+		bucket.Counts[pollutant] = count + 1
+	}
+
+	w.prune(w.Hours[len(w.Hours)-1].Hour)
+}
+
+func (w *Window) prune(latest time.Time) {
+	oldest := latest.Add(-(WindowHours - 1) * time.Hour)
+	i := sort.Search(len(w.Hours), func(i int) bool { return !w.Hours[i].Hour.Before(oldest) })
+	w.Hours = w.Hours[i:]
+}
+
+// Gets the hourly averages of the pollutant in the hours up to the hour of now, the latest first.
+// Missing hours are NaN.
+func (w *Window) hourly(pollutant Pollutant, now time.Time, hours int) []float64 {
+	current := now.UTC().Truncate(time.Hour)
+	values := make([]float64, hours)
+	for i := range values {
+		values[i] = math.NaN()
+	}
+
+	for _, bucket := range w.Hours {
+		age := int(current.Sub(bucket.Hour) / time.Hour)
+		if age < 0 || age >= hours || bucket.Counts[pollutant] == 0 {
+			continue
+		}
+		values[age] = bucket.Concentrations[pollutant]
+	}
+	return values
+}
+
+// Gets the average of the hourly averages of the pollutant in the last hours up to now.
+// The hours without readings are skipped, it is false when there is none.
+func (w *Window) Average(pollutant Pollutant, now time.Time, hours int) (float64, bool) {
+	sum, count := 0.0, 0
+	for _, value := range w.hourly(pollutant, now, hours) {
+		if !math.IsNaN(value) {
+			sum += value
+			count++
+		}
+	}
+
+	if count == 0 {
+		return 0, false
+	}
+	return sum / float64(count), true
+}
+
+// Gets the US EPA NowCast of the particulate matter, weighting the hourly averages of the last 12
+// hours by their age. It needs readings in 2 of the 3 latest hours, it is false otherwise.
+func (w *Window) NowCast(pollutant Pollutant, now time.Time) (float64, bool) {
+	values := w.hourly(pollutant, now, 12)
+
+	recent := 0
+	minimum, maximum := math.Inf(1), math.Inf(-1)
+	for i, value := range values {
+		if math.IsNaN(value) {
+			continue
+		}
+		if i < 3 {
+			recent++
+		}
+		minimum = math.Min(minimum, value)
+		maximum = math.Max(maximum, value)
+	}
+
+	if recent < 2 {
+		return 0, false
+	}
+
+	weight := 1.0
+	if maximum > 0 {
+		weight = math.Max(minimum/maximum, 0.5)
+	}
+
+	sum, weights := 0.0, 0.0
+	for i, value := range values {
+		if !math.IsNaN(value) {
+			sum += math.Pow(weight, float64(i)) * value
+			weights += math.Pow(weight, float64(i))
+		}
+	}
+	return sum / weights, true
+}
+
+// Gets the concentrations the AQI is calculated with at the time, the averages of the AveragingHours
+// of each pollutant. With nowCast the PM concentrations are their NowCast, when there are enough
+// readings for it. Pollutants without readings in their averaging period are left out.
+func (w *Window) Concentrations(now time.Time, nowCast bool) map[Pollutant]float64 {
+	concentrations := map[Pollutant]float64{}
+	for pollutant, hours := range AveragingHours {
+		if nowCast && (pollutant == PM10 || pollutant == PM25) {
+			if value, ok := w.NowCast(pollutant, now); ok {
+				concentrations[pollutant] = value
+				continue
+			}
+		}
+
+		if value, ok := w.Average(pollutant, now, hours); ok {
+			concentrations[pollutant] = value
+		}
+	}
+	return concentrations
+}
@@ -0,0 +1,131 @@
+package aqi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestWindowSuite(t *testing.T) {
+	suite.Run(t, new(WindowSuite))
+}
+
+type WindowSuite struct {
+	suite.Suite
+
+	now time.Time
+}
+
+func (s *WindowSuite) SetupTest() {
+	s.now, _ = time.Parse(time.RFC3339, "2024-01-01T12:30:00Z")
+}
+
+// Adds the readings of the pollutant, the latest first, one per hour up to now
+func (s *WindowSuite) window(pollutant Pollutant, readings ...float64) *Window {
+	window := NewWindow(nil)
+	for age := len(readings) - 1; age >= 0; age-- {
+		window.Add(s.now.Add(-time.Duration(age)*time.Hour), map[Pollutant]float64{pollutant: readings[age]})
+	}
+	return window
+}
+
+func (s *WindowSuite) Test_AddAveragesTheHour() {
+	window := NewWindow(nil)
+	window.Add(s.now, map[Pollutant]float64{CO: 2, O3: 10})
+	window.Add(s.now.Add(10*time.Minute), map[Pollutant]float64{CO: 4})
+
+	s.Require().Len(window.Hours, 1)
+	s.Assert().Equal(s.now.Truncate(time.Hour), window.Hours[0].Hour)
+	s.Assert().Equal(map[Pollutant]float64{CO: 3, O3: 10}, window.Hours[0].Concentrations)
+	s.Assert().Equal(map[Pollutant]int{CO: 2, O3: 1}, window.Hours[0].Counts)
+
+	// Late readings are added to their hour
+	window.Add(s.now.Add(-2*time.Hour), map[Pollutant]float64{CO: 1})
+	s.Require().Len(window.Hours, 2)
+	s.Assert().Equal(s.now.Add(-2*time.Hour).Truncate(time.Hour), window.Hours[0].Hour)
+}
+
+func (s *WindowSuite) Test_AddDropsOldHours() {
+	window := NewWindow(nil)
+	window.Add(s.now.Add(-30*time.Hour), map[Pollutant]float64{PM25: 100})
+	window.Add(s.now.Add(-23*time.Hour), map[Pollutant]float64{PM25: 10})
+	window.Add(s.now, map[Pollutant]float64{PM25: 20})
+
+	s.Assert().Len(window.Hours, 2)
+	average, ok := window.Average(PM25, s.now, 24)
+	s.Assert().True(ok)
+	s.Assert().Equal(15.0, average)
+}
+
+func (s *WindowSuite) Test_Average() {
+	window := s.window(O3, 80, 40, 40, 40, 40, 40, 40, 40, 500)
+
+	average, ok := window.Average(O3, s.now, 8)
+	s.Assert().True(ok)
+	s.Assert().Equal(45.0, average)
+
+	// Hours without readings are skipped
+	window = s.window(CO, 4, 0, 2)
+	window.Hours = append(window.Hours[:1], window.Hours[2:]...)
+	average, ok = window.Average(CO, s.now, 8)
+	s.Assert().True(ok)
+	s.Assert().Equal(3.0, average)
+
+	_, ok = window.Average(NO2, s.now, 1)
+	s.Assert().False(ok)
+}
+
+// Example of the EPA NowCast fact sheet
+func (s *WindowSuite) Test_NowCast() {
+	window := s.window(PM25, 64, 63, 72, 77, 65, 61, 70, 71, 64, 57, 88, 60)
+
+	nowCast, ok := window.NowCast(PM25, s.now)
+	s.Assert().True(ok)
+	s.Assert().InDelta(66.5, nowCast, 0.05)
+
+	// The weight is at least 0.5
+	window = s.window(PM10, 10, 100)
+	nowCast, ok = window.NowCast(PM10, s.now)
+	s.Assert().True(ok)
+	s.Assert().InDelta(40, nowCast, 1e-9)
+}
+
+func (s *WindowSuite) Test_NowCastNeedsRecentReadings() {
+	window := NewWindow(nil)
+	window.Add(s.now, map[Pollutant]float64{PM25: 10})
+	window.Add(s.now.Add(-3*time.Hour), map[Pollutant]float64{PM25: 10})
+
+	_, ok := window.NowCast(PM25, s.now)
+	s.Assert().False(ok)
+
+	window.Add(s.now.Add(-2*time.Hour), map[Pollutant]float64{PM25: 10})
+	_, ok = window.NowCast(PM25, s.now)
+	s.Assert().True(ok)
+}
+
+func (s *WindowSuite) Test_Concentrations() {
+	window := NewWindow(nil)
+	for age := 11; age >= 0; age-- {
+		window.Add(s.now.Add(-time.Duration(age)*time.Hour), map[Pollutant]float64{CO: float64(age), PM25: float64(10 * age)})
+	}
+	window.Add(s.now.Add(-time.Hour), map[Pollutant]float64{SO2: 50})
+
+	concentrations := window.Concentrations(s.now, true)
+	s.Assert().Equal(3.5, concentrations[CO])
+	s.Assert().InDelta(9.97, concentrations[PM25], 0.005)
+	// The 1-hour SO2 has no reading in the current hour
+	s.Assert().NotContains(concentrations, SO2)
+
+	concentrations = window.Concentrations(s.now, false)
+	s.Assert().Equal(55.0, concentrations[PM25])
+}
+
+func (s *WindowSuite) Test_NewWindowCopiesTheHours() {
+	window := s.window(CO, 1)
+	copied := NewWindow(window.Hours)
+	copied.Add(s.now, map[Pollutant]float64{CO: 3})
+
+	s.Assert().Equal(1.0, window.Hours[0].Concentrations[CO])
+	s.Assert().Equal(2.0, copied.Hours[0].Concentrations[CO])
+}
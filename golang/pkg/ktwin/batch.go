@@ -0,0 +1,90 @@
+package ktwin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// EventResult is the processing result of a single event of a batch
+type EventResult struct {
+	ID     string `json:"id"`
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BatchEventError is the error of an event of a batch that was parsed, with the ID of the event
+type BatchEventError struct {
+	ID  string
+	Err error
+}
+
+func (e *BatchEventError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *BatchEventError) Unwrap() error {
+	return e.Err
+}
+
+func IsBatchRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return mediaType == cloudevents.ApplicationCloudEventsBatchJSON
+}
+
+// Creates a TwinEvent from a CloudEvent, parsing its type
+func NewTwinEventFromCloudEvent(cloudEvent *cloudevents.Event) (*TwinEvent, error) {
+	twinEvent := NewTwinEvent()
+	if err := twinEvent.setCloudEvent(cloudEvent); err != nil {
+		return nil, err
+	}
+	return twinEvent, nil
+}
+
+// Parses a batch mode request (application/cloudevents-batch+json).
+// The returned slices are aligned with the batch: for each position either the
+// TwinEvent is set or the error describing why the event is invalid, a BatchEventError
+// when the event could be parsed.
+func HandleBatchRequest(r *http.Request) ([]*TwinEvent, []error, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rawEvents []json.RawMessage
+	if err := json.Unmarshal(body, &rawEvents); err != nil {
+		return nil, nil, fmt.Errorf("invalid cloud event batch: %w", err)
+	}
+
+	twinEvents := make([]*TwinEvent, len(rawEvents))
+	errs := make([]error, len(rawEvents))
+
+	for i, rawEvent := range rawEvents {
+		cloudEvent := cloudevents.NewEvent()
+		if err := json.Unmarshal(rawEvent, &cloudEvent); err != nil {
+			errs[i] = err
+			continue
+		}
+
+		if err := cloudEvent.Validate(); err != nil {
+			errs[i] = &BatchEventError{ID: cloudEvent.ID(), Err: err}
+			continue
+		}
+
+		twinEvent, err := NewTwinEventFromCloudEvent(&cloudEvent)
+		if err != nil {
+			errs[i] = &BatchEventError{ID: cloudEvent.ID(), Err: err}
+			continue
+		}
+		twinEvents[i] = twinEvent
+	}
+
+	return twinEvents, errs, nil
+}
@@ -0,0 +1,138 @@
+package ktwin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"reflect"
+	"strings"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/fxamacker/cbor/v2"
+)
+
+const (
+	ApplicationCBOR     = "application/cbor"
+	ApplicationProtobuf = "application/protobuf"
+)
+
+// Codec encodes and decodes twin data for a given datacontenttype
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// LenientCodec is implemented by the codecs that can also decode data ignoring the attributes
+// that are not declared in the model, as the state stored by a previous model version
+type LenientCodec interface {
+	UnmarshalLenient(data []byte, v interface{}) error
+}
+
+var mapStringInterfaceType = reflect.TypeOf(map[string]interface{}(nil))
+
+var codecs = map[string]Codec{
+	cloudevents.ApplicationJSON: jsonCodec{},
+	"text/json":                 jsonCodec{},
+	ApplicationCBOR:             newCBORCodec(),
+	ApplicationProtobuf:         protobufCodec{},
+	"application/x-protobuf":    protobufCodec{},
+	ApplicationLDJSON:           ngsildCodec{},
+}
+
+// Registers a codec for the content type, replacing any codec previously registered
+func RegisterCodec(contentType string, codec Codec) {
+	codecs[contentType] = codec
+}
+
+// Gets the codec of a datacontenttype. Events without datacontenttype are encoded as JSON.
+func GetCodec(contentType string) (Codec, error) {
+	if contentType == "" {
+		return codecs[cloudevents.ApplicationJSON], nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("invalid content type %s: %w", contentType, err)
+	}
+
+	codec, ok := codecs[strings.ToLower(mediaType)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported content type %s", contentType)
+	}
+
+	return codec, nil
+}
+
+func isJSONContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == cloudevents.ApplicationJSON || mediaType == "text/json")
+}
+
+func isNGSILDContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == ApplicationLDJSON
+}
+
+// JSON codec, rejecting attributes that are not declared in the model
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+func (jsonCodec) UnmarshalLenient(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// CBOR codec, using the model JSON field names as CBOR map keys
+
+type cborCodec struct {
+	encMode        cbor.EncMode
+	decMode        cbor.DecMode
+	lenientDecMode cbor.DecMode
+}
+
+func newCBORCodec() cborCodec {
+	encMode, err := cbor.EncOptions{Time: cbor.TimeRFC3339Nano}.EncMode()
+	if err != nil {
+		panic(err)
+	}
+
+	decMode, err := cbor.DecOptions{
+		DefaultMapType:    mapStringInterfaceType,
+		ExtraReturnErrors: cbor.ExtraDecErrorUnknownField,
+	}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+
+	lenientDecMode, err := cbor.DecOptions{DefaultMapType: mapStringInterfaceType}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+
+	return cborCodec{encMode: encMode, decMode: decMode, lenientDecMode: lenientDecMode}
+}
+
+func (c cborCodec) Marshal(v interface{}) ([]byte, error) {
+	return c.encMode.Marshal(v)
+}
+
+func (c cborCodec) Unmarshal(data []byte, v interface{}) error {
+	return c.decMode.Unmarshal(data, v)
+}
+
+func (c cborCodec) UnmarshalLenient(data []byte, v interface{}) error {
+	return c.lenientDecMode.Unmarshal(data, v)
+}
@@ -0,0 +1,447 @@
+package ktwin
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Protobuf codec for the twin models.
+//
+// Models are encoded as protobuf messages without generated code: the field number of
+// each attribute is set by its protobuf:"N" tag, and protobuf:"-" leaves it out. Every
+// exported attribute must have a tag, and the numbers of a model must not change nor be
+// reused to stay wire compatible. Fields of unknown numbers, sent by newer versions of a
+// model, are skipped.
+//
+// The equivalent .proto types are: string -> string, float64 -> double, float32 -> float,
+// int -> int64, bool -> bool, time.Time -> google.protobuf.Timestamp, slices -> repeated
+// fields, maps -> map fields, repeated entries with the key as field 1 and the value as
+// field 2, and nested structs -> embedded messages.
+
+var timeType = reflect.TypeOf(time.Time{})
+
+type protobufCodec struct{}
+
+type protobufField struct {
+	number protowire.Number
+	index  int
+	name   string
+}
+
+// Fields of the model types by type, parsed once
+var protobufFieldsCache sync.Map
+
+// Gets the fields of the model type from their protobuf tags
+func protobufFields(structType reflect.Type) ([]protobufField, error) {
+	if fields, ok := protobufFieldsCache.Load(structType); ok {
+		return fields.([]protobufField), nil
+	}
+
+	var fields []protobufField
+	fieldsByNumber := map[protowire.Number]string{}
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag, ok := field.Tag.Lookup("protobuf")
+		if !ok {
+			return nil, fmt.Errorf("%s.%s has no protobuf tag", structType, field.Name)
+		}
+		if tag == "-" {
+			continue
+		}
+
+		number, err := strconv.Atoi(tag)
+		if err != nil || !protowire.Number(number).IsValid() {
+			return nil, fmt.Errorf("%s.%s has an invalid protobuf field number %q", structType, field.Name, tag)
+		}
+		if other, ok := fieldsByNumber[protowire.Number(number)]; ok {
+			return nil, fmt.Errorf("%s.%s reuses the protobuf field number %d of %s", structType, field.Name, number, other)
+		}
+		fieldsByNumber[protowire.Number(number)] = field.Name
+
+		fields = append(fields, protobufField{
+			number: protowire.Number(number),
+			index:  i,
+			name:   jsonFieldName(field),
+		})
+	}
+
+	protobufFieldsCache.Store(structType, fields)
+	return fields, nil
+}
+
+func (protobufCodec) Marshal(v interface{}) ([]byte, error) {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil, nil
+		}
+		value = value.Elem()
+	}
+
+	if value.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("protobuf codec only encodes models, got %s", value.Type())
+	}
+
+	return appendMessage(nil, value)
+}
+
+func (protobufCodec) Unmarshal(data []byte, v interface{}) error {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return errors.New("protobuf codec only decodes into a pointer to a model")
+	}
+
+	return consumeMessage(data, value.Elem())
+}
+
+func appendMessage(b []byte, value reflect.Value) ([]byte, error) {
+	fields, err := protobufFields(value.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, field := range fields {
+		fieldValue := value.Field(field.index)
+		if fieldValue.IsZero() {
+			continue
+		}
+		b, err = appendField(b, field.number, fieldValue)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", field.name, err)
+		}
+	}
+	return b, nil
+}
+
+func appendField(b []byte, number protowire.Number, value reflect.Value) ([]byte, error) {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return b, nil
+		}
+		value = value.Elem()
+	}
+
+	if value.Type() == timeType {
+		t := value.Interface().(time.Time)
+		var timestamp []byte
+		timestamp = protowire.AppendTag(timestamp, 1, protowire.VarintType)
+		timestamp = protowire.AppendVarint(timestamp, uint64(t.Unix()))
+		timestamp = protowire.AppendTag(timestamp, 2, protowire.VarintType)
+		timestamp = protowire.AppendVarint(timestamp, uint64(t.Nanosecond()))
+		b = protowire.AppendTag(b, number, protowire.BytesType)
+		return protowire.AppendBytes(b, timestamp), nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		b = protowire.AppendTag(b, number, protowire.BytesType)
+		return protowire.AppendString(b, value.String()), nil
+	case reflect.Bool:
+		b = protowire.AppendTag(b, number, protowire.VarintType)
+		return protowire.AppendVarint(b, protowire.EncodeBool(value.Bool())), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		b = protowire.AppendTag(b, number, protowire.VarintType)
+		return protowire.AppendVarint(b, uint64(value.Int())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		b = protowire.AppendTag(b, number, protowire.VarintType)
+		return protowire.AppendVarint(b, value.Uint()), nil
+	case reflect.Float32:
+		b = protowire.AppendTag(b, number, protowire.Fixed32Type)
+		return protowire.AppendFixed32(b, math.Float32bits(float32(value.Float()))), nil
+	case reflect.Float64:
+		b = protowire.AppendTag(b, number, protowire.Fixed64Type)
+		return protowire.AppendFixed64(b, math.Float64bits(value.Float())), nil
+	case reflect.Slice:
+		var err error
+		for i := 0; i < value.Len(); i++ {
+			b, err = appendField(b, number, value.Index(i))
+			if err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case reflect.Map:
+		for _, key := range sortedMapKeys(value) {
+			entry, err := appendField(nil, 1, key)
+			if err != nil {
+				return nil, err
+			}
+			entry, err = appendField(entry, 2, value.MapIndex(key))
+			if err != nil {
+				return nil, err
+			}
+			b = protowire.AppendTag(b, number, protowire.BytesType)
+			b = protowire.AppendBytes(b, entry)
+		}
+		return b, nil
+	case reflect.Struct:
+		message, err := appendMessage(nil, value)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, number, protowire.BytesType)
+		return protowire.AppendBytes(b, message), nil
+	default:
+		return nil, fmt.Errorf("unsupported protobuf type %s", value.Type())
+	}
+}
+
+func consumeMessage(data []byte, value reflect.Value) error {
+	fields, err := protobufFields(value.Type())
+	if err != nil {
+		return err
+	}
+
+	fieldsByNumber := map[protowire.Number]protobufField{}
+	for _, field := range fields {
+		fieldsByNumber[field.number] = field
+	}
+
+	for len(data) > 0 {
+		number, wireType, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		field, ok := fieldsByNumber[number]
+		if !ok {
+			// Fields of newer versions of the model are skipped, as protobuf decoders do
+			n, err := skipField(number, wireType, data)
+			if err != nil {
+				return err
+			}
+			data = data[n:]
+			continue
+		}
+
+		n, err := consumeField(data, wireType, value.Field(field.index))
+		if err != nil {
+			return fmt.Errorf("%s: %w", field.name, err)
+		}
+		data = data[n:]
+	}
+
+	return nil
+}
+
+func consumeField(data []byte, wireType protowire.Type, value reflect.Value) (int, error) {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		value = value.Elem()
+	}
+
+	if value.Type() == timeType {
+		timestamp, n, err := consumeBytes(data, wireType)
+		if err != nil {
+			return 0, err
+		}
+		t, err := consumeTimestamp(timestamp)
+		if err != nil {
+			return 0, err
+		}
+		value.Set(reflect.ValueOf(t))
+		return n, nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		s, n, err := consumeBytes(data, wireType)
+		if err != nil {
+			return 0, err
+		}
+		value.SetString(string(s))
+		return n, nil
+	case reflect.Bool:
+		v, n, err := consumeVarint(data, wireType)
+		if err != nil {
+			return 0, err
+		}
+		value.SetBool(protowire.DecodeBool(v))
+		return n, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, n, err := consumeVarint(data, wireType)
+		if err != nil {
+			return 0, err
+		}
+		value.SetInt(int64(v))
+		return n, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, n, err := consumeVarint(data, wireType)
+		if err != nil {
+			return 0, err
+		}
+		value.SetUint(v)
+		return n, nil
+	case reflect.Float32:
+		if wireType != protowire.Fixed32Type {
+			return 0, fmt.Errorf("expected fixed32 wire type, got %d", wireType)
+		}
+		v, n := protowire.ConsumeFixed32(data)
+		if n < 0 {
+			return 0, protowire.ParseError(n)
+		}
+		value.SetFloat(float64(math.Float32frombits(v)))
+		return n, nil
+	case reflect.Float64:
+		if wireType != protowire.Fixed64Type {
+			return 0, fmt.Errorf("expected fixed64 wire type, got %d", wireType)
+		}
+		v, n := protowire.ConsumeFixed64(data)
+		if n < 0 {
+			return 0, protowire.ParseError(n)
+		}
+		value.SetFloat(math.Float64frombits(v))
+		return n, nil
+	case reflect.Slice:
+		element := reflect.New(value.Type().Elem()).Elem()
+		n, err := consumeField(data, wireType, element)
+		if err != nil {
+			return 0, err
+		}
+		value.Set(reflect.Append(value, element))
+		return n, nil
+	case reflect.Map:
+		entry, n, err := consumeBytes(data, wireType)
+		if err != nil {
+			return 0, err
+		}
+		if value.IsNil() {
+			value.Set(reflect.MakeMap(value.Type()))
+		}
+		key := reflect.New(value.Type().Key()).Elem()
+		element := reflect.New(value.Type().Elem()).Elem()
+		if err := consumeMapEntry(entry, key, element); err != nil {
+			return 0, err
+		}
+		value.SetMapIndex(key, element)
+		return n, nil
+	case reflect.Struct:
+		message, n, err := consumeBytes(data, wireType)
+		if err != nil {
+			return 0, err
+		}
+		return n, consumeMessage(message, value)
+	default:
+		return 0, fmt.Errorf("unsupported protobuf type %s", value.Type())
+	}
+}
+
+func consumeMapEntry(data []byte, key, element reflect.Value) error {
+	for len(data) > 0 {
+		number, wireType, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		var err error
+		switch number {
+		case 1:
+			n, err = consumeField(data, wireType, key)
+		case 2:
+			n, err = consumeField(data, wireType, element)
+		default:
+			n, err = skipField(number, wireType, data)
+		}
+		if err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+// Gets the keys of the map in order, so the same map is always encoded the same way
+func sortedMapKeys(value reflect.Value) []reflect.Value {
+	keys := value.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		switch keys[i].Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return keys[i].Int() < keys[j].Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return keys[i].Uint() < keys[j].Uint()
+		default:
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		}
+	})
+	return keys
+}
+
+// Gets the length of the value of a field that is not in the model
+func skipField(number protowire.Number, wireType protowire.Type, data []byte) (int, error) {
+	n := protowire.ConsumeFieldValue(number, wireType, data)
+	if n < 0 {
+		return 0, protowire.ParseError(n)
+	}
+	return n, nil
+}
+
+func consumeBytes(data []byte, wireType protowire.Type) ([]byte, int, error) {
+	if wireType != protowire.BytesType {
+		return nil, 0, fmt.Errorf("expected bytes wire type, got %d", wireType)
+	}
+	v, n := protowire.ConsumeBytes(data)
+	if n < 0 {
+		return nil, 0, protowire.ParseError(n)
+	}
+	return v, n, nil
+}
+
+func consumeVarint(data []byte, wireType protowire.Type) (uint64, int, error) {
+	if wireType != protowire.VarintType {
+		return 0, 0, fmt.Errorf("expected varint wire type, got %d", wireType)
+	}
+	v, n := protowire.ConsumeVarint(data)
+	if n < 0 {
+		return 0, 0, protowire.ParseError(n)
+	}
+	return v, n, nil
+}
+
+func consumeTimestamp(data []byte) (time.Time, error) {
+	var seconds, nanos uint64
+	for len(data) > 0 {
+		number, wireType, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return time.Time{}, protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		if number != 1 && number != 2 {
+			n, err := skipField(number, wireType, data)
+			if err != nil {
+				return time.Time{}, err
+			}
+			data = data[n:]
+			continue
+		}
+
+		v, n, err := consumeVarint(data, wireType)
+		if err != nil {
+			return time.Time{}, err
+		}
+		data = data[n:]
+
+		if number == 1 {
+			seconds = v
+		} else {
+			nanos = v
+		}
+	}
+	return time.Unix(int64(seconds), int64(nanos)).UTC(), nil
+}
@@ -0,0 +1,189 @@
+package ktwin
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestCodecSuite(t *testing.T) {
+	suite.Run(t, new(CodecSuite))
+}
+
+type CodecSuite struct {
+	suite.Suite
+}
+
+type codecModel struct {
+	Name         string                  `json:"name,omitempty" protobuf:"1"`
+	Level        float64                 `json:"level,omitempty" validate:"min=0,max=100" protobuf:"2"`
+	Frequency    int                     `json:"frequency,omitempty" protobuf:"3"`
+	Enabled      bool                    `json:"enabled,omitempty" protobuf:"4"`
+	Tags         []string                `json:"tags,omitempty" protobuf:"5"`
+	DateObserved *time.Time              `json:"dateObserved,omitempty" protobuf:"6"`
+	Levels       map[string]float64      `json:"levels,omitempty" protobuf:"7"`
+	Children     map[string]codecChild   `json:"children,omitempty" protobuf:"8"`
+	Groups       map[string][]string     `json:"groups,omitempty" protobuf:"9"`
+	Nested       map[string]map[int]bool `json:"nested,omitempty" protobuf:"10"`
+}
+
+type codecChild struct {
+	Name  string `json:"name,omitempty" protobuf:"1"`
+	Level int    `json:"level,omitempty" protobuf:"2"`
+}
+
+func (s *CodecSuite) buildTwinEvent(contentType string, model interface{}) *TwinEvent {
+	cloudEvent, err := BuildCloudEventWithContentType("ktwin.real.ngsi-ld-city-device", "ngsi-ld-city-device-nb001-ofp0003-s0012", contentType, model)
+	s.Require().NoError(err)
+
+	twinEvent, err := NewTwinEventFromCloudEvent(cloudEvent)
+	s.Require().NoError(err)
+	return twinEvent
+}
+
+func (s *CodecSuite) Test_RoundTrip() {
+	dateObserved := time.Date(2023, 6, 1, 10, 30, 0, 500, time.UTC)
+	expected := codecModel{
+		Name:         "device",
+		Level:        42.5,
+		Frequency:    15,
+		Enabled:      true,
+		Tags:         []string{"a", "b"},
+		DateObserved: &dateObserved,
+		Levels:       map[string]float64{"pm25": 12.5, "zero": 0},
+		Children:     map[string]codecChild{"a": {Name: "child", Level: 2}, "empty": {}},
+		Groups:       map[string][]string{"a": {"x", "y"}},
+		Nested:       map[string]map[int]bool{"a": {1: true, 2: false}},
+	}
+
+	for _, contentType := range []string{cloudevents.ApplicationJSON, ApplicationCBOR, ApplicationProtobuf} {
+		s.Run(contentType, func() {
+			twinEvent := s.buildTwinEvent(contentType, expected)
+			s.Assert().Equal(contentType, twinEvent.CloudEvent.DataContentType())
+
+			actual := codecModel{}
+			s.Assert().NoError(twinEvent.ToModel(&actual))
+			s.Assert().Equal(expected, actual)
+		})
+	}
+}
+
+func (s *CodecSuite) Test_ValidationWithBinaryCodec() {
+	twinEvent := s.buildTwinEvent(ApplicationCBOR, map[string]interface{}{"level": 150})
+
+	err := twinEvent.ToModel(&codecModel{})
+
+	var validationError *ValidationError
+	s.Require().ErrorAs(err, &validationError)
+	s.Assert().Equal("max", validationError.Violations[0].Rule)
+}
+
+func (s *CodecSuite) Test_ToStoredModelIsLenient() {
+	stored := map[string]interface{}{"name": "device", "level": 150, "removedAttribute": "value"}
+
+	for _, contentType := range []string{cloudevents.ApplicationJSON, ApplicationCBOR, ApplicationLDJSON} {
+		s.Run(contentType, func() {
+			twinEvent := s.buildTwinEvent(contentType, stored)
+			s.Assert().Error(twinEvent.ToModel(&codecModel{}))
+
+			actual := codecModel{}
+			s.Assert().NoError(twinEvent.ToStoredModel(&actual))
+			s.Assert().Equal(codecModel{Name: "device", Level: 150}, actual)
+		})
+	}
+}
+
+func (s *CodecSuite) Test_UnsupportedContentType() {
+	twinEvent := s.buildTwinEvent(cloudevents.ApplicationJSON, codecModel{Level: 10})
+	twinEvent.CloudEvent.SetDataContentType("application/xml")
+
+	err := twinEvent.ToModel(&codecModel{})
+
+	var validationError *ValidationError
+	s.Require().ErrorAs(err, &validationError)
+	s.Assert().Equal("contentType", validationError.Violations[0].Rule)
+}
+
+func (s *CodecSuite) Test_TranscodeData() {
+	twinEvent := s.buildTwinEvent(ApplicationProtobuf, codecModel{Name: "device", Level: 10})
+
+	model := codecModel{}
+	s.Require().NoError(twinEvent.ToModel(&model))
+	model.Frequency = 60
+	s.Require().NoError(twinEvent.SetData(model))
+	s.Assert().Equal(ApplicationProtobuf, twinEvent.CloudEvent.DataContentType())
+
+	s.Require().NoError(twinEvent.TranscodeData(cloudevents.ApplicationJSON))
+	s.Assert().Equal(cloudevents.ApplicationJSON, twinEvent.CloudEvent.DataContentType())
+
+	var actual map[string]interface{}
+	s.Assert().NoError(json.Unmarshal(twinEvent.CloudEvent.Data(), &actual))
+	s.Assert().Equal(map[string]interface{}{"name": "device", "level": 10.0, "frequency": 60.0}, actual)
+}
+
+func (s *CodecSuite) Test_ProtobufFieldNumbers() {
+	type reorderedModel struct {
+		Level    float64 `json:"level,omitempty" protobuf:"2"`
+		Internal string  `json:"internal,omitempty" protobuf:"-"`
+		Name     string  `json:"name,omitempty" protobuf:"1"`
+	}
+
+	codec, err := GetCodec(ApplicationProtobuf)
+	s.Require().NoError(err)
+
+	// The field numbers come from the tags, not from the position of the attributes
+	data, err := codec.Marshal(codecModel{Name: "device", Level: 10})
+	s.Require().NoError(err)
+	actual := reorderedModel{}
+	s.Require().NoError(codec.Unmarshal(data, &actual))
+	s.Assert().Equal(reorderedModel{Name: "device", Level: 10}, actual)
+
+	_, err = codec.Marshal(reorderedModel{Internal: "secret"})
+	s.Assert().NoError(err)
+
+	type missingTagModel struct {
+		Name  string `json:"name,omitempty" protobuf:"1"`
+		Level int    `json:"level,omitempty"`
+	}
+	_, err = codec.Marshal(missingTagModel{Name: "device"})
+	s.Assert().ErrorContains(err, "Level has no protobuf tag")
+
+	type duplicateTagModel struct {
+		Name  string `json:"name,omitempty" protobuf:"1"`
+		Level int    `json:"level,omitempty" protobuf:"1"`
+	}
+	s.Assert().ErrorContains(codec.Unmarshal(data, &duplicateTagModel{}), "Level reuses the protobuf field number 1 of Name")
+
+	type invalidTagModel struct {
+		Name string `json:"name,omitempty" protobuf:"0"`
+	}
+	s.Assert().ErrorContains(codec.Unmarshal(data, &invalidTagModel{}), "invalid protobuf field number")
+}
+
+// Devices with a newer version of the model send fields this version does not know
+func (s *CodecSuite) Test_ProtobufSkipsUnknownFields() {
+	codec, err := GetCodec(ApplicationProtobuf)
+	s.Require().NoError(err)
+
+	data, err := codec.Marshal(codecModel{Name: "device", Level: 10})
+	s.Require().NoError(err)
+	data = protowire.AppendTag(data, 100, protowire.VarintType)
+	data = protowire.AppendVarint(data, 7)
+	data = protowire.AppendTag(data, 101, protowire.BytesType)
+	data = protowire.AppendString(data, "newer")
+	data = protowire.AppendTag(data, 102, protowire.Fixed64Type)
+	data = protowire.AppendFixed64(data, 1)
+	data = protowire.AppendTag(data, 3, protowire.VarintType)
+	data = protowire.AppendVarint(data, 15)
+
+	actual := codecModel{}
+	s.Require().NoError(codec.Unmarshal(data, &actual))
+	s.Assert().Equal(codecModel{Name: "device", Level: 10, Frequency: 15}, actual)
+
+	// A truncated unknown field is still an error
+	s.Assert().Error(codec.Unmarshal(append(data, protowire.AppendTag(nil, 103, protowire.BytesType)...), &actual))
+}
@@ -2,7 +2,6 @@ package ktwin
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,13 +10,12 @@ import (
 
 	"log"
 	"net/http"
-	"os"
-	"strings"
 
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/clock"
-	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/uuid"
 	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/types"
 )
 
 const (
@@ -25,22 +23,49 @@ const (
 	EventVirtualGenerated = "ktwin.virtual.%s"
 	EventCommandExecuted  = "ktwin.command.%s.%s"
 	EventStoreGenerated   = "ktwin.store.%s"
+	EventValidationFailed = "ktwin.validation.%s"
+	EventTimerFired       = "ktwin.timer.%s.%s"
 )
 
+// CloudEvent extension of the commands with the twin instance whose event caused them
+const CausationExtension = "ktwincausation"
+
 func GetEventStoreURL() string {
-	return os.Getenv("KTWIN_EVENT_STORE")
+	return config.Get().EventStore
 }
 
 func GetBrokerURL() string {
-	return os.Getenv("KTWIN_BROKER")
+	return config.Get().Broker
+}
+
+// Publisher intercepts the published events before they are posted, returning whether
+// it handled the event. It allows events to stay in process when services run together.
+type Publisher func(event *cloudevents.Event) (bool, error)
+
+var publisher Publisher
+
+func SetPublisher(p Publisher) {
+	publisher = p
 }
 
+func ResetPublisher() {
+	publisher = nil
+}
+
+// Publishes the event to the url through the sink, unless the publisher handles it
 func PostCloudEvent(event *cloudevents.Event, url string) error {
-	if os.Getenv("ENV") == "local" {
-		return nil
+	if publisher != nil {
+		if handled, err := publisher(event); handled {
+			return err
+		}
 	}
 
-	client := NewClient()
+	return GetSink().Publish(event, url)
+}
+
+// Posts the event to the url, whatever the sink
+func SendCloudEvent(event *cloudevents.Event, url string) error {
+	client := NewClientWithMode(GetCloudEventMode())
 	response, err := client.Post(url, event)
 
 	if err != nil {
@@ -60,34 +85,44 @@ func PostCloudEvent(event *cloudevents.Event, url string) error {
 	return fmt.Errorf("error to publish cloud event. status code: %d. response body: %s", response.StatusCode, string(body))
 }
 
+// Publishes all events in a single batch mode request, returning the result of each event
+func PostCloudEventBatch(events []*cloudevents.Event, url string) ([]EventResult, error) {
+	return GetSink().PublishBatch(events, url)
+}
+
 func GetCloudEvent(cloudEvent *cloudevents.Event, url string) (*cloudevents.Event, error) {
-	if os.Getenv("ENV") == "local" {
-		return cloudEvent, nil
-	}
+	return GetSink().Request(cloudEvent, url)
+}
 
-	ctx := cloudevents.ContextWithTarget(context.Background(), GetBrokerURL())
+// Mode is the CloudEvents HTTP content mode used to send events
+type Mode string
 
-	c, err := cloudevents.NewClientHTTP()
-	if err != nil {
-		logger.NewLogger().Error("failed to create client", err)
-		return nil, err
-	}
+const (
+	BinaryMode     Mode = "binary"
+	StructuredMode Mode = "structured"
+)
 
-	var event *cloudevents.Event
-	if event, err = c.Request(ctx, *cloudEvent); err != nil {
-		return nil, errors.New("Error to get Cloud Event: " + err.Error())
+// The content mode is set by the eventMode config, binary mode is used by default
+func GetCloudEventMode() Mode {
+	if Mode(config.Get().EventMode) == StructuredMode {
+		return StructuredMode
 	}
-	return event, nil
-
+	return BinaryMode
 }
 
 type Client struct {
 	client http.Client
+	mode   Mode
 }
 
 func NewClient() Client {
+	return NewClientWithMode(BinaryMode)
+}
+
+func NewClientWithMode(mode Mode) Client {
 	return Client{
 		client: http.Client{},
+		mode:   mode,
 	}
 }
 
@@ -99,10 +134,30 @@ func (c *Client) Post(url string, event *cloudevents.Event) (*http.Response, err
 	return c.client.Do(req)
 }
 
+func (c *Client) PostBatch(url string, events []*cloudevents.Event) (*http.Response, error) {
+	req, err := c.createBatchRequest(url, events)
+	if err != nil {
+		return nil, err
+	}
+	return c.client.Do(req)
+}
+
 func (c *Client) createRequest(url string, cloudEvent *cloudevents.Event) (*http.Request, error) {
-	req, _ := http.NewRequest("POST", url, bytes.NewBuffer(cloudEvent.Data()))
+	if c.mode == StructuredMode {
+		return c.createStructuredRequest(url, cloudEvent)
+	}
 
-	req.Header.Set("Content-Type", "application/json")
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(cloudEvent.Data()))
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := cloudEvent.DataContentType()
+	if contentType == "" {
+		contentType = cloudevents.ApplicationJSON
+	}
+
+	req.Header.Set("Content-Type", contentType)
 	req.Header.Set("ce-id", cloudEvent.ID())
 	req.Header.Set("ce-specversion", cloudEvent.SpecVersion())
 	req.Header.Set("ce-time", cloudEvent.Time().Format(time.RFC3339))
@@ -110,6 +165,48 @@ func (c *Client) createRequest(url string, cloudEvent *cloudevents.Event) (*http
 	req.Header.Set("ce-type", cloudEvent.Type())
 	req.Header.Set("ce-subject", cloudEvent.Subject())
 
+	if cloudEvent.DataSchema() != "" {
+		req.Header.Set("ce-dataschema", cloudEvent.DataSchema())
+	}
+
+	for name, value := range cloudEvent.Extensions() {
+		formattedValue, err := types.Format(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extension attribute %s: %w", name, err)
+		}
+		req.Header.Set("ce-"+name, formattedValue)
+	}
+
+	return req, nil
+}
+
+func (c *Client) createStructuredRequest(url string, cloudEvent *cloudevents.Event) (*http.Request, error) {
+	body, err := json.Marshal(cloudEvent)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", cloudevents.ApplicationCloudEventsJSON)
+	return req, nil
+}
+
+func (c *Client) createBatchRequest(url string, cloudEvents []*cloudevents.Event) (*http.Request, error) {
+	body, err := json.Marshal(cloudEvents)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", cloudevents.ApplicationCloudEventsBatchJSON)
 	return req, nil
 }
 
@@ -118,9 +215,12 @@ func (c *Client) createRequest(url string, cloudEvent *cloudevents.Event) (*http
 type EventType string
 
 const (
-	RealEvent    EventType = "real"
-	VirtualEvent EventType = "virtual"
-	CommandEvent EventType = "command"
+	RealEvent       EventType = "real"
+	VirtualEvent    EventType = "virtual"
+	CommandEvent    EventType = "command"
+	StoreEvent      EventType = "store"
+	ValidationEvent EventType = "validation"
+	TimerEvent      EventType = "timer"
 )
 
 type TwinEvent struct {
@@ -130,9 +230,16 @@ type TwinEvent struct {
 	EventType     EventType
 	TwinInterface string
 	CommandName   string
+	TimerName     string
 
 	// The Source of the CloudEvent
 	TwinInstance string
+
+	// The model last decoded from or encoded into the CloudEvent data
+	model interface{}
+
+	// The NGSI-LD entity of the CloudEvent data, when it is encoded as application/ld+json
+	ngsildEntity *NGSILDEntity
 }
 
 func NewTwinEvent() *TwinEvent {
@@ -142,6 +249,7 @@ func NewTwinEvent() *TwinEvent {
 // Real Event Type: ktwin.real.<twin-interface>
 // Virtual Event Type: ktwin.virtual.<twin-interface>
 // Command Event Type: ktwin.command.<twin-interface>.<command-name>
+// Timer Event Type: ktwin.timer.<twin-interface>.<timer-name>
 func (e *TwinEvent) HandleRequest(r *http.Request) error {
 	cloudEvent, err := cloudevents.NewEventFromHTTPRequest(r)
 	if err != nil {
@@ -149,25 +257,21 @@ func (e *TwinEvent) HandleRequest(r *http.Request) error {
 		return err
 	}
 
-	ceType := strings.Split(cloudEvent.Type(), ".")
-	e.EventType = EventType(ceType[1])
-	e.TwinInterface = ceType[2]
-	e.TwinInstance = cloudEvent.Source()
-	e.CloudEvent = cloudEvent
-
-	if len(ceType) > 3 {
-		e.CommandName = ceType[3]
-	}
+	return e.setCloudEvent(cloudEvent)
+}
 
-	if e.EventType == "" {
-		return errors.New("event type not found")
+// Gets the twin instance that caused the command, empty when it is not known
+func (k *TwinEvent) Causation() string {
+	if k.CloudEvent == nil {
+		return ""
 	}
 
-	if e.EventType == CommandEvent && e.CommandName == "" {
-		return errors.New("command name not found")
+	causation, ok := k.CloudEvent.Extensions()[CausationExtension]
+	if !ok {
+		return ""
 	}
-
-	return nil
+	value, _ := types.ToString(causation)
+	return value
 }
 
 func (k *TwinEvent) HandleResponse(r *http.Response) error {
@@ -176,31 +280,186 @@ func (k *TwinEvent) HandleResponse(r *http.Response) error {
 		log.Printf("failed to parse CloudEvent from request: %v", err)
 		return err
 	}
-	ceType := strings.Split(cloudEvent.Type(), ".")
+
+	return k.setCloudEvent(cloudEvent)
+}
+
+func (k *TwinEvent) setCloudEvent(cloudEvent *cloudevents.Event) error {
+	twinEventType, err := ParseEventType(cloudEvent.Type())
+	if err != nil {
+		return err
+	}
+
+	k.EventType = twinEventType.EventType
+	k.TwinInterface = twinEventType.TwinInterface
+	k.CommandName = twinEventType.CommandName
+	k.TimerName = twinEventType.TimerName
 	k.TwinInstance = cloudEvent.Source()
-	k.EventType = EventType(ceType[1])
-	k.TwinInterface = ceType[2]
 	k.CloudEvent = cloudEvent
 	return nil
 }
 
+// Decodes the event data into the model using the codec of its datacontenttype, rejecting
+// unknown attributes, wrong types and values that do not satisfy the model constraints
+// with a ValidationError
 func (k *TwinEvent) ToModel(model interface{}) error {
-	err := json.Unmarshal(k.CloudEvent.Data(), model)
+	codec, err := GetCodec(k.CloudEvent.DataContentType())
+	if err != nil {
+		return k.newValidationError([]FieldViolation{{Rule: "contentType", Message: err.Error()}})
+	}
+
+	if _, ok := codec.(ngsildCodec); ok {
+		// Keep the NGSI-LD metadata to write it back when the model is set
+		k.ngsildEntity, err = DecodeNGSILD(k.CloudEvent.Data(), model)
+	} else {
+		err = codec.Unmarshal(k.CloudEvent.Data(), model)
+	}
+
+	if err != nil {
+		return k.newValidationError([]FieldViolation{decodeViolation(err)})
+	}
+
+	if violations := Validate(model); len(violations) > 0 {
+		return k.newValidationError(violations)
+	}
+
+	k.model = model
+	return nil
+}
+
+// Decodes the stored state of a twin into the model. Unlike ToModel, attributes that are not
+// declared in the model are ignored and the model constraints are not checked, so the state
+// written by a previous model version does not fail the processing of new events.
+func (k *TwinEvent) ToStoredModel(model interface{}) error {
+	codec, err := GetCodec(k.CloudEvent.DataContentType())
 	if err != nil {
 		return err
 	}
+
+	if _, ok := codec.(ngsildCodec); ok {
+		k.ngsildEntity, err = decodeNGSILD(k.CloudEvent.Data(), model, (jsonCodec{}).UnmarshalLenient)
+	} else if lenientCodec, ok := codec.(LenientCodec); ok {
+		err = lenientCodec.UnmarshalLenient(k.CloudEvent.Data(), model)
+	} else {
+		err = codec.Unmarshal(k.CloudEvent.Data(), model)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	k.model = model
 	return nil
 }
 
+func (k *TwinEvent) newValidationError(violations []FieldViolation) *ValidationError {
+	return &ValidationError{
+		TwinInterface: k.TwinInterface,
+		TwinInstance:  k.TwinInstance,
+		Violations:    violations,
+	}
+}
+
+// Encodes the model as the event data, keeping the datacontenttype of the event
+// (JSON when it is not set)
 func (k *TwinEvent) SetData(model interface{}) error {
-	return k.CloudEvent.SetData(cloudevents.ApplicationJSON, model)
+	contentType := k.CloudEvent.DataContentType()
+	if contentType == "" {
+		contentType = cloudevents.ApplicationJSON
+	}
+
+	if k.ngsildEntity != nil && isNGSILDContentType(contentType) {
+		encoded, err := EncodeNGSILD(model, k.ngsildEntity)
+		if err != nil {
+			return err
+		}
+		if err := k.CloudEvent.SetData(contentType, encoded); err != nil {
+			return err
+		}
+	} else if err := setEventData(k.CloudEvent, contentType, model); err != nil {
+		return err
+	}
+
+	k.model = model
+	return nil
+}
+
+// Gets the NGSI-LD entity the event data was decoded from, with the attribute metadata
+// (observedAt, unitCode, Relationships) of the real twin. It is nil for other encodings.
+func (k *TwinEvent) NGSILDEntity() *NGSILDEntity {
+	return k.ngsildEntity
 }
 
-func (ktwinEvent *TwinEvent) SetEvent(twinInterface, twinInstance string, eventType EventType, data interface{}) {
+// Re-encodes the event data with the codec of the content type. The model last decoded
+// or set is used when available, otherwise JSON, CBOR and NGSI-LD data are decoded generically.
+func (k *TwinEvent) TranscodeData(contentType string) error {
+	currentContentType := k.CloudEvent.DataContentType()
+	if currentContentType == contentType {
+		return nil
+	}
+
+	data := k.model
+	if data == nil {
+		if len(k.CloudEvent.Data()) == 0 {
+			k.CloudEvent.SetDataContentType(contentType)
+			return nil
+		}
+
+		if isJSONContentType(currentContentType) || currentContentType == ApplicationCBOR {
+			codec, err := GetCodec(currentContentType)
+			if err != nil {
+				return err
+			}
+
+			var generic map[string]interface{}
+			if err := codec.Unmarshal(k.CloudEvent.Data(), &generic); err != nil {
+				return err
+			}
+			data = generic
+		} else if isNGSILDContentType(currentContentType) {
+			entity := &NGSILDEntity{}
+			if err := json.Unmarshal(k.CloudEvent.Data(), entity); err != nil {
+				return err
+			}
+			data = entity.KeyValues()
+		} else {
+			return fmt.Errorf("cannot transcode %s data without a model", currentContentType)
+		}
+	}
+
+	return setEventData(k.CloudEvent, contentType, data)
+}
+
+func setEventData(event *cloudevents.Event, contentType string, data interface{}) error {
+	codec, err := GetCodec(contentType)
+	if err != nil {
+		return err
+	}
+
+	encoded, err := codec.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return event.SetData(contentType, encoded)
+}
+
+func (ktwinEvent *TwinEvent) SetEvent(twinInterface, twinInstance string, eventType EventType, data interface{}) error {
+	ceType, err := FormatEventType(TwinEventType{EventType: eventType, TwinInterface: twinInterface})
+	if err != nil {
+		return err
+	}
+
+	cloudEvent, err := BuildCloudEventWithContentType(ceType, twinInstance, cloudevents.ApplicationJSON, data)
+	if err != nil {
+		return err
+	}
+
+	ktwinEvent.EventType = eventType
 	ktwinEvent.TwinInterface = twinInterface
 	ktwinEvent.TwinInstance = twinInstance
-	ceType := fmt.Sprintf("ktwin.%s.%s", eventType, ktwinEvent.TwinInterface)
-	ktwinEvent.CloudEvent = BuildCloudEvent(ceType, twinInstance, data)
+	ktwinEvent.CloudEvent = cloudEvent
+	return nil
 }
 
 func BuildCloudEvent(ceType, ceSource string, data interface{}) *cloudevents.Event {
@@ -213,6 +472,19 @@ func BuildCloudEvent(ceType, ceSource string, data interface{}) *cloudevents.Eve
 	return &event
 }
 
+// Builds a CloudEvent with the data encoded by the codec of the content type
+func BuildCloudEventWithContentType(ceType, ceSource, contentType string, data interface{}) (*cloudevents.Event, error) {
+	event := cloudevents.NewEvent()
+	event.SetID(uuid.Uuid())
+	event.SetTime(*clock.Now())
+	event.SetType(ceType)
+	event.SetSource(ceSource)
+	if err := setEventData(&event, contentType, data); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
 type TwinInstanceReference struct {
 	Name      string `json:"name"`
 	Interface string `json:"interface"`
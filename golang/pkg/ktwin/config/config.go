@@ -1,24 +1,313 @@
 package config
 
 import (
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
 
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
-func LoadEnv() {
-	if os.Getenv("ENV") == "local" {
-		err := godotenv.Load("local.env")
+const (
+	EnvLocal = "local"
+	EnvTest  = "test"
+)
+
+// Env variables holding paths, which are relative to the env file directory
+var pathVariables = []string{"KTWIN_GRAPH_FILE", "KTWIN_BROKER_CONFIG", "KTWIN_SINK_FILE", "KTWIN_FIXTURES_DIR", "KTWIN_POLICY_FILE", "KTWIN_SCHEDULER_FILE"}
+
+// Config is the configuration shared by all services. It is loaded from the defaults, then
+// the YAML file set by -config or KTWIN_CONFIG, then the env variables of the `env` tags and
+// finally the command line flags of the `flag` tags, each one overriding the previous ones:
+//
+//	broker: http://localhost:8081
+//	eventStore: http://localhost:8082
+//	graph:
+//	  file: twin-graph.txt
+//	services:
+//	  device:
+//	    batteryThreshold: 20
+//
+// Paths of the file, tagged `path`, are relative to its directory. The business thresholds of
+// each service are read from its section of services with LoadService.
+type Config struct {
+	Env        string          `yaml:"env" env:"ENV" flag:"env"`
+	Port       string          `yaml:"port" env:"PORT" flag:"port"`
+	Broker     string          `yaml:"broker" env:"KTWIN_BROKER" flag:"broker"`
+	EventStore string          `yaml:"eventStore" env:"KTWIN_EVENT_STORE" flag:"event-store"`
+	EventMode  string          `yaml:"eventMode" env:"KTWIN_EVENT_MODE" flag:"event-mode"`
+	Graph      GraphConfig     `yaml:"graph"`
+	Sink       SinkConfig      `yaml:"sink"`
+	Record     RecordConfig    `yaml:"record"`
+	Policy     PolicyConfig    `yaml:"policy"`
+	Scheduler  SchedulerConfig `yaml:"scheduler"`
+
+	Services map[string]yaml.Node `yaml:"services"`
+
+	// Directory of the config file, paths of the file are relative to it
+	dir string
+}
+
+type GraphConfig struct {
+	URL  string `yaml:"url" env:"KTWIN_GRAPH_URL" flag:"graph-url"`
+	File string `yaml:"file" env:"KTWIN_GRAPH_FILE" flag:"graph-file" path:"true"`
+	JSON string `yaml:"json" env:"KTWIN_GRAPH"`
+}
+
+type SinkConfig struct {
+	Type        string `yaml:"type" env:"KTWIN_SINK" flag:"sink"`
+	File        string `yaml:"file" env:"KTWIN_SINK_FILE" flag:"sink-file" path:"true"`
+	FixturesDir string `yaml:"fixturesDir" env:"KTWIN_FIXTURES_DIR" flag:"fixtures-dir" path:"true"`
+}
+
+type RecordConfig struct {
+	File     string `yaml:"file" env:"KTWIN_RECORD_FILE" flag:"record-file" path:"true"`
+	MaxSize  int64  `yaml:"maxSize" env:"KTWIN_RECORD_MAX_SIZE"`
+	MaxFiles int    `yaml:"maxFiles" env:"KTWIN_RECORD_MAX_FILES"`
+}
+
+// The policy file overrides the service thresholds per twin instance, see the kpolicy package.
+// Its admin API is only served on the admin port, kept apart from the public event port, when it is set.
+type PolicyConfig struct {
+	File      string `yaml:"file" env:"KTWIN_POLICY_FILE" flag:"policy-file" path:"true"`
+	AdminPort string `yaml:"adminPort" env:"KTWIN_POLICY_ADMIN_PORT" flag:"policy-admin-port"`
+}
+
+// The scheduler file keeps the timers of the twin instances across restarts, see the kscheduler package
+type SchedulerConfig struct {
+	File string `yaml:"file" env:"KTWIN_SCHEDULER_FILE" flag:"scheduler-file" path:"true"`
+}
+
+func defaultConfig() *Config {
+	return &Config{
+		Port:      "8080",
+		EventMode: "binary",
+	}
+}
+
+func (c *Config) IsLocal() bool {
+	return c.Env == EnvLocal
+}
+
+func (c *Config) IsTest() bool {
+	return c.Env == EnvTest
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+
+	if !oneOf(c.Env, "", EnvLocal, EnvTest) {
+		errs = append(errs, fmt.Errorf("env must be %s or %s, got %q", EnvLocal, EnvTest, c.Env))
+	}
+	if !oneOf(c.EventMode, "", "binary", "structured") {
+		errs = append(errs, fmt.Errorf("eventMode must be binary or structured, got %q", c.EventMode))
+	}
+	if !oneOf(c.Sink.Type, "", "http", "local") {
+		errs = append(errs, fmt.Errorf("sink.type must be http or local, got %q", c.Sink.Type))
+	}
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port <= 0 || port > 65535 {
+		errs = append(errs, fmt.Errorf("port must be a number between 1 and 65535, got %q", c.Port))
+	}
+	if c.Policy.AdminPort != "" {
+		if port, err := strconv.Atoi(c.Policy.AdminPort); err != nil || port <= 0 || port > 65535 {
+			errs = append(errs, fmt.Errorf("policy.adminPort must be a number between 1 and 65535, got %q", c.Policy.AdminPort))
+		} else if c.Policy.AdminPort == c.Port {
+			errs = append(errs, fmt.Errorf("policy.adminPort must not be the event port %s", c.Port))
+		}
+	}
+
+	for name, value := range map[string]string{"broker": c.Broker, "eventStore": c.EventStore, "graph.url": c.Graph.URL} {
+		if err := validateURL(value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+
+	if c.Record.MaxSize < 0 || c.Record.MaxFiles < 0 {
+		errs = append(errs, errors.New("record.maxSize and record.maxFiles must not be negative"))
+	}
+
+	return errors.Join(errs...)
+}
+
+func oneOf(value string, values ...string) bool {
+	for _, v := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+func validateURL(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	parsed, err := url.ParseRequestURI(value)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("invalid url %q: scheme must be http or https", value)
+	}
+	return nil
+}
+
+var (
+	current *Config
+	mutex   sync.Mutex
+)
+
+// Loads the config of the service, args are the command line arguments without the program name.
+// In local and test modes the env file is loaded first, see LoadEnv.
+func Load(args []string) (*Config, error) {
+	flags := flag.NewFlagSet("ktwin", flag.ContinueOnError)
+	configFile := flags.String("config", "", "config file, overrides KTWIN_CONFIG")
+	flagValues := registerFlags(flags, defaultConfig())
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// The env selects the env file, which is loaded before the config
+	if isFlagSet(flags, "env") {
+		os.Setenv("ENV", *flagValues["env"])
+	}
+	if err := LoadEnv(); err != nil {
+		return nil, err
+	}
+
+	if *configFile == "" {
+		*configFile = os.Getenv("KTWIN_CONFIG")
+	}
+
+	config, err := load(*configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var flagErrs []error
+	flags.Visit(func(f *flag.Flag) {
+		if value, ok := flagValues[f.Name]; ok {
+			flagErrs = append(flagErrs, setFieldByTag(config, "flag", f.Name, *value))
+		}
+	})
+	if err := errors.Join(flagErrs...); err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	Set(config)
+	return config, nil
+}
+
+func load(configFile string) (*Config, error) {
+	config := defaultConfig()
+
+	if configFile != "" {
+		content, err := os.ReadFile(configFile)
 		if err != nil {
-			log.Fatal("Error loading .env file")
+			return nil, err
 		}
+		if err := yaml.Unmarshal(content, config); err != nil {
+			return nil, fmt.Errorf("error parsing config %s: %w", configFile, err)
+		}
+		config.dir = filepath.Dir(configFile)
+		resolvePaths(config, config.dir)
+	}
+
+	if err := applyEnv(config); err != nil {
+		return nil, err
 	}
+	return config, nil
+}
+
+// Gets the loaded config. When Load was not called, as in tests, the config is loaded from the
+// KTWIN_CONFIG file and the environment.
+func Get() *Config {
+	mutex.Lock()
+	defer mutex.Unlock()
 
-	if os.Getenv("ENV") == "test" {
-		err := godotenv.Load("../local.env")
+	if current == nil {
+		config, err := load(os.Getenv("KTWIN_CONFIG"))
 		if err != nil {
-			log.Fatal("Error loading .env file")
+			fmt.Fprintf(os.Stderr, "Error loading config, using the defaults: %v\n", err)
+			config = defaultConfig()
+		}
+		current = config
+	}
+	return current
+}
+
+func Set(config *Config) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	current = config
+}
+
+// Discards the loaded config, it is loaded again on the next Get
+func Reset() {
+	Set(nil)
+}
+
+// Decodes the section of the service into its config, which holds the defaults, applying the
+// `env` tags and validating it when it has a Validate method
+func LoadService(name string, serviceConfig interface{}) error {
+	config := Get()
+
+	if node, ok := config.Services[name]; ok {
+		if err := node.Decode(serviceConfig); err != nil {
+			return fmt.Errorf("error parsing services.%s config: %w", name, err)
+		}
+		resolvePaths(serviceConfig, config.dir)
+	}
+
+	if err := applyEnv(serviceConfig); err != nil {
+		return fmt.Errorf("services.%s: %w", name, err)
+	}
+
+	if validator, ok := serviceConfig.(interface{ Validate() error }); ok {
+		if err := validator.Validate(); err != nil {
+			return fmt.Errorf("invalid services.%s config: %w", name, err)
 		}
 	}
+	return nil
+}
+
+// Loads local.env in local mode, or ../local.env from the service package in test mode
+func LoadEnv() error {
+	if os.Getenv("ENV") == EnvLocal {
+		return loadEnvFile("local.env")
+	}
+
+	if os.Getenv("ENV") == EnvTest {
+		return loadEnvFile("../local.env")
+	}
+	return nil
+}
+
+func loadEnvFile(envFile string) error {
+	if err := godotenv.Load(envFile); err != nil {
+		return fmt.Errorf("error loading env file %s: %w", envFile, err)
+	}
+
+	for _, variable := range pathVariables {
+		path := os.Getenv(variable)
+		if path != "" && !filepath.IsAbs(path) {
+			os.Setenv(variable, filepath.Join(filepath.Dir(envFile), path))
+		}
+	}
+
+	// The config is loaded again with the variables of the file
+	Reset()
+	return nil
 }
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestConfigSuite(t *testing.T) {
+	suite.Run(t, new(ConfigSuite))
+}
+
+type ConfigSuite struct {
+	suite.Suite
+
+	dir  string
+	file string
+}
+
+type deviceConfig struct {
+	BatteryThreshold float64       `yaml:"batteryThreshold" env:"KTWIN_TEST_BATTERY_THRESHOLD"`
+	Window           time.Duration `yaml:"window" env:"KTWIN_TEST_WINDOW"`
+	Fixture          string        `yaml:"fixture" path:"true"`
+}
+
+func (s *ConfigSuite) SetupTest() {
+	s.dir = s.T().TempDir()
+	s.file = filepath.Join(s.dir, "ktwin.yaml")
+	s.Require().NoError(os.WriteFile(s.file, []byte(`
+port: "9000"
+broker: http://broker.file
+eventStore: http://event-store.file
+graph:
+  file: twin-graph.txt
+services:
+  device:
+    batteryThreshold: 20
+    window: 2h
+    fixture: fixtures/device.json
+`), 0644))
+
+	for _, key := range []string{"ENV", "PORT", "KTWIN_BROKER", "KTWIN_EVENT_STORE", "KTWIN_GRAPH_FILE", "KTWIN_CONFIG", "KTWIN_TEST_BATTERY_THRESHOLD", "KTWIN_TEST_WINDOW"} {
+		s.T().Setenv(key, "")
+		os.Unsetenv(key)
+	}
+}
+
+func (s *ConfigSuite) TearDownTest() {
+	Reset()
+}
+
+func (s *ConfigSuite) Test_Precedence() {
+	s.T().Setenv("KTWIN_BROKER", "http://broker.env")
+	s.T().Setenv("KTWIN_EVENT_STORE", "http://event-store.env")
+
+	config, err := Load([]string{"-config", s.file, "-event-store", "http://event-store.flag"})
+	s.Require().NoError(err)
+
+	s.Assert().Equal("9000", config.Port)
+	s.Assert().Equal("http://broker.env", config.Broker)
+	s.Assert().Equal("http://event-store.flag", config.EventStore)
+	s.Assert().Equal("binary", config.EventMode)
+	s.Assert().Equal(filepath.Join(s.dir, "twin-graph.txt"), config.Graph.File)
+	s.Assert().Same(config, Get())
+}
+
+func (s *ConfigSuite) Test_LoadService() {
+	_, err := Load([]string{"-config", s.file})
+	s.Require().NoError(err)
+
+	device := deviceConfig{BatteryThreshold: 15, Window: time.Hour}
+	s.Require().NoError(LoadService("device", &device))
+	s.Assert().Equal(deviceConfig{BatteryThreshold: 20, Window: 2 * time.Hour, Fixture: filepath.Join(s.dir, "fixtures/device.json")}, device)
+
+	s.T().Setenv("KTWIN_TEST_WINDOW", "30m")
+	s.Require().NoError(LoadService("device", &device))
+	s.Assert().Equal(30*time.Minute, device.Window)
+
+	s.T().Setenv("KTWIN_TEST_WINDOW", "soon")
+	s.Assert().ErrorContains(LoadService("device", &device), "KTWIN_TEST_WINDOW")
+
+	// The defaults are kept when the service has no section
+	other := deviceConfig{BatteryThreshold: 15}
+	os.Unsetenv("KTWIN_TEST_WINDOW")
+	s.Require().NoError(LoadService("other", &other))
+	s.Assert().Equal(deviceConfig{BatteryThreshold: 15}, other)
+}
+
+func (s *ConfigSuite) Test_Validate() {
+	_, err := Load([]string{"-config", s.file, "-broker", "broker:8081", "-event-mode", "batch", "-port", "http"})
+	s.Require().Error(err)
+	s.Assert().ErrorContains(err, "broker")
+	s.Assert().ErrorContains(err, "eventMode")
+	s.Assert().ErrorContains(err, "port")
+
+	// The policy admin API is not served on the event port
+	_, err = Load([]string{"-config", s.file, "-policy-admin-port", "9000"})
+	s.Assert().ErrorContains(err, "policy.adminPort")
+
+	_, err = Load([]string{"-unknown"})
+	s.Assert().Error(err)
+}
+
+func (s *ConfigSuite) Test_MissingEnvFileIsAnError() {
+	wd, err := os.Getwd()
+	s.Require().NoError(err)
+	s.Require().NoError(os.Chdir(s.dir))
+	defer os.Chdir(wd)
+	s.T().Setenv("ENV", EnvLocal)
+
+	s.Assert().ErrorContains(LoadEnv(), "local.env")
+
+	_, err = Load(nil)
+	s.Assert().Error(err)
+}
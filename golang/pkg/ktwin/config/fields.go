@@ -0,0 +1,154 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Walks the fields of the struct pointed by target, including the nested structs
+func walkFields(target interface{}, visit func(field reflect.StructField, value reflect.Value) error) error {
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Pointer || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config must be a pointer to a struct, got %T", target)
+	}
+	return walkStruct(value.Elem(), visit)
+}
+
+func walkStruct(value reflect.Value, visit func(field reflect.StructField, value reflect.Value) error) error {
+	var errs []error
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Time{}) {
+			errs = append(errs, walkStruct(value.Field(i), visit))
+			continue
+		}
+		errs = append(errs, visit(field, value.Field(i)))
+	}
+	return errors.Join(errs...)
+}
+
+// Sets the fields from the env variables of their `env` tag
+func applyEnv(target interface{}) error {
+	return walkFields(target, func(field reflect.StructField, value reflect.Value) error {
+		name := field.Tag.Get("env")
+		if name == "" {
+			return nil
+		}
+
+		envValue, ok := os.LookupEnv(name)
+		if !ok {
+			return nil
+		}
+		if err := setField(value, envValue); err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+		return nil
+	})
+}
+
+// Registers a string flag for each field with a `flag` tag, returning the flag values by name
+func registerFlags(flags *flag.FlagSet, target interface{}) map[string]*string {
+	values := map[string]*string{}
+	walkFields(target, func(field reflect.StructField, value reflect.Value) error {
+		name := field.Tag.Get("flag")
+		if name == "" {
+			return nil
+		}
+
+		usage := "overrides the config file"
+		if env := field.Tag.Get("env"); env != "" {
+			usage = "overrides " + env
+		}
+		values[name] = flags.String(name, fmt.Sprint(value.Interface()), usage)
+		return nil
+	})
+	return values
+}
+
+func isFlagSet(flags *flag.FlagSet, name string) bool {
+	set := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// Sets the field whose tag has the given name
+func setFieldByTag(target interface{}, tag, name, fieldValue string) error {
+	return walkFields(target, func(field reflect.StructField, value reflect.Value) error {
+		if field.Tag.Get(tag) != name {
+			return nil
+		}
+		if err := setField(value, fieldValue); err != nil {
+			return fmt.Errorf("invalid -%s: %w", name, err)
+		}
+		return nil
+	})
+}
+
+// Makes the relative paths of the fields tagged `path` relative to dir
+func resolvePaths(target interface{}, dir string) {
+	if dir == "" {
+		return
+	}
+
+	walkFields(target, func(field reflect.StructField, value reflect.Value) error {
+		if field.Tag.Get("path") != "true" || value.Kind() != reflect.String {
+			return nil
+		}
+
+		if path := value.String(); path != "" && !filepath.IsAbs(path) {
+			value.SetString(filepath.Join(dir, path))
+		}
+		return nil
+	})
+}
+
+func setField(field reflect.Value, value string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(duration))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(parsed)
+	default:
+		return fmt.Errorf("unsupported config type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package ktwin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const eventTypePrefix = "ktwin"
+
+var (
+	ErrUnknownPrefix    = errors.New("unknown event type prefix")
+	ErrUnknownKind      = errors.New("unknown event kind")
+	ErrMissingPart      = errors.New("missing event type part")
+	ErrUnexpectedPart   = errors.New("unexpected event type part")
+	ErrIllegalCharacter = errors.New("illegal character in event type")
+)
+
+// EventTypeError describes why a CloudEvent type does not follow the
+// ktwin.<kind>.<interface>[.<command>|.<timer>] grammar
+type EventTypeError struct {
+	Type   string
+	Err    error
+	Detail string
+}
+
+func (e *EventTypeError) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("invalid event type %q: %s", e.Type, e.Err)
+	}
+	return fmt.Sprintf("invalid event type %q: %s: %s", e.Type, e.Err, e.Detail)
+}
+
+func (e *EventTypeError) Unwrap() error {
+	return e.Err
+}
+
+// TwinEventType is the parsed form of a KTWIN CloudEvent type
+type TwinEventType struct {
+	EventType     EventType
+	TwinInterface string
+	CommandName   string
+	TimerName     string
+}
+
+func isKnownEventType(eventType EventType) bool {
+	switch eventType {
+	case RealEvent, VirtualEvent, CommandEvent, StoreEvent, ValidationEvent, TimerEvent:
+		return true
+	default:
+		return false
+	}
+}
+
+// Parses a CloudEvent type with the following grammar:
+// Real Event Type: ktwin.real.<twin-interface>
+// Virtual Event Type: ktwin.virtual.<twin-interface>
+// Store Event Type: ktwin.store.<twin-interface>
+// Validation Event Type: ktwin.validation.<twin-interface>
+// Command Event Type: ktwin.command.<twin-interface>.<command-name>
+// Timer Event Type: ktwin.timer.<twin-interface>.<timer-name>
+func ParseEventType(ceType string) (TwinEventType, error) {
+	var twinEventType TwinEventType
+	parts := strings.Split(ceType, ".")
+
+	if parts[0] != eventTypePrefix {
+		return twinEventType, &EventTypeError{Type: ceType, Err: ErrUnknownPrefix, Detail: fmt.Sprintf("expected %q, got %q", eventTypePrefix, parts[0])}
+	}
+
+	if len(parts) < 2 || parts[1] == "" {
+		return twinEventType, &EventTypeError{Type: ceType, Err: ErrMissingPart, Detail: "event kind"}
+	}
+
+	twinEventType.EventType = EventType(parts[1])
+	if !isKnownEventType(twinEventType.EventType) {
+		return twinEventType, &EventTypeError{Type: ceType, Err: ErrUnknownKind, Detail: parts[1]}
+	}
+
+	if len(parts) < 3 || parts[2] == "" {
+		return twinEventType, &EventTypeError{Type: ceType, Err: ErrMissingPart, Detail: "twin interface"}
+	}
+	twinEventType.TwinInterface = parts[2]
+
+	if twinEventType.EventType == CommandEvent {
+		if len(parts) < 4 || parts[3] == "" {
+			return twinEventType, &EventTypeError{Type: ceType, Err: ErrMissingPart, Detail: "command name"}
+		}
+		twinEventType.CommandName = parts[3]
+	}
+
+	if twinEventType.EventType == TimerEvent {
+		if len(parts) < 4 || parts[3] == "" {
+			return twinEventType, &EventTypeError{Type: ceType, Err: ErrMissingPart, Detail: "timer name"}
+		}
+		twinEventType.TimerName = parts[3]
+	}
+
+	maxParts := 3
+	if twinEventType.EventType == CommandEvent || twinEventType.EventType == TimerEvent {
+		maxParts = 4
+	}
+	if len(parts) > maxParts {
+		return twinEventType, &EventTypeError{Type: ceType, Err: ErrUnexpectedPart, Detail: strings.Join(parts[maxParts:], ".")}
+	}
+
+	if err := twinEventType.validateNames(ceType); err != nil {
+		return twinEventType, err
+	}
+
+	return twinEventType, nil
+}
+
+// Formats the event type as a CloudEvent type, validating all its parts
+func FormatEventType(twinEventType TwinEventType) (string, error) {
+	var ceType string
+	if twinEventType.EventType == CommandEvent {
+		ceType = fmt.Sprintf("%s.%s.%s.%s", eventTypePrefix, twinEventType.EventType, twinEventType.TwinInterface, twinEventType.CommandName)
+	} else if twinEventType.EventType == TimerEvent {
+		ceType = fmt.Sprintf("%s.%s.%s.%s", eventTypePrefix, twinEventType.EventType, twinEventType.TwinInterface, twinEventType.TimerName)
+	} else {
+		ceType = fmt.Sprintf("%s.%s.%s", eventTypePrefix, twinEventType.EventType, twinEventType.TwinInterface)
+	}
+
+	if _, err := ParseEventType(ceType); err != nil {
+		return "", err
+	}
+
+	return ceType, nil
+}
+
+func (t TwinEventType) validateNames(ceType string) error {
+	if !isValidName(t.TwinInterface) {
+		return &EventTypeError{Type: ceType, Err: ErrIllegalCharacter, Detail: fmt.Sprintf("twin interface %q", t.TwinInterface)}
+	}
+
+	if t.CommandName != "" && !isValidName(t.CommandName) {
+		return &EventTypeError{Type: ceType, Err: ErrIllegalCharacter, Detail: fmt.Sprintf("command name %q", t.CommandName)}
+	}
+
+	if t.TimerName != "" && !isValidName(t.TimerName) {
+		return &EventTypeError{Type: ceType, Err: ErrIllegalCharacter, Detail: fmt.Sprintf("timer name %q", t.TimerName)}
+	}
+
+	return nil
+}
+
+// Twin interfaces, command and timer names may contain letters, digits, '-' and '_'
+func isValidName(name string) bool {
+	for _, c := range name {
+		isLetter := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		isDigit := c >= '0' && c <= '9'
+		if !isLetter && !isDigit && c != '-' && c != '_' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,118 @@
+package ktwin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestEventTypeSuite(t *testing.T) {
+	suite.Run(t, new(EventTypeSuite))
+}
+
+type EventTypeSuite struct {
+	suite.Suite
+}
+
+func (s *EventTypeSuite) Test_ParseEventType() {
+	tests := []struct {
+		name              string
+		ceType            string
+		expectedEventType TwinEventType
+		expectedError     error
+	}{
+		{
+			name:              `Real event type`,
+			ceType:            "ktwin.real.ngsi-ld-city-device",
+			expectedEventType: TwinEventType{EventType: RealEvent, TwinInterface: "ngsi-ld-city-device"},
+		},
+		{
+			name:              `Command event type`,
+			ceType:            "ktwin.command.city-pole.updateairqualityindex",
+			expectedEventType: TwinEventType{EventType: CommandEvent, TwinInterface: "city-pole", CommandName: "updateairqualityindex"},
+		},
+		{
+			name:              `Timer event type`,
+			ceType:            "ktwin.timer.ngsi-ld-city-streetlight.checkdefect",
+			expectedEventType: TwinEventType{EventType: TimerEvent, TwinInterface: "ngsi-ld-city-streetlight", TimerName: "checkdefect"},
+		},
+		{
+			name:          `Unknown prefix`,
+			ceType:        "foo",
+			expectedError: ErrUnknownPrefix,
+		},
+		{
+			name:          `Missing kind`,
+			ceType:        "ktwin",
+			expectedError: ErrMissingPart,
+		},
+		{
+			name:          `Unknown kind`,
+			ceType:        "ktwin.unknown.ngsi-ld-city-device",
+			expectedError: ErrUnknownKind,
+		},
+		{
+			name:          `Missing twin interface`,
+			ceType:        "ktwin.real",
+			expectedError: ErrMissingPart,
+		},
+		{
+			name:          `Missing command name`,
+			ceType:        "ktwin.command.city-pole",
+			expectedError: ErrMissingPart,
+		},
+		{
+			name:          `Missing timer name`,
+			ceType:        "ktwin.timer.ngsi-ld-city-streetlight",
+			expectedError: ErrMissingPart,
+		},
+		{
+			name:          `Unexpected command name in real event`,
+			ceType:        "ktwin.real.ngsi-ld-city-device.updatebattery",
+			expectedError: ErrUnexpectedPart,
+		},
+		{
+			name:          `Illegal character in twin interface`,
+			ceType:        "ktwin.real.ngsi-ld-city-device$",
+			expectedError: ErrIllegalCharacter,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			actualEventType, actualError := ParseEventType(tt.ceType)
+
+			if tt.expectedError != nil {
+				s.Assert().ErrorIs(actualError, tt.expectedError)
+				return
+			}
+
+			s.Assert().NoError(actualError)
+			s.Assert().Equal(tt.expectedEventType, actualEventType)
+		})
+	}
+}
+
+func (s *EventTypeSuite) Test_FormatEventType() {
+	ceType, err := FormatEventType(TwinEventType{EventType: CommandEvent, TwinInterface: "city-pole", CommandName: "updateairqualityindex"})
+	s.Assert().NoError(err)
+	s.Assert().Equal("ktwin.command.city-pole.updateairqualityindex", ceType)
+
+	_, err = FormatEventType(TwinEventType{EventType: CommandEvent, TwinInterface: "city-pole"})
+	s.Assert().ErrorIs(err, ErrMissingPart)
+
+	ceType, err = FormatEventType(TwinEventType{EventType: TimerEvent, TwinInterface: "ngsi-ld-city-streetlight", TimerName: "checkdefect"})
+	s.Assert().NoError(err)
+	s.Assert().Equal("ktwin.timer.ngsi-ld-city-streetlight.checkdefect", ceType)
+}
+
+func (s *EventTypeSuite) Test_SetEvent() {
+	twinEvent := NewTwinEvent()
+	s.Require().NoError(twinEvent.SetEvent("ngsi-ld-city-device", "ngsi-ld-city-device-nb001", RealEvent, map[string]int{"level": 1}))
+	s.Assert().Equal("ktwin.real.ngsi-ld-city-device", twinEvent.CloudEvent.Type())
+	s.Assert().JSONEq(`{"level":1}`, string(twinEvent.CloudEvent.Data()))
+
+	// Data that can not be encoded is not published as an empty event
+	s.Assert().Error(NewTwinEvent().SetEvent("ngsi-ld-city-device", "ngsi-ld-city-device-nb001", RealEvent, map[string]interface{}{"level": make(chan int)}))
+	s.Assert().Error(NewTwinEvent().SetEvent("", "ngsi-ld-city-device-nb001", RealEvent, nil))
+}
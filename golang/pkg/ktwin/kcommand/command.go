@@ -6,8 +6,11 @@ import (
 
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/ktwingraph"
+	log "github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
 )
 
+var logger = log.NewLogger()
+
 // TwinCommand
 
 func PublishCommand(command string, commandPayload interface{}, relationshipName, twinInstanceSource string, twinGraph ktwin.TwinGraph) error {
@@ -15,9 +18,40 @@ func PublishCommand(command string, commandPayload interface{}, relationshipName
 	if relationship == nil {
 		return fmt.Errorf("relationship %s not found in Twin Instance %s", relationshipName, twinInstanceSource)
 	}
+	return publishCommand(command, commandPayload, relationship, twinInstanceSource)
+}
+
+// Publishes the command to the twin instance whose relationship by name points to the source, for
+// parents such as the city that reference their children while the children do not reference them
+func PublishCommandByReverseRelationship(command string, commandPayload interface{}, relationshipName, twinInstanceSource string, twinGraph ktwin.TwinGraph) error {
+	relationship := ktwingraph.GetReverseRelationshipFromGraph(twinInstanceSource, relationshipName, twinGraph)
+	if relationship == nil {
+		return fmt.Errorf("relationship %s to Twin Instance %s not found", relationshipName, twinInstanceSource)
+	}
+	return publishCommand(command, commandPayload, relationship, twinInstanceSource)
+}
+
+// Publishes the command to the twin instance that references the source by the relationship, such as
+// the pole a sensor is attached to. Sources that no twin instance references are skipped.
+func PublishCommandToReferrer(command string, commandPayload interface{}, relationshipName, twinInstanceSource string, twinGraph ktwin.TwinGraph) error {
+	if ktwingraph.GetReverseRelationshipFromGraph(twinInstanceSource, relationshipName, twinGraph) == nil {
+		logger.Info(fmt.Sprintf("TwinInstance %s is not referenced by relation %s", twinInstanceSource, relationshipName))
+		return nil
+	}
+
+	err := PublishCommandByReverseRelationship(command, commandPayload, relationshipName, twinInstanceSource, twinGraph)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error executing command %s in relation %s in TwinInstance %s\n", command, relationshipName, twinInstanceSource), err)
+		return err
+	}
+	return nil
+}
+
+func publishCommand(command string, commandPayload interface{}, relationship *ktwin.TwinInstanceReference, twinInstanceSource string) error {
 	ceType := fmt.Sprintf(ktwin.EventCommandExecuted, relationship.Interface, strings.ToLower(command))
 	ceSource := relationship.Instance
 	cloudEvent := ktwin.BuildCloudEvent(ceType, ceSource, commandPayload)
+	cloudEvent.SetExtension(ktwin.CausationExtension, twinInstanceSource)
 
 	fmt.Printf("Publishing Command Ce-Type: %s - Publishing Ce-Source: %s\n", ceType, ceSource)
 
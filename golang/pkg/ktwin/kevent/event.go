@@ -1,11 +1,14 @@
 package kevent
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
 	log "github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
 )
 
 var logger = log.NewLogger()
@@ -17,6 +20,23 @@ func PublishToRealTwin(twinInterface, twinInstance string, data interface{}) err
 	return ktwin.PostCloudEvent(cloudEvent, ktwin.GetBrokerURL())
 }
 
+// Publishes data to the real twin that generated the event, encoded with the
+// datacontenttype the real twin sent the event in
+func ReplyToRealTwin(twinEvent *ktwin.TwinEvent, data interface{}) error {
+	contentType := twinEvent.CloudEvent.DataContentType()
+	if contentType == "" {
+		contentType = cloudevents.ApplicationJSON
+	}
+
+	ceType := fmt.Sprintf(ktwin.EventVirtualGenerated, twinEvent.TwinInterface)
+	ceSource := twinEvent.TwinInstance
+	cloudEvent, err := ktwin.BuildCloudEventWithContentType(ceType, ceSource, contentType, data)
+	if err != nil {
+		return err
+	}
+	return ktwin.PostCloudEvent(cloudEvent, ktwin.GetBrokerURL())
+}
+
 func PublishToVirtualTwin(twinInterface, twinInstance string, data interface{}) error {
 	ceType := fmt.Sprintf(ktwin.EventRealGenerated, twinInterface)
 	ceSource := twinInstance
@@ -24,17 +44,40 @@ func PublishToVirtualTwin(twinInterface, twinInstance string, data interface{})
 	return ktwin.PostCloudEvent(cloudEvent, ktwin.GetBrokerURL())
 }
 
-func HandleRequest(r *http.Request) *ktwin.TwinEvent {
+type validationFailure struct {
+	EventID   string `json:"eventId"`
+	EventType string `json:"eventType"`
+	*ktwin.ValidationError
+}
+
+// Notifies the broker that an event was rejected because its payload is invalid
+func PublishValidationFailure(twinEvent *ktwin.TwinEvent, validationError *ktwin.ValidationError) error {
+	failure := validationFailure{
+		ValidationError: validationError,
+	}
+
+	if twinEvent.CloudEvent != nil {
+		failure.EventID = twinEvent.CloudEvent.ID()
+		failure.EventType = twinEvent.CloudEvent.Type()
+	}
+
+	ceType := fmt.Sprintf(ktwin.EventValidationFailed, twinEvent.TwinInterface)
+	ceSource := twinEvent.TwinInstance
+	cloudEvent := ktwin.BuildCloudEvent(ceType, ceSource, failure)
+	return ktwin.PostCloudEvent(cloudEvent, ktwin.GetBrokerURL())
+}
+
+func HandleRequest(r *http.Request) (*ktwin.TwinEvent, error) {
 	// Handle incoming events
 	twinEvent := ktwin.NewTwinEvent()
 	err := twinEvent.HandleRequest(r)
 
 	if err != nil {
 		logger.Error("Error handling cloud event request", err)
-		return nil
+		return nil, err
 	}
 
-	return twinEvent
+	return twinEvent, nil
 }
 
 func HandleEvent(twinEvent *ktwin.TwinEvent, twinInterface string, callback func(*ktwin.TwinEvent) error) error {
@@ -58,19 +101,94 @@ func HandleEvent(twinEvent *ktwin.TwinEvent, twinInterface string, callback func
 }
 
 func RequestHandlerFunc(w http.ResponseWriter, r *http.Request, handleEvent func(*ktwin.TwinEvent) error) {
-	twinEvent := HandleRequest(r)
+	if ktwin.IsBatchRequest(r) {
+		batchRequestHandlerFunc(w, r, handleEvent)
+		return
+	}
+
+	twinEvent, err := HandleRequest(r)
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("Invalid cloud event request: " + err.Error()))
+		return
+	}
+
+	result := ProcessEvent(twinEvent, handleEvent)
+	if result.Status != http.StatusOK {
+		w.WriteHeader(result.Status)
+		w.Write([]byte(result.Error))
+	}
+}
+
+// Dispatches each event of a batch to the handler and replies with the result of each event.
+// The response status is 200 when all events succeed and 207 otherwise.
+func batchRequestHandlerFunc(w http.ResponseWriter, r *http.Request, handleEvent func(*ktwin.TwinEvent) error) {
+	twinEvents, errs, err := ktwin.HandleBatchRequest(r)
+
+	if err != nil {
+		logger.Error("Error handling cloud event batch request", err)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("Invalid cloud event batch request: " + err.Error()))
+		return
+	}
+
+	status := http.StatusOK
+	results := make([]ktwin.EventResult, len(twinEvents))
+
+	for i, twinEvent := range twinEvents {
+		if errs[i] != nil {
+			logger.Error("Error handling cloud event in batch request", errs[i])
+			results[i] = ktwin.EventResult{Status: http.StatusBadRequest, Error: "Invalid cloud event: " + errs[i].Error()}
+			var batchEventError *ktwin.BatchEventError
+			if errors.As(errs[i], &batchEventError) {
+				results[i].ID = batchEventError.ID
+			}
+		} else {
+			results[i] = ProcessEvent(twinEvent, handleEvent)
+		}
+
+		if results[i].Status != http.StatusOK {
+			status = http.StatusMultiStatus
+		}
+	}
 
-	if twinEvent == nil {
-		logger.Error("Error handling cloud event request", nil)
+	body, err := json.Marshal(results)
+	if err != nil {
+		logger.Error("Error encoding cloud event batch results", err)
 		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("Error handling cloud event request"))
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// Dispatches the event to the handler, publishing a validation failure when the payload is invalid.
+// The events of the same twin instance are dispatched one at a time.
+func ProcessEvent(twinEvent *ktwin.TwinEvent, handleEvent func(*ktwin.TwinEvent) error) ktwin.EventResult {
+	result := ktwin.EventResult{ID: twinEvent.CloudEvent.ID(), Status: http.StatusOK}
+
+	unlock := instanceLocks.Lock(twinEvent.TwinInstance)
+	defer unlock()
+
 	if err := handleEvent(twinEvent); err != nil {
+		var validationError *ktwin.ValidationError
+		if errors.As(err, &validationError) {
+			logger.Error("Invalid cloud event payload", err)
+			if err := PublishValidationFailure(twinEvent, validationError); err != nil {
+				logger.Error("Error publishing validation failure event", err)
+			}
+			result.Status = http.StatusBadRequest
+			result.Error = validationError.Error()
+			return result
+		}
+
 		logger.Error("Error processing cloud event request", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("Error processing cloud event request"))
-		return
+		result.Status = http.StatusInternalServerError
+		result.Error = "Error processing cloud event request"
 	}
+
+	return result
 }
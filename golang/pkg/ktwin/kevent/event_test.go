@@ -0,0 +1,160 @@
+package kevent
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestEventSuite(t *testing.T) {
+	suite.Run(t, new(EventSuite))
+}
+
+type EventSuite struct {
+	suite.Suite
+}
+
+func (s *EventSuite) buildCloudEvent(id, ceType, ceSource string) cloudevents.Event {
+	cloudEvent := cloudevents.NewEvent()
+	cloudEvent.SetID(id)
+	cloudEvent.SetType(ceType)
+	cloudEvent.SetSource(ceSource)
+	cloudEvent.SetData(cloudevents.ApplicationJSON, map[string]int{"batteryLevel": 20})
+	return cloudEvent
+}
+
+func (s *EventSuite) Test_StructuredModeRequest() {
+	cloudEvent := s.buildCloudEvent("1", "ktwin.real.ngsi-ld-city-device", "ngsi-ld-city-device-nb001-ofp0003-s0012")
+	body, _ := json.Marshal(cloudEvent)
+
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(body))
+	request.Header.Set("Content-Type", cloudevents.ApplicationCloudEventsJSON)
+	recorder := httptest.NewRecorder()
+
+	var handledEvent *ktwin.TwinEvent
+	RequestHandlerFunc(recorder, request, func(twinEvent *ktwin.TwinEvent) error {
+		handledEvent = twinEvent
+		return nil
+	})
+
+	s.Assert().Equal(http.StatusOK, recorder.Code)
+	s.Assert().Equal(ktwin.RealEvent, handledEvent.EventType)
+	s.Assert().Equal("ngsi-ld-city-device", handledEvent.TwinInterface)
+	s.Assert().Equal("ngsi-ld-city-device-nb001-ofp0003-s0012", handledEvent.TwinInstance)
+}
+
+func (s *EventSuite) Test_BatchModeRequest() {
+	events := []cloudevents.Event{
+		s.buildCloudEvent("1", "ktwin.real.ngsi-ld-city-device", "ngsi-ld-city-device-nb001-ofp0003-s0012"),
+		s.buildCloudEvent("2", "foo", "ngsi-ld-city-device-nb001-ofp0003-s0013"),
+		s.buildCloudEvent("3", "ktwin.real.ngsi-ld-city-device", "ngsi-ld-city-device-nb001-ofp0003-s0014"),
+	}
+	body, _ := json.Marshal(events)
+
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(body))
+	request.Header.Set("Content-Type", cloudevents.ApplicationCloudEventsBatchJSON)
+	recorder := httptest.NewRecorder()
+
+	var handledInstances []string
+	RequestHandlerFunc(recorder, request, func(twinEvent *ktwin.TwinEvent) error {
+		handledInstances = append(handledInstances, twinEvent.TwinInstance)
+		if twinEvent.CloudEvent.ID() == "3" {
+			return errors.New("processing error")
+		}
+		return nil
+	})
+
+	var results []ktwin.EventResult
+	s.Assert().NoError(json.Unmarshal(recorder.Body.Bytes(), &results))
+
+	s.Assert().Equal(http.StatusMultiStatus, recorder.Code)
+	s.Assert().Equal([]string{"ngsi-ld-city-device-nb001-ofp0003-s0012", "ngsi-ld-city-device-nb001-ofp0003-s0014"}, handledInstances)
+	s.Assert().Len(results, 3)
+	s.Assert().Equal(ktwin.EventResult{ID: "1", Status: http.StatusOK}, results[0])
+	s.Assert().Equal("2", results[1].ID)
+	s.Assert().Equal(http.StatusBadRequest, results[1].Status)
+	s.Assert().Equal(ktwin.EventResult{ID: "3", Status: http.StatusInternalServerError, Error: "Error processing cloud event request"}, results[2])
+}
+
+func (s *EventSuite) Test_BatchModeRequestInvalidEvents() {
+	body := `[
+		{"specversion": "1.0", "id": "1", "type": "ktwin.real.ngsi-ld-city-device"},
+		{"specversion": "1.0", "id": 2, "type": "ktwin.real.ngsi-ld-city-device", "source": "ngsi-ld-city-device-nb001-ofp0003-s0012"}
+	]`
+
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+	request.Header.Set("Content-Type", cloudevents.ApplicationCloudEventsBatchJSON)
+	recorder := httptest.NewRecorder()
+
+	RequestHandlerFunc(recorder, request, func(twinEvent *ktwin.TwinEvent) error {
+		s.Fail("invalid events must not be handled")
+		return nil
+	})
+
+	var results []ktwin.EventResult
+	s.Assert().NoError(json.Unmarshal(recorder.Body.Bytes(), &results))
+
+	s.Assert().Equal(http.StatusMultiStatus, recorder.Code)
+	s.Assert().Len(results, 2)
+	// The event without source is parsed, so its result keeps its ID
+	s.Assert().Equal("1", results[0].ID)
+	s.Assert().Equal(http.StatusBadRequest, results[0].Status)
+	s.Assert().Contains(results[0].Error, "source")
+	s.Assert().Equal("", results[1].ID)
+	s.Assert().Equal(http.StatusBadRequest, results[1].Status)
+}
+
+func (s *EventSuite) Test_ProcessEventSerializesTwinInstance() {
+	newTwinEvent := func(id, ceSource string) *ktwin.TwinEvent {
+		cloudEvent := s.buildCloudEvent(id, "ktwin.timer.ngsi-ld-city-streetlight.checkdefect", ceSource)
+		twinEvent, err := ktwin.NewTwinEventFromCloudEvent(&cloudEvent)
+		s.Require().NoError(err)
+		return twinEvent
+	}
+
+	started := make(chan string, 3)
+	release := make(chan struct{})
+	handleEvent := func(twinEvent *ktwin.TwinEvent) error {
+		started <- twinEvent.CloudEvent.ID()
+		if twinEvent.CloudEvent.ID() == "1" {
+			<-release
+		}
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ProcessEvent(newTwinEvent("1", "ngsi-ld-city-streetlight-nb001-p00007-l00001"), handleEvent)
+		done <- struct{}{}
+	}()
+	s.Require().Equal("1", <-started)
+
+	go func() {
+		ProcessEvent(newTwinEvent("2", "ngsi-ld-city-streetlight-nb001-p00007-l00001"), handleEvent)
+		done <- struct{}{}
+	}()
+
+	// Other twin instances are not blocked
+	s.Assert().Equal(http.StatusOK, ProcessEvent(newTwinEvent("3", "ngsi-ld-city-streetlight-nb001-p00007-l00002"), handleEvent).Status)
+	s.Require().Equal("3", <-started)
+
+	select {
+	case id := <-started:
+		s.Failf("event handled concurrently", "event %s of the same twin instance was handled before event 1 completed", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	<-done
+	s.Assert().Equal("2", <-started)
+	<-done
+	s.Assert().Empty(instanceLocks.locks)
+}
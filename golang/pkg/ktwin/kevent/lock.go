@@ -0,0 +1,47 @@
+package kevent
+
+import "sync"
+
+// The events of a twin instance are handled one at a time, whether they come from a request or
+// a timer, as the handlers read the latest state of the instance and store the updated one
+var instanceLocks = newKeyedMutex()
+
+// keyedMutex is a mutex per key, the mutex of a key is released when no one holds or waits for it
+type keyedMutex struct {
+	mutex sync.Mutex
+	locks map[string]*keyLock
+}
+
+type keyLock struct {
+	sync.Mutex
+	// Number of callers holding or waiting for the lock
+	refs int
+}
+
+func newKeyedMutex() *keyedMutex {
+	return &keyedMutex{locks: map[string]*keyLock{}}
+}
+
+// Locks the key and returns the function unlocking it
+func (k *keyedMutex) Lock(key string) func() {
+	k.mutex.Lock()
+	lock, ok := k.locks[key]
+	if !ok {
+		lock = &keyLock{}
+		k.locks[key] = lock
+	}
+	lock.refs++
+	k.mutex.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+
+		k.mutex.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(k.locks, key)
+		}
+		k.mutex.Unlock()
+	}
+}
@@ -2,45 +2,26 @@ package keventstore
 
 import (
 	"fmt"
-	"net/http"
-	"os"
 
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
 )
 
 func GetLatestTwinEvent(twinInterface, twinInstance string) (*ktwin.TwinEvent, error) {
-	if os.Getenv("ENV") == "local" {
-		return nil, nil
-	}
-
-	url := fmt.Sprintf("%s/api/v1/twin-events/%s/%s/latest", ktwin.GetEventStoreURL(), twinInterface, twinInstance)
-
-	response, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer response.Body.Close()
-
-	if response.StatusCode == http.StatusNotFound {
-		return nil, nil
-	}
-
-	event := ktwin.NewTwinEvent()
-	err = event.HandleResponse(response)
-
-	if err != nil {
+	cloudEvent, err := ktwin.GetSink().GetLatest(twinInterface, twinInstance)
+	if err != nil || cloudEvent == nil {
 		return nil, err
 	}
 
-	return event, nil
+	return ktwin.NewTwinEventFromCloudEvent(cloudEvent)
 }
 
 func UpdateTwinEvent(twinEvent *ktwin.TwinEvent) error {
-	if os.Getenv("ENV") == "local" {
-		return nil
+	// The event store keeps the twin events as JSON, whatever the encoding of the real twin
+	if err := twinEvent.TranscodeData(cloudevents.ApplicationJSON); err != nil {
+		return err
 	}
 
-	url := ktwin.GetBrokerURL()
 	twinEvent.CloudEvent.SetType(fmt.Sprintf(ktwin.EventStoreGenerated, twinEvent.TwinInterface))
-	return ktwin.PostCloudEvent(twinEvent.CloudEvent, url)
+	return ktwin.GetSink().Store(twinEvent.CloudEvent)
 }
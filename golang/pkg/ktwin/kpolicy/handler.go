@@ -0,0 +1,73 @@
+package kpolicy
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+const AdminPath = "/admin/policies"
+
+type resolvedPolicy struct {
+	Interface string      `json:"interface"`
+	Instance  string      `json:"instance"`
+	Scopes    []string    `json:"scopes"`
+	Values    Values      `json:"values"`
+	Policy    interface{} `json:"policy,omitempty"`
+}
+
+// Serves the policy admin API:
+//
+//	GET  /admin/policies                          loaded policy file
+//	GET  /admin/policies/{interface}/{instance}   resolved policy of the instance
+//	POST /admin/policies/reload                   loads the policy file again
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		path := strings.Trim(strings.TrimPrefix(req.URL.Path, AdminPath), "/")
+
+		switch {
+		case req.Method == http.MethodGet && path == "":
+			writeJSON(w, http.StatusOK, r.Policies())
+		case req.Method == http.MethodPost && path == "reload":
+			if err := r.Load(); err != nil {
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+			writeJSON(w, http.StatusOK, r.Policies())
+		case req.Method == http.MethodGet && strings.Count(path, "/") == 1:
+			r.handleGetResolved(w, path)
+		case req.Method != http.MethodGet && req.Method != http.MethodPost:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func (r *Registry) handleGetResolved(w http.ResponseWriter, path string) {
+	parts := strings.Split(path, "/")
+	twinInterface, twinInstance := parts[0], parts[1]
+
+	values, scopes := r.ResolveValues(twinInterface, twinInstance)
+	policy, _, err := r.resolveRegistered(twinInterface, twinInstance)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resolvedPolicy{
+		Interface: twinInterface,
+		Instance:  twinInstance,
+		Scopes:    scopes,
+		Values:    values,
+		Policy:    policy,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		logger.Error("Error writing response", err)
+	}
+}
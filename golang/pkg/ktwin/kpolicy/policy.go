@@ -0,0 +1,401 @@
+package kpolicy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/ktwingraph"
+	log "github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
+	"gopkg.in/yaml.v3"
+)
+
+var logger = log.NewLogger()
+
+// Values of a policy by field, as in the yaml tags of the service config
+type Values map[string]interface{}
+
+// Policies is the policy file (policy.file or KTWIN_POLICY_FILE). The values of an interface
+// are its defaults, overridden by the values set on the instance or on any of its ancestors,
+// the nearest one winning:
+//
+//	defaults:
+//	  ngsi-ld-city-crowdflowobserved:
+//	    averageSpeedThreshold: 4
+//	overrides:
+//	  s4city-city-neighborhood-nb001:
+//	    ngsi-ld-city-crowdflowobserved:
+//	      averageSpeedThreshold: 6
+//
+// The ancestors are found walking up the twin graph through the hierarchy, the child interfaces
+// of each parent interface, which is DefaultHierarchy when the file does not set it.
+type Policies struct {
+	Hierarchy map[string][]string          `yaml:"hierarchy" json:"hierarchy"`
+	Defaults  map[string]Values            `yaml:"defaults" json:"defaults"`
+	Overrides map[string]map[string]Values `yaml:"overrides" json:"overrides"`
+}
+
+// Parent interfaces of the city twin graph and their child interfaces
+var DefaultHierarchy = map[string][]string{
+	"s4city-city-city":         {"s4city-city-neighborhood"},
+	"s4city-city-neighborhood": {"city-pole"},
+	"city-pole": {
+		"ngsi-ld-city-streetlight",
+		"ngsi-ld-city-airqualityobserved",
+		"ngsi-ld-city-noiselevelobserved",
+		"ngsi-ld-city-weatherobserved",
+		"ngsi-ld-city-crowdflowobserved",
+		"ngsi-ld-city-trafficflowobserved",
+		"ngsi-ld-city-evchargingstation",
+	},
+	"ngsi-ld-city-offstreetparking":   {"ngsi-ld-city-parkingspot"},
+	"ngsi-ld-city-onstreetparking":    {"ngsi-ld-city-parkingspot"},
+	"ngsi-ld-city-parkingspot":        {"ngsi-ld-city-device"},
+	"ngsi-ld-city-streetlight":        {"ngsi-ld-city-device"},
+	"ngsi-ld-city-airqualityobserved": {"ngsi-ld-city-device"},
+	"ngsi-ld-city-noiselevelobserved": {"ngsi-ld-city-device"},
+	"ngsi-ld-city-weatherobserved":    {"ngsi-ld-city-device"},
+}
+
+// Loads the twin graph of the interfaces
+type GraphLoader func(twinInterfaces []string) (ktwin.TwinGraph, error)
+
+// Registry resolves the policy of the twin instances. The policies can be loaded again at runtime
+// with Load, the previous ones are kept when they are not valid.
+type Registry struct {
+	file      string
+	loadGraph GraphLoader
+
+	mutex    sync.RWMutex
+	policies Policies
+	parents  map[string][]string
+	defaults map[string]interface{}
+}
+
+func NewRegistry(file string, loadGraph GraphLoader) *Registry {
+	return &Registry{
+		file:      file,
+		loadGraph: loadGraph,
+		parents:   map[string][]string{},
+		defaults:  map[string]interface{}{},
+	}
+}
+
+// Registers the policy of the interface with its defaults, a service config struct with yaml
+// tags. The values of the file are decoded into a copy of it and validated by its Validate method,
+// returning an error when the loaded policies of the interface are not valid.
+func (r *Registry) Register(twinInterface string, defaults interface{}) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.defaults[twinInterface] = defaults
+	return validatePolicies(&r.policies, map[string]interface{}{twinInterface: defaults})
+}
+
+// Loads the policy file and the twin graph of its hierarchy. Without a file there are no overrides.
+func (r *Registry) Load() error {
+	var policies Policies
+	if r.file != "" {
+		content, err := os.ReadFile(r.file)
+		if err != nil {
+			return err
+		}
+
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&policies); err != nil {
+			return fmt.Errorf("error parsing policy file %s: %w", r.file, err)
+		}
+	}
+
+	if policies.Hierarchy == nil {
+		policies.Hierarchy = DefaultHierarchy
+	}
+
+	r.mutex.RLock()
+	err := validatePolicies(&policies, r.defaults)
+	r.mutex.RUnlock()
+	if err != nil {
+		return fmt.Errorf("invalid policy file %s: %w", r.file, err)
+	}
+
+	parents := map[string][]string{}
+	if len(policies.Overrides) > 0 {
+		twinGraph, err := r.loadGraph(hierarchyInterfaces(policies.Hierarchy))
+		if err != nil {
+			return fmt.Errorf("error loading the twin graph of the policies: %w", err)
+		}
+		parents = indexParents(twinGraph, policies.Hierarchy)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.policies = policies
+	r.parents = parents
+	return nil
+}
+
+// Gets the loaded policy file
+func (r *Registry) Policies() Policies {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.policies
+}
+
+// Resolves the policy of the twin instance into policy, which holds its defaults: the values of
+// the interface defaults, then the ones of the ancestors from the farthest, then the instance ones.
+// It returns the scopes whose values were applied, the nearest first.
+func (r *Registry) Resolve(twinInterface, twinInstance string, policy interface{}) ([]string, error) {
+	values, scopes := r.ResolveValues(twinInterface, twinInstance)
+	if err := decodeValues(values, policy); err != nil {
+		return nil, fmt.Errorf("policy of %s: %w", twinInstance, err)
+	}
+	return scopes, nil
+}
+
+// Merges the values of the twin instance policy, see Resolve
+func (r *Registry) ResolveValues(twinInterface, twinInstance string) (Values, []string) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var scopes []string
+	for _, scope := range r.ancestors(twinInstance) {
+		if _, ok := r.policies.Overrides[scope][twinInterface]; ok {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	values := Values{}
+	for key, value := range r.policies.Defaults[twinInterface] {
+		values[key] = value
+	}
+	for i := len(scopes) - 1; i >= 0; i-- {
+		for key, value := range r.policies.Overrides[scopes[i]][twinInterface] {
+			values[key] = value
+		}
+	}
+
+	if _, ok := r.policies.Defaults[twinInterface]; ok {
+		scopes = append(scopes, "defaults")
+	}
+	return values, scopes
+}
+
+// Gets the defaults of the interface with its resolved policy, nil when it is not registered
+func (r *Registry) resolveRegistered(twinInterface, twinInstance string) (interface{}, []string, error) {
+	r.mutex.RLock()
+	defaults, ok := r.defaults[twinInterface]
+	r.mutex.RUnlock()
+	if !ok {
+		return nil, nil, nil
+	}
+
+	policy := newCopy(defaults)
+	scopes, err := r.Resolve(twinInterface, twinInstance, policy)
+	return policy, scopes, err
+}
+
+// Gets the instance followed by its ancestors, walking up the graph breadth first
+func (r *Registry) ancestors(twinInstance string) []string {
+	visited := map[string]bool{twinInstance: true}
+	result := []string{twinInstance}
+
+	for level := []string{twinInstance}; len(level) > 0; {
+		var next []string
+		for _, instance := range level {
+			for _, parent := range r.parents[instance] {
+				if !visited[parent] {
+					visited[parent] = true
+					next = append(next, parent)
+				}
+			}
+		}
+		sort.Strings(next)
+		result = append(result, next...)
+		level = next
+	}
+	return result
+}
+
+// Indexes the parent instances of each instance. A relationship links a parent and a child in
+// either direction, as the parent may reference the child or the child its parent.
+func indexParents(twinGraph ktwin.TwinGraph, hierarchy map[string][]string) map[string][]string {
+	isParent := map[[2]string]bool{}
+	for parent, children := range hierarchy {
+		for _, child := range children {
+			isParent[[2]string{parent, child}] = true
+		}
+	}
+
+	parents := map[string][]string{}
+	for _, instance := range twinGraph.TwinInstancesGraph {
+		for _, relationship := range instance.Relationships {
+			if isParent[[2]string{instance.Interface, relationship.Interface}] {
+				parents[relationship.Instance] = append(parents[relationship.Instance], instance.Name)
+			}
+			if isParent[[2]string{relationship.Interface, instance.Interface}] {
+				parents[instance.Name] = append(parents[instance.Name], relationship.Instance)
+			}
+		}
+	}
+	return parents
+}
+
+func hierarchyInterfaces(hierarchy map[string][]string) []string {
+	unique := map[string]bool{}
+	for parent, children := range hierarchy {
+		unique[parent] = true
+		for _, child := range children {
+			unique[child] = true
+		}
+	}
+
+	var interfaces []string
+	for twinInterface := range unique {
+		interfaces = append(interfaces, twinInterface)
+	}
+	sort.Strings(interfaces)
+	return interfaces
+}
+
+// Checks the defaults and each override of the registered interfaces decode into their policy
+func validatePolicies(policies *Policies, registered map[string]interface{}) error {
+	var errs []error
+	for twinInterface, defaults := range registered {
+		if err := decodeValues(policies.Defaults[twinInterface], newCopy(defaults)); err != nil {
+			errs = append(errs, fmt.Errorf("defaults.%s: %w", twinInterface, err))
+		}
+
+		for scope, overrides := range policies.Overrides {
+			values, ok := overrides[twinInterface]
+			if !ok {
+				continue
+			}
+
+			merged := Values{}
+			for key, value := range policies.Defaults[twinInterface] {
+				merged[key] = value
+			}
+			for key, value := range values {
+				merged[key] = value
+			}
+			if err := decodeValues(merged, newCopy(defaults)); err != nil {
+				errs = append(errs, fmt.Errorf("overrides.%s.%s: %w", scope, twinInterface, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func decodeValues(values Values, policy interface{}) error {
+	if len(values) > 0 {
+		// The maps of the defaults would be merged in place, shared with the defaults
+		cloneMaps(policy)
+
+		content, err := yaml.Marshal(values)
+		if err != nil {
+			return err
+		}
+
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(policy); err != nil {
+			return err
+		}
+	}
+
+	if validator, ok := policy.(interface{ Validate() error }); ok {
+		return validator.Validate()
+	}
+	return nil
+}
+
+// Replaces the maps of the fields of the struct pointed to by the policy with copies
+func cloneMaps(policy interface{}) {
+	value := reflect.ValueOf(policy)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return
+	}
+
+	value = value.Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		if field.Kind() != reflect.Map || field.IsNil() || !field.CanSet() {
+			continue
+		}
+
+		clone := reflect.MakeMapWithSize(field.Type(), field.Len())
+		iterator := field.MapRange()
+		for iterator.Next() {
+			clone.SetMapIndex(iterator.Key(), iterator.Value())
+		}
+		field.Set(clone)
+	}
+}
+
+// Creates a pointer to a copy of the value
+func newCopy(value interface{}) interface{} {
+	clone := reflect.New(reflect.TypeOf(value))
+	clone.Elem().Set(reflect.ValueOf(value))
+	return clone.Interface()
+}
+
+var (
+	registry      *Registry
+	registryMutex sync.Mutex
+)
+
+func Set(r *Registry) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	registry = r
+}
+
+func Reset() {
+	Set(nil)
+}
+
+// Gets the registry of the policy.file config (KTWIN_POLICY_FILE), loading it on the first call.
+// When the file can not be loaded the defaults are used until it is loaded again.
+func Get() *Registry {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if registry == nil {
+		registry = NewRegistry(config.Get().Policy.File, ktwingraph.LoadTwinGraphByInterfaces)
+		if err := registry.Load(); err != nil {
+			logger.Error("Error loading policies, using the defaults", err)
+		}
+	}
+	return registry
+}
+
+// Registers the policy of the interface in the registry, see Registry.Register
+func Register(twinInterface string, defaults interface{}) error {
+	return Get().Register(twinInterface, defaults)
+}
+
+// Resolves the policy of the twin instance, see Registry.Resolve. On error it is logged and
+// policy is expected to be reset to the defaults by the caller.
+func Resolve(twinInterface, twinInstance string, policy interface{}) error {
+	_, err := Get().Resolve(twinInterface, twinInstance, policy)
+	if err != nil {
+		logger.Error("Error resolving policy, using the defaults", err)
+	}
+	return err
+}
+
+// Gets the policy of the twin instance resolved from the defaults, see Resolve. The defaults are
+// returned when it can not be resolved.
+func ResolveOrDefault[T any](twinInterface, twinInstance string, defaults T) T {
+	policy := defaults
+	if err := Resolve(twinInterface, twinInstance, &policy); err != nil {
+		return defaults
+	}
+	return policy
+}
@@ -0,0 +1,218 @@
+package kpolicy
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestPolicySuite(t *testing.T) {
+	suite.Run(t, new(PolicySuite))
+}
+
+type PolicySuite struct {
+	suite.Suite
+
+	file     string
+	registry *Registry
+}
+
+type flowPolicy struct {
+	AverageSpeedThreshold float64       `yaml:"averageSpeedThreshold"`
+	HeadwayTimeThreshold  float64       `yaml:"headwayTimeThreshold"`
+	Window                time.Duration `yaml:"window"`
+}
+
+func (p *flowPolicy) Validate() error {
+	if p.AverageSpeedThreshold < 0 {
+		return errors.New("averageSpeedThreshold must not be negative")
+	}
+	return nil
+}
+
+var flowDefaults = flowPolicy{AverageSpeedThreshold: 4, HeadwayTimeThreshold: 2, Window: time.Hour}
+
+// The crowd flow p00007 is referenced by its pole, which references the neighborhood nb001
+var twinGraph = ktwin.TwinGraph{TwinInstancesGraph: []ktwin.TwinInstanceGraph{
+	{Name: "s4city-city-city", Interface: "s4city-city-city", Relationships: []ktwin.TwinInstanceReference{
+		{Name: "neighborhoods", Interface: "s4city-city-neighborhood", Instance: "s4city-city-neighborhood-nb001"},
+		{Name: "neighborhoods", Interface: "s4city-city-neighborhood", Instance: "s4city-city-neighborhood-nb002"},
+	}},
+	{Name: "city-pole-nb001-p00007", Interface: "city-pole", Relationships: []ktwin.TwinInstanceReference{
+		{Name: "refNeighborhood", Interface: "s4city-city-neighborhood", Instance: "s4city-city-neighborhood-nb001"},
+		{Name: "refCrowdFlow", Interface: "ngsi-ld-city-crowdflowobserved", Instance: "ngsi-ld-city-crowdflowobserved-nb001-p00007"},
+	}},
+	{Name: "city-pole-nb001-p00008", Interface: "city-pole", Relationships: []ktwin.TwinInstanceReference{
+		{Name: "refNeighborhood", Interface: "s4city-city-neighborhood", Instance: "s4city-city-neighborhood-nb001"},
+		{Name: "refCrowdFlow", Interface: "ngsi-ld-city-crowdflowobserved", Instance: "ngsi-ld-city-crowdflowobserved-nb001-p00008"},
+	}},
+	{Name: "city-pole-nb002-p00001", Interface: "city-pole", Relationships: []ktwin.TwinInstanceReference{
+		{Name: "refNeighborhood", Interface: "s4city-city-neighborhood", Instance: "s4city-city-neighborhood-nb002"},
+		{Name: "refCrowdFlow", Interface: "ngsi-ld-city-crowdflowobserved", Instance: "ngsi-ld-city-crowdflowobserved-nb002-p00001"},
+	}},
+}}
+
+const policies = `
+defaults:
+  ngsi-ld-city-crowdflowobserved:
+    averageSpeedThreshold: 5
+overrides:
+  s4city-city-city:
+    ngsi-ld-city-crowdflowobserved:
+      window: 2h
+  s4city-city-neighborhood-nb001:
+    ngsi-ld-city-crowdflowobserved:
+      averageSpeedThreshold: 6
+      headwayTimeThreshold: 3
+  ngsi-ld-city-crowdflowobserved-nb001-p00008:
+    ngsi-ld-city-crowdflowobserved:
+      averageSpeedThreshold: 8
+`
+
+func (s *PolicySuite) SetupTest() {
+	s.file = filepath.Join(s.T().TempDir(), "policies.yaml")
+	s.Require().NoError(os.WriteFile(s.file, []byte(policies), 0644))
+
+	s.registry = NewRegistry(s.file, func(twinInterfaces []string) (ktwin.TwinGraph, error) {
+		s.Assert().Contains(twinInterfaces, "city-pole")
+		return twinGraph, nil
+	})
+	s.Require().NoError(s.registry.Load())
+	s.Require().NoError(s.registry.Register("ngsi-ld-city-crowdflowobserved", flowDefaults))
+}
+
+func (s *PolicySuite) resolve(twinInstance string) (flowPolicy, []string) {
+	policy := flowDefaults
+	scopes, err := s.registry.Resolve("ngsi-ld-city-crowdflowobserved", twinInstance, &policy)
+	s.Require().NoError(err)
+	return policy, scopes
+}
+
+func (s *PolicySuite) Test_ResolveWalksUpTheGraph() {
+	policy, scopes := s.resolve("ngsi-ld-city-crowdflowobserved-nb001-p00007")
+	s.Assert().Equal(flowPolicy{AverageSpeedThreshold: 6, HeadwayTimeThreshold: 3, Window: 2 * time.Hour}, policy)
+	s.Assert().Equal([]string{"s4city-city-neighborhood-nb001", "s4city-city-city", "defaults"}, scopes)
+
+	// The instance override wins over the neighborhood one
+	policy, _ = s.resolve("ngsi-ld-city-crowdflowobserved-nb001-p00008")
+	s.Assert().Equal(flowPolicy{AverageSpeedThreshold: 8, HeadwayTimeThreshold: 3, Window: 2 * time.Hour}, policy)
+
+	// Other neighborhoods only get the city and interface values
+	policy, _ = s.resolve("ngsi-ld-city-crowdflowobserved-nb002-p00001")
+	s.Assert().Equal(flowPolicy{AverageSpeedThreshold: 5, HeadwayTimeThreshold: 2, Window: 2 * time.Hour}, policy)
+
+	// Instances out of the graph get the interface defaults
+	policy, scopes = s.resolve("ngsi-ld-city-crowdflowobserved-nb003-p00001")
+	s.Assert().Equal(flowPolicy{AverageSpeedThreshold: 5, HeadwayTimeThreshold: 2, Window: time.Hour}, policy)
+	s.Assert().Equal([]string{"defaults"}, scopes)
+}
+
+func (s *PolicySuite) Test_ResolveKeepsTheDefaultMaps() {
+	type weightsPolicy struct {
+		Weights map[string]float64 `yaml:"weights"`
+	}
+	s.Require().NoError(os.WriteFile(s.file, []byte(`
+overrides:
+  s4city-city-neighborhood-nb001:
+    s4city-city-neighborhood:
+      weights: {noise: 3}
+`), 0644))
+	s.Require().NoError(s.registry.Load())
+
+	defaults := weightsPolicy{Weights: map[string]float64{"air": 1}}
+	policy := defaults
+	_, err := s.registry.Resolve("s4city-city-neighborhood", "s4city-city-neighborhood-nb001", &policy)
+	s.Require().NoError(err)
+
+	s.Assert().Equal(map[string]float64{"air": 1, "noise": 3}, policy.Weights)
+	s.Assert().Equal(map[string]float64{"air": 1}, defaults.Weights)
+}
+
+func (s *PolicySuite) Test_ResolveOrDefault() {
+	Set(s.registry)
+	defer Reset()
+
+	policy := ResolveOrDefault("ngsi-ld-city-crowdflowobserved", "ngsi-ld-city-crowdflowobserved-nb001-p00008", flowDefaults)
+	s.Assert().Equal(flowPolicy{AverageSpeedThreshold: 8, HeadwayTimeThreshold: 3, Window: 2 * time.Hour}, policy)
+	s.Assert().Equal(4.0, flowDefaults.AverageSpeedThreshold)
+
+	// Defaults that can not be validated are returned as they are
+	invalid := flowPolicy{AverageSpeedThreshold: -1}
+	s.Assert().Equal(invalid, ResolveOrDefault("ngsi-ld-city-trafficflowobserved", "ngsi-ld-city-trafficflowobserved-nb001-p00007", invalid))
+}
+
+func (s *PolicySuite) Test_ReloadKeepsPoliciesWhenInvalid() {
+	s.Require().NoError(os.WriteFile(s.file, []byte(`
+overrides:
+  s4city-city-neighborhood-nb001:
+    ngsi-ld-city-crowdflowobserved:
+      averageSpeedThreshold: -1
+`), 0644))
+	s.Assert().ErrorContains(s.registry.Load(), "averageSpeedThreshold must not be negative")
+
+	s.Require().NoError(os.WriteFile(s.file, []byte(`
+overrides:
+  s4city-city-neighborhood-nb001:
+    ngsi-ld-city-crowdflowobserved:
+      averageSpeedTreshold: 7
+`), 0644))
+	s.Assert().ErrorContains(s.registry.Load(), "averageSpeedTreshold")
+
+	policy, _ := s.resolve("ngsi-ld-city-crowdflowobserved-nb001-p00007")
+	s.Assert().Equal(6.0, policy.AverageSpeedThreshold)
+
+	s.Require().NoError(os.WriteFile(s.file, []byte(`
+overrides:
+  s4city-city-neighborhood-nb001:
+    ngsi-ld-city-crowdflowobserved:
+      averageSpeedThreshold: 7
+`), 0644))
+	s.Require().NoError(s.registry.Load())
+
+	policy, _ = s.resolve("ngsi-ld-city-crowdflowobserved-nb001-p00007")
+	s.Assert().Equal(flowPolicy{AverageSpeedThreshold: 7, HeadwayTimeThreshold: 2, Window: time.Hour}, policy)
+}
+
+func (s *PolicySuite) Test_AdminHandler() {
+	server := httptest.NewServer(s.registry.Handler())
+	defer server.Close()
+
+	response, err := http.Get(server.URL + AdminPath + "/ngsi-ld-city-crowdflowobserved/ngsi-ld-city-crowdflowobserved-nb001-p00008")
+	s.Require().NoError(err)
+	defer response.Body.Close()
+	s.Require().Equal(http.StatusOK, response.StatusCode)
+
+	var resolved struct {
+		Scopes []string
+		Values Values
+		Policy flowPolicy
+	}
+	s.Require().NoError(json.NewDecoder(response.Body).Decode(&resolved))
+	s.Assert().Equal([]string{"ngsi-ld-city-crowdflowobserved-nb001-p00008", "s4city-city-neighborhood-nb001", "s4city-city-city", "defaults"}, resolved.Scopes)
+	s.Assert().Equal(8.0, resolved.Values["averageSpeedThreshold"])
+	s.Assert().Equal(flowPolicy{AverageSpeedThreshold: 8, HeadwayTimeThreshold: 3, Window: 2 * time.Hour}, resolved.Policy)
+
+	s.Require().NoError(os.WriteFile(s.file, []byte(`defaults: {}`), 0644))
+	response, err = http.Post(server.URL+AdminPath+"/reload", "", nil)
+	s.Require().NoError(err)
+	response.Body.Close()
+	s.Assert().Equal(http.StatusOK, response.StatusCode)
+
+	policy, scopes := s.resolve("ngsi-ld-city-crowdflowobserved-nb001-p00008")
+	s.Assert().Equal(flowDefaults, policy)
+	s.Assert().Empty(scopes)
+
+	s.Require().NoError(os.WriteFile(s.file, []byte(`defaults: [`), 0644))
+	response, err = http.Post(server.URL+AdminPath+"/reload", "", nil)
+	s.Require().NoError(err)
+	response.Body.Close()
+	s.Assert().Equal(http.StatusUnprocessableEntity, response.StatusCode)
+}
@@ -0,0 +1,174 @@
+package kscheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron is a recurring schedule, parsed from a cron expression with the fields
+// <minute> <hour> <day-of-month> <month> <day-of-week>, e.g. "*/15 * * * *". Each field is
+// *, a value, a range a-b or a list of them, optionally with a step /n. Sunday is 0 or 7.
+// The descriptors @hourly, @daily, @weekly, @monthly and @every <duration> are also accepted.
+type Cron struct {
+	minute, hour, dayOfMonth, month, dayOfWeek uint64
+
+	// Day of month and day of week are matched with OR when both are restricted
+	anyDayOfMonth, anyDayOfWeek bool
+
+	// Fixed interval of @every
+	every time.Duration
+}
+
+var descriptors = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+func ParseCron(expression string) (*Cron, error) {
+	expression = strings.TrimSpace(expression)
+
+	if strings.HasPrefix(expression, "@every ") {
+		every, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expression, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", expression, err)
+		}
+		if every < time.Minute {
+			return nil, fmt.Errorf("invalid cron expression %q: interval must be at least 1m", expression)
+		}
+		return &Cron{every: every}, nil
+	}
+
+	if descriptor, ok := descriptors[expression]; ok {
+		expression = descriptor
+	}
+
+	parts := strings.Fields(expression)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("invalid cron expression %q: expected %d fields, got %d", expression, len(cronFields), len(parts))
+	}
+
+	var bits [5]uint64
+	for i, part := range parts {
+		var err error
+		bits[i], err = parseCronField(part, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", expression, err)
+		}
+	}
+
+	// Sunday is both 0 and 7
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+
+	return &Cron{
+		minute:        bits[0],
+		hour:          bits[1],
+		dayOfMonth:    bits[2],
+		month:         bits[3],
+		dayOfWeek:     bits[4],
+		anyDayOfMonth: strings.HasPrefix(parts[2], "*"),
+		anyDayOfWeek:  strings.HasPrefix(parts[4], "*"),
+	}, nil
+}
+
+// Parses a field into the bits of its values
+func parseCronField(field string, bounds cronField) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		valueRange, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			valueRange = item[:i]
+			step, err = strconv.Atoi(item[i+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %s %q", bounds.name, item)
+			}
+		}
+
+		low, high := bounds.min, bounds.max
+		if valueRange != "*" {
+			var err error
+			values := strings.SplitN(valueRange, "-", 2)
+			if low, err = strconv.Atoi(values[0]); err != nil {
+				return 0, fmt.Errorf("invalid value in %s %q", bounds.name, item)
+			}
+			high = low
+			if len(values) == 2 {
+				if high, err = strconv.Atoi(values[1]); err != nil {
+					return 0, fmt.Errorf("invalid value in %s %q", bounds.name, item)
+				}
+			} else if step > 1 {
+				// a/n is a range up to the maximum
+				high = bounds.max
+			}
+		}
+
+		if low < bounds.min || high > bounds.max || low > high {
+			return 0, fmt.Errorf("%s %q out of range %d-%d", bounds.name, item, bounds.min, bounds.max)
+		}
+
+		for value := low; value <= high; value += step {
+			bits |= 1 << value
+		}
+	}
+	return bits, nil
+}
+
+// Gets the first time of the schedule after the time, zero when there is none in the next 5 years
+func (c *Cron) Next(after time.Time) time.Time {
+	if c.every > 0 {
+		return after.Add(c.every)
+	}
+
+	next := after.Truncate(time.Minute).Add(time.Minute)
+	limit := next.AddDate(5, 0, 0)
+
+	for next.Before(limit) {
+		if c.month&(1<<int(next.Month())) == 0 {
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, next.Location())
+			continue
+		}
+		if !c.matchesDay(next) {
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, next.Location())
+			continue
+		}
+		if c.hour&(1<<next.Hour()) == 0 {
+			next = time.Date(next.Year(), next.Month(), next.Day(), next.Hour()+1, 0, 0, 0, next.Location())
+			continue
+		}
+		if c.minute&(1<<next.Minute()) == 0 {
+			next = next.Add(time.Minute)
+			continue
+		}
+		return next
+	}
+	return time.Time{}
+}
+
+func (c *Cron) matchesDay(t time.Time) bool {
+	dayOfMonth := c.dayOfMonth&(1<<t.Day()) != 0
+	dayOfWeek := c.dayOfWeek&(1<<int(t.Weekday())) != 0
+
+	if c.anyDayOfMonth || c.anyDayOfWeek {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
@@ -0,0 +1,321 @@
+package kscheduler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/clock"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kevent"
+	log "github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
+)
+
+var logger = log.NewLogger()
+
+// Interval at which Run checks the due timers
+const Resolution = time.Second
+
+// Timer fires a ktwin.timer.<interface>.<name> event with the twin instance as source. A twin
+// instance has at most one timer of each name, scheduling it again replaces it.
+type Timer struct {
+	TwinInterface string `json:"twinInterface"`
+	TwinInstance  string `json:"twinInstance"`
+	Name          string `json:"name"`
+	// Cron expression of the recurring timers, empty for the one-shot ones
+	Cron string `json:"cron,omitempty"`
+	// Next time the timer fires
+	Next time.Time `json:"next"`
+	// Data of the fired events
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+func (t Timer) key() string {
+	return timerKey(t.TwinInterface, t.TwinInstance, t.Name)
+}
+
+func timerKey(twinInterface, twinInstance, name string) string {
+	return strings.Join([]string{twinInterface, twinInstance, strings.ToLower(name)}, "/")
+}
+
+// Builds the event fired by the timer
+func (t Timer) Event() (*ktwin.TwinEvent, error) {
+	ceType := fmt.Sprintf(ktwin.EventTimerFired, t.TwinInterface, strings.ToLower(t.Name))
+
+	var data interface{}
+	if len(t.Data) > 0 {
+		data = t.Data
+	}
+	return ktwin.NewTwinEventFromCloudEvent(ktwin.BuildCloudEvent(ceType, t.TwinInstance, data))
+}
+
+// Scheduler keeps the timers of the twin instances and fires the due ones into a handler. The
+// changed timers are persisted in its store by Run every Resolution, so handlers rescheduling a
+// timer on each event do not rewrite the store each time. The timers due while the service was
+// down fire on the first check after a restart, once.
+type Scheduler struct {
+	store Store
+
+	mutex  sync.Mutex
+	timers map[string]Timer
+	// Whether the timers changed since they were last saved
+	dirty bool
+}
+
+// Creates a scheduler with the timers of the store
+func NewScheduler(store Store) (*Scheduler, error) {
+	timers, err := store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("error loading timers: %w", err)
+	}
+
+	scheduler := &Scheduler{store: store, timers: map[string]Timer{}}
+	for _, timer := range timers {
+		scheduler.timers[timer.key()] = timer
+	}
+	return scheduler, nil
+}
+
+// Schedules a one-shot timer of the twin instance firing at the time
+func (s *Scheduler) Schedule(twinInterface, twinInstance, name string, at time.Time, data interface{}) error {
+	timer, err := newTimer(twinInterface, twinInstance, name, data)
+	if err != nil {
+		return err
+	}
+	timer.Next = at.UTC()
+	return s.set(timer)
+}
+
+// Schedules a recurring timer of the twin instance firing at the times of the cron expression,
+// see ParseCron
+func (s *Scheduler) ScheduleCron(twinInterface, twinInstance, name, expression string, data interface{}) error {
+	cron, err := ParseCron(expression)
+	if err != nil {
+		return err
+	}
+
+	timer, err := newTimer(twinInterface, twinInstance, name, data)
+	if err != nil {
+		return err
+	}
+	timer.Cron = expression
+	timer.Next = cron.Next(*clock.Now())
+	if timer.Next.IsZero() {
+		return fmt.Errorf("cron expression %q never fires", expression)
+	}
+	return s.set(timer)
+}
+
+func newTimer(twinInterface, twinInstance, name string, data interface{}) (Timer, error) {
+	timer := Timer{TwinInterface: twinInterface, TwinInstance: twinInstance, Name: name}
+
+	ceType := fmt.Sprintf(ktwin.EventTimerFired, twinInterface, strings.ToLower(name))
+	if _, err := ktwin.ParseEventType(ceType); err != nil {
+		return timer, err
+	}
+
+	if data != nil {
+		content, err := json.Marshal(data)
+		if err != nil {
+			return timer, err
+		}
+		timer.Data = content
+	}
+	return timer, nil
+}
+
+func (s *Scheduler) set(timer Timer) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if current, ok := s.timers[timer.key()]; ok && current.equal(timer) {
+		return nil
+	}
+	s.timers[timer.key()] = timer
+	s.dirty = true
+	return nil
+}
+
+func (t Timer) equal(other Timer) bool {
+	return t.Next.Equal(other.Next) && t.Cron == other.Cron && bytes.Equal(t.Data, other.Data)
+}
+
+// Cancels the timer of the twin instance, if any
+func (s *Scheduler) Cancel(twinInterface, twinInstance, name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := timerKey(twinInterface, twinInstance, name)
+	if _, ok := s.timers[key]; !ok {
+		return nil
+	}
+	delete(s.timers, key)
+	s.dirty = true
+	return nil
+}
+
+// Gets the timers, the next to fire first
+func (s *Scheduler) Timers() []Timer {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.sortedTimers()
+}
+
+func (s *Scheduler) sortedTimers() []Timer {
+	timers := make([]Timer, 0, len(s.timers))
+	for _, timer := range s.timers {
+		timers = append(timers, timer)
+	}
+	sort.Slice(timers, func(i, j int) bool {
+		if !timers[i].Next.Equal(timers[j].Next) {
+			return timers[i].Next.Before(timers[j].Next)
+		}
+		return timers[i].key() < timers[j].key()
+	})
+	return timers
+}
+
+// Saves the timers in the store when they changed since they were last saved
+func (s *Scheduler) Flush() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.save()
+}
+
+// Must be called with the mutex held
+func (s *Scheduler) save() error {
+	if !s.dirty {
+		return nil
+	}
+	if err := s.store.Save(s.sortedTimers()); err != nil {
+		return fmt.Errorf("error saving timers: %w", err)
+	}
+	s.dirty = false
+	return nil
+}
+
+// Fires the timers due at now into the handler. The one-shot timers are removed and the recurring
+// ones moved to their next time and saved before the events are handled, so the handlers may
+// schedule them again, and a timer fires at most once even when the handler fails.
+func (s *Scheduler) Fire(now time.Time, handleEvent func(*ktwin.TwinEvent) error) {
+	var due []Timer
+
+	s.mutex.Lock()
+	for _, timer := range s.sortedTimers() {
+		if timer.Next.After(now) {
+			break
+		}
+		due = append(due, timer)
+
+		if timer.Cron == "" {
+			delete(s.timers, timer.key())
+			continue
+		}
+
+		cron, err := ParseCron(timer.Cron)
+		if err == nil {
+			timer.Next = cron.Next(now)
+		}
+		if err != nil || timer.Next.IsZero() {
+			logger.Error(fmt.Sprintf("Removing timer %s of TwinInstance %s", timer.Name, timer.TwinInstance), err)
+			delete(s.timers, timer.key())
+			continue
+		}
+		s.timers[timer.key()] = timer
+	}
+
+	if len(due) > 0 {
+		s.dirty = true
+		if err := s.save(); err != nil {
+			logger.Error("Error saving fired timers", err)
+		}
+	}
+	s.mutex.Unlock()
+
+	for _, timer := range due {
+		twinEvent, err := timer.Event()
+		if err != nil {
+			logger.Error(fmt.Sprintf("Error building event of timer %s of TwinInstance %s", timer.Name, timer.TwinInstance), err)
+			continue
+		}
+
+		logger.Info(fmt.Sprintf("Firing timer %s of TwinInstance %s - Ce-Type: %s", timer.Name, timer.TwinInstance, twinEvent.CloudEvent.Type()))
+		result := kevent.ProcessEvent(twinEvent, handleEvent)
+		if result.Status != http.StatusOK {
+			logger.Error(fmt.Sprintf("Error handling timer event %s: %s", result.ID, result.Error), nil)
+		}
+	}
+}
+
+// Fires the due timers into the handler and saves the changed timers every Resolution until the
+// context is done
+func (s *Scheduler) Run(ctx context.Context, handleEvent func(*ktwin.TwinEvent) error) {
+	ticker := time.NewTicker(Resolution)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := s.Flush(); err != nil {
+				logger.Error("Error saving timers", err)
+			}
+			return
+		case <-ticker.C:
+			s.Fire(*clock.Now(), handleEvent)
+			if err := s.Flush(); err != nil {
+				logger.Error("Error saving timers", err)
+			}
+		}
+	}
+}
+
+// Calls the callback with the timer events of the interface with the name
+func HandleTimer(twinEvent *ktwin.TwinEvent, twinInterface, name string, callback func(*ktwin.TwinEvent) error) error {
+	if twinEvent.EventType == ktwin.TimerEvent && strings.EqualFold(twinEvent.TwinInterface, twinInterface) && strings.EqualFold(twinEvent.TimerName, name) {
+		return callback(twinEvent)
+	}
+	return nil
+}
+
+var (
+	scheduler      *Scheduler
+	schedulerMutex sync.Mutex
+)
+
+func Set(s *Scheduler) {
+	schedulerMutex.Lock()
+	defer schedulerMutex.Unlock()
+	scheduler = s
+}
+
+func Reset() {
+	Set(nil)
+}
+
+// Gets the scheduler of the scheduler.file config (KTWIN_SCHEDULER_FILE), loading it on the first
+// call. Without a file, or when it can not be loaded, the timers are kept in memory.
+func Get() *Scheduler {
+	schedulerMutex.Lock()
+	defer schedulerMutex.Unlock()
+
+	if scheduler == nil {
+		var err error
+		if file := config.Get().Scheduler.File; file != "" {
+			scheduler, err = NewScheduler(NewFileStore(file))
+			if err != nil {
+				logger.Error("Error loading the scheduler file, keeping the timers in memory", err)
+			}
+		}
+		if scheduler == nil {
+			scheduler, _ = NewScheduler(NewMemoryStore())
+		}
+	}
+	return scheduler
+}
@@ -0,0 +1,187 @@
+package kscheduler
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/clock"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestSchedulerSuite(t *testing.T) {
+	suite.Run(t, new(SchedulerSuite))
+}
+
+type SchedulerSuite struct {
+	suite.Suite
+
+	now   time.Time
+	fired []*ktwin.TwinEvent
+}
+
+const streetlightInterface = "ngsi-ld-city-streetlight"
+
+func (s *SchedulerSuite) SetupTest() {
+	s.now = time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	s.fired = nil
+	clock.NowFunc = func() *time.Time {
+		return &s.now
+	}
+}
+
+func (s *SchedulerSuite) TearDownTest() {
+	clock.ResetClockImplementation()
+}
+
+func (s *SchedulerSuite) handleEvent(twinEvent *ktwin.TwinEvent) error {
+	s.fired = append(s.fired, twinEvent)
+	return nil
+}
+
+func (s *SchedulerSuite) Test_ParseCron() {
+	tests := []struct {
+		name       string
+		expression string
+		after      time.Time
+		expected   time.Time
+	}{
+		{"Every 15 minutes", "*/15 * * * *", s.now.Add(time.Minute), time.Date(2024, 5, 10, 12, 15, 0, 0, time.UTC)},
+		{"Daily at 02:30", "30 2 * * *", s.now, time.Date(2024, 5, 11, 2, 30, 0, 0, time.UTC)},
+		{"Sunday as 7", "0 0 * * 7", s.now, time.Date(2024, 5, 12, 0, 0, 0, 0, time.UTC)},
+		{"Day of month or day of week", "0 0 1 * 1", s.now, time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC)},
+		{"Range and list", "0 8-9,18 * 6 *", s.now, time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC)},
+		{"Descriptor", "@monthly", s.now, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{"Fixed interval", "@every 90m", s.now, s.now.Add(90 * time.Minute)},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			cron, err := ParseCron(tt.expression)
+			s.Require().NoError(err)
+			s.Assert().Equal(tt.expected, cron.Next(tt.after))
+		})
+	}
+
+	for _, expression := range []string{"* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "@every 10s", "@yearly"} {
+		_, err := ParseCron(expression)
+		s.Assert().Error(err, expression)
+	}
+}
+
+func (s *SchedulerSuite) Test_OneShotTimer() {
+	scheduler, err := NewScheduler(NewMemoryStore())
+	s.Require().NoError(err)
+
+	s.Require().NoError(scheduler.Schedule(streetlightInterface, "ngsi-ld-city-streetlight-nb001-p00007", "checkDefect", s.now.Add(time.Hour), map[string]string{"powerState": "on"}))
+	// Scheduling it again replaces it
+	s.Require().NoError(scheduler.Schedule(streetlightInterface, "ngsi-ld-city-streetlight-nb001-p00007", "checkDefect", s.now.Add(2*time.Hour), nil))
+	s.Require().Len(scheduler.Timers(), 1)
+
+	scheduler.Fire(s.now.Add(time.Hour), s.handleEvent)
+	s.Assert().Empty(s.fired)
+
+	scheduler.Fire(s.now.Add(2*time.Hour), s.handleEvent)
+	s.Require().Len(s.fired, 1)
+	s.Assert().Equal("ktwin.timer.ngsi-ld-city-streetlight.checkdefect", s.fired[0].CloudEvent.Type())
+	s.Assert().Equal(ktwin.TimerEvent, s.fired[0].EventType)
+	s.Assert().Equal("ngsi-ld-city-streetlight-nb001-p00007", s.fired[0].TwinInstance)
+	s.Assert().Empty(scheduler.Timers())
+
+	scheduler.Fire(s.now.Add(3*time.Hour), s.handleEvent)
+	s.Assert().Len(s.fired, 1)
+}
+
+func (s *SchedulerSuite) Test_CronTimer() {
+	scheduler, err := NewScheduler(NewMemoryStore())
+	s.Require().NoError(err)
+
+	s.Require().NoError(scheduler.ScheduleCron("s4city-city-neighborhood", "s4city-city-neighborhood-nb001", "expire", "@hourly", nil))
+	s.Assert().Equal(s.now.Add(time.Hour), scheduler.Timers()[0].Next)
+
+	// The times missed while the service was down fire once
+	scheduler.Fire(s.now.Add(3*time.Hour+time.Minute), s.handleEvent)
+	s.Require().Len(s.fired, 1)
+	s.Assert().Equal("ktwin.timer.s4city-city-neighborhood.expire", s.fired[0].CloudEvent.Type())
+	s.Assert().Equal(s.now.Add(4*time.Hour), scheduler.Timers()[0].Next)
+
+	s.Require().NoError(scheduler.Cancel("s4city-city-neighborhood", "s4city-city-neighborhood-nb001", "EXPIRE"))
+	s.Assert().Empty(scheduler.Timers())
+
+	s.Assert().Error(scheduler.ScheduleCron("s4city-city-neighborhood", "s4city-city-neighborhood-nb001", "expire", "0 0 31 2 *", nil))
+	s.Assert().Error(scheduler.Schedule("s4city-city-neighborhood", "s4city-city-neighborhood-nb001", "expire.now", s.now, nil))
+}
+
+func (s *SchedulerSuite) Test_TimersSurviveRestart() {
+	file := filepath.Join(s.T().TempDir(), "timers.json")
+
+	scheduler, err := NewScheduler(NewFileStore(file))
+	s.Require().NoError(err)
+	s.Require().NoError(scheduler.Schedule(streetlightInterface, "ngsi-ld-city-streetlight-nb001-p00007", "checkDefect", s.now.Add(time.Hour), map[string]string{"powerState": "on"}))
+	s.Require().NoError(scheduler.ScheduleCron(streetlightInterface, "ngsi-ld-city-streetlight-nb001-p00008", "report", "0 0 * * *", nil))
+	s.Require().NoError(scheduler.Flush())
+
+	restarted, err := NewScheduler(NewFileStore(file))
+	s.Require().NoError(err)
+	s.Require().Equal(scheduler.Timers(), restarted.Timers())
+
+	// The handler may schedule the fired timer again
+	restarted.Fire(s.now.Add(time.Hour), func(twinEvent *ktwin.TwinEvent) error {
+		s.fired = append(s.fired, twinEvent)
+		return restarted.Schedule(twinEvent.TwinInterface, twinEvent.TwinInstance, twinEvent.TimerName, s.now.Add(2*time.Hour), nil)
+	})
+	s.Require().Len(s.fired, 1)
+	s.Assert().JSONEq(`{"powerState":"on"}`, string(s.fired[0].CloudEvent.Data()))
+	s.Require().NoError(restarted.Flush())
+
+	restarted, err = NewScheduler(NewFileStore(file))
+	s.Require().NoError(err)
+	timers := restarted.Timers()
+	s.Require().Len(timers, 2)
+	s.Assert().Equal(s.now.Add(2*time.Hour), timers[0].Next)
+	s.Assert().Equal(time.Date(2024, 5, 11, 0, 0, 0, 0, time.UTC), timers[1].Next)
+}
+
+type countingStore struct {
+	MemoryStore
+	saves int
+}
+
+func (s *countingStore) Save(timers []Timer) error {
+	s.saves++
+	return s.MemoryStore.Save(timers)
+}
+
+func (s *SchedulerSuite) Test_SavesChangedTimersOnFlush() {
+	store := &countingStore{}
+	scheduler, err := NewScheduler(store)
+	s.Require().NoError(err)
+
+	// Rescheduling on each event is saved once
+	for minutes := 1; minutes <= 3; minutes++ {
+		s.Require().NoError(scheduler.Schedule(streetlightInterface, "ngsi-ld-city-streetlight-nb001-p00007", "checkDefect", s.now.Add(time.Duration(minutes)*time.Minute), nil))
+	}
+	s.Assert().Equal(0, store.saves)
+	s.Require().NoError(scheduler.Flush())
+	s.Assert().Equal(1, store.saves)
+
+	// Scheduling the same timer again is not a change
+	s.Require().NoError(scheduler.Schedule(streetlightInterface, "ngsi-ld-city-streetlight-nb001-p00007", "checkDefect", s.now.Add(3*time.Minute), nil))
+	s.Require().NoError(scheduler.Cancel(streetlightInterface, "ngsi-ld-city-streetlight-nb001-p00007", "report"))
+	s.Require().NoError(scheduler.Flush())
+	s.Assert().Equal(1, store.saves)
+
+	timers, err := store.Load()
+	s.Require().NoError(err)
+	s.Require().Len(timers, 1)
+	s.Assert().Equal(s.now.Add(3*time.Minute), timers[0].Next)
+
+	// Fired timers are saved before the events are handled
+	scheduler.Fire(s.now.Add(3*time.Minute), s.handleEvent)
+	s.Assert().Len(s.fired, 1)
+	s.Assert().Equal(2, store.saves)
+	timers, err = store.Load()
+	s.Require().NoError(err)
+	s.Assert().Empty(timers)
+}
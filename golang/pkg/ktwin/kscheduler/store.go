@@ -0,0 +1,87 @@
+package kscheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Store persists the timers of the scheduler, they are saved whole when they changed
+type Store interface {
+	Load() ([]Timer, error)
+	Save(timers []Timer) error
+}
+
+// FileStore keeps the timers in a JSON file, written to a temporary file and renamed so a
+// crash while saving does not lose the previous timers
+type FileStore struct {
+	file string
+}
+
+func NewFileStore(file string) *FileStore {
+	return &FileStore{file: file}
+}
+
+// Loads the timers of the file, none when it does not exist yet
+func (s *FileStore) Load() ([]Timer, error) {
+	content, err := os.ReadFile(s.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var timers []Timer
+	if err := json.Unmarshal(content, &timers); err != nil {
+		return nil, err
+	}
+	return timers, nil
+}
+
+func (s *FileStore) Save(timers []Timer) error {
+	content, err := json.Marshal(timers)
+	if err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(s.file), filepath.Base(s.file)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(content); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), s.file)
+}
+
+// MemoryStore keeps the timers in memory, they are lost on restart
+type MemoryStore struct {
+	mutex  sync.Mutex
+	timers []Timer
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (s *MemoryStore) Load() ([]Timer, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]Timer(nil), s.timers...), nil
+}
+
+func (s *MemoryStore) Save(timers []Timer) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.timers = append([]Timer(nil), timers...)
+	return nil
+}
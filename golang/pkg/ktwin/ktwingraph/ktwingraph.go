@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
 
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
 	log "github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
 )
 
@@ -21,6 +23,9 @@ func LoadTwinGraphByInterfaces(twinInterfaces []string) (ktwin.TwinGraph, error)
 		if err != nil {
 			logger.Error("Error getting Twin Graph instance", err)
 		}
+		if ktwinGraph == nil {
+			continue
+		}
 		ktwinGraphList = append(ktwinGraphList, ktwinGraph.TwinInstancesGraph...)
 	}
 
@@ -31,7 +36,7 @@ func LoadTwinGraphByInterfaces(twinInterfaces []string) (ktwin.TwinGraph, error)
 
 	ktwinGraph.TwinInstancesGraph = ktwinGraphList
 
-	if os.Getenv("ENV") != "local" && os.Getenv("ENV") != "test" {
+	if c := config.Get(); !c.IsLocal() && !c.IsTest() {
 		writeTwinGraph(ktwinGraph)
 	}
 	return ktwinGraph, nil
@@ -40,18 +45,17 @@ func LoadTwinGraphByInterfaces(twinInterfaces []string) (ktwin.TwinGraph, error)
 func getTwinGraphInstance(twinInterface string) (*ktwin.TwinGraph, error) {
 	var ktwinGraph ktwin.TwinGraph
 
-	if os.Getenv("ENV") == "local" || os.Getenv("ENV") == "test" {
+	// Local runs may use a ktwin-graph-server through the graph url, tests always use the graph file
+	c := config.Get()
+	if c.IsTest() || (c.IsLocal() && c.Graph.URL == "") {
 		ktwinGraph, err := loadLocalTwinGraph()
-		filteredGraph := &ktwin.TwinGraph{}
-		for _, instanceGraph := range ktwinGraph.TwinInstancesGraph {
-			if instanceGraph.Interface == twinInterface {
-				filteredGraph.TwinInstancesGraph = append(filteredGraph.TwinInstancesGraph, instanceGraph)
-			}
+		if err != nil {
+			return nil, err
 		}
-		return filteredGraph, err
+		return FilterTwinGraphByInterface(*ktwinGraph, twinInterface), nil
 	}
 
-	ktwinGraphStoreURL := os.Getenv("KTWIN_GRAPH_URL")
+	ktwinGraphStoreURL := c.Graph.URL
 	response, err := http.Get(ktwinGraphStoreURL + "/" + twinInterface)
 	if err != nil {
 		fmt.Println("Error while calling service:", err)
@@ -77,8 +81,14 @@ func getTwinGraphInstance(twinInterface string) (*ktwin.TwinGraph, error) {
 	return &ktwinGraph, nil
 }
 
+// Loads the graph from the graph file or directory (KTWIN_GRAPH_FILE), or from the graph JSON (KTWIN_GRAPH)
 func loadLocalTwinGraph() (*ktwin.TwinGraph, error) {
-	jsonStr := os.Getenv("KTWIN_GRAPH")
+	c := config.Get()
+	if c.Graph.File != "" {
+		return LoadTwinGraphFile(c.Graph.File)
+	}
+
+	jsonStr := c.Graph.JSON
 	var result ktwin.TwinGraph
 	err := json.Unmarshal([]byte(jsonStr), &result)
 	if err != nil {
@@ -88,6 +98,56 @@ func loadLocalTwinGraph() (*ktwin.TwinGraph, error) {
 	return &result, nil
 }
 
+// Loads a twin graph file, or all the graph files of a directory merged in a single graph
+func LoadTwinGraphFile(path string) (*ktwin.TwinGraph, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+
+		paths = nil
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				paths = append(paths, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+
+	var result ktwin.TwinGraph
+	for _, graphPath := range paths {
+		content, err := os.ReadFile(graphPath)
+		if err != nil {
+			return nil, err
+		}
+
+		var graph ktwin.TwinGraph
+		if err := json.Unmarshal(content, &graph); err != nil {
+			return nil, fmt.Errorf("error parsing twin graph file %s: %w", graphPath, err)
+		}
+		result.TwinInstancesGraph = append(result.TwinInstancesGraph, graph.TwinInstancesGraph...)
+	}
+
+	return &result, nil
+}
+
+// Get the instances of the twin interface in the graph
+func FilterTwinGraphByInterface(twinGraph ktwin.TwinGraph, twinInterface string) *ktwin.TwinGraph {
+	filteredGraph := &ktwin.TwinGraph{}
+	for _, instanceGraph := range twinGraph.TwinInstancesGraph {
+		if instanceGraph.Interface == twinInterface {
+			filteredGraph.TwinInstancesGraph = append(filteredGraph.TwinInstancesGraph, instanceGraph)
+		}
+	}
+	return filteredGraph
+}
+
 func writeTwinGraph(ktwinGraph ktwin.TwinGraph) error {
 	graphByteArray, err := json.Marshal(ktwinGraph)
 
@@ -119,6 +179,44 @@ func GetRelationshipFromGraph(twinInstance, relationshipName string, twinGraph k
 	return nil
 }
 
+// Get the twin instance with the relationship by name to the instance, following the relationship in
+// reverse. The reference is the relationship from the instance back to it, e.g. the city of a
+// neighborhood from the neighborhoods relationship of the city.
+func GetReverseRelationshipFromGraph(twinInstance, relationshipName string, twinGraph ktwin.TwinGraph) *ktwin.TwinInstanceReference {
+	for _, instance := range twinGraph.TwinInstancesGraph {
+		for _, relationship := range instance.Relationships {
+			if relationship.Name == relationshipName && relationship.Instance == twinInstance {
+				return &ktwin.TwinInstanceReference{
+					Name:      relationshipName,
+					Interface: instance.Interface,
+					Instance:  instance.Name,
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Get the twin instances with the relationship by name to the instance, e.g. the parking spots
+// of a parking from their refOffStreetParking relationship
+func GetReverseRelationshipsFromGraph(twinInstance, relationshipName string, twinGraph ktwin.TwinGraph) []ktwin.TwinInstanceReference {
+	var references []ktwin.TwinInstanceReference
+	for _, instance := range twinGraph.TwinInstancesGraph {
+		for _, relationship := range instance.Relationships {
+			if relationship.Name == relationshipName && relationship.Instance == twinInstance {
+				references = append(references, ktwin.TwinInstanceReference{
+					Name:      relationshipName,
+					Interface: instance.Interface,
+					Instance:  instance.Name,
+				})
+			}
+		}
+	}
+
+	return references
+}
+
 // Get Twin Graph Node by twin instance and interface
 func GetTwinGraphByRelation(targetTwinInterface, sourceTwinInstance string, twinGraph ktwin.TwinGraph) *ktwin.TwinInstanceReference {
 	for _, sourceTwinGraph := range twinGraph.TwinInstancesGraph {
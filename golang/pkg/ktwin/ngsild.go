@@ -0,0 +1,280 @@
+package ktwin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// NGSI-LD representation adapter.
+//
+// Decodes NGSI-LD entities, either in normalized form ({"type":"Property","value":...})
+// or in keyValues form ({"attr": value}), into the flat twin models and encodes the
+// models back into normalized entities. The entity metadata that has no place in the
+// models (observedAt, unitCode, Relationship objects and @context) is kept in the
+// NGSILDEntity so it is written back when the model is encoded again.
+
+const (
+	ApplicationLDJSON = "application/ld+json"
+
+	NGSILDProperty     = "Property"
+	NGSILDRelationship = "Relationship"
+	NGSILDGeoProperty  = "GeoProperty"
+
+	NGSILDCoreContext = "https://uri.etsi.org/ngsi-ld/v1/ngsi-ld-core-context.jsonld"
+)
+
+type NGSILDAttribute struct {
+	Type       string      `json:"type"`
+	Value      interface{} `json:"value,omitempty"`
+	Object     interface{} `json:"object,omitempty"`
+	ObservedAt string      `json:"observedAt,omitempty"`
+	UnitCode   string      `json:"unitCode,omitempty"`
+}
+
+type NGSILDEntity struct {
+	ID         string
+	Type       string
+	Context    interface{}
+	Attributes map[string]NGSILDAttribute
+}
+
+func NewNGSILDEntity(id, entityType string) *NGSILDEntity {
+	return &NGSILDEntity{
+		ID:         id,
+		Type:       entityType,
+		Context:    NGSILDCoreContext,
+		Attributes: map[string]NGSILDAttribute{},
+	}
+}
+
+func (e *NGSILDEntity) UnmarshalJSON(data []byte) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+
+	entity := NGSILDEntity{Attributes: map[string]NGSILDAttribute{}}
+
+	for name, raw := range members {
+		var err error
+		switch name {
+		case "id":
+			err = json.Unmarshal(raw, &entity.ID)
+		case "type":
+			err = json.Unmarshal(raw, &entity.Type)
+		case "@context":
+			err = json.Unmarshal(raw, &entity.Context)
+		default:
+			entity.Attributes[name], err = decodeNGSILDAttribute(raw)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid NGSI-LD attribute %s: %w", name, err)
+		}
+	}
+
+	*e = entity
+	return nil
+}
+
+// Normalized attributes are objects with a NGSI-LD attribute type, any other value
+// is a keyValues attribute and becomes a Property
+func decodeNGSILDAttribute(raw json.RawMessage) (NGSILDAttribute, error) {
+	var attribute NGSILDAttribute
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("{")) {
+		if err := json.Unmarshal(raw, &attribute); err == nil && isNGSILDAttributeType(attribute.Type) {
+			if attribute.Type == NGSILDRelationship && attribute.Object == nil {
+				return NGSILDAttribute{}, errors.New("relationship without object")
+			}
+			attribute.Value = unwrapNGSILDValue(attribute.Value)
+			return attribute, nil
+		}
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return NGSILDAttribute{}, err
+	}
+	return NGSILDAttribute{Type: NGSILDProperty, Value: value}, nil
+}
+
+func isNGSILDAttributeType(attributeType string) bool {
+	return attributeType == NGSILDProperty || attributeType == NGSILDRelationship || attributeType == NGSILDGeoProperty
+}
+
+// Typed values such as {"@type":"DateTime","@value":"2023-06-01T10:00:00Z"} are unwrapped
+func unwrapNGSILDValue(value interface{}) interface{} {
+	if typed, ok := value.(map[string]interface{}); ok {
+		if v, ok := typed["@value"]; ok {
+			return v
+		}
+	}
+	return value
+}
+
+func (e NGSILDEntity) MarshalJSON() ([]byte, error) {
+	members := map[string]interface{}{}
+	for name, attribute := range e.Attributes {
+		members[name] = attribute
+	}
+	if e.ID != "" {
+		members["id"] = e.ID
+	}
+	if e.Type != "" {
+		members["type"] = e.Type
+	}
+	if e.Context != nil {
+		members["@context"] = e.Context
+	}
+	return json.Marshal(members)
+}
+
+// Gets the entity in keyValues form, with Relationships replaced by their object
+func (e *NGSILDEntity) KeyValues() map[string]interface{} {
+	keyValues := map[string]interface{}{}
+	for name, attribute := range e.Attributes {
+		if attribute.Type == NGSILDRelationship {
+			keyValues[name] = attribute.Object
+		} else {
+			keyValues[name] = attribute.Value
+		}
+	}
+	return keyValues
+}
+
+// Decodes a normalized or keyValues NGSI-LD entity into the model. Properties that are
+// not declared in the model are rejected, Relationships are only set in the model when it
+// declares the attribute and are otherwise kept in the returned entity.
+func DecodeNGSILD(data []byte, model interface{}) (*NGSILDEntity, error) {
+	return decodeNGSILD(data, model, (jsonCodec{}).Unmarshal)
+}
+
+func decodeNGSILD(data []byte, model interface{}, unmarshal func([]byte, interface{}) error) (*NGSILDEntity, error) {
+	entity := &NGSILDEntity{}
+	if err := json.Unmarshal(data, entity); err != nil {
+		return nil, err
+	}
+
+	modelAttributes := ngsildModelAttributes(model)
+	keyValues := map[string]interface{}{}
+	for name, value := range entity.KeyValues() {
+		if entity.Attributes[name].Type == NGSILDRelationship && !modelAttributes[name] {
+			continue
+		}
+		keyValues[name] = value
+	}
+
+	encoded, err := json.Marshal(keyValues)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := unmarshal(encoded, model); err != nil {
+		return nil, err
+	}
+
+	return entity, nil
+}
+
+// Encodes the model as a normalized NGSI-LD entity. The id, type, @context, attribute
+// metadata and Relationships of the entity are kept, model attributes replace the values.
+func EncodeNGSILD(model interface{}, entity *NGSILDEntity) ([]byte, error) {
+	encoded := NGSILDEntity{Context: NGSILDCoreContext, Attributes: map[string]NGSILDAttribute{}}
+	if entity != nil {
+		encoded.ID = entity.ID
+		encoded.Type = entity.Type
+		encoded.Context = entity.Context
+		for name, attribute := range entity.Attributes {
+			if attribute.Type == NGSILDRelationship {
+				encoded.Attributes[name] = attribute
+			}
+		}
+	}
+
+	keyValues, err := modelKeyValues(model)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range keyValues {
+		attribute := NGSILDAttribute{Type: NGSILDProperty}
+		if entity != nil {
+			if previous, ok := entity.Attributes[name]; ok {
+				attribute = previous
+			}
+		}
+
+		if attribute.Type == NGSILDRelationship {
+			attribute.Object = value
+		} else {
+			attribute.Value = value
+		}
+		encoded.Attributes[name] = attribute
+	}
+
+	return json.Marshal(encoded)
+}
+
+// Encodes the model as a keyValues NGSI-LD entity
+func EncodeNGSILDKeyValues(model interface{}, id, entityType string) ([]byte, error) {
+	keyValues, err := modelKeyValues(model)
+	if err != nil {
+		return nil, err
+	}
+
+	keyValues["id"] = id
+	keyValues["type"] = entityType
+	return json.Marshal(keyValues)
+}
+
+func modelKeyValues(model interface{}) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(model)
+	if err != nil {
+		return nil, err
+	}
+
+	var keyValues map[string]interface{}
+	if err := json.Unmarshal(encoded, &keyValues); err != nil {
+		return nil, fmt.Errorf("NGSI-LD entities can only be encoded from models: %w", err)
+	}
+	return keyValues, nil
+}
+
+func ngsildModelAttributes(model interface{}) map[string]bool {
+	attributes := map[string]bool{}
+	modelType := reflect.TypeOf(model)
+	for modelType != nil && modelType.Kind() == reflect.Ptr {
+		modelType = modelType.Elem()
+	}
+	if modelType == nil || modelType.Kind() != reflect.Struct {
+		return attributes
+	}
+
+	for i := 0; i < modelType.NumField(); i++ {
+		if field := modelType.Field(i); field.IsExported() {
+			attributes[jsonFieldName(field)] = true
+		}
+	}
+	return attributes
+}
+
+// NGSI-LD codec, decoding normalized or keyValues entities and encoding normalized
+// entities without metadata. Use DecodeNGSILD and EncodeNGSILD to keep the metadata.
+
+type ngsildCodec struct{}
+
+func (ngsildCodec) Marshal(v interface{}) ([]byte, error) {
+	return EncodeNGSILD(v, nil)
+}
+
+func (ngsildCodec) Unmarshal(data []byte, v interface{}) error {
+	_, err := DecodeNGSILD(data, v)
+	return err
+}
+
+func (ngsildCodec) UnmarshalLenient(data []byte, v interface{}) error {
+	_, err := decodeNGSILD(data, v, (jsonCodec{}).UnmarshalLenient)
+	return err
+}
@@ -0,0 +1,102 @@
+package ktwin
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestNGSILDSuite(t *testing.T) {
+	suite.Run(t, new(NGSILDSuite))
+}
+
+type NGSILDSuite struct {
+	suite.Suite
+}
+
+type ngsildModel struct {
+	Name           string     `json:"name,omitempty"`
+	AvailableSpots int        `json:"availableSpotNumber,omitempty" validate:"min=0"`
+	Temperature    float64    `json:"temperature,omitempty"`
+	DateObserved   *time.Time `json:"dateObserved,omitempty"`
+}
+
+const normalizedEntity = `{
+	"id": "urn:ngsi-ld:OffStreetParking:nb001",
+	"type": "OffStreetParking",
+	"@context": ["https://uri.etsi.org/ngsi-ld/v1/ngsi-ld-core-context.jsonld"],
+	"name": {"type": "Property", "value": "Downtown"},
+	"availableSpotNumber": {"type": "Property", "value": 12, "observedAt": "2023-06-01T10:00:00Z"},
+	"temperature": {"type": "Property", "value": 21.5, "unitCode": "CEL"},
+	"dateObserved": {"type": "Property", "value": {"@type": "DateTime", "@value": "2023-06-01T10:00:00Z"}},
+	"refParkingSite": {"type": "Relationship", "object": "urn:ngsi-ld:ParkingSite:ps001"}
+}`
+
+func (s *NGSILDSuite) Test_DecodeNormalized() {
+	model := ngsildModel{}
+	entity, err := DecodeNGSILD([]byte(normalizedEntity), &model)
+	s.Require().NoError(err)
+
+	dateObserved := time.Date(2023, 6, 1, 10, 0, 0, 0, time.UTC)
+	s.Assert().Equal(ngsildModel{Name: "Downtown", AvailableSpots: 12, Temperature: 21.5, DateObserved: &dateObserved}, model)
+	s.Assert().Equal("urn:ngsi-ld:OffStreetParking:nb001", entity.ID)
+	s.Assert().Equal("2023-06-01T10:00:00Z", entity.Attributes["availableSpotNumber"].ObservedAt)
+	s.Assert().Equal("CEL", entity.Attributes["temperature"].UnitCode)
+	s.Assert().Equal(NGSILDRelationship, entity.Attributes["refParkingSite"].Type)
+}
+
+func (s *NGSILDSuite) Test_DecodeKeyValues() {
+	model := ngsildModel{}
+	_, err := DecodeNGSILD([]byte(`{"id": "urn:ngsi-ld:OffStreetParking:nb001", "type": "OffStreetParking", "name": "Downtown", "availableSpotNumber": 12}`), &model)
+	s.Require().NoError(err)
+	s.Assert().Equal(ngsildModel{Name: "Downtown", AvailableSpots: 12}, model)
+
+	_, err = DecodeNGSILD([]byte(`{"id": "urn:ngsi-ld:OffStreetParking:nb001", "unknown": {"type": "Property", "value": 1}}`), &model)
+	s.Assert().Error(err)
+}
+
+func (s *NGSILDSuite) Test_EncodeKeepsMetadata() {
+	model := ngsildModel{}
+	entity, err := DecodeNGSILD([]byte(normalizedEntity), &model)
+	s.Require().NoError(err)
+
+	model.AvailableSpots = 10
+	encoded, err := EncodeNGSILD(model, entity)
+	s.Require().NoError(err)
+
+	reencoded := &NGSILDEntity{}
+	s.Require().NoError(json.Unmarshal(encoded, reencoded))
+	s.Assert().Equal(entity.ID, reencoded.ID)
+	s.Assert().Equal(entity.Type, reencoded.Type)
+	s.Assert().Equal(entity.Context, reencoded.Context)
+	s.Assert().Equal(NGSILDAttribute{Type: NGSILDProperty, Value: 10.0, ObservedAt: "2023-06-01T10:00:00Z"}, reencoded.Attributes["availableSpotNumber"])
+	s.Assert().Equal(NGSILDAttribute{Type: NGSILDProperty, Value: 21.5, UnitCode: "CEL"}, reencoded.Attributes["temperature"])
+	s.Assert().Equal(NGSILDAttribute{Type: NGSILDRelationship, Object: "urn:ngsi-ld:ParkingSite:ps001"}, reencoded.Attributes["refParkingSite"])
+}
+
+func (s *NGSILDSuite) Test_TwinEventWithNGSILD() {
+	cloudEvent := cloudevents.NewEvent()
+	cloudEvent.SetID("1")
+	cloudEvent.SetType("ktwin.real.ngsi-ld-city-offstreetparking")
+	cloudEvent.SetSource("ngsi-ld-city-offstreetparking-nb001")
+	cloudEvent.SetData(ApplicationLDJSON, []byte(normalizedEntity))
+
+	twinEvent, err := NewTwinEventFromCloudEvent(&cloudEvent)
+	s.Require().NoError(err)
+
+	model := ngsildModel{}
+	s.Require().NoError(twinEvent.ToModel(&model))
+	s.Assert().Equal(12, model.AvailableSpots)
+
+	model.AvailableSpots = 11
+	s.Require().NoError(twinEvent.SetData(model))
+
+	entity := &NGSILDEntity{}
+	s.Require().NoError(json.Unmarshal(twinEvent.CloudEvent.Data(), entity))
+	s.Assert().Equal(11.0, entity.Attributes["availableSpotNumber"].Value)
+	s.Assert().Equal("2023-06-01T10:00:00Z", entity.Attributes["availableSpotNumber"].ObservedAt)
+	s.Assert().Equal("urn:ngsi-ld:ParkingSite:ps001", entity.Attributes["refParkingSite"].Object)
+}
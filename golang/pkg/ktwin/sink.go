@@ -0,0 +1,294 @@
+package ktwin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// Sink is where the events of a service go out, and where the twin state is read from
+type Sink interface {
+	// Publishes the event to the url, usually the broker
+	Publish(event *cloudevents.Event, url string) error
+	// Publishes the events in a single request, returning the result of each event
+	PublishBatch(events []*cloudevents.Event, url string) ([]EventResult, error)
+	// Sends the event to the url and returns the event of the response
+	Request(event *cloudevents.Event, url string) (*cloudevents.Event, error)
+	// Gets the latest stored event of the twin instance, nil when there is none
+	GetLatest(twinInterface, twinInstance string) (*cloudevents.Event, error)
+	// Stores the event of the twin instance
+	Store(event *cloudevents.Event) error
+}
+
+const (
+	HTTPSinkType  = "http"
+	LocalSinkType = "local"
+)
+
+var (
+	sink      Sink
+	sinkMutex sync.Mutex
+)
+
+func SetSink(s Sink) {
+	sinkMutex.Lock()
+	defer sinkMutex.Unlock()
+	sink = s
+}
+
+func ResetSink() {
+	SetSink(nil)
+}
+
+// Gets the sink of the sink.type config (KTWIN_SINK), http or local. The local sink is used
+// by default in local mode, so a service runs alone, and the http sink otherwise.
+func GetSink() Sink {
+	sinkMutex.Lock()
+	defer sinkMutex.Unlock()
+
+	if sink == nil {
+		sink = newSinkFromConfig(config.Get())
+	}
+	return sink
+}
+
+func newSinkFromConfig(c *config.Config) Sink {
+	sinkType := c.Sink.Type
+	if sinkType == "" && c.IsLocal() {
+		sinkType = LocalSinkType
+	}
+
+	if sinkType == LocalSinkType {
+		localSink, err := NewLocalSink(os.Stdout, c.Sink.File, c.Sink.FixturesDir)
+		if err != nil {
+			logger.NewLogger().Fatal("Error creating local sink", err)
+		}
+		return localSink
+	}
+
+	// There is no broker to route the store events in local mode, they are sent to the event store
+	return &HTTPSink{DirectStore: c.IsLocal()}
+}
+
+// HTTPSink posts the events to the broker and reads the twin state from the event store.
+// The urls are read from the config on each call.
+type HTTPSink struct {
+	// Sends the store events to the event store instead of the broker
+	DirectStore bool
+}
+
+func (s *HTTPSink) Publish(event *cloudevents.Event, url string) error {
+	if url == "" {
+		return fmt.Errorf("error to publish cloud event %s: no url, is KTWIN_BROKER set?", event.Type())
+	}
+	return SendCloudEvent(event, url)
+}
+
+func (s *HTTPSink) PublishBatch(events []*cloudevents.Event, url string) ([]EventResult, error) {
+	client := NewClient()
+	response, err := client.PostBatch(url, events)
+
+	if err != nil {
+		return nil, errors.New("error to publish cloud event batch: " + err.Error())
+	}
+
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, errors.New("error to read response body: " + err.Error())
+	}
+
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("error to publish cloud event batch. status code: %d. response body: %s", response.StatusCode, string(body))
+	}
+
+	var results []EventResult
+	if err := json.Unmarshal(body, &results); err != nil {
+		return nil, errors.New("error to parse batch results: " + err.Error())
+	}
+
+	return results, nil
+}
+
+func (s *HTTPSink) Request(event *cloudevents.Event, url string) (*cloudevents.Event, error) {
+	ctx := cloudevents.ContextWithTarget(context.Background(), url)
+
+	c, err := cloudevents.NewClientHTTP()
+	if err != nil {
+		logger.NewLogger().Error("failed to create client", err)
+		return nil, err
+	}
+
+	var response *cloudevents.Event
+	if response, err = c.Request(ctx, *event); err != nil {
+		return nil, errors.New("Error to get Cloud Event: " + err.Error())
+	}
+	return response, nil
+}
+
+func (s *HTTPSink) GetLatest(twinInterface, twinInstance string) (*cloudevents.Event, error) {
+	url := fmt.Sprintf("%s/api/v1/twin-events/%s/%s/latest", GetEventStoreURL(), twinInterface, twinInstance)
+
+	response, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+
+	event, err := cloudevents.NewEventFromHTTPResponse(response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CloudEvent from response: %w", err)
+	}
+	return event, nil
+}
+
+func (s *HTTPSink) Store(event *cloudevents.Event) error {
+	if s.DirectStore {
+		return SendCloudEvent(event, GetEventStoreURL())
+	}
+	return PostCloudEvent(event, GetBrokerURL())
+}
+
+// LocalSink lets a service run alone: the outbound events are pretty-printed and appended
+// to a NDJSON file, and the twin state is read from the stored events or from JSON fixtures
+// at <fixtures>/<twin-interface>/<twin-instance>.json holding the twin data.
+type LocalSink struct {
+	output      io.Writer
+	file        *os.File
+	fixturesDir string
+
+	mutex  sync.Mutex
+	stored map[string]*cloudevents.Event
+}
+
+// Creates a local sink printing to output. The file and the fixtures directory are optional.
+func NewLocalSink(output io.Writer, file, fixturesDir string) (*LocalSink, error) {
+	localSink := &LocalSink{
+		output:      output,
+		fixturesDir: fixturesDir,
+		stored:      map[string]*cloudevents.Event{},
+	}
+
+	if file != "" {
+		var err error
+		localSink.file, err = os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return localSink, nil
+}
+
+func (s *LocalSink) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Close()
+}
+
+// Prints the event as it would be sent, and appends it to the file
+func (s *LocalSink) write(action string, event *cloudevents.Event, url string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	target := ""
+	if url != "" {
+		target = " -> " + url
+	}
+	fmt.Fprintf(s.output, "[%s] %s %s%s\n", action, event.Type(), event.Source(), target)
+
+	var data interface{}
+	if json.Unmarshal(event.Data(), &data) == nil {
+		indented, _ := json.MarshalIndent(data, "", "  ")
+		fmt.Fprintf(s.output, "%s\n", indented)
+	} else if len(event.Data()) > 0 {
+		fmt.Fprintf(s.output, "%s (%d bytes)\n", event.DataContentType(), len(event.Data()))
+	}
+
+	if s.file == nil {
+		return nil
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+func (s *LocalSink) Publish(event *cloudevents.Event, url string) error {
+	return s.write("publish", event, url)
+}
+
+func (s *LocalSink) PublishBatch(events []*cloudevents.Event, url string) ([]EventResult, error) {
+	results := make([]EventResult, len(events))
+	for i, event := range events {
+		results[i] = EventResult{ID: event.ID(), Status: http.StatusAccepted}
+		if err := s.write("publish", event, url); err != nil {
+			results[i] = EventResult{ID: event.ID(), Status: http.StatusInternalServerError, Error: err.Error()}
+		}
+	}
+	return results, nil
+}
+
+// There is no one to reply locally, the event itself is returned
+func (s *LocalSink) Request(event *cloudevents.Event, url string) (*cloudevents.Event, error) {
+	return event, s.write("request", event, url)
+}
+
+func (s *LocalSink) GetLatest(twinInterface, twinInstance string) (*cloudevents.Event, error) {
+	s.mutex.Lock()
+	event, ok := s.stored[twinInterface+"/"+twinInstance]
+	s.mutex.Unlock()
+	if ok {
+		clone := event.Clone()
+		return &clone, nil
+	}
+
+	if s.fixturesDir == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.fixturesDir, twinInterface, twinInstance+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("invalid fixture of %s/%s: not JSON", twinInterface, twinInstance)
+	}
+
+	return BuildCloudEvent(fmt.Sprintf(EventStoreGenerated, twinInterface), twinInstance, data), nil
+}
+
+func (s *LocalSink) Store(event *cloudevents.Event) error {
+	twinEventType, err := ParseEventType(event.Type())
+	if err != nil {
+		return err
+	}
+
+	clone := event.Clone()
+	s.mutex.Lock()
+	s.stored[twinEventType.TwinInterface+"/"+event.Source()] = &clone
+	s.mutex.Unlock()
+
+	return s.write("store", event, "")
+}
@@ -0,0 +1,105 @@
+package ktwin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestSinkSuite(t *testing.T) {
+	suite.Run(t, new(SinkSuite))
+}
+
+type SinkSuite struct {
+	suite.Suite
+
+	output      bytes.Buffer
+	file        string
+	fixturesDir string
+	sink        *LocalSink
+}
+
+func (s *SinkSuite) SetupTest() {
+	dir := s.T().TempDir()
+	s.file = filepath.Join(dir, "outbound.ndjson")
+	s.fixturesDir = filepath.Join(dir, "fixtures")
+	s.Require().NoError(os.MkdirAll(filepath.Join(s.fixturesDir, "city-pole"), 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(s.fixturesDir, "city-pole", "city-pole-nb001-p00007.json"), []byte(`{"airQualityIndex": 3}`), 0644))
+
+	s.output.Reset()
+	localSink, err := NewLocalSink(&s.output, s.file, s.fixturesDir)
+	s.Require().NoError(err)
+	s.sink = localSink
+	SetSink(localSink)
+}
+
+func (s *SinkSuite) TearDownTest() {
+	s.sink.Close()
+	ResetSink()
+}
+
+func (s *SinkSuite) readFile() []*cloudevents.Event {
+	file, err := os.Open(s.file)
+	s.Require().NoError(err)
+	defer file.Close()
+
+	var events []*cloudevents.Event
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		event := cloudevents.NewEvent()
+		s.Require().NoError(json.Unmarshal(scanner.Bytes(), &event))
+		events = append(events, &event)
+	}
+	return events
+}
+
+func (s *SinkSuite) Test_LocalSinkPublish() {
+	command := BuildCloudEvent("ktwin.command.city-pole.updateairqualityindex", "city-pole-nb001-p00007", map[string]int{"airQualityIndex": 2})
+	s.Require().NoError(PostCloudEvent(command, GetBrokerURL()))
+
+	results, err := PostCloudEventBatch([]*cloudevents.Event{command}, GetBrokerURL())
+	s.Require().NoError(err)
+	s.Assert().Equal([]EventResult{{ID: command.ID(), Status: 202}}, results)
+
+	s.Assert().Contains(s.output.String(), "[publish] ktwin.command.city-pole.updateairqualityindex city-pole-nb001-p00007")
+	s.Assert().Contains(s.output.String(), "\"airQualityIndex\": 2")
+
+	events := s.readFile()
+	s.Require().Len(events, 2)
+	s.Assert().Equal(command.ID(), events[0].ID())
+	s.Assert().JSONEq(`{"airQualityIndex": 2}`, string(events[0].Data()))
+}
+
+func (s *SinkSuite) Test_LocalSinkReadsFixturesAndStoredEvents() {
+	event, err := GetSink().GetLatest("city-pole", "city-pole-nb001-p00007")
+	s.Require().NoError(err)
+	s.Require().NotNil(event)
+	s.Assert().Equal("ktwin.store.city-pole", event.Type())
+	s.Assert().JSONEq(`{"airQualityIndex": 3}`, string(event.Data()))
+
+	event, err = GetSink().GetLatest("city-pole", "city-pole-nb002-p00001")
+	s.Require().NoError(err)
+	s.Assert().Nil(event)
+
+	stored := BuildCloudEvent("ktwin.store.city-pole", "city-pole-nb001-p00007", map[string]int{"airQualityIndex": 5})
+	s.Require().NoError(GetSink().Store(stored))
+
+	event, err = GetSink().GetLatest("city-pole", "city-pole-nb001-p00007")
+	s.Require().NoError(err)
+	s.Assert().JSONEq(`{"airQualityIndex": 5}`, string(event.Data()))
+	s.Assert().Contains(s.output.String(), "[store] ktwin.store.city-pole city-pole-nb001-p00007")
+	s.Assert().Len(s.readFile(), 1)
+}
+
+func (s *SinkSuite) Test_LocalSinkRejectsInvalidFixture() {
+	s.Require().NoError(os.WriteFile(filepath.Join(s.fixturesDir, "city-pole", "city-pole-nb001-p00008.json"), []byte(`{`), 0644))
+
+	_, err := GetSink().GetLatest("city-pole", "city-pole-nb001-p00008")
+	s.Assert().Error(err)
+}
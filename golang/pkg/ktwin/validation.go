@@ -0,0 +1,204 @@
+package ktwin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Model constraints are declared with the `validate` struct tag, e.g.:
+//
+//	BatteryLevel float64 `json:"batteryLevel,omitempty" validate:"min=0,max=100"`
+//	PowerState   string  `json:"powerState,omitempty" validate:"oneof=on off"`
+//
+// Supported rules: required, min=<number>, max=<number>, oneof=<space separated values>.
+// Zero values are only checked by the required rule, so optional attributes can be omitted.
+const validateTag = "validate"
+
+type FieldViolation struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+type ValidationError struct {
+	TwinInterface string           `json:"twinInterface"`
+	TwinInstance  string           `json:"twinInstance"`
+	Violations    []FieldViolation `json:"violations"`
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		messages = append(messages, violation.Message)
+	}
+	return fmt.Sprintf("invalid event payload for Twin Instance %s: %s", e.TwinInstance, strings.Join(messages, "; "))
+}
+
+// Validate checks the model against the constraints declared in its struct tags
+func Validate(model interface{}) []FieldViolation {
+	value := reflect.ValueOf(model)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	return validateStruct(value)
+}
+
+func validateStruct(value reflect.Value) []FieldViolation {
+	var violations []FieldViolation
+	valueType := value.Type()
+
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		fieldValue := value.Field(i)
+
+		// Fields of embedded structs are promoted, even when the embedded type is unexported
+		if field.Anonymous && fieldValue.Kind() == reflect.Struct {
+			violations = append(violations, validateStruct(fieldValue)...)
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		fieldName := jsonFieldName(field)
+
+		tag := field.Tag.Get(validateTag)
+		if tag == "" {
+			continue
+		}
+
+		for _, rule := range strings.Split(tag, ",") {
+			if violation := validateRule(fieldName, strings.TrimSpace(rule), fieldValue); violation != nil {
+				violations = append(violations, *violation)
+			}
+		}
+	}
+
+	return violations
+}
+
+func validateRule(fieldName, rule string, value reflect.Value) *FieldViolation {
+	ruleName, ruleParam, _ := strings.Cut(rule, "=")
+
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			if ruleName == "required" {
+				return &FieldViolation{Field: fieldName, Rule: ruleName, Message: fmt.Sprintf("%s is required", fieldName)}
+			}
+			return nil
+		}
+		value = value.Elem()
+	}
+
+	if ruleName == "required" {
+		if value.IsZero() {
+			return &FieldViolation{Field: fieldName, Rule: ruleName, Message: fmt.Sprintf("%s is required", fieldName)}
+		}
+		return nil
+	}
+
+	if value.IsZero() {
+		return nil
+	}
+
+	switch ruleName {
+	case "min", "max":
+		limit, err := strconv.ParseFloat(ruleParam, 64)
+		if err != nil {
+			return nil
+		}
+		number, ok := numericValue(value)
+		if !ok {
+			return nil
+		}
+		if ruleName == "min" && number < limit {
+			return &FieldViolation{Field: fieldName, Rule: ruleName, Message: fmt.Sprintf("%s must be greater than or equal to %s, got %v", fieldName, ruleParam, number)}
+		}
+		if ruleName == "max" && number > limit {
+			return &FieldViolation{Field: fieldName, Rule: ruleName, Message: fmt.Sprintf("%s must be less than or equal to %s, got %v", fieldName, ruleParam, number)}
+		}
+	case "oneof":
+		if value.Kind() != reflect.String {
+			return nil
+		}
+		allowed := strings.Fields(ruleParam)
+		for _, option := range allowed {
+			if value.String() == option {
+				return nil
+			}
+		}
+		return &FieldViolation{Field: fieldName, Rule: ruleName, Message: fmt.Sprintf("%s must be one of [%s], got %q", fieldName, strings.Join(allowed, ", "), value.String())}
+	}
+
+	return nil
+}
+
+func numericValue(value reflect.Value) (float64, bool) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	default:
+		return 0, false
+	}
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+// Converts a JSON decoding error into a field violation
+func decodeViolation(err error) FieldViolation {
+	var typeError *json.UnmarshalTypeError
+	if errors.As(err, &typeError) {
+		return FieldViolation{
+			Field:   typeError.Field,
+			Rule:    "type",
+			Message: fmt.Sprintf("%s must be of type %s, got %s", typeError.Field, typeError.Type.String(), typeError.Value),
+		}
+	}
+
+	var cborTypeError *cbor.UnmarshalTypeError
+	if errors.As(err, &cborTypeError) {
+		return FieldViolation{
+			Field:   cborTypeError.StructFieldName,
+			Rule:    "type",
+			Message: fmt.Sprintf("%s must be of type %s, got %s", cborTypeError.StructFieldName, cborTypeError.GoType, cborTypeError.CBORType),
+		}
+	}
+
+	var syntaxError *json.SyntaxError
+	if errors.As(err, &syntaxError) {
+		return FieldViolation{Rule: "syntax", Message: fmt.Sprintf("malformed payload: %s", syntaxError.Error())}
+	}
+
+	message := err.Error()
+	if field, found := strings.CutPrefix(message, "json: unknown field "); found {
+		field = strings.Trim(field, `"`)
+		return FieldViolation{Field: field, Rule: "unknown", Message: fmt.Sprintf("%s is not a known attribute", field)}
+	}
+
+	return FieldViolation{Rule: "decode", Message: message}
+}
@@ -0,0 +1,149 @@
+package ktwin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestValidationSuite(t *testing.T) {
+	suite.Run(t, new(ValidationSuite))
+}
+
+type ValidationSuite struct {
+	suite.Suite
+}
+
+type validationState string
+
+type validationBase struct {
+	Name string `json:"name,omitempty" validate:"required"`
+}
+
+type validationModel struct {
+	validationBase
+	Level     float64         `json:"level,omitempty" validate:"min=0,max=100"`
+	Count     int             `json:"count,omitempty" validate:"min=1"`
+	Mode      string          `json:"mode,omitempty" validate:"oneof=on off"`
+	State     validationState `json:"state,omitempty" validate:"oneof=open closed"`
+	Threshold *float64        `json:"threshold,omitempty" validate:"max=10"`
+	Owner     *string         `json:"owner,omitempty" validate:"required"`
+}
+
+func (s *ValidationSuite) Test_Validate() {
+	float := func(value float64) *float64 { return &value }
+	owner := "operator"
+	emptyOwner := ""
+	valid := validationModel{validationBase: validationBase{Name: "model"}, Owner: &owner}
+
+	tests := []struct {
+		name               string
+		model              func() interface{}
+		expectedViolations []FieldViolation
+	}{
+		{
+			name:  `Zero values only check the required rule`,
+			model: func() interface{} { return valid },
+		},
+		{
+			name: `Values within the rules`,
+			model: func() interface{} {
+				model := valid
+				model.Level, model.Count, model.Mode, model.State, model.Threshold = 100, 1, "off", "closed", float(10)
+				return model
+			},
+		},
+		{
+			name: `Min rule`,
+			model: func() interface{} {
+				model := valid
+				model.Level, model.Count = -1, -2
+				return model
+			},
+			expectedViolations: []FieldViolation{
+				{Field: "level", Rule: "min", Message: "level must be greater than or equal to 0, got -1"},
+				{Field: "count", Rule: "min", Message: "count must be greater than or equal to 1, got -2"},
+			},
+		},
+		{
+			name: `Max rule`,
+			model: func() interface{} {
+				model := valid
+				model.Level = 100.5
+				return model
+			},
+			expectedViolations: []FieldViolation{
+				{Field: "level", Rule: "max", Message: "level must be less than or equal to 100, got 100.5"},
+			},
+		},
+		{
+			name: `Oneof rule on string and named string types`,
+			model: func() interface{} {
+				model := valid
+				model.Mode, model.State = "dim", "opened"
+				return model
+			},
+			expectedViolations: []FieldViolation{
+				{Field: "mode", Rule: "oneof", Message: `mode must be one of [on, off], got "dim"`},
+				{Field: "state", Rule: "oneof", Message: `state must be one of [open, closed], got "opened"`},
+			},
+		},
+		{
+			name: `Required rule on embedded struct and pointer fields`,
+			model: func() interface{} {
+				return validationModel{}
+			},
+			expectedViolations: []FieldViolation{
+				{Field: "name", Rule: "required", Message: "name is required"},
+				{Field: "owner", Rule: "required", Message: "owner is required"},
+			},
+		},
+		{
+			name: `Required rule on pointer to zero value`,
+			model: func() interface{} {
+				model := valid
+				model.Owner = &emptyOwner
+				return model
+			},
+			expectedViolations: []FieldViolation{
+				{Field: "owner", Rule: "required", Message: "owner is required"},
+			},
+		},
+		{
+			name: `Pointer fields are checked by their value`,
+			model: func() interface{} {
+				model := valid
+				model.Threshold = float(11)
+				return model
+			},
+			expectedViolations: []FieldViolation{
+				{Field: "threshold", Rule: "max", Message: "threshold must be less than or equal to 10, got 11"},
+			},
+		},
+		{
+			name: `Pointer to model`,
+			model: func() interface{} {
+				model := valid
+				model.Level = -1
+				return &model
+			},
+			expectedViolations: []FieldViolation{
+				{Field: "level", Rule: "min", Message: "level must be greater than or equal to 0, got -1"},
+			},
+		},
+		{
+			name:  `Nil pointer to model`,
+			model: func() interface{} { return (*validationModel)(nil) },
+		},
+		{
+			name:  `Not a struct`,
+			model: func() interface{} { return map[string]interface{}{"level": -1} },
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.Assert().Equal(tt.expectedViolations, Validate(tt.model()))
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package recording
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/clock"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
+	log "github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+var logger = log.NewLogger()
+
+const (
+	defaultMaxSize  = 100 * 1024 * 1024
+	defaultMaxFiles = 5
+)
+
+// Record is a received CloudEvent HTTP request, one JSON line of a recording
+type Record struct {
+	ReceivedAt time.Time   `json:"receivedAt"`
+	Method     string      `json:"method"`
+	Path       string      `json:"path"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+}
+
+func NewRecord(r *http.Request, body []byte) Record {
+	return Record{
+		ReceivedAt: *clock.Now(),
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Header:     r.Header.Clone(),
+		Body:       body,
+	}
+}
+
+// Rebuilds the recorded request against the target url
+func (r *Record) ToRequest(url string) (*http.Request, error) {
+	request, err := http.NewRequest(r.Method, strings.TrimSuffix(url, "/")+r.Path, bytes.NewReader(r.Body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header = r.Header.Clone()
+	return request, nil
+}
+
+// Parses the CloudEvents of the record, several for batch requests
+func (r *Record) CloudEvents() ([]*cloudevents.Event, error) {
+	request, err := r.ToRequest("http://recording")
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), cloudevents.ApplicationCloudEventsBatchJSON) {
+		var events []*cloudevents.Event
+		if err := json.Unmarshal(r.Body, &events); err != nil {
+			return nil, err
+		}
+		return events, nil
+	}
+
+	event, err := cloudevents.NewEventFromHTTPRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	return []*cloudevents.Event{event}, nil
+}
+
+// Recorder appends records to a NDJSON file, rotating it when it reaches the max size.
+// Rotated files are renamed <file>.1 (the most recent) to <file>.<maxFiles-1>.
+type Recorder struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	mutex sync.Mutex
+	file  *os.File
+	size  int64
+}
+
+func NewRecorder(path string, maxSize int64, maxFiles int) (*Recorder, error) {
+	if maxSize <= 0 {
+		maxSize = defaultMaxSize
+	}
+	if maxFiles <= 0 {
+		maxFiles = defaultMaxFiles
+	}
+
+	recorder := &Recorder{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := recorder.open(); err != nil {
+		return nil, err
+	}
+	return recorder, nil
+}
+
+// Creates the recorder of the record config, nil when no record file is set
+func NewRecorderFromConfig(recordConfig config.RecordConfig) (*Recorder, error) {
+	if recordConfig.File == "" {
+		return nil, nil
+	}
+	return NewRecorder(recordConfig.File, recordConfig.MaxSize, recordConfig.MaxFiles)
+}
+
+func (r *Recorder) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+func (r *Recorder) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+
+	os.Remove(fmt.Sprintf("%s.%d", r.path, r.maxFiles-1))
+	for i := r.maxFiles - 2; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+
+	if r.maxFiles > 1 {
+		if err := os.Rename(r.path, r.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(r.path); err != nil {
+		return err
+	}
+
+	return r.open()
+}
+
+func (r *Recorder) Write(record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.size > 0 && r.size+int64(len(line)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := r.file.Write(line)
+	r.size += int64(n)
+	return err
+}
+
+func (r *Recorder) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.file.Close()
+}
+
+// Records every request before handling it
+func (r *Recorder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, request *http.Request) {
+		body, err := io.ReadAll(request.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		request.Body = io.NopCloser(bytes.NewReader(body))
+
+		if err := r.Write(NewRecord(request, body)); err != nil {
+			logger.Error("Error recording request", err)
+		}
+
+		next.ServeHTTP(w, request)
+	})
+}
+
+// Reads the records of a recording file
+func ReadFile(path string) ([]Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		records = append(records, record)
+	}
+
+	return records, scanner.Err()
+}
+
+// Reads a recording and its rotated files, oldest first
+func ReadRecording(path string) ([]Record, error) {
+	rotated, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return nil, err
+	}
+
+	var indexes []int
+	for _, rotatedPath := range rotated {
+		if index, err := strconv.Atoi(strings.TrimPrefix(rotatedPath, path+".")); err == nil {
+			indexes = append(indexes, index)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(indexes)))
+
+	var paths []string
+	for _, index := range indexes {
+		paths = append(paths, fmt.Sprintf("%s.%d", path, index))
+	}
+	paths = append(paths, path)
+
+	var records []Record
+	for _, recordingPath := range paths {
+		fileRecords, err := ReadFile(recordingPath)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, fileRecords...)
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].ReceivedAt.Before(records[j].ReceivedAt)
+	})
+	return records, nil
+}
+
+// Writes the records to a recording file, replacing it
+func WriteFile(path string, records []Record) error {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, buffer.Bytes(), 0644)
+}
@@ -1,37 +1,64 @@
 package server
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/config"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kevent"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kpolicy"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/ktwin/kscheduler"
 	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/logger"
+	"github.com/Open-Digital-Twin/ktwin-smart-cities-services/pkg/recording"
 )
 
 type HandlerEventFunc func(*ktwin.TwinEvent) error
 
 func StartServer(handleFuncTwin HandlerEventFunc) {
 	handleFunc := func(w http.ResponseWriter, r *http.Request) {
-		logger := logger.NewLogger()
-		twinEvent := kevent.HandleRequest(r)
-		if twinEvent == nil {
-			logger.Error("Error handling cloud event request", nil)
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte("Error handling cloud event request"))
-			return
-		}
+		kevent.RequestHandlerFunc(w, r, handleFuncTwin)
+	}
+
+	logger := logger.NewLogger()
+
+	// Incoming events are recorded when a record file is set, to be replayed with ktwin-replay
+	recorder, err := recording.NewRecorderFromConfig(config.Get().Record)
+	if err != nil {
+		logger.Fatal("Error creating event recorder", err)
+	}
 
-		if err := handleFuncTwin(twinEvent); err != nil {
-			logger.Error("Error processing cloud event request", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte("Error processing cloud event request"))
-			return
+	// Fatal exits without running deferred calls, so the recording is closed before exiting
+	fatal := func(message string, err error) {
+		if recorder != nil {
+			recorder.Close()
 		}
+		logger.Fatal(message, err)
 	}
 
-	http.HandleFunc("/", handleFunc)
+	if recorder != nil {
+		http.Handle("/", recorder.Middleware(http.HandlerFunc(handleFunc)))
+	} else {
+		http.HandleFunc("/", handleFunc)
+	}
+
+	// The policies of the twin instances are queried and reloaded at runtime on the admin port, as
+	// the event port is public. The admin API is disabled when the port is not set.
+	if adminPort := config.Get().Policy.AdminPort; adminPort != "" {
+		policyHandler := kpolicy.Get().Handler()
+		adminMux := http.NewServeMux()
+		adminMux.Handle(kpolicy.AdminPath, policyHandler)
+		adminMux.Handle(kpolicy.AdminPath+"/", policyHandler)
+		go func() {
+			fatal("Admin server error", http.ListenAndServe(":"+adminPort, adminMux))
+		}()
+	}
+
+	// The timers registered by the handlers fire their events into the same handler, serialized with
+	// the requests of the same twin instance by kevent.ProcessEvent
+	go kscheduler.Get().Run(context.Background(), handleFuncTwin)
 
-	logger := logger.NewLogger()
 	logger.Info("Starting up server...")
-	logger.Fatal("Server error", http.ListenAndServe(":8080", nil))
+	// The port is set by the PORT env variable on Knative, services also run side by side locally
+	fatal("Server error", http.ListenAndServe(":"+config.Get().Port, nil))
 }